@@ -12,6 +12,26 @@ type Config struct {
 	JWTSecret      string
 	ServerPort     string
 	Environment    string
+	CostingMethod  string // "weighted_average" or "fifo"
+
+	// Staff alert channels (optional; disabled when empty)
+	SMTPHost        string
+	SMTPPort        string
+	SMTPUsername    string
+	SMTPPassword    string
+	AlertEmailFrom  string
+	SMSGatewayURL   string
+	SMSGatewayToken string
+
+	// Barcode numbering: a GS1 company prefix when the business has one,
+	// otherwise an in-store prefix in the restricted 20-29 range
+	GS1CompanyPrefix string
+	InStoreEANPrefix string
+
+	// Product view retention: raw views lose their IP, user agent and session after
+	// the first period and are deleted after the second; daily rollups are kept
+	ViewAnonymizeDays string
+	ViewRetentionDays string
 }
 
 var AppConfig *Config
@@ -28,6 +48,21 @@ func Load() error {
 		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		ServerPort:    getEnv("PORT", "8080"),
 		Environment:   getEnv("ENVIRONMENT", "development"),
+		CostingMethod: getEnv("COSTING_METHOD", "weighted_average"),
+
+		SMTPHost:        getEnv("SMTP_HOST", ""),
+		SMTPPort:        getEnv("SMTP_PORT", "587"),
+		SMTPUsername:    getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:    getEnv("SMTP_PASSWORD", ""),
+		AlertEmailFrom:  getEnv("ALERT_EMAIL_FROM", ""),
+		SMSGatewayURL:   getEnv("SMS_GATEWAY_URL", ""),
+		SMSGatewayToken: getEnv("SMS_GATEWAY_TOKEN", ""),
+
+		GS1CompanyPrefix: getEnv("GS1_COMPANY_PREFIX", ""),
+		InStoreEANPrefix: getEnv("EAN_INSTORE_PREFIX", "20"),
+
+		ViewAnonymizeDays: getEnv("VIEW_ANONYMIZE_DAYS", "30"),
+		ViewRetentionDays: getEnv("VIEW_RETENTION_DAYS", "365"),
 	}
 
 	// Debug: Print the database URL being used
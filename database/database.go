@@ -38,6 +38,11 @@ func (db *DB) InitializeTables() error {
 		return fmt.Errorf("failed to enable pgcrypto extension: %w", err)
 	}
 
+	// Enable pg_trgm extension (typo-tolerant search)
+	if _, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm;`); err != nil {
+		return fmt.Errorf("failed to enable pg_trgm extension: %w", err)
+	}
+
 	// Define the order of table creation (respecting foreign key dependencies)
 	models := []interface{}{
 		models.Category{},
@@ -61,6 +66,14 @@ func (db *DB) InitializeTables() error {
 		models.CartItem{},
 		models.WishlistItem{},
 		models.Review{},
+		models.ReviewPhoto{},
+		models.ProductQuestion{},
+		models.ProductAnswer{},
+		models.ProductCoOccurrence{},
+		models.ProductRecommendation{},
+		models.RecommendationWatermark{},
+		models.ProductTrendingScore{},
+		models.ProductViewDaily{},
 		// CRM models
 		models.Customer{},
 		models.CustomerInteraction{},
@@ -89,6 +102,31 @@ func (db *DB) InitializeTables() error {
 		models.FeedBlockItem{},
 		models.Campaign{},
 		models.ScheduledNotification{},
+		// Inventory control models
+		models.InventoryMovement{},
+		models.StockTakeSession{},
+		models.StockTakeLine{},
+		models.StockTakeScan{},
+		models.StockReceipt{},
+		models.StockReceiptLine{},
+		models.ItemCost{},
+		models.StockAlert{},
+		models.StockAlertSubscription{},
+		models.BackInStockSubscription{},
+		models.PriceDropNotification{},
+		models.BarcodeRegistry{},
+		models.BarcodeScan{},
+		models.CatalogImportJob{},
+		models.SizeAlias{},
+		models.SaleEvent{},
+		models.PriceHistory{},
+		models.Bundle{},
+		models.BundleComponent{},
+		models.OrderItemComponent{},
+		models.SearchDocument{},
+		models.SearchQuery{},
+		models.SearchClick{},
+		models.SearchRule{},
 	}
 
 	for _, model := range models {
@@ -318,6 +356,15 @@ func (db *DB) runMigrations() error {
 		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS color VARCHAR(50);`,
 		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();`,
 		
+		// Costing: identify the sold item across catalogs and snapshot its cost at sale time
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS item_type VARCHAR(20);`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS item_id UUID;`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,4);`,
+		`UPDATE order_items SET item_type = 'sku', item_id = sku_id WHERE item_type IS NULL AND sku_id IS NOT NULL;`,
+		
+		// Stock alerts: perfume variants get a reorder point like SKUs and Melhaf colors
+		`ALTER TABLE maison_adrar_perfume_colors ADD COLUMN IF NOT EXISTS reorder_point INTEGER NOT NULL DEFAULT 0;`,
+		
 		// Create payment_methods table if it doesn't exist
 		`CREATE TABLE IF NOT EXISTS payment_methods (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -331,6 +378,8 @@ func (db *DB) runMigrations() error {
 		);`,
         // Inventory improvements
         `ALTER TABLE inventory ADD COLUMN IF NOT EXISTS reorder_point INT NOT NULL DEFAULT 0;`,
+		`ALTER TABLE inventory ADD COLUMN IF NOT EXISTS location TEXT;`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_location ON inventory(location);`,
 		
 		// Create product views tracking table
 		`CREATE TABLE IF NOT EXISTS product_views (
@@ -350,6 +399,11 @@ func (db *DB) runMigrations() error {
 		`CREATE INDEX IF NOT EXISTS idx_product_views_anonymous_session ON product_views(anonymous_session_id);`,
 		`CREATE INDEX IF NOT EXISTS idx_product_views_timestamp ON product_views(view_timestamp);`,
 		`CREATE INDEX IF NOT EXISTS idx_product_views_composite ON product_views(product_id, view_timestamp);`,
+		// Views by when they were written, for the jobs that read them incrementally
+		`CREATE INDEX IF NOT EXISTS idx_product_views_created ON product_views(created_at);`,
+		// Views still holding an IP, user agent or session, for the retention job
+		`CREATE INDEX IF NOT EXISTS idx_product_views_identifiable ON product_views(view_timestamp)
+		 WHERE ip_address IS NOT NULL OR user_agent IS NOT NULL OR anonymous_session_id IS NOT NULL;`,
 		
 		// Create an admin user if none exists
 		`INSERT INTO users (id, phone, full_name, role, is_active, created_at, metadata) 
@@ -386,6 +440,421 @@ func (db *DB) runMigrations() error {
 		`CREATE INDEX IF NOT EXISTS idx_scheduled_notifications_scheduled_for ON scheduled_notifications(scheduled_for);`,
 		`CREATE INDEX IF NOT EXISTS idx_scheduled_notifications_sent ON scheduled_notifications(sent);`,
 		`CREATE INDEX IF NOT EXISTS idx_scheduled_notifications_cancelled ON scheduled_notifications(cancelled);`,
+		
+		// Barcode registry: register existing codes (first holder wins; duplicates and
+		// invalid codes are re-issued from the admin barcode endpoint), then keep the
+		// registry in sync so an EAN can only be used once across all catalogs.
+		// Codes are TEXT like the catalog columns, so over-long legacy codes register
+		// too and are left to the re-issue endpoint.
+		`ALTER TABLE barcode_registry ALTER COLUMN ean TYPE TEXT;`,
+		`INSERT INTO barcode_registry (ean, item_type, item_id)
+		 SELECT DISTINCT ON (ean) ean, item_type, item_id FROM (
+			SELECT ean, 'sku' AS item_type, id AS item_id, created_at FROM skus WHERE ean IS NOT NULL AND ean <> ''
+			UNION ALL
+			SELECT ean, 'melhaf_color', id, created_at FROM melhaf_colors WHERE ean IS NOT NULL AND ean <> ''
+			UNION ALL
+			SELECT ean, 'perfume', id, created_at FROM maison_adrar_perfumes WHERE ean IS NOT NULL AND ean <> ''
+		 ) codes
+		 ORDER BY ean, created_at
+		 ON CONFLICT (ean) DO NOTHING;`,
+		`CREATE OR REPLACE FUNCTION sync_barcode_registry() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' THEN
+				IF OLD.ean IS NOT DISTINCT FROM NEW.ean THEN
+					RETURN NEW;
+				END IF;
+			END IF;
+			IF TG_OP <> 'INSERT' THEN
+				DELETE FROM barcode_registry WHERE ean = OLD.ean AND item_id = OLD.id;
+			END IF;
+			IF TG_OP = 'DELETE' THEN
+				RETURN OLD;
+			END IF;
+			IF NEW.ean IS NOT NULL AND NEW.ean <> '' THEN
+				INSERT INTO barcode_registry (ean, item_type, item_id) VALUES (NEW.ean, TG_ARGV[0], NEW.id);
+			END IF;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_skus_barcode_registry ON skus;`,
+		`CREATE TRIGGER trg_skus_barcode_registry AFTER INSERT OR UPDATE OF ean OR DELETE ON skus
+		 FOR EACH ROW EXECUTE FUNCTION sync_barcode_registry('sku');`,
+		`DROP TRIGGER IF EXISTS trg_melhaf_colors_barcode_registry ON melhaf_colors;`,
+		`CREATE TRIGGER trg_melhaf_colors_barcode_registry AFTER INSERT OR UPDATE OF ean OR DELETE ON melhaf_colors
+		 FOR EACH ROW EXECUTE FUNCTION sync_barcode_registry('melhaf_color');`,
+		`DROP TRIGGER IF EXISTS trg_maison_adrar_perfumes_barcode_registry ON maison_adrar_perfumes;`,
+		`CREATE TRIGGER trg_maison_adrar_perfumes_barcode_registry AFTER INSERT OR UPDATE OF ean OR DELETE ON maison_adrar_perfumes
+		 FOR EACH ROW EXECUTE FUNCTION sync_barcode_registry('perfume');`,
+
+		// Size charts: brand/category defaults and assignment to product models
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS description TEXT;`,
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS brand_id UUID REFERENCES brands(id) ON DELETE SET NULL;`,
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS category_id UUID REFERENCES categories(id) ON DELETE SET NULL;`,
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS is_default BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS unit VARCHAR(5) NOT NULL DEFAULT 'cm';`,
+		`ALTER TABLE size_charts ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT now();`,
+		`CREATE INDEX IF NOT EXISTS idx_size_charts_defaults ON size_charts(brand_id, category_id) WHERE is_default;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS size_chart_id UUID REFERENCES size_charts(id) ON DELETE SET NULL;`,
+
+		// Canonical sizes: scale, sort rank and letter-size group next to size_normalized
+		`ALTER TABLE skus ADD COLUMN IF NOT EXISTS size_scale VARCHAR(20);`,
+		`ALTER TABLE skus ADD COLUMN IF NOT EXISTS size_rank INTEGER;`,
+		`ALTER TABLE skus ADD COLUMN IF NOT EXISTS size_group VARCHAR(10);`,
+		`CREATE INDEX IF NOT EXISTS idx_skus_size_normalized ON skus(size_normalized);`,
+		`CREATE INDEX IF NOT EXISTS idx_skus_size_group ON skus(size_group);`,
+
+		// Color families: taxonomy slug, how it was set (manual, hex, name, image) and the image color
+		`ALTER TABLE product_colors ADD COLUMN IF NOT EXISTS color_family VARCHAR(20);`,
+		`ALTER TABLE product_colors ADD COLUMN IF NOT EXISTS color_family_source VARCHAR(10);`,
+		`ALTER TABLE product_colors ADD COLUMN IF NOT EXISTS dominant_color VARCHAR(7);`,
+		`CREATE INDEX IF NOT EXISTS idx_product_colors_family ON product_colors(color_family);`,
+		`ALTER TABLE melhaf_colors ADD COLUMN IF NOT EXISTS color_family VARCHAR(20);`,
+		`ALTER TABLE melhaf_colors ADD COLUMN IF NOT EXISTS color_family_source VARCHAR(10);`,
+		`ALTER TABLE melhaf_colors ADD COLUMN IF NOT EXISTS dominant_color VARCHAR(7);`,
+		`CREATE INDEX IF NOT EXISTS idx_melhaf_colors_family ON melhaf_colors(color_family);`,
+
+		// Scheduled prices: rows without a window are base prices, rows with a window
+		// override them while it is open. SKUs that only had windowed rows get a base
+		// row at their list price so they keep a price once the window closes.
+		`ALTER TABLE prices ADD COLUMN IF NOT EXISTS sale_event_id UUID REFERENCES sale_events(id) ON DELETE CASCADE;`,
+		`ALTER TABLE prices ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT now();`,
+		`CREATE INDEX IF NOT EXISTS idx_prices_window ON prices(sku_id, currency, start_at, end_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_prices_sale_event ON prices(sale_event_id) WHERE sale_event_id IS NOT NULL;`,
+		`INSERT INTO prices (id, sku_id, currency, list_price, created_at)
+		 SELECT DISTINCT ON (p.sku_id, p.currency) gen_random_uuid(), p.sku_id, p.currency, p.list_price, p.created_at
+		 FROM prices p
+		 WHERE (p.start_at IS NOT NULL OR p.end_at IS NOT NULL)
+		   AND NOT EXISTS (SELECT 1 FROM prices b WHERE b.sku_id = p.sku_id AND b.currency = p.currency
+		                   AND b.start_at IS NULL AND b.end_at IS NULL)
+		 ORDER BY p.sku_id, p.currency, p.created_at DESC;`,
+		// Price resolver: the price of each SKU and currency at a given time. An open
+		// scheduled row wins over the base row, the latest start wins among scheduled
+		// rows and the newest row breaks ties.
+		`CREATE OR REPLACE FUNCTION prices_at(price_time TIMESTAMP WITH TIME ZONE)
+		 RETURNS TABLE (id UUID, sku_id UUID, currency CHAR(3), list_price NUMERIC(12,2), sale_price NUMERIC(12,2),
+		                start_at TIMESTAMP WITH TIME ZONE, end_at TIMESTAMP WITH TIME ZONE, sale_event_id UUID,
+		                created_at TIMESTAMP WITH TIME ZONE, effective_price NUMERIC(12,2)) AS $$
+			SELECT DISTINCT ON (p.sku_id, p.currency)
+			       p.id, p.sku_id, p.currency, p.list_price, p.sale_price, p.start_at, p.end_at, p.sale_event_id, p.created_at,
+			       CASE WHEN p.sale_price IS NOT NULL AND p.sale_price > 0 THEN LEAST(p.sale_price, p.list_price)
+			            ELSE p.list_price END
+			FROM prices p
+			WHERE (p.start_at IS NULL OR p.start_at <= price_time) AND (p.end_at IS NULL OR p.end_at > price_time)
+			ORDER BY p.sku_id, p.currency, (p.start_at IS NULL AND p.end_at IS NULL), p.start_at DESC NULLS LAST, p.created_at DESC
+		$$ LANGUAGE sql STABLE;`,
+		`CREATE OR REPLACE VIEW active_prices AS SELECT * FROM prices_at(now());`,
+		// Price history: log every change to prices, whoever writes it
+		`CREATE OR REPLACE FUNCTION log_price_change() RETURNS trigger AS $$
+		DECLARE
+			src TEXT := COALESCE(NULLIF(current_setting('fmbq.price_source', true), ''), 'system');
+			actor UUID := NULLIF(current_setting('fmbq.price_actor', true), '')::uuid;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				INSERT INTO price_history (price_id, sku_id, currency, action, old_list_price, old_sale_price,
+				                           start_at, end_at, sale_event_id, source, changed_by)
+				VALUES (OLD.id, OLD.sku_id, OLD.currency, 'delete', OLD.list_price, OLD.sale_price,
+				        OLD.start_at, OLD.end_at, OLD.sale_event_id, src, actor);
+				RETURN OLD;
+			END IF;
+			IF TG_OP = 'UPDATE' THEN
+				IF OLD.list_price IS NOT DISTINCT FROM NEW.list_price AND OLD.sale_price IS NOT DISTINCT FROM NEW.sale_price
+				   AND OLD.start_at IS NOT DISTINCT FROM NEW.start_at AND OLD.end_at IS NOT DISTINCT FROM NEW.end_at THEN
+					RETURN NEW;
+				END IF;
+				INSERT INTO price_history (price_id, sku_id, currency, action, old_list_price, old_sale_price,
+				                           new_list_price, new_sale_price, start_at, end_at, sale_event_id, source, changed_by)
+				VALUES (NEW.id, NEW.sku_id, NEW.currency, 'update', OLD.list_price, OLD.sale_price,
+				        NEW.list_price, NEW.sale_price, NEW.start_at, NEW.end_at, NEW.sale_event_id, src, actor);
+				RETURN NEW;
+			END IF;
+			INSERT INTO price_history (price_id, sku_id, currency, action, new_list_price, new_sale_price,
+			                           start_at, end_at, sale_event_id, source, changed_by)
+			VALUES (NEW.id, NEW.sku_id, NEW.currency, 'create', NEW.list_price, NEW.sale_price,
+			        NEW.start_at, NEW.end_at, NEW.sale_event_id, src, actor);
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_prices_history ON prices;`,
+		`CREATE TRIGGER trg_prices_history AFTER INSERT OR UPDATE OR DELETE ON prices
+		 FOR EACH ROW EXECUTE FUNCTION log_price_change();`,
+
+		// Product lifecycle: draft, review, scheduled, published, archived. Existing
+		// products keep their visibility; new ones start as drafts. is_active stays the
+		// visibility flag read by the storefront and follows the status.
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'published';`,
+		`ALTER TABLE product_models ALTER COLUMN status SET DEFAULT 'draft';`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS unpublish_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS published_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS submitted_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS submitted_by UUID REFERENCES users(id) ON DELETE SET NULL;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS review_note TEXT;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS preview_token TEXT;`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS preview_token_expires_at TIMESTAMP WITH TIME ZONE;`,
+		`CREATE INDEX IF NOT EXISTS idx_product_models_status ON product_models(status);`,
+		`CREATE INDEX IF NOT EXISTS idx_product_models_publish_at ON product_models(publish_at) WHERE status = 'scheduled';`,
+		`CREATE INDEX IF NOT EXISTS idx_product_models_unpublish_at ON product_models(unpublish_at) WHERE unpublish_at IS NOT NULL;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_product_models_preview_token ON product_models(preview_token) WHERE preview_token IS NOT NULL;`,
+		`CREATE OR REPLACE FUNCTION sync_product_visibility() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'UPDATE' AND NEW.status IS NOT DISTINCT FROM OLD.status AND NEW.is_active IS DISTINCT FROM OLD.is_active THEN
+				-- Writers that only toggle is_active publish or archive the product
+				NEW.status := CASE WHEN NEW.is_active THEN 'published' ELSE 'archived' END;
+			END IF;
+			NEW.is_active := NEW.status = 'published';
+			IF NEW.status = 'published' AND NEW.published_at IS NULL THEN
+				NEW.published_at := now();
+			END IF;
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_product_models_visibility ON product_models;`,
+		`CREATE TRIGGER trg_product_models_visibility BEFORE INSERT OR UPDATE ON product_models
+		 FOR EACH ROW EXECUTE FUNCTION sync_product_visibility();`,
+		`UPDATE product_models SET status = 'archived' WHERE status = 'published' AND is_active = false;`,
+
+		// Search index: normalisation folds case, French accents and Arabic letter
+		// variants and strips Arabic diacritics and tatweel, so "Mélhafa", "melhafa"
+		// and "ملحفة"/"ملحفه" match each other
+		`CREATE OR REPLACE FUNCTION search_normalize(input TEXT) RETURNS TEXT AS $$
+			SELECT regexp_replace(
+				translate(replace(lower(COALESCE(input, '')), 'œ', 'oe'),
+					'àâäáãåçéèêëíìîïñóòôöõúùûüýÿأإآٱىةؤئـ',
+					'aaaaaaceeeeiiiinooooouuuuyyاااايهوي'),
+				'[\u064B-\u0652\u0670]', '', 'g')
+		$$ LANGUAGE SQL IMMUTABLE;`,
+		`CREATE OR REPLACE FUNCTION search_vector(a TEXT, b TEXT, c TEXT, d TEXT) RETURNS tsvector AS $$
+			SELECT setweight(to_tsvector('french', search_normalize(a)), 'A') ||
+			       setweight(to_tsvector('french', search_normalize(b)), 'B') ||
+			       setweight(to_tsvector('french', search_normalize(c)), 'C') ||
+			       setweight(to_tsvector('french', search_normalize(d)), 'D')
+		$$ LANGUAGE SQL IMMUTABLE;`,
+		`CREATE OR REPLACE FUNCTION search_qa_text(kind TEXT, ref UUID) RETURNS TEXT AS $$
+			SELECT string_agg(concat_ws(' ', q.question, a.answers), ' ')
+			FROM product_questions q
+			JOIN (SELECT question_id, string_agg(answer, ' ') AS answers FROM product_answers
+			      WHERE status = 'approved' GROUP BY question_id) a ON a.question_id = q.id
+			WHERE q.item_type = kind AND q.item_id = ref AND q.status = 'approved'
+		$$ LANGUAGE SQL STABLE;`,
+		`CREATE OR REPLACE FUNCTION refresh_search_document(kind TEXT, ref UUID) RETURNS void AS $$
+		DECLARE
+			doc RECORD;
+		BEGIN
+			IF kind = 'product' THEN
+				SELECT pm.title AS title, COALESCE(b.name, '') AS subtitle, pm.is_active AS active,
+				       search_vector(pm.title,
+				                     concat_ws(' ', b.name, pm.model_code),
+				                     concat_ws(' ',
+				                         (SELECT string_agg(c.name, ' ') FROM product_model_categories pmc
+				                          JOIN categories c ON c.id = pmc.category_id WHERE pmc.product_model_id = pm.id),
+				                         (SELECT string_agg(DISTINCT pc.color_name, ' ') FROM product_colors pc WHERE pc.product_model_id = pm.id)),
+				                     concat_ws(' ', pm.short_description, pm.description, search_qa_text(kind, pm.id))) AS document,
+				       search_normalize(concat_ws(' ', pm.title, b.name, pm.model_code)) AS search_text
+				INTO doc
+				FROM product_models pm
+				LEFT JOIN brands b ON b.id = pm.brand_id
+				WHERE pm.id = ref;
+			ELSIF kind = 'melhaf_collection' THEN
+				SELECT mcol.name AS title, 'Melhaf' AS subtitle, mcol.is_active AS active,
+				       search_vector(mcol.name,
+				                     concat_ws(' ', 'melhaf', mt.name, mt.name_ar),
+				                     (SELECT string_agg(concat_ws(' ', mc.name, mc.name_ar), ' ') FROM melhaf_colors mc WHERE mc.collection_id = mcol.id),
+				                     concat_ws(' ', mcol.description, search_qa_text(kind, mcol.id))) AS document,
+				       search_normalize(concat_ws(' ', mcol.name, 'melhaf', mt.name)) AS search_text
+				INTO doc
+				FROM melhaf_collections mcol
+				LEFT JOIN melhaf_types mt ON mt.id = mcol.type_id
+				WHERE mcol.id = ref;
+			ELSIF kind = 'melhaf_color' THEN
+				SELECT concat_ws(' - ', mcol.name, mc.name) AS title, 'Melhaf' AS subtitle,
+				       COALESCE(mc.is_active, true) AND COALESCE(mcol.is_active, true) AS active,
+				       search_vector(concat_ws(' ', mcol.name, mc.name, mc.name_ar),
+				                     concat_ws(' ', 'melhaf', mt.name, mt.name_ar),
+				                     mc.color_family,
+				                     mcol.description) AS document,
+				       search_normalize(concat_ws(' ', mcol.name, mc.name, mc.name_ar, 'melhaf')) AS search_text
+				INTO doc
+				FROM melhaf_colors mc
+				JOIN melhaf_collections mcol ON mcol.id = mc.collection_id
+				LEFT JOIN melhaf_types mt ON mt.id = mcol.type_id
+				WHERE mc.id = ref;
+			ELSIF kind = 'perfume' THEN
+				SELECT p.name AS title, 'Maison Adrar' AS subtitle, p.is_active AS active,
+				       search_vector(concat_ws(' ', p.name, p.name_ar),
+				                     concat_ws(' ', 'maison adrar', p.fragrance_family, p.concentration, p.gender_category, p.type),
+				                     concat_ws(' ', p.top_notes, p.middle_notes, p.base_notes),
+				                     concat_ws(' ', p.description, p.ingredients, search_qa_text(kind, p.id))) AS document,
+				       search_normalize(concat_ws(' ', p.name, p.name_ar, 'maison adrar', p.fragrance_family,
+				                                  p.top_notes, p.middle_notes, p.base_notes)) AS search_text
+				INTO doc
+				FROM maison_adrar_perfumes p
+				WHERE p.id = ref;
+			END IF;
+
+			IF NOT FOUND THEN
+				DELETE FROM search_documents WHERE doc_type = kind AND doc_id = ref;
+				RETURN;
+			END IF;
+			INSERT INTO search_documents (doc_type, doc_id, title, subtitle, search_text, document, is_active, updated_at)
+			VALUES (kind, ref, doc.title, doc.subtitle, doc.search_text, doc.document, COALESCE(doc.active, true), now())
+			ON CONFLICT (doc_type, doc_id) DO UPDATE SET
+				title = EXCLUDED.title, subtitle = EXCLUDED.subtitle, search_text = EXCLUDED.search_text,
+				document = EXCLUDED.document, is_active = EXCLUDED.is_active, updated_at = now();
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`CREATE OR REPLACE FUNCTION sync_search_documents() RETURNS TRIGGER AS $$
+		BEGIN
+			IF TG_TABLE_NAME = 'product_models' THEN
+				IF TG_OP = 'DELETE' THEN
+					DELETE FROM search_documents WHERE doc_type = 'product' AND doc_id = OLD.id;
+				ELSE
+					PERFORM refresh_search_document('product', NEW.id);
+				END IF;
+			ELSIF TG_TABLE_NAME IN ('product_model_categories', 'product_colors') THEN
+				IF TG_OP = 'DELETE' THEN
+					PERFORM refresh_search_document('product', OLD.product_model_id);
+				ELSE
+					PERFORM refresh_search_document('product', NEW.product_model_id);
+				END IF;
+			ELSIF TG_TABLE_NAME = 'brands' THEN
+				PERFORM refresh_search_document('product', id) FROM product_models WHERE brand_id = NEW.id;
+			ELSIF TG_TABLE_NAME = 'categories' THEN
+				PERFORM refresh_search_document('product', product_model_id) FROM product_model_categories WHERE category_id = NEW.id;
+			ELSIF TG_TABLE_NAME = 'melhaf_collections' THEN
+				IF TG_OP = 'DELETE' THEN
+					DELETE FROM search_documents WHERE doc_type = 'melhaf_collection' AND doc_id = OLD.id;
+				ELSE
+					PERFORM refresh_search_document('melhaf_collection', NEW.id);
+					PERFORM refresh_search_document('melhaf_color', id) FROM melhaf_colors WHERE collection_id = NEW.id;
+				END IF;
+			ELSIF TG_TABLE_NAME = 'melhaf_colors' THEN
+				IF TG_OP = 'DELETE' THEN
+					DELETE FROM search_documents WHERE doc_type = 'melhaf_color' AND doc_id = OLD.id;
+					PERFORM refresh_search_document('melhaf_collection', OLD.collection_id);
+				ELSE
+					PERFORM refresh_search_document('melhaf_color', NEW.id);
+					PERFORM refresh_search_document('melhaf_collection', NEW.collection_id);
+				END IF;
+			ELSIF TG_TABLE_NAME = 'product_questions' THEN
+				IF TG_OP = 'DELETE' THEN
+					PERFORM refresh_search_document(OLD.item_type, OLD.item_id);
+				ELSE
+					PERFORM refresh_search_document(NEW.item_type, NEW.item_id);
+				END IF;
+			ELSIF TG_TABLE_NAME = 'product_answers' THEN
+				IF TG_OP = 'DELETE' THEN
+					PERFORM refresh_search_document(item_type, item_id) FROM product_questions WHERE id = OLD.question_id;
+				ELSE
+					PERFORM refresh_search_document(item_type, item_id) FROM product_questions WHERE id = NEW.question_id;
+				END IF;
+			ELSIF TG_TABLE_NAME = 'maison_adrar_perfumes' THEN
+				IF TG_OP = 'DELETE' THEN
+					DELETE FROM search_documents WHERE doc_type = 'perfume' AND doc_id = OLD.id;
+				ELSE
+					PERFORM refresh_search_document('perfume', NEW.id);
+				END IF;
+			END IF;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_product_models_search ON product_models;`,
+		`CREATE TRIGGER trg_product_models_search AFTER INSERT OR UPDATE OR DELETE ON product_models
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_product_model_categories_search ON product_model_categories;`,
+		`CREATE TRIGGER trg_product_model_categories_search AFTER INSERT OR UPDATE OR DELETE ON product_model_categories
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_product_colors_search ON product_colors;`,
+		`CREATE TRIGGER trg_product_colors_search AFTER INSERT OR UPDATE OR DELETE ON product_colors
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_brands_search ON brands;`,
+		`CREATE TRIGGER trg_brands_search AFTER UPDATE OF name ON brands
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_categories_search ON categories;`,
+		`CREATE TRIGGER trg_categories_search AFTER UPDATE OF name ON categories
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_melhaf_collections_search ON melhaf_collections;`,
+		`CREATE TRIGGER trg_melhaf_collections_search AFTER INSERT OR UPDATE OR DELETE ON melhaf_collections
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_melhaf_colors_search ON melhaf_colors;`,
+		`CREATE TRIGGER trg_melhaf_colors_search AFTER INSERT OR UPDATE OR DELETE ON melhaf_colors
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_maison_adrar_perfumes_search ON maison_adrar_perfumes;`,
+		`CREATE TRIGGER trg_maison_adrar_perfumes_search AFTER INSERT OR UPDATE OR DELETE ON maison_adrar_perfumes
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_product_questions_search ON product_questions;`,
+		`CREATE TRIGGER trg_product_questions_search AFTER UPDATE OF status OR DELETE ON product_questions
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`DROP TRIGGER IF EXISTS trg_product_answers_search ON product_answers;`,
+		`CREATE TRIGGER trg_product_answers_search AFTER INSERT OR UPDATE OF status, answer OR DELETE ON product_answers
+		 FOR EACH ROW EXECUTE FUNCTION sync_search_documents();`,
+		`SELECT refresh_search_document('product', pm.id) FROM product_models pm
+		 WHERE NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.doc_type = 'product' AND sd.doc_id = pm.id);`,
+		`SELECT refresh_search_document('melhaf_collection', mcol.id) FROM melhaf_collections mcol
+		 WHERE NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.doc_type = 'melhaf_collection' AND sd.doc_id = mcol.id);`,
+		`SELECT refresh_search_document('melhaf_color', mc.id) FROM melhaf_colors mc
+		 WHERE NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.doc_type = 'melhaf_color' AND sd.doc_id = mc.id);`,
+		`SELECT refresh_search_document('perfume', p.id) FROM maison_adrar_perfumes p
+		 WHERE NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.doc_type = 'perfume' AND sd.doc_id = p.id);`,
+
+		// Search analytics: the filters sent with each query and what synonyms and
+		// redirects made of it
+		`ALTER TABLE search_queries ADD COLUMN IF NOT EXISTS filters JSONB NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE search_queries ADD COLUMN IF NOT EXISTS rewritten_query TEXT;`,
+		`ALTER TABLE search_queries ADD COLUMN IF NOT EXISTS redirect_id UUID REFERENCES search_rules(id) ON DELETE SET NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_search_queries_source ON search_queries(source, created_at);`,
+
+		// Reviews: verified purchase, moderation and merchant reply; product_models
+		// keeps the approved rating for search and sorting
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS order_item_id UUID REFERENCES order_items(id) ON DELETE SET NULL;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'pending';`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderation_note TEXT;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_by UUID REFERENCES users(id) ON DELETE SET NULL;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS reply TEXT;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS replied_by UUID REFERENCES users(id) ON DELETE SET NULL;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS replied_at TIMESTAMP WITH TIME ZONE;`,
+		`ALTER TABLE reviews ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT now();`,
+		// One review per customer and product: keep each customer's approved, then
+		// latest, review of a product before enforcing it
+		`DELETE FROM reviews r USING (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY product_model_id, user_id
+			                              ORDER BY status = 'approved' DESC, COALESCE(updated_at, created_at) DESC, created_at DESC, id) AS rn
+			FROM reviews WHERE user_id IS NOT NULL
+		 ) dup
+		 WHERE r.id = dup.id AND dup.rn > 1;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reviews_product_user ON reviews(product_model_id, user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_reviews_status ON reviews(status, updated_at);`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS rating_average NUMERIC(3,2);`,
+		`ALTER TABLE product_models ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;`,
+		`CREATE OR REPLACE FUNCTION refresh_product_rating(product UUID) RETURNS void AS $$
+			UPDATE product_models pm SET rating_average = r.average, rating_count = r.count
+			FROM (SELECT ROUND(AVG(rating), 2) AS average, COUNT(*) AS count
+			      FROM reviews WHERE product_model_id = product AND status = 'approved') r
+			WHERE pm.id = product
+			  AND (pm.rating_average IS DISTINCT FROM r.average OR pm.rating_count <> r.count);
+		$$ LANGUAGE sql;`,
+		`CREATE OR REPLACE FUNCTION sync_product_rating() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'INSERT' THEN
+				PERFORM refresh_product_rating(NEW.product_model_id);
+			ELSIF TG_OP = 'DELETE' THEN
+				PERFORM refresh_product_rating(OLD.product_model_id);
+			ELSE
+				PERFORM refresh_product_rating(OLD.product_model_id);
+				IF NEW.product_model_id IS DISTINCT FROM OLD.product_model_id THEN
+					PERFORM refresh_product_rating(NEW.product_model_id);
+				END IF;
+			END IF;
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`,
+		`DROP TRIGGER IF EXISTS trg_reviews_rating ON reviews;`,
+		`CREATE TRIGGER trg_reviews_rating AFTER INSERT OR UPDATE OF status, rating, product_model_id OR DELETE ON reviews
+		 FOR EACH ROW EXECUTE FUNCTION sync_product_rating();`,
+		`SELECT refresh_product_rating(id) FROM product_models pm
+		 WHERE EXISTS (SELECT 1 FROM reviews r WHERE r.product_model_id = pm.id);`,
 	}
 
 	for i, migration := range migrations {
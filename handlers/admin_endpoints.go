@@ -250,6 +250,9 @@ func GetAdminOrders(c *gin.Context) {
 		} else {
 			order["items"] = items
 		}
+		if bundles, err := orderBundleItems(orderID); err == nil && len(bundles) > 0 {
+			order["bundles"] = bundles
+		}
 
 		orders = append(orders, order)
 	}
@@ -284,7 +287,7 @@ func getOrderItemsForAdmin(orderID string) ([]map[string]interface{}, error) {
 		LEFT JOIN product_models pm ON oi.product_id = pm.id
 		LEFT JOIN brands b ON pm.brand_id = b.id
 		LEFT JOIN product_images pi ON pm.id = pi.product_model_id AND pi.position = 1
-		WHERE oi.order_id = $1
+		WHERE oi.order_id = $1 AND oi.item_type IS DISTINCT FROM 'bundle'
 		ORDER BY oi.created_at
 	`
 
@@ -552,6 +555,9 @@ func GetOrderDetails(c *gin.Context) {
 		"billing_address_id":   order.BillingAddressID,
 		"items":                items,
 	}
+	if bundles, err := orderBundleItems(orderID); err == nil && len(bundles) > 0 {
+		orderData["bundles"] = bundles
+	}
 
 	c.JSON(http.StatusOK, orderData)
 }
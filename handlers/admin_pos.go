@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type AdminOrderSummary struct {
@@ -119,7 +120,8 @@ func AdminGetPOSOrder(c *gin.Context) {
             items = append(items, it)
         }
     }
-    c.JSON(http.StatusOK, gin.H{"order": order, "items": items})
+    bundles, _ := orderBundleItems(id)
+    c.JSON(http.StatusOK, gin.H{"order": order, "items": items, "bundles": bundles})
 }
 
 // GET /api/v1/admin/pos/stats
@@ -266,8 +268,26 @@ func AdminUpdateQuantity(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quantities"})
         return
     }
-    if _, err := DB.Exec(`UPDATE inventory SET available = $1, reserved = $2, updated_at = now() WHERE sku_id = $3`, 
-                        body.Available, body.Reserved, skuID); err != nil {
+    skuUUID, err := uuid.Parse(skuID)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SKU ID"})
+        return
+    }
+    tx, err := DB.Begin()
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+        return
+    }
+    defer tx.Rollback()
+    if err := setStockLevel(tx, "sku", skuUUID, body.Available, "manual_adjustment", c.GetString("user_id")); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quantities"})
+        return
+    }
+    if _, err := tx.Exec(`UPDATE inventory SET reserved = $1, updated_at = now() WHERE sku_id = $2`, body.Reserved, skuUUID); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quantities"})
+        return
+    }
+    if err := tx.Commit(); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update quantities"})
         return
     }
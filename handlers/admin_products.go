@@ -9,7 +9,9 @@ import (
 	"strings"
 	"time"
 
+	"fmbq-server/models"
 	"fmbq-server/services"
+	"fmbq-server/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -22,14 +24,14 @@ func GetAdminProducts(c *gin.Context) {
 	categoryID := c.Query("category_id")
 	brandID := c.Query("brand_id")
 	search := c.Query("search")
-	status := c.Query("status") // "active", "inactive", or "all"
+	status := c.Query("status") // "active", "inactive", "all" or a lifecycle status
 
 	offset := (page - 1) * limit
 
 	query := `
 		SELECT pm.id, pm.brand_id, pm.title, pm.description, pm.short_description, 
 		       pm.model_code, pm.is_active, pm.attributes, pm.created_at, pm.updated_at,
-		       b.name as brand_name,
+		       b.name as brand_name, pm.status, pm.publish_at, pm.unpublish_at,
 		       COALESCE(MIN(p.list_price), 0) as min_price,
 		       COALESCE(MAX(p.list_price), 0) as max_price,
 		       COALESCE(SUM(i.available), 0) as total_stock,
@@ -37,12 +39,9 @@ func GetAdminProducts(c *gin.Context) {
 		FROM product_models pm
 		LEFT JOIN brands b ON pm.brand_id = b.id
 		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices p ON s.id = p.sku_id
+		LEFT JOIN active_prices p ON s.id = p.sku_id
 		LEFT JOIN inventory i ON s.id = i.sku_id
 		WHERE 1=1
-		GROUP BY pm.id, pm.brand_id, pm.title, pm.description, pm.short_description, 
-		         pm.model_code, pm.is_active, pm.attributes, pm.created_at, pm.updated_at,
-		         b.name
 	`
 	args := []interface{}{}
 	argIndex := 1
@@ -52,6 +51,10 @@ func GetAdminProducts(c *gin.Context) {
 		query += ` AND pm.is_active = true`
 	} else if status == "inactive" {
 		query += ` AND pm.is_active = false`
+	} else if productStatuses[status] {
+		query += ` AND pm.status = $` + strconv.Itoa(argIndex)
+		args = append(args, status)
+		argIndex++
 	}
 	// If status is "all" or not provided, show all products
 
@@ -78,6 +81,10 @@ func GetAdminProducts(c *gin.Context) {
 		argIndex += 2
 	}
 
+	query += `
+		GROUP BY pm.id, pm.brand_id, pm.title, pm.description, pm.short_description, 
+		         pm.model_code, pm.is_active, pm.attributes, pm.created_at, pm.updated_at,
+		         b.name, pm.status, pm.publish_at, pm.unpublish_at`
 	query += ` ORDER BY pm.created_at DESC LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
 	args = append(args, limit, offset)
 
@@ -95,12 +102,15 @@ func GetAdminProducts(c *gin.Context) {
 		var isActive bool
 		var attributes, createdAt, updatedAt string
 		var brandName sql.NullString
+		var lifecycleStatus string
+		var publishAt, unpublishAt *time.Time
 		var minPrice, maxPrice float64
 		var totalStock int
 		var variantsCount int
 
 		err := rows.Scan(&id, &brandID, &title, &description, &shortDescription, 
 			&modelCode, &isActive, &attributes, &createdAt, &updatedAt, &brandName,
+			&lifecycleStatus, &publishAt, &unpublishAt,
 			&minPrice, &maxPrice, &totalStock, &variantsCount)
 		if err != nil {
 			continue
@@ -164,6 +174,9 @@ func GetAdminProducts(c *gin.Context) {
 			"short_description": shortDescription,
 			"model_code":        modelCode,
 			"is_active":         isActive,
+			"status":            lifecycleStatus,
+			"publish_at":        publishAt,
+			"unpublish_at":      unpublishAt,
 			"attributes":        attributes,
 			"created_at":        createdAt,
 			"updated_at":        updatedAt,
@@ -191,6 +204,10 @@ func GetAdminProducts(c *gin.Context) {
 		countQuery += ` AND pm.is_active = true`
 	} else if status == "inactive" {
 		countQuery += ` AND pm.is_active = false`
+	} else if productStatuses[status] {
+		countQuery += ` AND pm.status = $` + strconv.Itoa(countArgIndex)
+		countArgs = append(countArgs, status)
+		countArgIndex++
 	}
 
 	if categoryID != "" {
@@ -248,17 +265,21 @@ func GetAdminProduct(c *gin.Context) {
 		ShortDescription string    `json:"short_description"`
 		ModelCode        string    `json:"model_code"`
 		IsActive         bool      `json:"is_active"`
+		Status           string    `json:"status"`
+		PublishAt        *time.Time `json:"publish_at"`
+		UnpublishAt      *time.Time `json:"unpublish_at"`
 		Attributes       string    `json:"attributes"`
 		CreatedAt        time.Time `json:"created_at"`
 		UpdatedAt        time.Time `json:"updated_at"`
 	}
 
-	productQuery := `SELECT id, brand_id, title, description, short_description, model_code, is_active, attributes, created_at, updated_at 
+	productQuery := `SELECT id, brand_id, title, description, short_description, model_code, is_active, status, publish_at, unpublish_at, attributes, created_at, updated_at 
 	                 FROM product_models WHERE id = $1`
 	
 	err := DB.QueryRow(productQuery, productID).Scan(
 		&productModel.ID, &productModel.BrandID, &productModel.Title, &productModel.Description,
 		&productModel.ShortDescription, &productModel.ModelCode, &productModel.IsActive,
+		&productModel.Status, &productModel.PublishAt, &productModel.UnpublishAt,
 		&productModel.Attributes, &productModel.CreatedAt, &productModel.UpdatedAt,
 	)
 	
@@ -329,7 +350,11 @@ func GetAdminProduct(c *gin.Context) {
 	                    pc.color_name, p.list_price, p.sale_price, i.available
 	             FROM skus s
 	             JOIN product_colors pc ON s.product_color_id = pc.id
-	             LEFT JOIN prices p ON s.id = p.sku_id
+	             LEFT JOIN LATERAL (
+	                 SELECT list_price, sale_price FROM prices
+	                 WHERE sku_id = s.id AND currency = 'MRO' AND start_at IS NULL AND end_at IS NULL
+	                 ORDER BY created_at DESC LIMIT 1
+	             ) p ON true
 	             LEFT JOIN inventory i ON s.id = i.sku_id
 	             WHERE s.product_model_id = $1
 	             ORDER BY pc.color_name, s.size`
@@ -378,6 +403,9 @@ func GetAdminProduct(c *gin.Context) {
 		"short_description": productModel.ShortDescription,
 		"model_code":        productModel.ModelCode,
 		"is_active":         productModel.IsActive,
+		"status":            productModel.Status,
+		"publish_at":        productModel.PublishAt,
+		"unpublish_at":      productModel.UnpublishAt,
 		"attributes":        attributesMap,
 		"categories":        categories,
 		"colors":            colors,
@@ -411,12 +439,20 @@ func CreateProduct(c *gin.Context) {
 			Inventory int     `json:"inventory" binding:"required"`
 		} `json:"skus" binding:"required"`
 		Attributes map[string]interface{} `json:"attributes"`
+		Status     string                 `json:"status"` // draft (default) or review; publishing goes through the lifecycle endpoint
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.Status == "" {
+		req.Status = models.ProductStatusDraft
+	}
+	if req.Status != models.ProductStatusDraft && req.Status != models.ProductStatusReview {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be draft or review"})
+		return
+	}
 
 	// Start transaction
 	tx, err := DB.Begin()
@@ -425,6 +461,10 @@ func CreateProduct(c *gin.Context) {
 		return
 	}
 	defer tx.Rollback()
+	if err = setPriceChangeContext(tx, "admin", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
 
 	// Create product model
 	productModelID := uuid.New()
@@ -453,11 +493,11 @@ func CreateProduct(c *gin.Context) {
 		attributesJSON = string(jsonBytes)
 	}
 
-	productQuery := `INSERT INTO product_models (id, brand_id, title, description, short_description, model_code, is_active, attributes, created_at, updated_at) 
+	productQuery := `INSERT INTO product_models (id, brand_id, title, description, short_description, model_code, status, attributes, created_at, updated_at) 
 	                 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
 	
 	_, err = tx.Exec(productQuery, productModelID, req.BrandID, req.Title, req.Description, 
-	                 req.ShortDescription, productCode, true, attributesJSON, now, now)
+	                 req.ShortDescription, productCode, req.Status, attributesJSON, now, now)
 	if err != nil {
 		fmt.Printf("Error creating product model: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product model", "details": err.Error()})
@@ -518,7 +558,12 @@ func CreateProduct(c *gin.Context) {
 			}
 			
 			skuCode := generateSKUCode(productCode, sku.ColorName, skuSize)
-			eanCode := generateEANCode(productCode, sku.ColorName, skuSize)
+			eanCode, err := allocateEAN(tx)
+			if err != nil {
+				fmt.Printf("Error allocating EAN: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate EAN"})
+				return
+			}
 			
 			// Get color ID
 			colorID, exists := colorMap[sku.ColorName]
@@ -562,16 +607,29 @@ func CreateProduct(c *gin.Context) {
 		}
 	}
 
+	if err = refreshSKUSizes(tx, productModelID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to normalize sizes"})
+		return
+	}
+	if err = refreshColorFamilies(tx, productModelID.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify colors"})
+		return
+	}
+
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
+	queueColorExtraction("product", colorsNeedingExtraction("product", "product_model_id::text = $1", productModelID.String()))
 
+	issues, _ := services.ProductCompletenessIssues(DB, productModelID.String())
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Product created successfully",
 		"product_id": productModelID,
 		"product_code": productCode,
+		"status": req.Status,
+		"completeness_issues": issues,
 	})
 }
 
@@ -617,6 +675,10 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 	defer tx.Rollback()
+	if err = setPriceChangeContext(tx, "admin", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
 
 	// Build dynamic update query for product_models
 	query := "UPDATE product_models SET "
@@ -641,12 +703,6 @@ func UpdateProduct(c *gin.Context) {
 		argIndex++
 	}
 
-	if req.IsActive != nil {
-		query += "is_active = $" + strconv.Itoa(argIndex) + ", "
-		args = append(args, *req.IsActive)
-		argIndex++
-	}
-
 	if req.Attributes != nil {
 		attributesJSON, err := json.Marshal(req.Attributes)
 		if err != nil {
@@ -844,21 +900,20 @@ func UpdateProduct(c *gin.Context) {
 					}
 					
 					// Update inventory
-					_, err = tx.Exec("UPDATE inventory SET available = $1 WHERE sku_id = $2",
-						skuData.Inventory, skuID)
+					skuUUID, _ := uuid.Parse(skuID)
+					err = setStockLevel(tx, "sku", skuUUID, skuData.Inventory, "manual_adjustment", c.GetString("user_id"))
 					if err != nil {
 						fmt.Printf("Error updating inventory: %v\n", err)
 						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
 						return
 					}
 					
-					// Update price
+					// Update the base price; scheduled prices are managed separately
 					salePrice := skuData.SalePrice
 					if salePrice == 0 {
 						salePrice = skuData.Price
 					}
-					_, err = tx.Exec("UPDATE prices SET list_price = $1, sale_price = $2 WHERE sku_id = $3",
-						skuData.Price, salePrice, skuID)
+					err = setBasePrice(tx, skuID, "MRO", skuData.Price, &salePrice)
 					if err != nil {
 						fmt.Printf("Error updating price: %v\n", err)
 						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price"})
@@ -889,7 +944,12 @@ func UpdateProduct(c *gin.Context) {
 				
 				// Generate SKU code and EAN
 				skuCode := generateSKUCode("PROD", skuData.ColorName, skuSize)
-				eanCode := generateEANCode("PROD", skuData.ColorName, skuSize)
+				eanCode, err := allocateEAN(tx)
+				if err != nil {
+					fmt.Printf("Error allocating EAN: %v\n", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate EAN"})
+					return
+				}
 				
 				// Create SKU
 				_, err = tx.Exec("INSERT INTO skus (id, product_model_id, product_color_id, sku_code, ean, size, size_normalized, attributes, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())",
@@ -948,7 +1008,12 @@ func UpdateProduct(c *gin.Context) {
 				
 				// Generate SKU code and EAN
 				skuCode := generateSKUCode("PROD", skuData.ColorName, skuSize)
-				eanCode := generateEANCode("PROD", skuData.ColorName, skuSize)
+				eanCode, err := allocateEAN(tx)
+				if err != nil {
+					fmt.Printf("Error allocating EAN: %v\n", err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate EAN"})
+					return
+				}
 				
 				// Create SKU
 				_, err = tx.Exec("INSERT INTO skus (id, product_model_id, product_color_id, sku_code, ean, size, size_normalized, attributes, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())",
@@ -1010,11 +1075,44 @@ func UpdateProduct(c *gin.Context) {
 		}
 	}
 
+	if err = refreshSKUSizes(tx, productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to normalize sizes"})
+		return
+	}
+	if err = refreshColorFamilies(tx, productID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify colors"})
+		return
+	}
+
+	// The is_active toggle publishes (when the product is complete) or archives
+	if req.IsActive != nil {
+		status := models.ProductStatusArchived
+		if *req.IsActive {
+			issues, err := services.ProductCompletenessIssues(tx, productID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check product completeness"})
+				return
+			}
+			if len(issues) > 0 {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Product is not complete", "completeness_issues": issues})
+				return
+			}
+			status = models.ProductStatusPublished
+		}
+		_, err = tx.Exec(`UPDATE product_models SET status = $2, publish_at = NULL, updated_at = now()
+		                  WHERE id = $1 AND status <> $2`, productID, status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product status"})
+			return
+		}
+	}
+
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
+	queueColorExtraction("product", colorsNeedingExtraction("product", "product_model_id::text = $1", productID))
 
 	fmt.Printf("Product %s updated successfully with images and SKUs\n", productID)
 	c.JSON(http.StatusOK, gin.H{"message": "Product updated successfully"})
@@ -1031,6 +1129,7 @@ func DeleteProduct(c *gin.Context) {
 		return
 	}
 	defer tx.Rollback()
+	setPriceChangeContext(tx, "admin", c.GetString("user_id"))
 
 	// Delete in reverse order of dependencies
 	queries := []string{
@@ -1151,62 +1250,14 @@ func generateSKUCode(productCode, colorName, size string) string {
 	// Generate SKU code: PRODUCT-COLOR-SIZE
 	cleanColor := strings.ReplaceAll(strings.ToUpper(colorName), " ", "")
 	cleanSize := strings.ReplaceAll(strings.ToUpper(size), " ", "")
-	return fmt.Sprintf("%s-%s-%s", productCode, cleanColor[:4], cleanSize)
-}
-
-func generateEANCode(productCode, colorName, size string) string {
-	// Generate a proper 13-digit EAN code
-	// Use a combination of product info to create unique identifier
-	
-	// Create a unique string from product info
-	baseString := fmt.Sprintf("%s%s%s", productCode, colorName, size)
-	
-	// Convert to numeric representation (only digits)
-	var numericString strings.Builder
-	for _, char := range baseString {
-		if char >= '0' && char <= '9' {
-			numericString.WriteRune(char)
-		} else if char >= 'A' && char <= 'Z' {
-			// Convert letters to numbers (A=1, B=2, etc.)
-			numericString.WriteString(fmt.Sprintf("%d", int(char-'A')+1))
-		} else if char >= 'a' && char <= 'z' {
-			// Convert lowercase letters to numbers (a=1, b=2, etc.)
-			numericString.WriteString(fmt.Sprintf("%d", int(char-'a')+1))
-		}
-	}
-	
-	// Take first 12 digits and pad with zeros if needed
-	base := numericString.String()
-	if len(base) > 12 {
-		base = base[:12]
-	} else {
-		// Pad with zeros to make it exactly 12 digits
-		for len(base) < 12 {
-			base = "0" + base
-		}
-	}
-	
-	// Calculate EAN-13 check digit (proper algorithm)
-	sum := 0
-	for i, digit := range base {
-		num := int(digit - '0')
-		if i%2 == 0 {
-			sum += num
-		} else {
-			sum += num * 3
-		}
+	if len(cleanColor) > 4 {
+		cleanColor = cleanColor[:4]
 	}
-	checkDigit := (10 - (sum % 10)) % 10
-	
-	// Return 13-digit EAN code
-	return fmt.Sprintf("%s%d", base, checkDigit)
+	return fmt.Sprintf("%s-%s-%s", productCode, cleanColor, cleanSize)
 }
 
 func normalizeSize(size string) string {
-	// Normalize size for sorting (e.g., "42 EU" -> "42")
-	parts := strings.Fields(size)
-	if len(parts) > 0 {
-		return parts[0]
-	}
-	return size
+	// Canonical code without brand aliases or category hints (e.g., "Medium" -> "M",
+	// "42 EU" -> "EU42"); refreshSKUSizes fills in the rest once the SKUs are written
+	return utils.NormalizeSize(size, "").Code
 }
@@ -496,6 +496,42 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware sets the user info like AuthMiddleware when a valid token
+// is sent, and lets guests and invalid tokens through without it
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			c.Next()
+			return
+		}
+		tokenString := strings.TrimSpace(authHeader[7:])
+		if tokenString == "" {
+			c.Next()
+			return
+		}
+
+		var userID uuid.UUID
+		var phone sql.NullString
+		err := database.Database.QueryRow(`
+			SELECT ut.user_id, u.phone
+			FROM user_tokens ut
+			JOIN users u ON ut.user_id = u.id
+			WHERE ut.token = $1 AND ut.revoked = false AND u.is_active = true`, tokenString).Scan(&userID, &phone)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				fmt.Printf("❌ Database error during optional token validation: %v\n", err)
+			}
+			c.Next()
+			return
+		}
+
+		c.Set("user_id", userID.String())
+		c.Set("user_phone", phone.String)
+		c.Next()
+	}
+}
+
 // ChangePassword changes user password and revokes all existing tokens
 func ChangePassword(c *gin.Context) {
 	userIDStr, exists := c.Get("user_id")
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// currentStockSQL returns the available stock of an item ($1 item_type, $2 item_id)
+const currentStockSQL = `
+	SELECT CASE $1::text
+		WHEN 'sku' THEN (SELECT COALESCE(available, 0) FROM inventory WHERE sku_id = $2)
+		WHEN 'melhaf_color' THEN (SELECT COALESCE(available, 0) FROM melhaf_inventory WHERE color_id = $2)
+		ELSE (SELECT COALESCE(stock, 0) FROM maison_adrar_perfume_colors WHERE id = $2)
+	END`
+
+// itemExistsSQL checks that an item exists ($1 item_type, $2 item_id)
+const itemExistsSQL = `
+	SELECT CASE $1::text
+		WHEN 'sku' THEN EXISTS(SELECT 1 FROM skus WHERE id = $2)
+		WHEN 'melhaf_color' THEN EXISTS(SELECT 1 FROM melhaf_colors WHERE id = $2)
+		ELSE EXISTS(SELECT 1 FROM maison_adrar_perfume_colors WHERE id = $2)
+	END`
+
+// SubscribeBackInStock handles POST /api/v1/back-in-stock
+// Body: {"item_type": "sku|melhaf_color|perfume_variant", "item_id": "..."}
+func SubscribeBackInStock(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req struct {
+		ItemType string `json:"item_type" binding:"required,oneof=sku melhaf_color perfume_variant"`
+		ItemID   string `json:"item_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	itemID, err := uuid.Parse(req.ItemID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item_id"})
+		return
+	}
+
+	var exists bool
+	if err := DB.QueryRow(itemExistsSQL, req.ItemType, itemID).Scan(&exists); err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	var available sql.NullInt64
+	if err := DB.QueryRow(currentStockSQL, req.ItemType, itemID).Scan(&available); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check stock"})
+		return
+	}
+	if available.Int64 > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item is in stock", "available": available.Int64})
+		return
+	}
+
+	var id uuid.UUID
+	err = DB.QueryRow(`
+		INSERT INTO back_in_stock_subscriptions (id, user_id, item_type, item_id, status, created_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, 'active', now())
+		ON CONFLICT (user_id, item_type, item_id) WHERE status IN ('active', 'triggered') DO UPDATE SET status = back_in_stock_subscriptions.status
+		RETURNING id`, userID, req.ItemType, itemID).Scan(&id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": id, "item_type": req.ItemType, "item_id": itemID, "status": "active"})
+}
+
+// GetBackInStockSubscriptions handles GET /api/v1/back-in-stock
+// Lists the caller's pending subscriptions
+func GetBackInStockSubscriptions(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT id, item_type, item_id, status, created_at
+		FROM back_in_stock_subscriptions
+		WHERE user_id = $1 AND status IN ('active', 'triggered')
+		ORDER BY created_at DESC`, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subscriptions"})
+		return
+	}
+	defer rows.Close()
+
+	type Subscription struct {
+		ID        string    `json:"id"`
+		ItemType  string    `json:"item_type"`
+		ItemID    string    `json:"item_id"`
+		Status    string    `json:"status"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	out := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.ItemType, &s.ItemID, &s.Status, &s.CreatedAt); err != nil {
+			continue
+		}
+		out = append(out, s)
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": out})
+}
+
+// CancelBackInStockSubscription handles DELETE /api/v1/back-in-stock/:id
+func CancelBackInStockSubscription(c *gin.Context) {
+	res, err := DB.Exec(`
+		UPDATE back_in_stock_subscriptions SET status = 'cancelled'
+		WHERE id::text = $1 AND user_id = $2 AND status IN ('active', 'triggered')`, c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel subscription"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminBackInStockDemand handles GET /api/v1/admin/inventory/back-in-stock/demand
+// Counts waiting subscribers per item so restocks can be prioritised.
+// By default only out-of-stock items are listed; pass all=true to include the rest.
+func AdminBackInStockDemand(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT b.item_type, b.item_id::text, COUNT(*) AS waiting, MIN(b.created_at),
+		       CASE b.item_type
+		           WHEN 'sku' THEN COALESCE(i.available, 0)
+		           WHEN 'melhaf_color' THEN COALESCE(mi.available, 0)
+		           ELSE COALESCE(pvc.stock, 0)
+		       END AS available,
+		       CASE b.item_type
+		           WHEN 'sku' THEN COALESCE(pm.title, '') || COALESCE(' - ' || pc.color_name, '') || COALESCE(' (' || s.size || ')', '')
+		           WHEN 'melhaf_color' THEN 'Melhaf ' || COALESCE(mc.name, '')
+		           ELSE COALESCE(p.name, 'Maison Adrar') || COALESCE(' - ' || pvc.name, '')
+		       END AS label,
+		       COALESCE(s.sku_code, mc.ean, '')
+		FROM back_in_stock_subscriptions b
+		LEFT JOIN skus s ON b.item_type = 'sku' AND s.id = b.item_id
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		LEFT JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN melhaf_colors mc ON b.item_type = 'melhaf_color' AND mc.id = b.item_id
+		LEFT JOIN melhaf_inventory mi ON mi.color_id = mc.id
+		LEFT JOIN maison_adrar_perfume_colors pvc ON b.item_type = 'perfume_variant' AND pvc.id = b.item_id
+		LEFT JOIN maison_adrar_perfumes p ON p.id = pvc.perfume_id
+		WHERE b.status = 'active'
+		GROUP BY b.item_type, b.item_id, i.available, mi.available, pvc.stock, pm.title, pc.color_name, s.size, mc.name, p.name, pvc.name, s.sku_code, mc.ean
+		ORDER BY waiting DESC, MIN(b.created_at) ASC
+		LIMIT 200`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch back-in-stock demand"})
+		return
+	}
+	defer rows.Close()
+
+	type Demand struct {
+		ItemType      string    `json:"item_type"`
+		ItemID        string    `json:"item_id"`
+		Label         string    `json:"label"`
+		Code          string    `json:"code"`
+		Waiting       int       `json:"waiting"`
+		Available     int       `json:"available"`
+		OldestRequest time.Time `json:"oldest_request"`
+	}
+	includeAll := c.Query("all") == "true"
+	out := []Demand{}
+	for rows.Next() {
+		var d Demand
+		if err := rows.Scan(&d.ItemType, &d.ItemID, &d.Waiting, &d.OldestRequest, &d.Available, &d.Label, &d.Code); err != nil {
+			continue
+		}
+		if d.Available > 0 && !includeAll {
+			continue
+		}
+		d.Label = strings.TrimSpace(d.Label)
+		out = append(out, d)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": out})
+}
@@ -4,10 +4,15 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"fmbq-server/database"
+	"fmbq-server/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // GetProductSKUs handles GET /api/v1/admin/products/:id/skus
@@ -29,7 +34,7 @@ func GetProductSKUs(c *gin.Context) {
 			COALESCE(i.reserved, 0) as reserved_quantity
 		FROM skus s
 		JOIN product_colors pc ON s.product_color_id = pc.id
-		LEFT JOIN prices p ON s.id = p.sku_id
+		LEFT JOIN active_prices p ON s.id = p.sku_id
 		LEFT JOIN inventory i ON s.id = i.sku_id
 		WHERE s.product_model_id = $1
 		ORDER BY pc.color_name, s.size
@@ -80,9 +85,13 @@ func GetProductSKUs(c *gin.Context) {
 }
 
 // ScanBarcode handles POST /api/v1/barcode/scan and /api/v1/admin/barcode/scan
+// Body: {"code": "..."} (or the older {"ean": "..."}), optional "source": pos|app|admin|stock_take.
+// The code may be an EAN/UPC of any sellable item, an internal SKU code or a QR payload.
 func ScanBarcode(c *gin.Context) {
 	var req struct {
-		EAN string `json:"ean" binding:"required"`
+		Code   string `json:"code"`
+		EAN    string `json:"ean"`
+		Source string `json:"source" binding:"omitempty,oneof=pos app admin stock_take"`
 	}
 
 	fmt.Printf("🔍 Barcode scan request received\n")
@@ -92,13 +101,93 @@ func ScanBarcode(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	code := strings.TrimSpace(req.Code)
+	if code == "" {
+		code = strings.TrimSpace(req.EAN)
+	}
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+	source := req.Source
+	if source == "" {
+		source = "app"
+		if strings.Contains(c.FullPath(), "/admin/") {
+			source = "admin"
+		}
+	}
 
-	fmt.Printf("🔍 Scanning EAN: %s\n", req.EAN)
+	fmt.Printf("🔍 Scanning code: %s\n", code)
 
-	// Find product by EAN code
-	var skuID, productID, productTitle, brandName, skuCode, size, colorName string
-	var availableQuantity int
-	var listPrice, salePrice float64
+	item, err := resolveScan(code)
+	if err != nil && err != sql.ErrNoRows {
+		fmt.Printf("❌ Scan resolution failed for %s: %v\n", code, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	logScan(code, item, c.GetString("user_id"), source)
+
+	if item == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Product not found",
+			"code":  code,
+			"ean":   code,
+		})
+		return
+	}
+
+	resp := gin.H{
+		"success":   true,
+		"sellable":  item,
+		"scan_time": time.Now(),
+	}
+	// SKU scans keep the product/sku blocks older app builds read
+	if item.Type == "sku" {
+		salePrice := 0.0
+		if item.Price < item.ListPrice {
+			salePrice = item.Price
+		}
+		resp["product"] = gin.H{
+			"id":         item.ParentID,
+			"title":      item.Title,
+			"brand_name": item.Brand,
+			"images":     item.Images,
+		}
+		resp["sku"] = gin.H{
+			"id":                 item.ItemID,
+			"sku_code":           item.SKUCode,
+			"ean":                item.EAN,
+			"size":               item.Size,
+			"color_name":         item.Color,
+			"available_quantity": item.Stock,
+			"list_price":         item.ListPrice,
+			"sale_price":         salePrice,
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// scannedSKU is the SKU resolved from an EAN scan
+type scannedSKU struct {
+	SKUID             string
+	ProductID         string
+	ProductTitle      string
+	BrandName         string
+	SKUCode           string
+	Size              string
+	ColorName         string
+	AvailableQuantity int
+	ListPrice         float64
+	SalePrice         float64
+}
+
+// lookupSKUByEAN finds the SKU carrying the given EAN. It returns sql.ErrNoRows
+// when no SKU matches so callers can answer with a 404.
+// EAN-8/UPC-A/EAN-13 input is normalised first so a UPC-A scan finds its EAN-13.
+func lookupSKUByEAN(ean string) (*scannedSKU, error) {
+	if normalized, err := utils.NormalizeBarcode(ean); err == nil {
+		ean = normalized
+	}
 
 	query := `
 		SELECT 
@@ -117,86 +206,172 @@ func ScanBarcode(c *gin.Context) {
 		JOIN brands b ON pm.brand_id = b.id
 		JOIN product_colors pc ON s.product_color_id = pc.id
 		LEFT JOIN inventory i ON s.id = i.sku_id
-		LEFT JOIN prices p ON s.id = p.sku_id
+		LEFT JOIN active_prices p ON s.id = p.sku_id
 		WHERE s.ean = $1
 	`
 
-	err := database.Database.QueryRow(query, req.EAN).Scan(
-		&skuID, &productID, &productTitle, &brandName, &skuCode, &size, &colorName,
-		&availableQuantity, &listPrice, &salePrice,
+	var sku scannedSKU
+	err := database.Database.QueryRow(query, ean).Scan(
+		&sku.SKUID, &sku.ProductID, &sku.ProductTitle, &sku.BrandName, &sku.SKUCode, &sku.Size, &sku.ColorName,
+		&sku.AvailableQuantity, &sku.ListPrice, &sku.SalePrice,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return &sku, nil
+}
 
-	if err == sql.ErrNoRows {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Product not found",
-			"ean": req.EAN,
-		})
+// GenerateBarcodeImage handles GET /api/v1/admin/barcode/generate/:ean
+// Query: format=png|svg (default png), symbology=ean13|code128 (default: ean13 for
+// valid EAN-13/UPC-A codes, code128 otherwise), scale (pixels per module), height
+func GenerateBarcodeImage(c *gin.Context) {
+	code := c.Param("ean")
+	format := c.DefaultQuery("format", "png")
+	symbology := c.Query("symbology")
+	if symbology == "" {
+		symbology = "code128"
+		if normalized, err := utils.NormalizeBarcode(code); err == nil && len(normalized) == 13 {
+			symbology = "ean13"
+		}
+	}
+
+	scale, err := strconv.Atoi(c.DefaultQuery("scale", "3"))
+	if err != nil || scale < 1 || scale > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scale must be between 1 and 20"})
+		return
+	}
+	height, err := strconv.Atoi(c.DefaultQuery("height", strconv.Itoa(scale*40)))
+	if err != nil || height < 10 || height > 2000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "height must be between 10 and 2000"})
 		return
 	}
 
+	barcode, err := encodeBarcode(symbology, code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get product images
-	var images []string
-	imageRows, err := database.Database.Query(`
-		SELECT url FROM product_images 
-		WHERE product_model_id = $1 
-		ORDER BY position ASC
-	`, productID)
-	
-	if err == nil {
-		defer imageRows.Close()
-		for imageRows.Next() {
-			var imageURL string
-			if err := imageRows.Scan(&imageURL); err == nil {
-				images = append(images, imageURL)
-			}
+	switch format {
+	case "svg":
+		c.Data(http.StatusOK, "image/svg+xml", []byte(barcode.SVG(scale, height)))
+	case "png":
+		img, err := barcode.PNG(scale, height)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render barcode"})
+			return
 		}
+		c.Data(http.StatusOK, "image/png", img)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
 	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"product": gin.H{
-			"id": productID,
-			"title": productTitle,
-			"brand_name": brandName,
-			"images": images,
-		},
-		"sku": gin.H{
-			"id": skuID,
-			"sku_code": skuCode,
-			"ean": req.EAN,
-			"size": size,
-			"color_name": colorName,
-			"available_quantity": availableQuantity,
-			"list_price": listPrice,
-			"sale_price": salePrice,
-		},
-		"scan_time": gin.Mode(),
-	})
+// encodeBarcode encodes code in the requested symbology (ean13 or code128)
+func encodeBarcode(symbology, code string) (*utils.Barcode, error) {
+	switch symbology {
+	case "ean13":
+		return utils.EncodeEAN13(code)
+	case "code128":
+		return utils.EncodeCode128(code)
+	default:
+		return nil, fmt.Errorf("symbology must be ean13 or code128")
+	}
 }
 
-// GenerateBarcodeImage handles GET /api/v1/admin/barcode/generate/:ean
-func GenerateBarcodeImage(c *gin.Context) {
-	ean := c.Param("ean")
-	
-	// Validate EAN format (should be 13 digits)
-	if len(ean) != 13 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid EAN format"})
+// AdminBarcodeScanStats handles GET /api/v1/admin/barcode/scans/stats?days=30
+// Reports scan volume per source, match rate, the most scanned items and the most
+// scanned unknown codes (good candidates for registering)
+func AdminBarcodeScanStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	bySource := []gin.H{}
+	total, matched := 0, 0
+	rows, err := DB.Query(`
+		SELECT source, COUNT(*), COUNT(item_id)
+		FROM barcode_scans
+		WHERE created_at >= $1
+		GROUP BY source
+		ORDER BY COUNT(*) DESC`, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load scan stats"})
 		return
 	}
+	for rows.Next() {
+		var source string
+		var scans, hits int
+		if err := rows.Scan(&source, &scans, &hits); err != nil {
+			continue
+		}
+		total += scans
+		matched += hits
+		bySource = append(bySource, gin.H{"source": source, "scans": scans, "matched": hits})
+	}
+	rows.Close()
+
+	topItems := []gin.H{}
+	rows, err = DB.Query(`
+		SELECT item_type, item_id, COUNT(*)
+		FROM barcode_scans
+		WHERE created_at >= $1 AND item_id IS NOT NULL
+		GROUP BY item_type, item_id
+		ORDER BY COUNT(*) DESC
+		LIMIT 20`, since)
+	if err == nil {
+		for rows.Next() {
+			var itemType string
+			var itemID uuid.UUID
+			var scans int
+			if err := rows.Scan(&itemType, &itemID, &scans); err != nil {
+				continue
+			}
+			entry := gin.H{"item_type": itemType, "item_id": itemID, "scans": scans}
+			if item, err := loadSellable(itemType, itemID); err == nil {
+				entry["title"] = item.Title
+				entry["variant"] = item.Variant
+			}
+			topItems = append(topItems, entry)
+		}
+		rows.Close()
+	}
+
+	unmatched := []gin.H{}
+	rows, err = DB.Query(`
+		SELECT code, COUNT(*), MAX(created_at)
+		FROM barcode_scans
+		WHERE created_at >= $1 AND item_id IS NULL
+		GROUP BY code
+		ORDER BY COUNT(*) DESC
+		LIMIT 20`, since)
+	if err == nil {
+		for rows.Next() {
+			var code string
+			var scans int
+			var lastSeen time.Time
+			if err := rows.Scan(&code, &scans, &lastSeen); err != nil {
+				continue
+			}
+			unmatched = append(unmatched, gin.H{"code": code, "scans": scans, "last_seen": lastSeen})
+		}
+		rows.Close()
+	}
 
-	// For now, return the EAN as a simple text response
-	// In a real implementation, you would generate an actual barcode image
+	matchRate := 0.0
+	if total > 0 {
+		matchRate = float64(matched) / float64(total)
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"ean": ean,
-		"barcode_data": ean,
-		"format": "EAN-13",
-		"message": "Barcode data ready for printing",
+		"days":            days,
+		"total_scans":     total,
+		"matched_scans":   matched,
+		"match_rate":      matchRate,
+		"by_source":       bySource,
+		"top_items":       topItems,
+		"unmatched_codes": unmatched,
 	})
 }
-
-
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxLabelsPerSheetRequest caps a single label PDF so a typo in a quantity can't
+// produce a runaway document
+const maxLabelsPerSheetRequest = 2000
+
+// labelTemplate describes a printable label layout; all sizes are in millimetres.
+// Roll templates use one page per label.
+type labelTemplate struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	PageWidth   float64 `json:"page_width_mm" binding:"required,gt=0"`
+	PageHeight  float64 `json:"page_height_mm" binding:"required,gt=0"`
+	Columns     int     `json:"columns" binding:"required,gt=0"`
+	Rows        int     `json:"rows" binding:"required,gt=0"`
+	LabelWidth  float64 `json:"label_width_mm" binding:"required,gt=0"`
+	LabelHeight float64 `json:"label_height_mm" binding:"required,gt=0"`
+	MarginLeft  float64 `json:"margin_left_mm"`
+	MarginTop   float64 `json:"margin_top_mm"`
+	GapX        float64 `json:"gap_x_mm"`
+	GapY        float64 `json:"gap_y_mm"`
+}
+
+// labelTemplates are the built-in layouts: common A4 sticker sheets and 58mm thermal rolls
+var labelTemplates = map[string]labelTemplate{
+	"a4-3x8": {
+		Name: "a4-3x8", Description: "A4 sheet, 3 x 8 labels of 70 x 37 mm",
+		PageWidth: 210, PageHeight: 297, Columns: 3, Rows: 8,
+		LabelWidth: 70, LabelHeight: 37, MarginTop: 0.5,
+	},
+	"a4-4x10": {
+		Name: "a4-4x10", Description: "A4 sheet, 4 x 10 labels of 48.5 x 25.4 mm",
+		PageWidth: 210, PageHeight: 297, Columns: 4, Rows: 10,
+		LabelWidth: 48.5, LabelHeight: 25.4, MarginLeft: 8, MarginTop: 21.5,
+	},
+	"thermal-58x40": {
+		Name: "thermal-58x40", Description: "58 mm thermal roll, 40 mm labels",
+		PageWidth: 58, PageHeight: 40, Columns: 1, Rows: 1,
+		LabelWidth: 58, LabelHeight: 40,
+	},
+	"thermal-58x30": {
+		Name: "thermal-58x30", Description: "58 mm thermal roll, 30 mm labels",
+		PageWidth: 58, PageHeight: 30, Columns: 1, Rows: 1,
+		LabelWidth: 58, LabelHeight: 30,
+	},
+}
+
+// skuLabel is what gets printed on one tag
+type skuLabel struct {
+	Title   string
+	Size    string
+	Color   string
+	Price   float64
+	Barcode *utils.Barcode
+}
+
+// GetLabelTemplates handles GET /api/v1/admin/inventory/labels/templates
+func GetLabelTemplates(c *gin.Context) {
+	templates := make([]labelTemplate, 0, len(labelTemplates))
+	for _, t := range labelTemplates {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// GenerateLabelSheet handles POST /api/v1/admin/inventory/labels
+// Body: {"template": "a4-3x8", "items": [{"sku_id": "...", "quantity": 2}], "start_position": 0}
+// A custom layout may be sent as "custom_template" instead of a template name.
+// start_position skips that many cells on the first sheet so part-used sheets can be reused.
+// Responds with a PDF.
+func GenerateLabelSheet(c *gin.Context) {
+	var req struct {
+		Template       string         `json:"template"`
+		CustomTemplate *labelTemplate `json:"custom_template"`
+		StartPosition  int            `json:"start_position"`
+		Items          []struct {
+			SKUID    uuid.UUID `json:"sku_id" binding:"required"`
+			Quantity int       `json:"quantity" binding:"required,gt=0"`
+		} `json:"items" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tmpl labelTemplate
+	if req.CustomTemplate != nil {
+		tmpl = *req.CustomTemplate
+		if tmpl.Name == "" {
+			tmpl.Name = "custom"
+		}
+	} else {
+		name := req.Template
+		if name == "" {
+			name = "a4-3x8"
+		}
+		t, ok := labelTemplates[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown label template", "template": name})
+			return
+		}
+		tmpl = t
+	}
+	perPage := tmpl.Columns * tmpl.Rows
+	if req.StartPosition < 0 || req.StartPosition >= perPage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("start_position must be between 0 and %d", perPage-1)})
+		return
+	}
+
+	total := 0
+	for _, item := range req.Items {
+		total += item.Quantity
+	}
+	if total > maxLabelsPerSheetRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d labels per request", maxLabelsPerSheetRequest)})
+		return
+	}
+
+	var labels []skuLabel
+	for _, item := range req.Items {
+		label, err := loadSKULabel(item.SKUID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "SKU not found", "sku_id": item.SKUID})
+			return
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to load label data for SKU %s: %v\n", item.SKUID, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "sku_id": item.SKUID})
+			return
+		}
+		for i := 0; i < item.Quantity; i++ {
+			labels = append(labels, *label)
+		}
+	}
+
+	pdf := utils.NewPDFDocument()
+	mm := utils.PointsPerMM
+	for i, label := range labels {
+		cell := i + req.StartPosition
+		if cell%perPage == 0 || i == 0 {
+			pdf.AddPage(tmpl.PageWidth*mm, tmpl.PageHeight*mm)
+		}
+		pos := cell % perPage
+		col, row := pos%tmpl.Columns, pos/tmpl.Columns
+		x := (tmpl.MarginLeft + float64(col)*(tmpl.LabelWidth+tmpl.GapX)) * mm
+		y := (tmpl.MarginTop + float64(row)*(tmpl.LabelHeight+tmpl.GapY)) * mm
+		drawSKULabel(pdf, label, x, y, tmpl.LabelWidth*mm, tmpl.LabelHeight*mm)
+	}
+
+	fmt.Printf("🏷️ Generated %d labels on template %s\n", len(labels), tmpl.Name)
+	filename := fmt.Sprintf("labels-%s.pdf", time.Now().Format("20060102-150405"))
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+}
+
+// loadSKULabel fetches the printable fields of a SKU. The barcode is the SKU's EAN-13
+// when it has a valid one, otherwise Code 128 of its EAN or SKU code.
+func loadSKULabel(skuID uuid.UUID) (*skuLabel, error) {
+	var label skuLabel
+	var ean, skuCode string
+	err := DB.QueryRow(`
+		SELECT pm.title, COALESCE(s.size, ''), COALESCE(pc.color_name, ''),
+		       COALESCE(s.ean, ''), COALESCE(s.sku_code, ''),
+		       COALESCE(p.effective_price, 0)
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		WHERE s.id = $1
+		LIMIT 1`, skuID).Scan(&label.Title, &label.Size, &label.Color, &ean, &skuCode, &label.Price)
+	if err != nil {
+		return nil, err
+	}
+
+	if normalized, err := utils.NormalizeBarcode(ean); err == nil && len(normalized) == 13 {
+		label.Barcode, err = utils.EncodeEAN13(normalized)
+		return &label, err
+	}
+	code := ean
+	if code == "" {
+		code = skuCode
+	}
+	if code == "" {
+		return nil, fmt.Errorf("SKU has neither an EAN nor a SKU code to print")
+	}
+	label.Barcode, err = utils.EncodeCode128(code)
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// drawSKULabel lays out one tag inside the cell at (x, y): title, size/color and price
+// on top, the barcode with its human-readable code filling the rest
+func drawSKULabel(pdf *utils.PDFDocument, label skuLabel, x, y, w, h float64) {
+	pad := math.Min(2*utils.PointsPerMM, h*0.06)
+	textSize := math.Max(6, math.Min(10, h*0.09))
+	detailSize := textSize * 0.85
+	innerW := w - 2*pad
+
+	cursor := y + pad + textSize
+	pdf.Text(x+pad, cursor, textSize, true, utils.FitText(label.Title, textSize, innerW))
+
+	details := label.Size
+	if label.Color != "" {
+		if details != "" {
+			details += " / "
+		}
+		details += label.Color
+	}
+	cursor += detailSize + 2
+	pdf.Text(x+pad, cursor, detailSize, false, utils.FitText(details, detailSize, innerW))
+
+	if label.Price > 0 {
+		cursor += textSize + 2
+		pdf.Text(x+pad, cursor, textSize, true, fmt.Sprintf("%.0f MRU", label.Price))
+	}
+
+	digitsSize := textSize * 0.8
+	barTop := cursor + pad
+	barHeight := (y + h - pad) - digitsSize - 1 - barTop
+	if barHeight <= 0 {
+		return
+	}
+	module := innerW / float64(label.Barcode.TotalModules())
+	barLeft := x + pad + float64(label.Barcode.QuietZone)*module
+	for _, run := range label.Barcode.Runs() {
+		pdf.Rect(barLeft+float64(run.Start)*module, barTop, float64(run.Width)*module, barHeight)
+	}
+	textWidth := utils.TextWidth(label.Barcode.Text, digitsSize)
+	pdf.Text(x+(w-textWidth)/2, y+h-pad, digitsSize, false, label.Barcode.Text)
+}
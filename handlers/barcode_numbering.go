@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"fmbq-server/config"
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// queryRower is satisfied by both the DB handle and a transaction
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// eanPrefix returns the prefix new EAN-13 codes are issued under: the GS1 company
+// prefix when configured, otherwise the in-store (restricted circulation) prefix
+func eanPrefix() (string, error) {
+	gs1, inStore := "", "20"
+	if config.AppConfig != nil {
+		gs1, inStore = config.AppConfig.GS1CompanyPrefix, config.AppConfig.InStoreEANPrefix
+	}
+	if gs1 != "" {
+		if _, err := strconv.ParseUint(gs1, 10, 64); err != nil || len(gs1) < 6 || len(gs1) > 11 {
+			return "", fmt.Errorf("GS1_COMPANY_PREFIX must be 6 to 11 digits")
+		}
+		return gs1, nil
+	}
+	if n, err := strconv.Atoi(inStore); err != nil || len(inStore) != 2 || n < 20 || n > 29 {
+		return "", fmt.Errorf("EAN_INSTORE_PREFIX must be between 20 and 29")
+	}
+	return inStore, nil
+}
+
+// allocateEAN issues the next EAN-13 from the item reference sequence. Numbers already
+// present in the barcode registry (e.g. imported codes) are skipped; the registry's
+// primary key still rejects any race at insert time.
+func allocateEAN(q queryRower) (string, error) {
+	prefix, err := eanPrefix()
+	if err != nil {
+		return "", err
+	}
+	for attempt := 0; attempt < 50; attempt++ {
+		var next int64
+		if err := q.QueryRow(`SELECT nextval('ean_item_reference_seq')`).Scan(&next); err != nil {
+			return "", fmt.Errorf("failed to draw EAN sequence: %w", err)
+		}
+		code, err := utils.BuildEAN13(prefix, next)
+		if err != nil {
+			return "", fmt.Errorf("EAN range for prefix %s is exhausted: %w", prefix, err)
+		}
+		var taken bool
+		if err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM barcode_registry WHERE ean = $1)`, code).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("could not find a free EAN after 50 attempts")
+}
+
+// AdminValidateBarcode handles POST /api/v1/admin/barcodes/validate
+// Body: {"code": "..."}; reports whether the code is a valid EAN-8, UPC-A or EAN-13,
+// its normalised form and which item (if any) already carries it
+func AdminValidateBarcode(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	normalized, err := utils.NormalizeBarcode(req.Code)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"valid": false, "code": req.Code, "error": err.Error()})
+		return
+	}
+
+	format := "EAN-13"
+	if len(normalized) == 8 {
+		format = "EAN-8"
+	}
+	resp := gin.H{"valid": true, "code": req.Code, "normalized": normalized, "format": format, "in_use": false}
+
+	var itemType, itemID string
+	err = DB.QueryRow(`SELECT item_type, item_id FROM barcode_registry WHERE ean = $1`, normalized).Scan(&itemType, &itemID)
+	if err == nil {
+		resp["in_use"] = true
+		resp["item_type"] = itemType
+		resp["item_id"] = itemID
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AdminAllocateBarcode handles POST /api/v1/admin/barcodes/allocate
+// Draws the next EAN-13 number from the sequence, e.g. for printing before the item
+// is created. Nothing is registered until an item is saved with it; the sequence
+// never hands the same number out twice.
+func AdminAllocateBarcode(c *gin.Context) {
+	code, err := allocateEAN(DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ean": code})
+}
+
+// barcodeCatalogs lists the tables that carry an ean column, keyed by registry item type
+var barcodeCatalogs = []struct {
+	ItemType string
+	Table    string
+}{
+	{"sku", "skus"},
+	{"melhaf_color", "melhaf_colors"},
+	{"perfume", "maison_adrar_perfumes"},
+}
+
+// AdminReissueInvalidBarcodes handles POST /api/v1/admin/barcodes/reissue
+// Finds codes with a bad length or check digit, and duplicates that lost the
+// registry to another item, and gives them fresh EAN-13s. Valid UPC-A codes are
+// widened to EAN-13 rather than replaced. Runs as a dry run unless apply=true.
+// Pass include_missing=true to also number items without any code.
+func AdminReissueInvalidBarcodes(c *gin.Context) {
+	apply := c.Query("apply") == "true"
+	includeMissing := c.Query("include_missing") == "true"
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	type reissue struct {
+		ItemType string `json:"item_type"`
+		ItemID   string `json:"item_id"`
+		OldEAN   string `json:"old_ean"`
+		NewEAN   string `json:"new_ean,omitempty"`
+		Reason   string `json:"reason"`
+	}
+	var changes []reissue
+
+	for _, catalog := range barcodeCatalogs {
+		rows, err := tx.Query(`
+			SELECT t.id, COALESCE(t.ean, ''), r.item_id IS NOT NULL AND r.item_id = t.id
+			FROM ` + catalog.Table + ` t
+			LEFT JOIN barcode_registry r ON r.ean = t.ean
+			ORDER BY t.created_at`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan " + catalog.Table})
+			return
+		}
+		var pending []reissue
+		for rows.Next() {
+			var id uuid.UUID
+			var ean string
+			var owner bool
+			if err := rows.Scan(&id, &ean, &owner); err != nil {
+				continue
+			}
+			r := reissue{ItemType: catalog.ItemType, ItemID: id.String(), OldEAN: ean}
+			switch {
+			case ean == "":
+				if !includeMissing {
+					continue
+				}
+				r.Reason = "missing"
+			case len(ean) == 12 && utils.IsValidGTIN(ean):
+				r.Reason = "upc_a"
+				r.NewEAN = "0" + ean
+			case !utils.IsValidGTIN(ean):
+				r.Reason = "invalid"
+			case !owner:
+				r.Reason = "duplicate"
+			default:
+				continue
+			}
+			pending = append(pending, r)
+		}
+		rows.Close()
+		changes = append(changes, pending...)
+	}
+
+	if apply {
+		for i := range changes {
+			ch := &changes[i]
+			if ch.NewEAN != "" {
+				var taken bool
+				if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM barcode_registry WHERE ean = $1 AND item_id::text <> $2)`, ch.NewEAN, ch.ItemID).Scan(&taken); err != nil || taken {
+					ch.NewEAN = ""
+				}
+			}
+			if ch.NewEAN == "" {
+				code, err := allocateEAN(tx)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				ch.NewEAN = code
+			}
+			table := ""
+			for _, catalog := range barcodeCatalogs {
+				if catalog.ItemType == ch.ItemType {
+					table = catalog.Table
+				}
+			}
+			if _, err := tx.Exec(`UPDATE `+table+` SET ean = $1 WHERE id::text = $2`, ch.NewEAN, ch.ItemID); err != nil {
+				fmt.Printf("❌ Failed to reissue EAN for %s %s: %v\n", ch.ItemType, ch.ItemID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reissue EAN", "item_id": ch.ItemID})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit reissued EANs"})
+			return
+		}
+		fmt.Printf("✅ Reissued %d EAN codes\n", len(changes))
+	}
+
+	if changes == nil {
+		changes = []reissue{}
+	}
+	c.JSON(http.StatusOK, gin.H{"dry_run": !apply, "count": len(changes), "changes": changes})
+}
@@ -0,0 +1,563 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// bundleItemTypes are the sellable items a bundle can be made of
+var bundleItemTypes = map[string]bool{"sku": true, "melhaf_color": true, "perfume_variant": true}
+
+// bundleComponentView is a bundle component with the item it points at
+type bundleComponentView struct {
+	ID        uuid.UUID `json:"id"`
+	ItemType  string    `json:"item_type"`
+	ItemID    uuid.UUID `json:"item_id"`
+	Quantity  int       `json:"quantity"`
+	SortOrder int       `json:"sort_order"`
+	Item      *sellable `json:"item"` // nil when the item no longer exists
+	Available int       `json:"available"`
+}
+
+// bundleView is a bundle with its components, price and availability worked out
+type bundleView struct {
+	models.Bundle
+	ComponentsTotal float64               `json:"components_total"`
+	BundlePrice     float64               `json:"bundle_price"`
+	Savings         float64               `json:"savings"`
+	Available       int                   `json:"available"`
+	Components      []bundleComponentView `json:"components"`
+}
+
+// bundleStockError is returned by sellBundle when a component is short
+type bundleStockError struct {
+	Component string
+	Available int
+	Requested int
+}
+
+func (e *bundleStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for %s: %d available, %d requested", e.Component, e.Available, e.Requested)
+}
+
+var errBundleUnavailable = errors.New("bundle is not available")
+
+var errBundleQuantity = errors.New("bundle quantity must be at least 1")
+
+const bundleColumns = `id, name, name_ar, description, image_url, pricing_mode, price, discount_percent,
+	currency, is_active, sort_order, created_at, updated_at`
+
+func scanBundle(row interface{ Scan(...interface{}) error }) (*bundleView, error) {
+	var b bundleView
+	if err := row.Scan(&b.ID, &b.Name, &b.NameAr, &b.Description, &b.ImageURL, &b.PricingMode, &b.Price,
+		&b.DiscountPercent, &b.Currency, &b.IsActive, &b.SortOrder, &b.CreatedAt, &b.UpdatedAt); err != nil {
+		return nil, err
+	}
+	b.Currency = strings.TrimSpace(b.Currency)
+	return &b, nil
+}
+
+func roundPrice(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// loadBundle reads a bundle and prices it from its components' current prices.
+// Availability is the number of complete bundles the component stock allows; a
+// component whose item is gone makes the bundle unavailable.
+func loadBundle(id interface{}) (*bundleView, error) {
+	b, err := scanBundle(DB.QueryRow(`SELECT `+bundleColumns+` FROM bundles WHERE id::text = $1`, fmt.Sprint(id)))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT id, item_type, item_id, quantity, sort_order
+		FROM bundle_components WHERE bundle_id = $1
+		ORDER BY sort_order, id`, b.ID)
+	if err != nil {
+		return nil, err
+	}
+	b.Components = []bundleComponentView{}
+	for rows.Next() {
+		var comp bundleComponentView
+		if err := rows.Scan(&comp.ID, &comp.ItemType, &comp.ItemID, &comp.Quantity, &comp.SortOrder); err == nil {
+			b.Components = append(b.Components, comp)
+		}
+	}
+	rows.Close()
+
+	b.Available = -1
+	for i := range b.Components {
+		comp := &b.Components[i]
+		if item, err := loadSellable(comp.ItemType, comp.ItemID); err == nil {
+			comp.Item = item
+			comp.Available = item.Stock / comp.Quantity
+			b.ComponentsTotal += item.Price * float64(comp.Quantity)
+		}
+		if b.Available < 0 || comp.Available < b.Available {
+			b.Available = comp.Available
+		}
+	}
+	if b.Available < 0 {
+		b.Available = 0
+	}
+	b.ComponentsTotal = roundPrice(b.ComponentsTotal)
+
+	switch b.PricingMode {
+	case "percent_off":
+		if b.DiscountPercent != nil {
+			b.BundlePrice = roundPrice(discountedPrice(b.ComponentsTotal, *b.DiscountPercent))
+		}
+	default:
+		if b.Price != nil {
+			b.BundlePrice = *b.Price
+		}
+	}
+	if b.ComponentsTotal > b.BundlePrice {
+		b.Savings = roundPrice(b.ComponentsTotal - b.BundlePrice)
+	}
+	return b, nil
+}
+
+// sellBundle takes quantity bundles out of stock inside tx: every component is
+// decremented and costed, and the order gets one bundle line plus the components
+// to pick. A unitPrice of 0 sells at the current bundle price.
+func sellBundle(tx *sql.Tx, bundleID uuid.UUID, quantity int, unitPrice float64, orderID uuid.UUID, createdBy string) (*models.OrderBundleItem, error) {
+	if quantity <= 0 {
+		return nil, errBundleQuantity
+	}
+	b, err := loadBundle(bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if !b.IsActive || len(b.Components) == 0 || b.BundlePrice <= 0 {
+		return nil, errBundleUnavailable
+	}
+
+	line := &models.OrderBundleItem{
+		ID:        uuid.New(),
+		BundleID:  b.ID,
+		Name:      b.Name,
+		ImageURL:  b.ImageURL,
+		Quantity:  quantity,
+		UnitPrice: b.BundlePrice,
+	}
+	if unitPrice > 0 {
+		line.UnitPrice = unitPrice
+	}
+	line.TotalPrice = roundPrice(line.UnitPrice * float64(quantity))
+
+	unitCost := 0.0
+	for _, comp := range b.Components {
+		if comp.Item == nil {
+			return nil, errBundleUnavailable
+		}
+		need := comp.Quantity * quantity
+		before, _, err := applyStockChange(tx, comp.ItemType, comp.ItemID, -need, "sale", "order", &orderID, createdBy)
+		if err != nil {
+			return nil, err
+		}
+		if before < need {
+			return nil, &bundleStockError{Component: strings.TrimSpace(comp.Item.Title + " " + comp.Item.Variant), Available: before, Requested: need}
+		}
+		cost, err := consumeItemCost(tx, comp.ItemType, comp.ItemID, need)
+		if err != nil {
+			return nil, err
+		}
+		unitCost += cost * float64(comp.Quantity)
+		line.Components = append(line.Components, models.OrderItemComponent{
+			ID:          uuid.New(),
+			OrderItemID: line.ID,
+			ItemType:    comp.ItemType,
+			ItemID:      comp.ItemID,
+			Name:        comp.Item.Title,
+			Variant:     comp.Item.Variant,
+			SKUCode:     comp.Item.SKUCode,
+			Quantity:    need,
+			UnitCost:    cost,
+		})
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO order_items (id, order_id, product_id, sku_id, quantity, unit_price, total_price, color, item_type, item_id, unit_cost, created_at)
+		VALUES ($1, $2, NULL, NULL, $3, $4, $5, $6, 'bundle', $7, $8, now())`,
+		line.ID, orderID, quantity, line.UnitPrice, line.TotalPrice, b.Name, b.ID, unitCost); err != nil {
+		return nil, err
+	}
+	for _, comp := range line.Components {
+		if _, err := tx.Exec(`
+			INSERT INTO order_item_components (id, order_item_id, item_type, item_id, name, variant, sku_code, quantity, unit_cost)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			comp.ID, line.ID, comp.ItemType, comp.ItemID, comp.Name, comp.Variant, comp.SKUCode, comp.Quantity, comp.UnitCost); err != nil {
+			return nil, err
+		}
+	}
+	return line, nil
+}
+
+// bundleSaleErrorResponse turns a sellBundle error into the response for the client
+func bundleSaleErrorResponse(c *gin.Context, bundleID string, err error) {
+	var stockErr *bundleStockError
+	switch {
+	case errors.As(err, &stockErr):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "Insufficient stock for bundle component " + stockErr.Component,
+			"bundle_id": bundleID,
+			"available": stockErr.Available,
+			"requested": stockErr.Requested,
+		})
+	case err == errBundleQuantity:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bundle quantity must be at least 1", "bundle_id": bundleID})
+	case err == sql.ErrNoRows || err == errBundleUnavailable:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bundle not found or unavailable", "bundle_id": bundleID})
+	default:
+		fmt.Printf("❌ Failed to sell bundle %s: %v\n", bundleID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle order item"})
+	}
+}
+
+// orderBundleItems returns the bundle lines of an order with their components
+func orderBundleItems(orderID interface{}) ([]models.OrderBundleItem, error) {
+	rows, err := DB.Query(`
+		SELECT oi.id, oi.item_id, COALESCE(b.name, oi.color, ''), b.image_url, oi.quantity, oi.unit_price, oi.total_price
+		FROM order_items oi
+		LEFT JOIN bundles b ON b.id = oi.item_id
+		WHERE oi.order_id = $1 AND oi.item_type = 'bundle'
+		ORDER BY oi.created_at`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	lines := []models.OrderBundleItem{}
+	index := map[uuid.UUID]int{}
+	for rows.Next() {
+		var l models.OrderBundleItem
+		if err := rows.Scan(&l.ID, &l.BundleID, &l.Name, &l.ImageURL, &l.Quantity, &l.UnitPrice, &l.TotalPrice); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		l.Components = []models.OrderItemComponent{}
+		index[l.ID] = len(lines)
+		lines = append(lines, l)
+	}
+	rows.Close()
+	if len(lines) == 0 {
+		return lines, nil
+	}
+
+	rows, err = DB.Query(`
+		SELECT oic.id, oic.order_item_id, oic.item_type, oic.item_id, oic.name, oic.variant, oic.sku_code, oic.quantity, oic.unit_cost
+		FROM order_item_components oic
+		JOIN order_items oi ON oi.id = oic.order_item_id
+		WHERE oi.order_id = $1
+		ORDER BY oic.name, oic.variant`, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var comp models.OrderItemComponent
+		if err := rows.Scan(&comp.ID, &comp.OrderItemID, &comp.ItemType, &comp.ItemID, &comp.Name, &comp.Variant,
+			&comp.SKUCode, &comp.Quantity, &comp.UnitCost); err != nil {
+			continue
+		}
+		if i, ok := index[comp.OrderItemID]; ok {
+			lines[i].Components = append(lines[i].Components, comp)
+		}
+	}
+	return lines, nil
+}
+
+type bundleComponentRequest struct {
+	ItemType string    `json:"item_type" binding:"required"`
+	ItemID   uuid.UUID `json:"item_id" binding:"required"`
+	Quantity int       `json:"quantity"`
+}
+
+type bundleRequest struct {
+	Name            string                   `json:"name" binding:"required"`
+	NameAr          *string                  `json:"name_ar"`
+	Description     *string                  `json:"description"`
+	ImageURL        *string                  `json:"image_url"`
+	PricingMode     string                   `json:"pricing_mode"`
+	Price           *float64                 `json:"price"`
+	DiscountPercent *float64                 `json:"discount_percent"`
+	Currency        string                   `json:"currency"`
+	IsActive        *bool                    `json:"is_active"`
+	SortOrder       int                      `json:"sort_order"`
+	Components      []bundleComponentRequest `json:"components" binding:"required"`
+}
+
+// validate normalises the request and returns a message for the client when it is invalid
+func (req *bundleRequest) validate() string {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = "MRO"
+	}
+	if req.PricingMode == "" {
+		req.PricingMode = "fixed"
+	}
+	switch req.PricingMode {
+	case "fixed":
+		if req.Price == nil || *req.Price <= 0 {
+			return "fixed bundles need a price"
+		}
+		req.DiscountPercent = nil
+	case "percent_off":
+		if req.DiscountPercent == nil || *req.DiscountPercent <= 0 || *req.DiscountPercent >= 100 {
+			return "discount_percent must be between 0 and 100"
+		}
+		req.Price = nil
+	default:
+		return "pricing_mode must be fixed or percent_off"
+	}
+	if len(req.Components) == 0 {
+		return "A bundle needs at least one component"
+	}
+	seen := map[string]bool{}
+	for i := range req.Components {
+		comp := &req.Components[i]
+		if !bundleItemTypes[comp.ItemType] {
+			return "Unknown component item_type " + comp.ItemType
+		}
+		if comp.Quantity == 0 {
+			comp.Quantity = 1
+		}
+		if comp.Quantity < 0 {
+			return "Component quantity must be positive"
+		}
+		key := comp.ItemType + ":" + comp.ItemID.String()
+		if seen[key] {
+			return "Component listed twice: " + key
+		}
+		seen[key] = true
+		if _, err := loadSellable(comp.ItemType, comp.ItemID); err != nil {
+			return "Component not found: " + key
+		}
+	}
+	return ""
+}
+
+func saveBundleComponents(tx *sql.Tx, bundleID uuid.UUID, components []bundleComponentRequest) error {
+	if _, err := tx.Exec(`DELETE FROM bundle_components WHERE bundle_id = $1`, bundleID); err != nil {
+		return err
+	}
+	for i, comp := range components {
+		if _, err := tx.Exec(`
+			INSERT INTO bundle_components (id, bundle_id, item_type, item_id, quantity, sort_order)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)`,
+			bundleID, comp.ItemType, comp.ItemID, comp.Quantity, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listBundles(activeOnly bool) ([]*bundleView, error) {
+	query := `SELECT id FROM bundles`
+	if activeOnly {
+		query += ` WHERE is_active = true`
+	}
+	rows, err := DB.Query(query + ` ORDER BY sort_order, created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	bundles := []*bundleView{}
+	for _, id := range ids {
+		if b, err := loadBundle(id); err == nil {
+			bundles = append(bundles, b)
+		}
+	}
+	return bundles, nil
+}
+
+// GetBundles handles GET /api/v1/bundles
+// Active bundles with their price and availability; ?in_stock=true hides sold-out ones.
+func GetBundles(c *gin.Context) {
+	bundles, err := listBundles(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundles"})
+		return
+	}
+	if c.Query("in_stock") == "true" {
+		inStock := []*bundleView{}
+		for _, b := range bundles {
+			if b.Available > 0 {
+				inStock = append(inStock, b)
+			}
+		}
+		bundles = inStock
+	}
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// GetBundle handles GET /api/v1/bundles/:id
+func GetBundle(c *gin.Context) {
+	b, err := loadBundle(c.Param("id"))
+	if err == sql.ErrNoRows || (err == nil && !b.IsActive) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bundle": b})
+}
+
+// AdminListBundles handles GET /api/v1/admin/bundles
+func AdminListBundles(c *gin.Context) {
+	bundles, err := listBundles(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bundles": bundles})
+}
+
+// AdminGetBundle handles GET /api/v1/admin/bundles/:id
+func AdminGetBundle(c *gin.Context) {
+	b, err := loadBundle(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bundle"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"bundle": b})
+}
+
+// AdminCreateBundle handles POST /api/v1/admin/bundles
+func AdminCreateBundle(c *gin.Context) {
+	var req bundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	bundleID := uuid.New()
+	now := time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO bundles (id, name, name_ar, description, image_url, pricing_mode, price, discount_percent, currency, is_active, sort_order, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)`,
+		bundleID, req.Name, req.NameAr, req.Description, req.ImageURL, req.PricingMode, req.Price, req.DiscountPercent,
+		req.Currency, isActive, req.SortOrder, now); err != nil {
+		fmt.Printf("❌ Failed to create bundle: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle"})
+		return
+	}
+	if err := saveBundleComponents(tx, bundleID, req.Components); err != nil {
+		fmt.Printf("❌ Failed to save bundle components: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save bundle components"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create bundle"})
+		return
+	}
+
+	b, _ := loadBundle(bundleID)
+	c.JSON(http.StatusCreated, gin.H{"bundle": b})
+}
+
+// AdminUpdateBundle handles PUT /api/v1/admin/bundles/:id
+// Replaces the bundle, components included.
+func AdminUpdateBundle(c *gin.Context) {
+	var req bundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var bundleID uuid.UUID
+	err = tx.QueryRow(`
+		UPDATE bundles SET name = $2, name_ar = $3, description = $4, image_url = $5, pricing_mode = $6, price = $7,
+		       discount_percent = $8, currency = $9, is_active = COALESCE($10, is_active), sort_order = $11, updated_at = now()
+		WHERE id::text = $1
+		RETURNING id`,
+		c.Param("id"), req.Name, req.NameAr, req.Description, req.ImageURL, req.PricingMode, req.Price,
+		req.DiscountPercent, req.Currency, req.IsActive, req.SortOrder).Scan(&bundleID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to update bundle %s: %v\n", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+	if err := saveBundleComponents(tx, bundleID, req.Components); err != nil {
+		fmt.Printf("❌ Failed to save bundle components: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save bundle components"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bundle"})
+		return
+	}
+
+	b, _ := loadBundle(bundleID)
+	c.JSON(http.StatusOK, gin.H{"bundle": b})
+}
+
+// AdminDeleteBundle handles DELETE /api/v1/admin/bundles/:id
+// Orders keep their bundle lines and components.
+func AdminDeleteBundle(c *gin.Context) {
+	res, err := DB.Exec(`DELETE FROM bundles WHERE id::text = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete bundle"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bundle not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bundle deleted"})
+}
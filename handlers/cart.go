@@ -65,7 +65,7 @@ func GetCart(c *gin.Context) {
 		JOIN product_models pm ON s.product_model_id = pm.id
 		JOIN product_colors pc ON s.product_color_id = pc.id
 		JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN prices p ON s.id = p.sku_id AND p.currency = 'MRO'
+		LEFT JOIN active_prices p ON s.id = p.sku_id AND p.currency = 'MRO'
 		LEFT JOIN inventory i ON s.id = i.sku_id
 		WHERE ci.cart_id = $1
 		ORDER BY ci.added_at DESC
@@ -223,7 +223,7 @@ func AddToCart(c *gin.Context) {
 			COALESCE(p.sale_price, p.list_price, 0) as price
 		FROM skus s
 		JOIN product_models pm ON s.product_model_id = pm.id
-		LEFT JOIN prices p ON s.id = p.sku_id AND p.currency = 'MRO'
+		LEFT JOIN active_prices p ON s.id = p.sku_id AND p.currency = 'MRO'
 		WHERE s.id = $1
 	`
 	err = DB.QueryRow(metadataQuery, req.SKUID).Scan(&productName, &productImageURL, &productPrice)
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// AdminExportCatalog handles GET /api/v1/admin/catalog/export?format=csv|xlsx
+// Writes the whole catalog one row per SKU in the import layout, so the file can be
+// edited and imported back
+func AdminExportCatalog(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+
+	categoryPaths, err := loadCategoryPaths()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load categories"})
+		return
+	}
+
+	rows, err := DB.Query(`
+		SELECT COALESCE(pm.model_code, ''), pm.title, COALESCE(b.name, ''),
+		       (SELECT pmc.category_id::text FROM product_model_categories pmc
+		        WHERE pmc.product_model_id = pm.id LIMIT 1),
+		       COALESCE(pm.description, ''), COALESCE(pc.color_name, ''), COALESCE(pc.color_code, ''),
+		       COALESCE(s.size, ''), s.sku_code, COALESCE(s.ean, ''),
+		       COALESCE(p.list_price, 0), COALESCE(p.sale_price, 0), COALESCE(i.available, 0),
+		       COALESCE((SELECT string_agg(img.url, ' | ' ORDER BY img.position)
+		                 FROM product_images img
+		                 WHERE img.product_model_id = pm.id AND img.product_color_id = s.product_color_id), '')
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN LATERAL (
+			SELECT list_price, sale_price FROM prices
+			WHERE sku_id = s.id AND currency = 'MRO' AND start_at IS NULL AND end_at IS NULL
+			ORDER BY created_at DESC LIMIT 1
+		) p ON true
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		ORDER BY pm.title, pm.id, pc.color_name, s.size_normalized, s.size`)
+	if err != nil {
+		fmt.Printf("❌ Catalog export query failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export catalog"})
+		return
+	}
+	defer rows.Close()
+
+	table := [][]string{catalogColumns}
+	for rows.Next() {
+		var modelCode, title, brand, description, color, colorCode, size, skuCode, ean, images string
+		var categoryID *string
+		var listPrice, salePrice float64
+		var stock int
+		if err := rows.Scan(&modelCode, &title, &brand, &categoryID, &description, &color, &colorCode,
+			&size, &skuCode, &ean, &listPrice, &salePrice, &stock, &images); err != nil {
+			continue
+		}
+		categoryPath := ""
+		if categoryID != nil {
+			categoryPath = categoryPaths[*categoryID]
+		}
+		sale := ""
+		if salePrice > 0 && salePrice < listPrice {
+			sale = formatSheetNumber(salePrice)
+		}
+		table = append(table, []string{
+			modelCode, title, brand, categoryPath, description,
+			color, colorCode, size, skuCode, ean,
+			formatSheetNumber(listPrice), sale, strconv.Itoa(stock), images,
+		})
+	}
+
+	filename := "catalog-" + time.Now().Format("20060102")
+	var data []byte
+	var contentType string
+	if format == "xlsx" {
+		data, err = utils.WriteXLSX("Catalog", table)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	} else {
+		data, err = utils.WriteCSV(table)
+		contentType = "text/csv; charset=utf-8"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build export file"})
+		return
+	}
+
+	fmt.Printf("📤 Exported %d catalog rows as %s\n", len(table)-1, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", filename, format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// loadCategoryPaths builds the "Parent > Child" path of every category, keyed by ID
+func loadCategoryPaths() (map[string]string, error) {
+	rows, err := DB.Query(`SELECT id, name, parent_id FROM categories`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type node struct {
+		name   string
+		parent *uuid.UUID
+	}
+	nodes := map[uuid.UUID]node{}
+	for rows.Next() {
+		var id uuid.UUID
+		var n node
+		if err := rows.Scan(&id, &n.name, &n.parent); err != nil {
+			continue
+		}
+		nodes[id] = n
+	}
+
+	paths := map[string]string{}
+	for id := range nodes {
+		var names []string
+		seen := map[uuid.UUID]bool{}
+		for cur := &id; cur != nil && !seen[*cur]; {
+			n, ok := nodes[*cur]
+			if !ok {
+				break
+			}
+			seen[*cur] = true
+			names = append([]string{n.name}, names...)
+			cur = n.parent
+		}
+		paths[id.String()] = strings.Join(names, " > ")
+	}
+	return paths, nil
+}
+
+func formatSheetNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,810 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxCatalogImportBytes = 10 << 20
+	maxCatalogImportRows  = 20000
+)
+
+// catalogColumns is the spreadsheet layout shared by import and export: one row per SKU
+var catalogColumns = []string{
+	"model_code", "title", "brand", "category_path", "description",
+	"color", "color_code", "size", "sku_code", "ean",
+	"list_price", "sale_price", "stock", "image_urls",
+}
+
+// catalogRow is one parsed spreadsheet row. Line is the spreadsheet row number.
+type catalogRow struct {
+	Line         int      `json:"line"`
+	ModelKey     string   `json:"model_key"`
+	ModelCode    string   `json:"model_code"`
+	Title        string   `json:"title"`
+	Brand        string   `json:"brand"`
+	CategoryPath string   `json:"category_path"`
+	Description  string   `json:"description"`
+	Color        string   `json:"color"`
+	ColorCode    string   `json:"color_code"`
+	Size         string   `json:"size"`
+	SKUCode      string   `json:"sku_code"`
+	EAN          string   `json:"ean"`
+	ListPrice    float64  `json:"list_price"`
+	SalePrice    *float64 `json:"sale_price"`
+	Stock        *int     `json:"stock"`
+	ImageURLs    []string `json:"image_urls"`
+}
+
+type catalogRowError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type catalogRowPlan struct {
+	Line        int    `json:"line"`
+	ModelCode   string `json:"model_code"`
+	SKUCode     string `json:"sku_code"`
+	ModelAction string `json:"model_action"` // create, update
+	SKUAction   string `json:"sku_action"`   // create, update
+}
+
+// AdminImportCatalog handles POST /api/v1/admin/catalog/import
+// Multipart field "file" holds a CSV or XLSX sheet laid out as catalogColumns.
+// Runs as a dry run (validation and plan only) unless dry_run=false, in which case
+// the rows are applied by a background job; poll /catalog/import/jobs/:id for progress.
+// With skip_invalid=true rows with errors are left out instead of blocking the import.
+func AdminImportCatalog(c *gin.Context) {
+	dryRun := c.DefaultQuery("dry_run", "true") != "false"
+	skipInvalid := c.Query("skip_invalid") == "true"
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+		return
+	}
+	if file.Size > maxCatalogImportBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File is larger than %d MB", maxCatalogImportBytes>>20)})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	data, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	var table [][]string
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".xlsx":
+		table, err = utils.ReadXLSX(data)
+	case ".csv", ".txt":
+		table, err = utils.ReadCSV(data)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only .csv and .xlsx files are supported"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows, rowErrors := parseCatalogRows(table)
+	if len(rows) > maxCatalogImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d rows per import", maxCatalogImportRows)})
+		return
+	}
+	plan, validationErrors := planCatalogImport(rows)
+	rowErrors = append(rowErrors, validationErrors...)
+
+	invalid := map[int]bool{}
+	for _, e := range rowErrors {
+		invalid[e.Line] = true
+	}
+	var valid []catalogRow
+	summary := map[string]int{"models_to_create": 0, "models_to_update": 0, "skus_to_create": 0, "skus_to_update": 0}
+	seenModels := map[string]bool{}
+	for i, row := range rows {
+		if invalid[row.Line] {
+			continue
+		}
+		valid = append(valid, row)
+		if !seenModels[row.ModelKey] {
+			seenModels[row.ModelKey] = true
+			summary["models_to_"+plan[i].ModelAction]++
+		}
+		summary["skus_to_"+plan[i].SKUAction]++
+	}
+
+	if rowErrors == nil {
+		rowErrors = []catalogRowError{}
+	}
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":    true,
+			"total_rows": countDataRows(table),
+			"valid_rows": len(valid),
+			"summary":    summary,
+			"plan":       plan,
+			"errors":     rowErrors,
+		})
+		return
+	}
+
+	if len(rowErrors) > 0 && !skipInvalid {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "The file has invalid rows; fix them or pass skip_invalid=true",
+			"errors": rowErrors,
+		})
+		return
+	}
+	if len(valid) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No valid rows to import", "errors": rowErrors})
+		return
+	}
+
+	rowsJSON, _ := json.Marshal(valid)
+	errorsJSON, _ := json.Marshal(rowErrors)
+	jobID := uuid.New()
+	userID := c.GetString("user_id")
+	_, err = DB.Exec(`
+		INSERT INTO catalog_import_jobs (id, filename, status, total_rows, error_count, rows, errors, created_by, created_at)
+		VALUES ($1, $2, 'pending', $3, $4, $5, $6, NULLIF($7, '')::uuid, now())`,
+		jobID, file.Filename, len(valid), len(rowErrors), string(rowsJSON), string(errorsJSON), userID)
+	if err != nil {
+		fmt.Printf("❌ Failed to create catalog import job: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create import job"})
+		return
+	}
+
+	go runCatalogImport(jobID, userID)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":      "Import started",
+		"job_id":       jobID,
+		"total_rows":   len(valid),
+		"skipped_rows": len(invalid),
+		"summary":      summary,
+	})
+}
+
+// countDataRows counts the non-blank rows below the header
+func countDataRows(table [][]string) int {
+	n := 0
+	for i, record := range table {
+		if i > 0 && strings.TrimSpace(strings.Join(record, "")) != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// parseCatalogRows maps the sheet onto catalogRows using its header row. Column names
+// are matched case-insensitively; blank rows are skipped.
+func parseCatalogRows(table [][]string) ([]catalogRow, []catalogRowError) {
+	if len(table) == 0 {
+		return nil, []catalogRowError{{Line: 1, Message: "file is empty"}}
+	}
+	index := map[string]int{}
+	for i, name := range table[0] {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var errs []catalogRowError
+	for _, required := range []string{"brand", "color", "list_price"} {
+		if _, ok := index[required]; !ok {
+			errs = append(errs, catalogRowError{Line: 1, Field: required, Message: "missing column"})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var rows []catalogRow
+	for n, record := range table[1:] {
+		line := n + 2
+		get := func(col string) string {
+			i, ok := index[col]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := catalogRow{
+			Line:         line,
+			ModelCode:    get("model_code"),
+			Title:        get("title"),
+			Brand:        get("brand"),
+			CategoryPath: get("category_path"),
+			Description:  get("description"),
+			Color:        get("color"),
+			ColorCode:    get("color_code"),
+			Size:         get("size"),
+			SKUCode:      get("sku_code"),
+			EAN:          get("ean"),
+		}
+		rowOK := true
+		fail := func(field, message string) {
+			errs = append(errs, catalogRowError{Line: line, Field: field, Message: message})
+			rowOK = false
+		}
+
+		if row.Brand == "" {
+			fail("brand", "brand is required")
+		}
+		if row.Color == "" {
+			fail("color", "color is required")
+		}
+		if row.Size == "" {
+			row.Size = "One Size"
+		}
+		if price, err := parseSheetNumber(get("list_price")); err != nil || price <= 0 {
+			fail("list_price", "list_price must be a positive number")
+		} else {
+			row.ListPrice = price
+		}
+		if v := get("sale_price"); v != "" {
+			price, err := parseSheetNumber(v)
+			if err != nil || price <= 0 {
+				fail("sale_price", "sale_price must be a positive number")
+			} else if price > row.ListPrice {
+				fail("sale_price", "sale_price is higher than list_price")
+			} else {
+				row.SalePrice = &price
+			}
+		}
+		if v := get("stock"); v != "" {
+			stock, err := strconv.Atoi(strings.TrimSuffix(v, ".0"))
+			if err != nil || stock < 0 {
+				fail("stock", "stock must be a whole number of 0 or more")
+			} else {
+				row.Stock = &stock
+			}
+		}
+		if row.EAN != "" {
+			normalized, err := utils.NormalizeBarcode(row.EAN)
+			if err != nil {
+				fail("ean", err.Error())
+			} else {
+				row.EAN = normalized
+			}
+		}
+		for _, u := range strings.FieldsFunc(get("image_urls"), func(r rune) bool { return r == '|' || r == '\n' || r == ' ' }) {
+			if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				fail("image_urls", "image URL must start with http:// or https://: "+u)
+				continue
+			}
+			if strings.HasPrefix(u, "http://") {
+				u = "https://" + u[7:]
+			}
+			row.ImageURLs = append(row.ImageURLs, u)
+		}
+
+		if rowOK {
+			rows = append(rows, row)
+		}
+	}
+	return rows, errs
+}
+
+// parseSheetNumber accepts both "1234.5" and the "1234,5" decimal comma
+func parseSheetNumber(v string) (float64, error) {
+	v = strings.ReplaceAll(strings.TrimSpace(v), " ", "")
+	if strings.Count(v, ",") == 1 && !strings.Contains(v, ".") {
+		v = strings.Replace(v, ",", ".", 1)
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// catalogResolver looks up the existing catalog for an import. It only reads, so
+// the same code plans a dry run against the DB and applies rows inside a transaction.
+type catalogResolver struct {
+	q          queryRower
+	brands     map[string]uuid.UUID
+	categories map[string]uuid.UUID
+}
+
+func newCatalogResolver(q queryRower) *catalogResolver {
+	return &catalogResolver{q: q, brands: map[string]uuid.UUID{}, categories: map[string]uuid.UUID{}}
+}
+
+func (r *catalogResolver) brandID(name string) (uuid.UUID, error) {
+	key := strings.ToLower(name)
+	if id, ok := r.brands[key]; ok {
+		return id, nil
+	}
+	var id uuid.UUID
+	err := r.q.QueryRow(`SELECT id FROM brands WHERE LOWER(name) = $1 ORDER BY created_at LIMIT 1`, key).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, fmt.Errorf("unknown brand %q", name)
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	r.brands[key] = id
+	return id, nil
+}
+
+// categoryID walks a "Women > Dresses > Maxi" path from the root categories down
+func (r *catalogResolver) categoryID(path string) (uuid.UUID, error) {
+	key := strings.ToLower(path)
+	if id, ok := r.categories[key]; ok {
+		return id, nil
+	}
+	var parent *uuid.UUID
+	var id uuid.UUID
+	for _, name := range strings.Split(path, ">") {
+		name = strings.TrimSpace(name)
+		err := r.q.QueryRow(`
+			SELECT id FROM categories
+			WHERE LOWER(name) = LOWER($1) AND parent_id IS NOT DISTINCT FROM $2
+			ORDER BY created_at LIMIT 1`, name, parent).Scan(&id)
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("unknown category %q in %q", name, path)
+		}
+		if err != nil {
+			return uuid.Nil, err
+		}
+		next := id
+		parent = &next
+	}
+	r.categories[key] = id
+	return id, nil
+}
+
+// existingSKU finds the SKU a row updates, by SKU code or by model, color and size
+func (r *catalogResolver) existingSKU(row catalogRow, modelID *uuid.UUID) (skuID, skuModelID uuid.UUID, found bool, err error) {
+	if row.SKUCode != "" {
+		err = r.q.QueryRow(`SELECT id, product_model_id FROM skus WHERE sku_code = $1`, row.SKUCode).Scan(&skuID, &skuModelID)
+	} else if modelID != nil {
+		err = r.q.QueryRow(`
+			SELECT s.id, s.product_model_id FROM skus s
+			JOIN product_colors pc ON pc.id = s.product_color_id
+			WHERE s.product_model_id = $1 AND LOWER(pc.color_name) = LOWER($2) AND LOWER(s.size) = LOWER($3)
+			LIMIT 1`, *modelID, row.Color, row.Size).Scan(&skuID, &skuModelID)
+	} else {
+		return uuid.Nil, uuid.Nil, false, nil
+	}
+	if err == sql.ErrNoRows {
+		return uuid.Nil, uuid.Nil, false, nil
+	}
+	return skuID, skuModelID, err == nil, err
+}
+
+// existingModel finds the product model a row belongs to: the model of its SKU code,
+// else the model carrying its model code
+func (r *catalogResolver) existingModel(row catalogRow) (*uuid.UUID, error) {
+	var id uuid.UUID
+	if row.SKUCode != "" {
+		err := r.q.QueryRow(`SELECT product_model_id FROM skus WHERE sku_code = $1`, row.SKUCode).Scan(&id)
+		if err == nil {
+			return &id, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	if row.ModelCode != "" {
+		err := r.q.QueryRow(`SELECT id FROM product_models WHERE model_code = $1 ORDER BY created_at LIMIT 1`, row.ModelCode).Scan(&id)
+		if err == nil {
+			return &id, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// planCatalogImport validates rows against the catalog and works out what each would do.
+// It fills in each row's ModelKey, which groups the rows that belong to one product.
+func planCatalogImport(rows []catalogRow) ([]catalogRowPlan, []catalogRowError) {
+	r := newCatalogResolver(DB)
+	plan := make([]catalogRowPlan, len(rows))
+	var errs []catalogRowError
+	fail := func(line int, field, message string) {
+		errs = append(errs, catalogRowError{Line: line, Field: field, Message: message})
+	}
+
+	skuCodes := map[string]int{}
+	variants := map[string]int{}
+	eans := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		plan[i] = catalogRowPlan{Line: row.Line, ModelCode: row.ModelCode, SKUCode: row.SKUCode, ModelAction: "create", SKUAction: "create"}
+
+		if _, err := r.brandID(row.Brand); err != nil {
+			fail(row.Line, "brand", err.Error())
+		}
+		if row.CategoryPath != "" {
+			if _, err := r.categoryID(row.CategoryPath); err != nil {
+				fail(row.Line, "category_path", err.Error())
+			}
+		}
+
+		modelID, err := r.existingModel(*row)
+		if err != nil {
+			fail(row.Line, "", "lookup failed: "+err.Error())
+			continue
+		}
+		switch {
+		case modelID != nil:
+			row.ModelKey = "id:" + modelID.String()
+			plan[i].ModelAction = "update"
+		case row.ModelCode != "":
+			row.ModelKey = "code:" + row.ModelCode
+		default:
+			row.ModelKey = "new:" + strings.ToLower(row.Brand) + "|" + strings.ToLower(row.Title)
+		}
+		if modelID == nil && row.Title == "" {
+			fail(row.Line, "title", "title is required for a new product")
+		}
+
+		skuID, skuModelID, found, err := r.existingSKU(*row, modelID)
+		if err != nil {
+			fail(row.Line, "", "lookup failed: "+err.Error())
+			continue
+		}
+		if found {
+			plan[i].SKUAction = "update"
+			if row.ModelCode != "" {
+				var code sql.NullString
+				r.q.QueryRow(`SELECT model_code FROM product_models WHERE id = $1`, skuModelID).Scan(&code)
+				if code.Valid && code.String != row.ModelCode {
+					fail(row.Line, "sku_code", fmt.Sprintf("SKU %s belongs to model %s, not %s", row.SKUCode, code.String, row.ModelCode))
+				}
+			}
+		}
+
+		if row.SKUCode != "" {
+			if first, dup := skuCodes[row.SKUCode]; dup {
+				fail(row.Line, "sku_code", fmt.Sprintf("duplicate of row %d", first))
+			} else {
+				skuCodes[row.SKUCode] = row.Line
+			}
+		}
+		variant := row.ModelKey + "|" + strings.ToLower(row.Color) + "|" + strings.ToLower(row.Size)
+		if first, dup := variants[variant]; dup {
+			fail(row.Line, "size", fmt.Sprintf("same product, color and size as row %d", first))
+		} else {
+			variants[variant] = row.Line
+		}
+		if row.EAN != "" {
+			if first, dup := eans[row.EAN]; dup {
+				fail(row.Line, "ean", fmt.Sprintf("duplicate of row %d", first))
+			} else {
+				eans[row.EAN] = row.Line
+			}
+			var ownerType string
+			var ownerID uuid.UUID
+			err := r.q.QueryRow(`SELECT item_type, item_id FROM barcode_registry WHERE ean = $1`, row.EAN).Scan(&ownerType, &ownerID)
+			if err == nil && !(found && ownerType == "sku" && ownerID == skuID) {
+				fail(row.Line, "ean", fmt.Sprintf("EAN %s is already used by another %s", row.EAN, ownerType))
+			}
+		}
+	}
+	return plan, errs
+}
+
+// runCatalogImport applies a job's rows, one product per transaction, so a bad
+// product fails on its own and progress is visible while the job runs
+func runCatalogImport(jobID uuid.UUID, createdBy string) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			fmt.Printf("❌ Catalog import %s crashed: %v\n", jobID, rec)
+			DB.Exec(`UPDATE catalog_import_jobs SET status = 'failed', finished_at = now() WHERE id = $1`, jobID)
+		}
+	}()
+
+	var rowsJSON, errorsJSON string
+	if err := DB.QueryRow(`SELECT rows, errors FROM catalog_import_jobs WHERE id = $1`, jobID).Scan(&rowsJSON, &errorsJSON); err != nil {
+		fmt.Printf("❌ Failed to load catalog import %s: %v\n", jobID, err)
+		return
+	}
+	var rows []catalogRow
+	var rowErrors []catalogRowError
+	if err := json.Unmarshal([]byte(rowsJSON), &rows); err != nil {
+		DB.Exec(`UPDATE catalog_import_jobs SET status = 'failed', finished_at = now() WHERE id = $1`, jobID)
+		return
+	}
+	json.Unmarshal([]byte(errorsJSON), &rowErrors)
+	DB.Exec(`UPDATE catalog_import_jobs SET status = 'running', started_at = now() WHERE id = $1`, jobID)
+	fmt.Printf("📦 Catalog import %s started: %d rows\n", jobID, len(rows))
+
+	var order []string
+	groups := map[string][]catalogRow{}
+	for _, row := range rows {
+		if _, ok := groups[row.ModelKey]; !ok {
+			order = append(order, row.ModelKey)
+		}
+		groups[row.ModelKey] = append(groups[row.ModelKey], row)
+	}
+
+	processed, created, updated := 0, 0, 0
+	for _, key := range order {
+		group := groups[key]
+		c, u, err := applyCatalogGroup(group, createdBy)
+		if err != nil {
+			for _, row := range group {
+				rowErrors = append(rowErrors, catalogRowError{Line: row.Line, Message: err.Error()})
+			}
+		} else {
+			created += c
+			updated += u
+		}
+		processed += len(group)
+		errorsJSON, _ := json.Marshal(rowErrors)
+		DB.Exec(`
+			UPDATE catalog_import_jobs
+			SET processed_rows = $2, created_count = $3, updated_count = $4, error_count = $5, errors = $6
+			WHERE id = $1`, jobID, processed, created, updated, len(rowErrors), string(errorsJSON))
+	}
+
+	DB.Exec(`UPDATE catalog_import_jobs SET status = 'completed', rows = '[]', finished_at = now() WHERE id = $1`, jobID)
+	fmt.Printf("✅ Catalog import %s finished: %d created, %d updated, %d errors\n", jobID, created, updated, len(rowErrors))
+}
+
+// applyCatalogGroup upserts one product model and its SKUs. It returns how many SKUs
+// were created and updated.
+func applyCatalogGroup(rows []catalogRow, createdBy string) (int, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	if err = setPriceChangeContext(tx, "import", createdBy); err != nil {
+		return 0, 0, err
+	}
+
+	r := newCatalogResolver(tx)
+	first := rows[0]
+	brandID, err := r.brandID(first.Brand)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	modelID, err := r.existingModel(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	var modelCode string
+	if modelID == nil {
+		id := uuid.New()
+		modelID = &id
+		modelCode = first.ModelCode
+		if modelCode == "" {
+			modelCode = generateProductCode(first.Title, first.Brand)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO product_models (id, brand_id, title, description, model_code, status, attributes, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, 'draft', '{}', now(), now())`,
+			id, brandID, first.Title, first.Description, modelCode)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to create product: %w", err)
+		}
+	} else {
+		err = tx.QueryRow(`
+			UPDATE product_models
+			SET brand_id = $2, title = COALESCE(NULLIF($3, ''), title),
+			    description = COALESCE(NULLIF($4, ''), description), updated_at = now()
+			WHERE id = $1
+			RETURNING COALESCE(model_code, '')`, *modelID, brandID, first.Title, first.Description).Scan(&modelCode)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to update product: %w", err)
+		}
+	}
+
+	created, updated := 0, 0
+	colors := map[string]uuid.UUID{}
+	for _, row := range rows {
+		if row.CategoryPath != "" {
+			categoryID, err := r.categoryID(row.CategoryPath)
+			if err != nil {
+				return 0, 0, err
+			}
+			_, err = tx.Exec(`
+				INSERT INTO product_model_categories (product_model_id, category_id) VALUES ($1, $2)
+				ON CONFLICT DO NOTHING`, *modelID, categoryID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to link category: %w", err)
+			}
+		}
+
+		colorKey := strings.ToLower(row.Color)
+		colorID, ok := colors[colorKey]
+		if !ok {
+			err := tx.QueryRow(`
+				SELECT id FROM product_colors
+				WHERE product_model_id = $1 AND LOWER(color_name) = $2
+				ORDER BY created_at LIMIT 1`, *modelID, colorKey).Scan(&colorID)
+			if err == sql.ErrNoRows {
+				colorID = uuid.New()
+				_, err = tx.Exec(`
+					INSERT INTO product_colors (id, product_model_id, color_name, color_code, created_at)
+					VALUES ($1, $2, $3, $4, now())`, colorID, *modelID, row.Color, row.ColorCode)
+			} else if err == nil && row.ColorCode != "" {
+				_, err = tx.Exec(`UPDATE product_colors SET color_code = $2 WHERE id = $1`, colorID, row.ColorCode)
+			}
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to save color: %w", row.Line, err)
+			}
+			colors[colorKey] = colorID
+		}
+
+		skuID, skuModelID, found, err := r.existingSKU(row, modelID)
+		if err != nil {
+			return 0, 0, err
+		}
+		if found && skuModelID != *modelID {
+			return 0, 0, fmt.Errorf("line %d: SKU %s belongs to another product", row.Line, row.SKUCode)
+		}
+		if found {
+			_, err = tx.Exec(`
+				UPDATE skus SET product_color_id = $2, size = $3, size_normalized = $4, ean = COALESCE(NULLIF($5, ''), ean)
+				WHERE id = $1`, skuID, colorID, row.Size, normalizeSize(row.Size), row.EAN)
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to update SKU: %w", row.Line, err)
+			}
+			if err = setBasePrice(tx, skuID, "MRO", row.ListPrice, row.SalePrice); err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to update price: %w", row.Line, err)
+			}
+			updated++
+		} else {
+			skuID = uuid.New()
+			skuCode := row.SKUCode
+			if skuCode == "" {
+				skuCode = generateSKUCode(modelCode, row.Color, row.Size)
+			}
+			ean := row.EAN
+			if ean == "" {
+				if ean, err = allocateEAN(tx); err != nil {
+					return 0, 0, err
+				}
+			}
+			_, err = tx.Exec(`
+				INSERT INTO skus (id, product_model_id, product_color_id, sku_code, ean, size, size_normalized, attributes, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, '{}', now())`,
+				skuID, *modelID, colorID, skuCode, ean, row.Size, normalizeSize(row.Size))
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to create SKU: %w", row.Line, err)
+			}
+			_, err = tx.Exec(`
+				INSERT INTO prices (id, sku_id, currency, list_price, sale_price, created_at)
+				VALUES (gen_random_uuid(), $1, 'MRO', $2, $3, now())`, skuID, row.ListPrice, row.SalePrice)
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to create price: %w", row.Line, err)
+			}
+			_, err = tx.Exec(`INSERT INTO inventory (sku_id, available, reserved, updated_at) VALUES ($1, 0, 0, now())`, skuID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to create inventory: %w", row.Line, err)
+			}
+			created++
+		}
+
+		if row.Stock != nil {
+			if err := setStockLevel(tx, "sku", skuID, *row.Stock, "catalog_import", createdBy); err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to set stock: %w", row.Line, err)
+			}
+		}
+
+		for _, imageURL := range row.ImageURLs {
+			_, err = tx.Exec(`
+				INSERT INTO product_images (id, product_model_id, product_color_id, url, position, created_at)
+				SELECT gen_random_uuid(), $1, $2, $3,
+				       (SELECT COALESCE(MAX(position), -1) + 1 FROM product_images WHERE product_model_id = $1 AND product_color_id = $2),
+				       now()
+				WHERE NOT EXISTS (SELECT 1 FROM product_images WHERE product_model_id = $1 AND url = $3)`,
+				*modelID, colorID, imageURL)
+			if err != nil {
+				return 0, 0, fmt.Errorf("line %d: failed to add image: %w", row.Line, err)
+			}
+		}
+	}
+
+	if err := refreshSKUSizes(tx, modelID.String()); err != nil {
+		return 0, 0, fmt.Errorf("failed to normalize sizes: %w", err)
+	}
+	if err := refreshColorFamilies(tx, modelID.String()); err != nil {
+		return 0, 0, fmt.Errorf("failed to classify colors: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	queueColorExtraction("product", colorsNeedingExtraction("product", "product_model_id = $1", *modelID))
+	return created, updated, nil
+}
+
+// AdminListCatalogImports handles GET /api/v1/admin/catalog/import/jobs
+func AdminListCatalogImports(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT id, filename, status, total_rows, processed_rows, created_count, updated_count,
+		       error_count, created_by, started_at, finished_at, created_at
+		FROM catalog_import_jobs
+		ORDER BY created_at DESC
+		LIMIT 50`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import jobs"})
+		return
+	}
+	defer rows.Close()
+
+	jobs := []gin.H{}
+	for rows.Next() {
+		var id uuid.UUID
+		var filename, status string
+		var total, processed, created, updated, errorCount int
+		var createdBy *uuid.UUID
+		var startedAt, finishedAt *time.Time
+		var createdAt time.Time
+		if err := rows.Scan(&id, &filename, &status, &total, &processed, &created, &updated,
+			&errorCount, &createdBy, &startedAt, &finishedAt, &createdAt); err != nil {
+			continue
+		}
+		jobs = append(jobs, gin.H{
+			"id": id, "filename": filename, "status": status,
+			"total_rows": total, "processed_rows": processed, "progress": importProgress(processed, total),
+			"created_count": created, "updated_count": updated, "error_count": errorCount,
+			"created_by": createdBy, "started_at": startedAt, "finished_at": finishedAt, "created_at": createdAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// AdminGetCatalogImport handles GET /api/v1/admin/catalog/import/jobs/:id
+func AdminGetCatalogImport(c *gin.Context) {
+	var filename, status, errorsJSON string
+	var total, processed, created, updated, errorCount int
+	var startedAt, finishedAt *time.Time
+	var createdAt time.Time
+	err := DB.QueryRow(`
+		SELECT filename, status, total_rows, processed_rows, created_count, updated_count,
+		       error_count, errors, started_at, finished_at, created_at
+		FROM catalog_import_jobs WHERE id::text = $1`, c.Param("id")).Scan(&filename, &status, &total, &processed,
+		&created, &updated, &errorCount, &errorsJSON, &startedAt, &finishedAt, &createdAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch import job"})
+		return
+	}
+
+	rowErrors := []catalogRowError{}
+	json.Unmarshal([]byte(errorsJSON), &rowErrors)
+	c.JSON(http.StatusOK, gin.H{
+		"id": c.Param("id"), "filename": filename, "status": status,
+		"total_rows": total, "processed_rows": processed, "progress": importProgress(processed, total),
+		"created_count": created, "updated_count": updated, "error_count": errorCount, "errors": rowErrors,
+		"started_at": startedAt, "finished_at": finishedAt, "created_at": createdAt,
+	})
+}
+
+func importProgress(processed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(processed) / float64(total)
+}
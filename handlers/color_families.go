@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// colorTable describes a table of sellable colors that get a color family
+type colorTable struct {
+	table      string
+	nameSQL    string // name words used for matching
+	imageQuery string // first image of a color, $1 = color id
+}
+
+var colorTables = map[string]colorTable{
+	"product": {
+		table:   "product_colors",
+		nameSQL: "color_name",
+		// The color's own image, else the product's when it only comes in one color
+		imageQuery: `
+			SELECT url FROM (
+				SELECT pi.url, 0 AS pref, pi.position FROM product_images pi WHERE pi.product_color_id = $1
+				UNION ALL
+				SELECT pi.url, 1, pi.position FROM product_images pi
+				JOIN product_colors pc ON pc.product_model_id = pi.product_model_id
+				WHERE pc.id = $1 AND (SELECT COUNT(*) FROM product_colors WHERE product_model_id = pc.product_model_id) = 1
+			) images
+			ORDER BY pref, position NULLS LAST
+			LIMIT 1`,
+	},
+	"melhaf": {
+		table:      "melhaf_colors",
+		nameSQL:    "name || ' ' || COALESCE(name_ar, '')",
+		imageQuery: `SELECT url FROM melhaf_color_images WHERE color_id = $1 ORDER BY position LIMIT 1`,
+	},
+}
+
+// classifyColor picks the family of a color: from its hex code, then from words in
+// its name, then from the dominant color of its image
+func classifyColor(name, hex, dominant string) (family, source string) {
+	if slug, _, ok := utils.NearestColorFamily(hex); ok {
+		return slug, "hex"
+	}
+	if slug := utils.ColorFamilyFromName(name); slug != "" {
+		return slug, "name"
+	}
+	if slug, _, ok := utils.NearestColorFamily(dominant); ok {
+		return slug, "image"
+	}
+	return "", ""
+}
+
+// reclassifyColors recomputes the family of the colors matching where (a condition
+// on the color table using args). Manual overrides are kept. It returns how many
+// colors changed family.
+func reclassifyColors(q sqlHandle, kind, where string, args ...interface{}) (int, error) {
+	t := colorTables[kind]
+	rows, err := q.Query(`
+		SELECT id::text, `+t.nameSQL+`, COALESCE(color_code, ''), COALESCE(dominant_color, ''),
+		       COALESCE(color_family, ''), COALESCE(color_family_source, '')
+		FROM `+t.table+`
+		WHERE COALESCE(color_family_source, '') <> 'manual' AND (`+where+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	type colorRow struct{ id, family, source string }
+	var changes []colorRow
+	for rows.Next() {
+		var id, name, hex, dominant, family, source string
+		if err := rows.Scan(&id, &name, &hex, &dominant, &family, &source); err != nil {
+			continue
+		}
+		newFamily, newSource := classifyColor(name, hex, dominant)
+		if newFamily != family || newSource != source {
+			changes = append(changes, colorRow{id, newFamily, newSource})
+		}
+	}
+	rows.Close()
+
+	for _, ch := range changes {
+		_, err := q.Exec(`UPDATE `+t.table+` SET color_family = NULLIF($2, ''), color_family_source = NULLIF($3, '') WHERE id::text = $1`,
+			ch.id, ch.family, ch.source)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), nil
+}
+
+// refreshColorFamilies classifies the colors of a product. Write paths call it after
+// saving colors; colors without a hex code then get their image color extracted in
+// the background by queueColorExtraction.
+func refreshColorFamilies(q sqlHandle, productID string) error {
+	_, err := reclassifyColors(q, "product", "product_model_id::text = $1", productID)
+	return err
+}
+
+// colorsNeedingExtraction lists colors without a usable hex code or an extracted
+// image color, restricted by where/args as in reclassifyColors
+func colorsNeedingExtraction(kind, where string, args ...interface{}) []string {
+	t := colorTables[kind]
+	rows, err := DB.Query(`
+		SELECT id::text, COALESCE(color_code, '') FROM `+t.table+`
+		WHERE dominant_color IS NULL AND (`+where+`)`, args...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id, hex string
+		if err := rows.Scan(&id, &hex); err != nil {
+			continue
+		}
+		if _, _, _, ok := utils.ParseHexColor(hex); !ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// queueColorExtraction extracts the dominant image color of the given colors in the
+// background and reclassifies them. Image downloads never block the admin request.
+func queueColorExtraction(kind string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	go func() {
+		for _, id := range ids {
+			if _, err := extractColorFromImage(kind, id); err != nil {
+				fmt.Printf("⚠️ Dominant color extraction failed for %s color %s: %v\n", kind, id, err)
+			}
+		}
+	}()
+}
+
+// extractColorFromImage stores the dominant color of a color's first image and
+// reclassifies the color. It returns false when the color has no image yet.
+func extractColorFromImage(kind, id string) (bool, error) {
+	t := colorTables[kind]
+	var imageURL string
+	err := DB.QueryRow(t.imageQuery, id).Scan(&imageURL)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	hex, err := dominantImageColor(imageURL)
+	if err != nil {
+		return false, err
+	}
+	if _, err := DB.Exec(`UPDATE `+t.table+` SET dominant_color = $2 WHERE id::text = $1`, id, hex); err != nil {
+		return false, err
+	}
+	_, err = reclassifyColors(DB, kind, "id::text = $1", id)
+	return true, err
+}
+
+var colorImageClient = &http.Client{Timeout: 15 * time.Second}
+
+// dominantImageColor downloads an image and returns its dominant color. Cloudinary
+// images are requested as a small JPEG so formats the standard library cannot
+// decode (WebP, AVIF) still work.
+func dominantImageColor(imageURL string) (string, error) {
+	if strings.Contains(imageURL, "res.cloudinary.com") && strings.Contains(imageURL, "/image/upload/") {
+		imageURL = strings.Replace(imageURL, "/image/upload/", "/image/upload/f_jpg,w_240/", 1)
+	}
+	resp, err := colorImageClient.Get(imageURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("image download returned %s", resp.Status)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+	hex, ok := utils.DominantColor(img)
+	if !ok {
+		return "", fmt.Errorf("image has no visible pixels")
+	}
+	return hex, nil
+}
+
+// colorBackfillStatus tracks the background job classifying every color
+type colorBackfillStatus struct {
+	Running    bool       `json:"running"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Changed    int        `json:"changed"`
+	Extracted  int        `json:"extracted"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	colorBackfillMu    sync.Mutex
+	colorBackfillState colorBackfillStatus
+)
+
+func runColorBackfill() {
+	fmt.Printf("🎨 Color family backfill started\n")
+	record := func(f func(s *colorBackfillStatus)) {
+		colorBackfillMu.Lock()
+		f(&colorBackfillState)
+		colorBackfillMu.Unlock()
+	}
+
+	// Extract image colors first so the classification below can use them
+	pending := map[string][]string{}
+	total := 0
+	for kind := range colorTables {
+		pending[kind] = colorsNeedingExtraction(kind, "TRUE")
+		total += len(pending[kind])
+	}
+	record(func(s *colorBackfillStatus) { s.Total = total })
+
+	for kind, ids := range pending {
+		for _, id := range ids {
+			extracted, err := extractColorFromImage(kind, id)
+			record(func(s *colorBackfillStatus) {
+				s.Processed++
+				if err != nil {
+					s.Failed++
+				} else if extracted {
+					s.Extracted++
+				}
+			})
+		}
+	}
+
+	var firstErr error
+	for kind := range colorTables {
+		changed, err := reclassifyColors(DB, kind, "TRUE")
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		record(func(s *colorBackfillStatus) { s.Changed += changed })
+	}
+
+	record(func(s *colorBackfillStatus) {
+		now := time.Now()
+		s.Running = false
+		s.FinishedAt = &now
+		if firstErr != nil {
+			s.Error = firstErr.Error()
+			fmt.Printf("❌ Color family backfill failed: %v\n", firstErr)
+			return
+		}
+		fmt.Printf("✅ Color family backfill done: %d changed, %d images extracted\n", s.Changed, s.Extracted)
+	})
+}
+
+// AdminStartColorBackfill handles POST /api/v1/admin/colors/backfill
+func AdminStartColorBackfill(c *gin.Context) {
+	colorBackfillMu.Lock()
+	defer colorBackfillMu.Unlock()
+	if colorBackfillState.Running {
+		c.JSON(http.StatusConflict, gin.H{"error": "A color backfill is already running"})
+		return
+	}
+	now := time.Now()
+	colorBackfillState = colorBackfillStatus{Running: true, StartedAt: &now}
+	go runColorBackfill()
+	c.JSON(http.StatusAccepted, gin.H{"message": "Color backfill started"})
+}
+
+// AdminGetColorBackfill handles GET /api/v1/admin/colors/backfill
+func AdminGetColorBackfill(c *gin.Context) {
+	colorBackfillMu.Lock()
+	status := colorBackfillState
+	colorBackfillMu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+// GetColorFamilies handles GET /api/v1/colors/families
+func GetColorFamilies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"families": utils.ColorFamilies()})
+}
+
+// AdminListColors handles GET /api/v1/admin/colors?type=product|melhaf&family=&unclassified=true
+// Lists colors with their family so merchandisers can review and override them.
+func AdminListColors(c *gin.Context) {
+	kind := c.DefaultQuery("type", "product")
+	if _, ok := colorTables[kind]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be product or melhaf"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	parent := `pm.title FROM product_colors col JOIN product_models pm ON pm.id = col.product_model_id`
+	name := "col.color_name"
+	if kind == "melhaf" {
+		parent = `mc.name FROM melhaf_colors col JOIN melhaf_collections mc ON mc.id = col.collection_id`
+		name = "col.name"
+	}
+	rows, err := DB.Query(`
+		SELECT col.id, `+name+`, COALESCE(col.color_code, ''), COALESCE(col.dominant_color, ''),
+		       COALESCE(col.color_family, ''), COALESCE(col.color_family_source, ''), `+parent+`
+		WHERE ($1 = '' OR col.color_family = $1)
+		  AND (NOT $2 OR col.color_family IS NULL)
+		ORDER BY 7, 2
+		LIMIT $3 OFFSET $4`,
+		c.Query("family"), c.Query("unclassified") == "true", limit, (page-1)*limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch colors"})
+		return
+	}
+	defer rows.Close()
+
+	colors := []gin.H{}
+	for rows.Next() {
+		var id, colorName, hex, dominant, family, source, parentName string
+		if err := rows.Scan(&id, &colorName, &hex, &dominant, &family, &source, &parentName); err != nil {
+			continue
+		}
+		colors = append(colors, gin.H{
+			"id":                  id,
+			"name":                colorName,
+			"color_code":          hex,
+			"dominant_color":      dominant,
+			"color_family":        family,
+			"color_family_source": source,
+			"parent":              parentName,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"colors": colors, "page": page, "limit": limit})
+}
+
+// AdminSetColorFamily handles PUT /api/v1/admin/colors/:type/:id/family
+// Body: {"family": "red"} sets a manual override; {"family": null} removes it and
+// lets the color be classified automatically again.
+func AdminSetColorFamily(c *gin.Context) {
+	kind := c.Param("type")
+	t, ok := colorTables[kind]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be product or melhaf"})
+		return
+	}
+	var req struct {
+		Family *string `json:"family"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Family != nil && !utils.IsColorFamily(*req.Family) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown color family"})
+		return
+	}
+
+	var res sql.Result
+	var err error
+	if req.Family != nil {
+		res, err = DB.Exec(`UPDATE `+t.table+` SET color_family = $2, color_family_source = 'manual' WHERE id::text = $1`, c.Param("id"), *req.Family)
+	} else {
+		res, err = DB.Exec(`UPDATE `+t.table+` SET color_family = NULL, color_family_source = NULL WHERE id::text = $1`, c.Param("id"))
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update color family"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Color not found"})
+		return
+	}
+	if req.Family == nil {
+		reclassifyColors(DB, kind, "id::text = $1", c.Param("id"))
+	}
+
+	var family, source sql.NullString
+	DB.QueryRow(`SELECT color_family, color_family_source FROM `+t.table+` WHERE id::text = $1`, c.Param("id")).Scan(&family, &source)
+	c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "color_family": family.String, "color_family_source": source.String})
+}
+
+// ColorFamilyFilter is a color family facet option
+type ColorFamilyFilter struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Hex   string `json:"hex"`
+	Count int    `json:"count"`
+}
+
+// colorFamilyFacet counts items per color family from rows of (family, count),
+// listing families in taxonomy order and leaving out empty ones
+func colorFamilyFacet(rows *sql.Rows) []ColorFamilyFilter {
+	counts := map[string]int{}
+	for rows.Next() {
+		var family string
+		var count int
+		if err := rows.Scan(&family, &count); err == nil {
+			counts[family] = count
+		}
+	}
+	facet := []ColorFamilyFilter{}
+	for _, f := range utils.ColorFamilies() {
+		if counts[f.Slug] > 0 {
+			facet = append(facet, ColorFamilyFilter{Slug: f.Slug, Name: f.Name, Hex: f.Hex, Count: counts[f.Slug]})
+		}
+	}
+	return facet
+}
+
+// colorFamilyFilterSQL builds a condition matching products (alias pm) that have a
+// color in any of the comma separated families, numbered from argIndex
+func colorFamilyFilterSQL(families string, argIndex int) (string, interface{}) {
+	var slugs []string
+	for _, f := range strings.Split(families, ",") {
+		if f = strings.TrimSpace(strings.ToLower(f)); f != "" {
+			slugs = append(slugs, f)
+		}
+	}
+	cond := fmt.Sprintf("EXISTS (SELECT 1 FROM product_colors pcf WHERE pcf.product_model_id = pm.id AND pcf.color_family = ANY($%d::text[]))", argIndex)
+	return cond, pgTextArray(slugs)
+}
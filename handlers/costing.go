@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fmbq-server/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// costingMethod returns the configured costing method, defaulting to weighted average
+func costingMethod() string {
+	if config.AppConfig != nil && config.AppConfig.CostingMethod == "fifo" {
+		return "fifo"
+	}
+	return "weighted_average"
+}
+
+// receiveItemCost opens a FIFO layer for a received line and folds it into the
+// item's weighted-average cost. onHand is the stock held before the receipt.
+func receiveItemCost(tx *sql.Tx, receiptID uuid.UUID, itemType string, itemID uuid.UUID, quantity int, unitCost float64, onHand int, receivedAt time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO stock_receipt_lines (id, receipt_id, item_type, item_id, quantity, unit_cost, remaining_quantity, received_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $4, $6)
+	`, receiptID, itemType, itemID, quantity, unitCost, receivedAt)
+	if err != nil {
+		return err
+	}
+
+	if onHand < 0 {
+		onHand = 0
+	}
+	_, err = tx.Exec(`
+		INSERT INTO item_costs (item_type, item_id, average_cost, last_cost, updated_at)
+		VALUES ($1, $2, $3, $3, now())
+		ON CONFLICT (item_type, item_id) DO UPDATE SET
+			average_cost = (item_costs.average_cost * $4 + $3 * $5) / NULLIF($4 + $5, 0),
+			last_cost = $3,
+			updated_at = now()
+	`, itemType, itemID, unitCost, onHand, quantity)
+	return err
+}
+
+// consumeItemCost draws quantity units from the item's FIFO layers and returns the
+// unit cost to book against the sale, according to the configured costing method.
+// Items that were never received through a receipt cost 0.
+func consumeItemCost(tx *sql.Tx, itemType string, itemID uuid.UUID, quantity int) (float64, error) {
+	rows, err := tx.Query(`
+		SELECT id, remaining_quantity, unit_cost
+		FROM stock_receipt_lines
+		WHERE item_type = $1 AND item_id = $2 AND remaining_quantity > 0
+		ORDER BY received_at ASC, id ASC
+		FOR UPDATE`, itemType, itemID)
+	if err != nil {
+		return 0, err
+	}
+	type layer struct {
+		id        uuid.UUID
+		remaining int
+		unitCost  float64
+	}
+	var layers []layer
+	for rows.Next() {
+		var l layer
+		if err := rows.Scan(&l.id, &l.remaining, &l.unitCost); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		layers = append(layers, l)
+	}
+	rows.Close()
+
+	left := quantity
+	fifoTotal := 0.0
+	lastCost := 0.0
+	for _, l := range layers {
+		if left == 0 {
+			break
+		}
+		take := l.remaining
+		if take > left {
+			take = left
+		}
+		if _, err := tx.Exec(`UPDATE stock_receipt_lines SET remaining_quantity = remaining_quantity - $1 WHERE id = $2`, take, l.id); err != nil {
+			return 0, err
+		}
+		fifoTotal += float64(take) * l.unitCost
+		lastCost = l.unitCost
+		left -= take
+	}
+
+	var averageCost float64
+	err = tx.QueryRow(`SELECT average_cost FROM item_costs WHERE item_type = $1 AND item_id = $2`, itemType, itemID).Scan(&averageCost)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if costingMethod() != "fifo" || quantity <= 0 {
+		return averageCost, nil
+	}
+	// Units sold beyond the recorded layers are costed at the newest known cost
+	if left > 0 {
+		if lastCost == 0 {
+			lastCost = averageCost
+		}
+		fifoTotal += float64(left) * lastCost
+	}
+	return fifoTotal / float64(quantity), nil
+}
+
+// recordSale books a sale of quantity units out of the before stock: it writes the
+// movement ledger row and returns the unit cost to snapshot on the order item. It
+// does not touch the stock level; the caller updates it in the same transaction.
+func recordSale(tx *sql.Tx, itemType string, itemID uuid.UUID, before, quantity int, orderID uuid.UUID, createdBy string) (float64, error) {
+	if err := recordInventoryMovement(tx, itemType, itemID, before, before-quantity, "sale", "order", &orderID, createdBy); err != nil {
+		return 0, err
+	}
+	return consumeItemCost(tx, itemType, itemID, quantity)
+}
+
+// stockItemTables maps a stock item type to the table its items live in
+var stockItemTables = map[string]string{
+	"sku":             "skus",
+	"melhaf_color":    "melhaf_colors",
+	"perfume_variant": "maison_adrar_perfume_colors",
+}
+
+// AdminCreateStockReceipt handles POST /api/v1/admin/inventory/receipts
+// Brings stock in at a known unit cost and updates item costs.
+func AdminCreateStockReceipt(c *gin.Context) {
+	var req struct {
+		Supplier   *string    `json:"supplier"`
+		Reference  *string    `json:"reference"`
+		Location   *string    `json:"location"`
+		Notes      *string    `json:"notes"`
+		ReceivedAt *time.Time `json:"received_at"`
+		Lines      []struct {
+			ItemType string  `json:"item_type" binding:"required,oneof=sku melhaf_color perfume_variant"`
+			ItemID   string  `json:"item_id" binding:"required"`
+			Quantity int     `json:"quantity" binding:"required,min=1"`
+			UnitCost float64 `json:"unit_cost" binding:"min=0"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	receivedAt := time.Now()
+	if req.ReceivedAt != nil {
+		receivedAt = *req.ReceivedAt
+	}
+	userID := c.GetString("user_id")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	receiptID := uuid.New()
+	_, err = tx.Exec(`
+		INSERT INTO stock_receipts (id, supplier, reference, location, notes, received_at, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+	`, receiptID, req.Supplier, req.Reference, req.Location, req.Notes, receivedAt, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock receipt"})
+		return
+	}
+
+	totalCost := 0.0
+	for _, line := range req.Lines {
+		itemID, err := uuid.Parse(line.ItemID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item_id", "item_id": line.ItemID})
+			return
+		}
+
+		var exists bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+stockItemTables[line.ItemType]+` WHERE id = $1)`, itemID).Scan(&exists); err != nil {
+			fmt.Printf("❌ Failed to check %s %s: %v\n", line.ItemType, itemID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check item", "item_id": line.ItemID})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item not found", "item_type": line.ItemType, "item_id": line.ItemID})
+			return
+		}
+
+		before, _, err := applyStockChange(tx, line.ItemType, itemID, line.Quantity, "receipt", "stock_receipt", &receiptID, userID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Item not found", "item_type": line.ItemType, "item_id": line.ItemID})
+			return
+		}
+		if err != nil {
+			fmt.Printf("❌ Failed to receive stock for %s %s: %v\n", line.ItemType, itemID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock", "item_id": line.ItemID})
+			return
+		}
+
+		if err := receiveItemCost(tx, receiptID, line.ItemType, itemID, line.Quantity, line.UnitCost, before, receivedAt); err != nil {
+			fmt.Printf("❌ Failed to record cost for %s %s: %v\n", line.ItemType, itemID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record item cost", "item_id": line.ItemID})
+			return
+		}
+
+		if line.ItemType == "sku" && req.Location != nil && *req.Location != "" {
+			if _, err := tx.Exec(`UPDATE inventory SET location = $1 WHERE sku_id = $2 AND location IS NULL`, *req.Location, itemID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set stock location"})
+				return
+			}
+		}
+		totalCost += float64(line.Quantity) * line.UnitCost
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit stock receipt"})
+		return
+	}
+
+	fmt.Printf("✅ Stock receipt %s recorded: %d lines, total cost %.2f\n", receiptID, len(req.Lines), totalCost)
+	c.JSON(http.StatusCreated, gin.H{
+		"id":          receiptID,
+		"lines":       len(req.Lines),
+		"total_cost":  totalCost,
+		"received_at": receivedAt,
+	})
+}
+
+// AdminListStockReceipts handles GET /api/v1/admin/inventory/receipts
+func AdminListStockReceipts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	rows, err := DB.Query(`
+		SELECT r.id, r.supplier, r.reference, r.location, r.received_at,
+		       COUNT(l.id), COALESCE(SUM(l.quantity), 0), COALESCE(SUM(l.quantity * l.unit_cost), 0)
+		FROM stock_receipts r
+		LEFT JOIN stock_receipt_lines l ON l.receipt_id = r.id
+		GROUP BY r.id
+		ORDER BY r.received_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock receipts"})
+		return
+	}
+	defer rows.Close()
+
+	type Receipt struct {
+		ID         string    `json:"id"`
+		Supplier   *string   `json:"supplier"`
+		Reference  *string   `json:"reference"`
+		Location   *string   `json:"location"`
+		ReceivedAt time.Time `json:"received_at"`
+		Lines      int       `json:"lines"`
+		Units      int       `json:"units"`
+		TotalCost  float64   `json:"total_cost"`
+	}
+
+	out := []Receipt{}
+	for rows.Next() {
+		var r Receipt
+		var supplier, reference, location sql.NullString
+		if err := rows.Scan(&r.ID, &supplier, &reference, &location, &r.ReceivedAt, &r.Lines, &r.Units, &r.TotalCost); err != nil {
+			continue
+		}
+		if supplier.Valid {
+			r.Supplier = &supplier.String
+		}
+		if reference.Valid {
+			r.Reference = &reference.String
+		}
+		if location.Valid {
+			r.Location = &location.String
+		}
+		out = append(out, r)
+	}
+	c.JSON(http.StatusOK, gin.H{"receipts": out})
+}
+
+// AdminListItemCosts handles GET /api/v1/admin/inventory/costs
+// Optional filter: item_type
+func AdminListItemCosts(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT ic.item_type, ic.item_id, ic.average_cost, ic.last_cost,
+		       COALESCE((SELECT SUM(l.remaining_quantity) FROM stock_receipt_lines l
+		                 WHERE l.item_type = ic.item_type AND l.item_id = ic.item_id), 0),
+		       ic.updated_at
+		FROM item_costs ic
+		WHERE ($1 = '' OR ic.item_type = $1)
+		ORDER BY ic.updated_at DESC`, c.Query("item_type"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch item costs"})
+		return
+	}
+	defer rows.Close()
+
+	type Cost struct {
+		ItemType     string    `json:"item_type"`
+		ItemID       string    `json:"item_id"`
+		AverageCost  float64   `json:"average_cost"`
+		LastCost     float64   `json:"last_cost"`
+		FIFOQuantity int       `json:"fifo_quantity"`
+		UpdatedAt    time.Time `json:"updated_at"`
+	}
+
+	out := []Cost{}
+	for rows.Next() {
+		var ic Cost
+		if err := rows.Scan(&ic.ItemType, &ic.ItemID, &ic.AverageCost, &ic.LastCost, &ic.FIFOQuantity, &ic.UpdatedAt); err != nil {
+			continue
+		}
+		out = append(out, ic)
+	}
+	c.JSON(http.StatusOK, gin.H{"costing_method": costingMethod(), "costs": out})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recordInventoryMovement writes a ledger row for a stock change made inside tx.
+// referenceType/referenceID link the movement to whatever caused it (a stock-take
+// session, an order...). createdBy may be empty for system movements.
+func recordInventoryMovement(tx *sql.Tx, itemType string, itemID uuid.UUID, before, after int, reason, referenceType string, referenceID *uuid.UUID, createdBy string) error {
+	var refType *string
+	if referenceType != "" {
+		refType = &referenceType
+	}
+	var userID *uuid.UUID
+	if uid, err := uuid.Parse(createdBy); err == nil {
+		userID = &uid
+	}
+	_, err := tx.Exec(`
+		INSERT INTO inventory_movements (id, item_type, item_id, quantity_change, quantity_before, quantity_after, reason, reference_type, reference_id, created_by, created_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+	`, itemType, itemID, after-before, before, after, reason, refType, referenceID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Back in stock: arm "notify me" subscriptions; the notifier sends them after commit
+	if before <= 0 && after > 0 {
+		_, err = tx.Exec(`
+			UPDATE back_in_stock_subscriptions SET status = 'triggered', triggered_at = now()
+			WHERE item_type = $1 AND item_id = $2 AND status = 'active'`, itemType, itemID)
+	}
+	return err
+}
+
+// applyStockChange adds delta to the available stock of any sellable item inside tx
+// and records the matching movement. Stock never goes below zero.
+func applyStockChange(tx *sql.Tx, itemType string, itemID uuid.UUID, delta int, reason, referenceType string, referenceID *uuid.UUID, createdBy string) (int, int, error) {
+	var before int
+	var err error
+	switch itemType {
+	case "sku":
+		err = tx.QueryRow(`SELECT available FROM inventory WHERE sku_id = $1 FOR UPDATE`, itemID).Scan(&before)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec(`INSERT INTO inventory (sku_id, available, reserved, updated_at) VALUES ($1, 0, 0, now())`, itemID)
+		}
+	case "melhaf_color":
+		err = tx.QueryRow(`SELECT available FROM melhaf_inventory WHERE color_id = $1 FOR UPDATE`, itemID).Scan(&before)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec(`INSERT INTO melhaf_inventory (id, color_id, available, reserved, reorder_point, created_at, updated_at) VALUES (gen_random_uuid(), $1, 0, 0, 0, now(), now())`, itemID)
+		}
+	case "perfume_variant":
+		err = tx.QueryRow(`SELECT COALESCE(stock, 0) FROM maison_adrar_perfume_colors WHERE id = $1 FOR UPDATE`, itemID).Scan(&before)
+	default:
+		return 0, 0, fmt.Errorf("unknown item type %q", itemType)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	after := before + delta
+	if after < 0 {
+		after = 0
+	}
+
+	switch itemType {
+	case "sku":
+		_, err = tx.Exec(`UPDATE inventory SET available = $1, updated_at = now() WHERE sku_id = $2`, after, itemID)
+	case "melhaf_color":
+		_, err = tx.Exec(`UPDATE melhaf_inventory SET available = $1, updated_at = now() WHERE color_id = $2`, after, itemID)
+	case "perfume_variant":
+		_, err = tx.Exec(`UPDATE maison_adrar_perfume_colors SET stock = $1, updated_at = now() WHERE id = $2`, after, itemID)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := recordInventoryMovement(tx, itemType, itemID, before, after, reason, referenceType, referenceID, createdBy); err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// setStockLevel sets the available stock of an item to an absolute value inside tx,
+// recording the difference as a movement.
+func setStockLevel(tx *sql.Tx, itemType string, itemID uuid.UUID, available int, reason, createdBy string) error {
+	var current int
+	var err error
+	switch itemType {
+	case "sku":
+		err = tx.QueryRow(`SELECT available FROM inventory WHERE sku_id = $1 FOR UPDATE`, itemID).Scan(&current)
+	case "melhaf_color":
+		err = tx.QueryRow(`SELECT available FROM melhaf_inventory WHERE color_id = $1 FOR UPDATE`, itemID).Scan(&current)
+	case "perfume_variant":
+		err = tx.QueryRow(`SELECT COALESCE(stock, 0) FROM maison_adrar_perfume_colors WHERE id = $1 FOR UPDATE`, itemID).Scan(&current)
+	default:
+		return fmt.Errorf("unknown item type %q", itemType)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if available == current && err == nil {
+		return nil
+	}
+	_, _, err = applyStockChange(tx, itemType, itemID, available-current, reason, "", nil, createdBy)
+	return err
+}
+
+// AdminListInventoryMovements handles GET /api/v1/admin/inventory/movements
+// Optional filters: item_type, item_id, reason, reference_id, limit (default 100)
+func AdminListInventoryMovements(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := DB.Query(`
+		SELECT m.id, m.item_type, m.item_id, m.quantity_change, m.quantity_before, m.quantity_after,
+		       m.reason, m.reference_type, m.reference_id, COALESCE(u.full_name, ''), m.created_at
+		FROM inventory_movements m
+		LEFT JOIN users u ON u.id = m.created_by
+		WHERE ($1 = '' OR m.item_type = $1)
+		  AND ($2 = '' OR m.item_id::text = $2)
+		  AND ($3 = '' OR m.reason = $3)
+		  AND ($4 = '' OR m.reference_id::text = $4)
+		ORDER BY m.created_at DESC
+		LIMIT $5`,
+		c.Query("item_type"), c.Query("item_id"), c.Query("reason"), c.Query("reference_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch inventory movements"})
+		return
+	}
+	defer rows.Close()
+
+	type Movement struct {
+		ID             string    `json:"id"`
+		ItemType       string    `json:"item_type"`
+		ItemID         string    `json:"item_id"`
+		QuantityChange int       `json:"quantity_change"`
+		QuantityBefore int       `json:"quantity_before"`
+		QuantityAfter  int       `json:"quantity_after"`
+		Reason         string    `json:"reason"`
+		ReferenceType  *string   `json:"reference_type"`
+		ReferenceID    *string   `json:"reference_id"`
+		CreatedBy      string    `json:"created_by"`
+		CreatedAt      time.Time `json:"created_at"`
+	}
+
+	out := []Movement{}
+	for rows.Next() {
+		var m Movement
+		var refType, refID sql.NullString
+		if err := rows.Scan(&m.ID, &m.ItemType, &m.ItemID, &m.QuantityChange, &m.QuantityBefore, &m.QuantityAfter,
+			&m.Reason, &refType, &refID, &m.CreatedBy, &m.CreatedAt); err != nil {
+			continue
+		}
+		if refType.Valid {
+			m.ReferenceType = &refType.String
+		}
+		if refID.Valid {
+			m.ReferenceID = &refID.String
+		}
+		out = append(out, m)
+	}
+	c.JSON(http.StatusOK, gin.H{"movements": out})
+}
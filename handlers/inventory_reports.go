@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// valuationItemsSQL lists every stocked item with its reporting dimensions and the
+// quantity held at $1, rebuilt from the current stock minus later movements.
+const valuationItemsSQL = `
+	WITH items AS (
+		SELECT 'sku' AS item_type, s.id AS item_id, COALESCE(i.available, 0) AS on_hand,
+		       COALESCE((SELECT c.name FROM product_model_categories pmc
+		                 JOIN categories c ON c.id = pmc.category_id
+		                 WHERE pmc.product_model_id = pm.id ORDER BY c.level DESC, c.name LIMIT 1), 'Uncategorized') AS category,
+		       COALESCE(b.name, 'No brand') AS brand,
+		       COALESCE(i.location, 'Unassigned') AS location
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		UNION ALL
+		SELECT 'melhaf_color', mc.id, COALESCE(mi.available, 0), 'Melhaf', 'Melhaf', 'Unassigned'
+		FROM melhaf_colors mc
+		LEFT JOIN melhaf_inventory mi ON mi.color_id = mc.id
+		UNION ALL
+		SELECT 'perfume_variant', pc.id, COALESCE(pc.stock, 0), 'Maison Adrar', 'Maison Adrar', 'Unassigned'
+		FROM maison_adrar_perfume_colors pc
+	)
+	SELECT it.item_type, it.item_id::text, it.category, it.brand, it.location,
+	       it.on_hand - COALESCE((SELECT SUM(m.quantity_change) FROM inventory_movements m
+	                              WHERE m.item_type = it.item_type AND m.item_id = it.item_id
+	                                AND m.created_at > $1), 0) AS quantity
+	FROM items it`
+
+// costLayer is a received quantity at a unit cost, used to value stock at a date
+type costLayer struct {
+	quantity int
+	unitCost float64
+}
+
+// valueAtDate prices quantity units from the layers received up to the report date
+// (newest first). FIFO values the remaining stock at its newest layers; weighted
+// average uses the mean cost of everything received.
+func valueAtDate(quantity int, layers []costLayer, method string) float64 {
+	if quantity <= 0 || len(layers) == 0 {
+		return 0
+	}
+	if method != "fifo" {
+		units, total := 0, 0.0
+		for _, l := range layers {
+			units += l.quantity
+			total += float64(l.quantity) * l.unitCost
+		}
+		if units == 0 {
+			return 0
+		}
+		return float64(quantity) * total / float64(units)
+	}
+
+	left := quantity
+	value := 0.0
+	for _, l := range layers {
+		take := l.quantity
+		if take > left {
+			take = left
+		}
+		value += float64(take) * l.unitCost
+		left -= take
+		if left == 0 {
+			return value
+		}
+	}
+	// Stock older than any recorded receipt is valued at the oldest known cost
+	return value + float64(left)*layers[len(layers)-1].unitCost
+}
+
+// writeCSVReport streams rows as a CSV attachment
+func writeCSVReport(c *gin.Context, filename string, header []string, rows [][]string) {
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write(header)
+	_ = w.WriteAll(rows)
+	w.Flush()
+}
+
+// AdminInventoryValuationReport handles GET /api/v1/admin/reports/inventory-valuation
+// Query: date (YYYY-MM-DD, default today), group_by (category|brand|location), format=csv
+func AdminInventoryValuationReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "category")
+	if groupBy != "category" && groupBy != "brand" && groupBy != "location" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be category, brand or location"})
+		return
+	}
+
+	asOf := time.Now()
+	if d := c.Query("date"); d != "" {
+		parsed, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		asOf = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+	method := costingMethod()
+
+	// Cost layers received up to the report date, newest first per item
+	layerRows, err := DB.Query(`
+		SELECT item_type, item_id::text, quantity, unit_cost
+		FROM stock_receipt_lines
+		WHERE received_at <= $1
+		ORDER BY received_at DESC, id DESC`, asOf)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cost layers"})
+		return
+	}
+	layers := map[string][]costLayer{}
+	for layerRows.Next() {
+		var itemType, itemID string
+		var l costLayer
+		if err := layerRows.Scan(&itemType, &itemID, &l.quantity, &l.unitCost); err != nil {
+			continue
+		}
+		layers[itemType+":"+itemID] = append(layers[itemType+":"+itemID], l)
+	}
+	layerRows.Close()
+
+	rows, err := DB.Query(valuationItemsSQL, asOf)
+	if err != nil {
+		fmt.Printf("❌ Failed to compute valuation quantities: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute inventory valuation"})
+		return
+	}
+	defer rows.Close()
+
+	type Group struct {
+		Name     string  `json:"name"`
+		Items    int     `json:"items"`
+		Quantity int     `json:"quantity"`
+		Value    float64 `json:"value"`
+	}
+	groups := map[string]*Group{}
+	totalQuantity, totalValue := 0, 0.0
+	for rows.Next() {
+		var itemType, itemID, category, brand, location string
+		var quantity int
+		if err := rows.Scan(&itemType, &itemID, &category, &brand, &location, &quantity); err != nil {
+			continue
+		}
+		if quantity <= 0 {
+			continue
+		}
+		key := category
+		if groupBy == "brand" {
+			key = brand
+		} else if groupBy == "location" {
+			key = location
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &Group{Name: key}
+			groups[key] = g
+		}
+		value := valueAtDate(quantity, layers[itemType+":"+itemID], method)
+		g.Items++
+		g.Quantity += quantity
+		g.Value += value
+		totalQuantity += quantity
+		totalValue += value
+	}
+
+	out := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value > out[j].Value })
+
+	if c.Query("format") == "csv" {
+		records := make([][]string, 0, len(out))
+		for _, g := range out {
+			records = append(records, []string{g.Name, strconv.Itoa(g.Items), strconv.Itoa(g.Quantity), strconv.FormatFloat(g.Value, 'f', 2, 64)})
+		}
+		writeCSVReport(c, fmt.Sprintf("inventory-valuation-%s-%s.csv", groupBy, asOf.Format("2006-01-02")),
+			[]string{groupBy, "items", "quantity", "value"}, records)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date":           asOf.Format("2006-01-02"),
+		"group_by":       groupBy,
+		"costing_method": method,
+		"groups":         out,
+		"total_quantity": totalQuantity,
+		"total_value":    totalValue,
+	})
+}
+
+// marginGroupSQL maps a group_by value to its key and label expressions over margin_lines
+var marginGroupSQL = map[string][2]string{
+	"order":   {"order_id::text", "MAX(order_number)"},
+	"product": {"product_key", "MAX(product_name)"},
+	"brand":   {"brand_name", "brand_name"},
+}
+
+// AdminMarginReport handles GET /api/v1/admin/reports/margins
+// Query: start, end (YYYY-MM-DD), source (web|pos), group_by (order|product|brand), format=csv
+func AdminMarginReport(c *gin.Context) {
+	groupBy := c.DefaultQuery("group_by", "product")
+	group, ok := marginGroupSQL[groupBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be order, product or brand"})
+		return
+	}
+	source := c.Query("source")
+	if source != "" && source != "web" && source != "pos" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be web or pos"})
+		return
+	}
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -30)
+	if s := c.Query("start"); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date, expected YYYY-MM-DD"})
+			return
+		}
+		start = parsed
+	}
+	if e := c.Query("end"); e != "" {
+		parsed, err := time.Parse("2006-01-02", e)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date, expected YYYY-MM-DD"})
+			return
+		}
+		end = parsed.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	query := `
+		WITH margin_lines AS (
+			SELECT o.id AS order_id, o.order_number,
+			       COALESCE(oi.item_type, 'sku') AS item_type,
+			       CASE
+			           WHEN oi.item_type = 'melhaf_color' THEN 'melhaf:' || oi.item_id::text
+			           WHEN oi.item_type = 'perfume_variant' THEN 'perfume:' || COALESCE(pc.perfume_id::text, oi.item_id::text)
+			           ELSE COALESCE(oi.product_id::text, 'unknown')
+			       END AS product_key,
+			       CASE
+			           WHEN oi.item_type = 'melhaf_color' THEN COALESCE(mc.name, oi.color, 'Melhaf')
+			           WHEN oi.item_type = 'perfume_variant' THEN COALESCE(p.name, 'Maison Adrar')
+			           ELSE COALESCE(pm.title, 'Unknown product')
+			       END AS product_name,
+			       CASE
+			           WHEN oi.item_type = 'melhaf_color' THEN 'Melhaf'
+			           WHEN oi.item_type = 'perfume_variant' THEN 'Maison Adrar'
+			           ELSE COALESCE(b.name, 'No brand')
+			       END AS brand_name,
+			       oi.quantity,
+			       oi.total_price AS revenue,
+			       oi.quantity * COALESCE(oi.unit_cost, ic.average_cost, 0) AS cost,
+			       (oi.unit_cost IS NULL AND ic.average_cost IS NULL) AS cost_missing
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			LEFT JOIN product_models pm ON pm.id = oi.product_id
+			LEFT JOIN brands b ON b.id = pm.brand_id
+			LEFT JOIN melhaf_colors mc ON oi.item_type = 'melhaf_color' AND mc.id = oi.item_id
+			LEFT JOIN maison_adrar_perfume_colors pc ON oi.item_type = 'perfume_variant' AND pc.id = oi.item_id
+			LEFT JOIN maison_adrar_perfumes p ON p.id = pc.perfume_id
+			LEFT JOIN item_costs ic ON ic.item_type = COALESCE(oi.item_type, 'sku') AND ic.item_id = COALESCE(oi.item_id, oi.sku_id)
+			WHERE o.created_at BETWEEN $1 AND $2
+			  AND o.status NOT IN ('cancelled', 'returned')
+			  AND ($3 = '' OR COALESCE(o.source, 'web') = $3)
+		)
+		SELECT ` + group[0] + `, ` + group[1] + `,
+		       SUM(quantity), SUM(revenue), SUM(cost), COUNT(*) FILTER (WHERE cost_missing)
+		FROM margin_lines
+		GROUP BY ` + group[0] + `
+		ORDER BY SUM(revenue) - SUM(cost) DESC`
+
+	rows, err := DB.Query(query, start, end, source)
+	if err != nil {
+		fmt.Printf("❌ Failed to compute margins: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute margins"})
+		return
+	}
+	defer rows.Close()
+
+	type Margin struct {
+		Key          string  `json:"key"`
+		Name         string  `json:"name"`
+		Quantity     int     `json:"quantity"`
+		Revenue      float64 `json:"revenue"`
+		Cost         float64 `json:"cost"`
+		GrossMargin  float64 `json:"gross_margin"`
+		MarginPct    float64 `json:"margin_percent"`
+		UncostedRows int     `json:"uncosted_lines"`
+	}
+
+	out := []Margin{}
+	var totalRevenue, totalCost float64
+	for rows.Next() {
+		var m Margin
+		if err := rows.Scan(&m.Key, &m.Name, &m.Quantity, &m.Revenue, &m.Cost, &m.UncostedRows); err != nil {
+			continue
+		}
+		m.GrossMargin = m.Revenue - m.Cost
+		if m.Revenue != 0 {
+			m.MarginPct = m.GrossMargin / m.Revenue * 100
+		}
+		totalRevenue += m.Revenue
+		totalCost += m.Cost
+		out = append(out, m)
+	}
+
+	if c.Query("format") == "csv" {
+		records := make([][]string, 0, len(out))
+		for _, m := range out {
+			records = append(records, []string{
+				m.Key, m.Name, strconv.Itoa(m.Quantity),
+				strconv.FormatFloat(m.Revenue, 'f', 2, 64),
+				strconv.FormatFloat(m.Cost, 'f', 2, 64),
+				strconv.FormatFloat(m.GrossMargin, 'f', 2, 64),
+				strconv.FormatFloat(m.MarginPct, 'f', 2, 64),
+				strconv.Itoa(m.UncostedRows),
+			})
+		}
+		writeCSVReport(c, fmt.Sprintf("margins-%s-%s-%s.csv", groupBy, start.Format("2006-01-02"), end.Format("2006-01-02")),
+			[]string{groupBy, "name", "quantity", "revenue", "cost", "gross_margin", "margin_percent", "uncosted_lines"}, records)
+		return
+	}
+
+	totalMargin := totalRevenue - totalCost
+	marginPct := 0.0
+	if totalRevenue != 0 {
+		marginPct = totalMargin / totalRevenue * 100
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"start":    start.Format("2006-01-02"),
+		"end":      end.Format("2006-01-02"),
+		"source":   source,
+		"group_by": groupBy,
+		"rows":     out,
+		"totals": gin.H{
+			"revenue":        totalRevenue,
+			"cost":           totalCost,
+			"gross_margin":   totalMargin,
+			"margin_percent": marginPct,
+		},
+	})
+}
@@ -210,8 +210,12 @@ func AdminCreateMelhafCollection(c *gin.Context) {
 	for _, colorData := range req.Colors {
 		colorID := uuid.New()
 		
-		// Auto-generate EAN code (13 digits)
-		eanCode := generateMelhafEANCode(req.Name, colorData.Name)
+		// Issue an EAN-13 from the barcode numbering sequence
+		eanCode, err := allocateEAN(tx)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate EAN"})
+			return
+		}
 
 		_, err = tx.Exec(`
 			INSERT INTO melhaf_colors (id, collection_id, name, name_ar, color_code, price, discount, ean, is_active, sort_order, created_at, updated_at)
@@ -249,6 +253,11 @@ func AdminCreateMelhafCollection(c *gin.Context) {
 		})
 	}
 
+	if _, err = reclassifyColors(tx, "melhaf", "collection_id = $1", collectionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify colors"})
+		return
+	}
+
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit collection"})
@@ -265,52 +274,6 @@ func AdminCreateMelhafCollection(c *gin.Context) {
 	})
 }
 
-// generateMelhafEANCode generates a 13-digit EAN code for melhaf colors
-func generateMelhafEANCode(collectionName, colorName string) string {
-	// Create a unique string from collection and color info
-	baseString := fmt.Sprintf("%s%s%d", collectionName, colorName, time.Now().UnixNano())
-
-	// Convert to numeric representation (only digits)
-	var numericString strings.Builder
-	for _, char := range baseString {
-		if char >= '0' && char <= '9' {
-			numericString.WriteRune(char)
-		} else if char >= 'A' && char <= 'Z' {
-			// Convert letters to numbers (A=1, B=2, etc.)
-			numericString.WriteString(fmt.Sprintf("%d", int(char-'A')+1))
-		} else if char >= 'a' && char <= 'z' {
-			// Convert lowercase letters to numbers
-			numericString.WriteString(fmt.Sprintf("%d", int(char-'a')+1))
-		}
-	}
-
-	// Take last 12 digits (to ensure uniqueness from timestamp)
-	base := numericString.String()
-	if len(base) > 12 {
-		base = base[len(base)-12:]
-	} else {
-		// Pad with zeros to make it exactly 12 digits
-		for len(base) < 12 {
-			base = "0" + base
-		}
-	}
-
-	// Calculate EAN-13 check digit (proper algorithm)
-	sum := 0
-	for i, digit := range base {
-		num := int(digit - '0')
-		if i%2 == 0 {
-			sum += num
-		} else {
-			sum += num * 3
-		}
-	}
-	checkDigit := (10 - (sum % 10)) % 10
-
-	// Return 13-digit EAN code
-	return fmt.Sprintf("%s%d", base, checkDigit)
-}
-
 // AdminGetMelhafCollection handles GET /api/v1/admin/melhaf/collections/:id
 func AdminGetMelhafCollection(c *gin.Context) {
 	id := c.Param("id")
@@ -505,7 +468,7 @@ func AdminCreateMelhafColor(c *gin.Context) {
 		return
 	}
 
-	// Get collection name for EAN generation
+	// Check the collection exists
 	var collectionName string
 	err = database.Database.QueryRow(`SELECT name FROM melhaf_collections WHERE id = $1`, collectionID).Scan(&collectionName)
 	if err != nil {
@@ -513,8 +476,12 @@ func AdminCreateMelhafColor(c *gin.Context) {
 		return
 	}
 
-	// Auto-generate EAN code (13 digits)
-	eanCode := generateMelhafEANCode(collectionName, req.Name)
+	// Issue an EAN-13 from the barcode numbering sequence
+	eanCode, err := allocateEAN(database.Database)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to allocate EAN"})
+		return
+	}
 
 	// Start transaction
 	tx, err := database.Database.Begin()
@@ -554,6 +521,11 @@ func AdminCreateMelhafColor(c *gin.Context) {
 		fmt.Printf("Warning: Failed to create inventory entry: %v\n", err)
 	}
 
+	if _, err = reclassifyColors(tx, "melhaf", "id = $1", colorID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to classify color"})
+		return
+	}
+
 	// Commit transaction
 	if err = tx.Commit(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit color"})
@@ -609,6 +581,9 @@ func AdminUploadMelhafImage(c *gin.Context) {
 
 	fmt.Printf("✅ Image URL saved to database for color %s\n", colorID)
 
+	// Colors without a hex code take their family from the first image
+	queueColorExtraction("melhaf", colorsNeedingExtraction("melhaf", "id::text = $1", colorID))
+
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"data": map[string]interface{}{
@@ -685,17 +660,40 @@ func AdminUpdateMelhafInventory(c *gin.Context) {
 		return
 	}
 
-	_, err := database.Database.Exec(`
+	colorUUID, err := uuid.Parse(colorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid color ID"})
+		return
+	}
+
+	tx, err := database.Database.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := setStockLevel(tx, "melhaf_color", colorUUID, req.Available, "manual_adjustment", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+		return
+	}
+
+	_, err = tx.Exec(`
 		UPDATE melhaf_inventory 
-		SET available = $1, reserved = $2, reorder_point = $3, updated_at = now()
-		WHERE color_id = $4
-	`, req.Available, req.Reserved, req.ReorderPoint, colorID)
+		SET reserved = $1, reorder_point = $2, updated_at = now()
+		WHERE color_id = $3
+	`, req.Reserved, req.ReorderPoint, colorUUID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
@@ -718,15 +716,22 @@ func GetMelhafVideos(c *gin.Context) {
 		JOIN melhaf_collections mc ON mv.collection_id = mc.id
 		WHERE mv.is_active = true
 	`
-	var rows *sql.Rows
-	var err error
+	args := []interface{}{}
 
 	if collectionID != "" {
-		query += " AND mv.collection_id = $1"
-		rows, err = database.Database.Query(query+" ORDER BY mv.sort_order, mv.created_at DESC LIMIT $2", collectionID, limitInt)
-	} else {
-		rows, err = database.Database.Query(query+" ORDER BY mv.sort_order, mv.created_at DESC LIMIT $1", limitInt)
+		args = append(args, collectionID)
+		query += fmt.Sprintf(" AND mv.collection_id = $%d", len(args))
+	}
+	// Only videos whose collection has a color in one of the requested families
+	if colorFamily := c.Query("color_family"); colorFamily != "" {
+		args = append(args, pgTextArray(strings.Split(strings.ToLower(colorFamily), ",")))
+		query += fmt.Sprintf(` AND EXISTS (
+			SELECT 1 FROM melhaf_colors mcf
+			WHERE mcf.collection_id = mv.collection_id AND mcf.is_active = true AND mcf.color_family = ANY($%d::text[])
+		)`, len(args))
 	}
+	args = append(args, limitInt)
+	rows, err := database.Database.Query(query+fmt.Sprintf(" ORDER BY mv.sort_order, mv.created_at DESC LIMIT $%d", len(args)), args...)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch videos"})
@@ -751,7 +756,8 @@ func GetMelhafVideos(c *gin.Context) {
 		// Get collection colors
 		colorRows, _ := database.Database.Query(`
 			SELECT mc.id, mc.name, mc.name_ar, mc.color_code, mc.price, mc.discount,
-			       COALESCE((SELECT url FROM melhaf_color_images WHERE color_id = mc.id ORDER BY position LIMIT 1), '') as image_url
+			       COALESCE((SELECT url FROM melhaf_color_images WHERE color_id = mc.id ORDER BY position LIMIT 1), '') as image_url,
+			       mc.color_family
 			FROM melhaf_colors mc
 			WHERE mc.collection_id = $1 AND mc.is_active = true
 			ORDER BY mc.sort_order
@@ -764,17 +770,18 @@ func GetMelhafVideos(c *gin.Context) {
 			var nameAr, colorCode sql.NullString
 			var price float64
 			var discount sql.NullFloat64
-			var imageURL sql.NullString
+			var imageURL, colorFamily sql.NullString
 
-			if err := colorRows.Scan(&colorID, &colorName, &nameAr, &colorCode, &price, &discount, &imageURL); err == nil {
+			if err := colorRows.Scan(&colorID, &colorName, &nameAr, &colorCode, &price, &discount, &imageURL, &colorFamily); err == nil {
 				colors = append(colors, map[string]interface{}{
-					"id":         colorID.String(),
-					"name":       colorName,
-					"name_ar":    nameAr.String,
-					"color_code": colorCode.String,
-					"price":      price,
-					"discount":   discount.Float64,
-					"image_url":  imageURL.String,
+					"id":           colorID.String(),
+					"name":         colorName,
+					"name_ar":      nameAr.String,
+					"color_code":   colorCode.String,
+					"price":        price,
+					"discount":     discount.Float64,
+					"image_url":    imageURL.String,
+					"color_family": colorFamily.String,
 				})
 			}
 		}
@@ -844,7 +851,19 @@ func GetMelhafVideos(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": videos})
+	// Color family facet over active melhaf colors
+	var colorFamilies []ColorFamilyFilter
+	familyRows, err := database.Database.Query(`
+		SELECT color_family, COUNT(*) FROM melhaf_colors
+		WHERE is_active = true AND color_family IS NOT NULL
+		GROUP BY color_family
+	`)
+	if err == nil {
+		colorFamilies = colorFamilyFacet(familyRows)
+		familyRows.Close()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": videos, "facets": gin.H{"color_families": colorFamilies}})
 }
 
 // ==================== VIDEO LIKES & REACTIONS ====================
@@ -69,7 +69,7 @@ func ValidateProductVariant(c *gin.Context) {
 		FROM skus s
 		JOIN product_models pm ON s.product_model_id = pm.id
 		LEFT JOIN inventory i ON s.id = i.sku_id
-		LEFT JOIN prices p ON s.id = p.sku_id
+		LEFT JOIN active_prices p ON s.id = p.sku_id
 		WHERE s.id = $1 AND pm.id = $2 AND pm.is_active = true
 		ORDER BY p.created_at DESC
 		LIMIT 1`
@@ -127,14 +127,8 @@ func ValidateProductVariant(c *gin.Context) {
 
 	// Validate size if provided
 	if request.Size != nil && *request.Size != "" {
-		var sizeExists bool
-		err = database.Database.QueryRow(`
-			SELECT EXISTS(
-				SELECT 1 FROM size_charts sc
-				JOIN skus s ON sc.id = s.size_chart_id
-				WHERE s.id = $1 AND sc.size_name = $2
-			)`, request.SKUID, *request.Size).Scan(&sizeExists)
-		
+		sizeExists, err := skuHasSize(request.SKUID, *request.Size)
+
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate size"})
 			return
@@ -222,7 +216,7 @@ func ValidateCartItems(c *gin.Context) {
 			FROM skus s
 			JOIN product_models pm ON s.product_model_id = pm.id
 			LEFT JOIN inventory i ON s.id = i.sku_id
-			LEFT JOIN prices p ON s.id = p.sku_id
+			LEFT JOIN active_prices p ON s.id = p.sku_id
 			WHERE s.id = $1 AND pm.id = $2 AND pm.is_active = true
 			ORDER BY p.created_at DESC
 			LIMIT 1`
@@ -278,14 +272,8 @@ func ValidateCartItems(c *gin.Context) {
 
 		// Validate size if provided
 		if item.Size != nil && *item.Size != "" {
-			var sizeExists bool
-			err = database.Database.QueryRow(`
-				SELECT EXISTS(
-					SELECT 1 FROM size_charts sc
-					JOIN skus s ON sc.id = s.size_chart_id
-					WHERE s.id = $1 AND sc.size_name = $2
-				)`, item.SKUID, *item.Size).Scan(&sizeExists)
-			
+			sizeExists, err := skuHasSize(item.SKUID, *item.Size)
+
 			if err != nil || !sizeExists {
 				validationResults = append(validationResults, map[string]interface{}{
 					"product_id": item.ProductID,
@@ -41,6 +41,7 @@ func CreateOrder(c *gin.Context) {
             Size      *string `json:"size"`
             Color     *string `json:"color"`
             MaisonAdrarColorID *string `json:"maison_adrar_color_id"`
+            BundleID           *string `json:"bundle_id"`
         } `json:"items" binding:"required"`
 		DeliveryAddress struct {
 			AddressID  string   `json:"address_id" binding:"required"`
@@ -155,6 +156,31 @@ func CreateOrder(c *gin.Context) {
         fmt.Printf("Processing order item: ProductID=%s, SKUID=%s, Quantity=%d, MA_ColorID=%v\n", 
             item.ProductID, item.SKUID, item.Quantity, item.MaisonAdrarColorID)
 		
+        // Bundles take every component out of stock and are priced server-side
+        if item.BundleID != nil && *item.BundleID != "" {
+            bundleID, err := uuid.Parse(*item.BundleID)
+            if err != nil {
+                c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle_id"})
+                return
+            }
+            line, err := sellBundle(tx, bundleID, item.Quantity, 0, orderID, userID)
+            if err != nil {
+                bundleSaleErrorResponse(c, *item.BundleID, err)
+                return
+            }
+            orderItems = append(orderItems, map[string]interface{}{
+                "id": line.ID.String(),
+                "bundle_id": line.BundleID.String(),
+                "product_name": line.Name,
+                "product_image": line.ImageURL,
+                "quantity": line.Quantity,
+                "unit_price": line.UnitPrice,
+                "total_price": line.TotalPrice,
+                "components": line.Components,
+            })
+            continue
+        }
+
         // Maison Adrar perfume color handling (no SKU path)
         if item.MaisonAdrarColorID != nil && *item.MaisonAdrarColorID != "" {
             candidateUUID, err := uuid.Parse(*item.MaisonAdrarColorID)
@@ -166,12 +192,12 @@ func CreateOrder(c *gin.Context) {
             // First, try treating provided ID as a color ID
             var stock int
             colorUUID := candidateUUID
-            err = tx.QueryRow(`SELECT COALESCE(stock,0) FROM maison_adrar_perfume_colors WHERE id = $1`, colorUUID).Scan(&stock)
+            err = tx.QueryRow(`SELECT COALESCE(stock,0) FROM maison_adrar_perfume_colors WHERE id = $1 FOR UPDATE`, colorUUID).Scan(&stock)
             if err == sql.ErrNoRows {
                 // Fallback: treat provided ID as a perfume ID and pick a variant (highest stock)
                 var fallbackColor uuid.UUID
                 var fallbackStock int
-                q := `SELECT id, COALESCE(stock,0) FROM maison_adrar_perfume_colors WHERE perfume_id = $1 ORDER BY COALESCE(stock,0) DESC, sort_order ASC LIMIT 1`
+                q := `SELECT id, COALESCE(stock,0) FROM maison_adrar_perfume_colors WHERE perfume_id = $1 ORDER BY COALESCE(stock,0) DESC, sort_order ASC LIMIT 1 FOR UPDATE`
                 if err2 := tx.QueryRow(q, candidateUUID).Scan(&fallbackColor, &fallbackStock); err2 == nil {
                     colorUUID = fallbackColor
                     stock = fallbackStock
@@ -197,13 +223,19 @@ func CreateOrder(c *gin.Context) {
                 return
             }
 
+            unitCost, err := recordSale(tx, "perfume_variant", colorUUID, stock, item.Quantity, orderID, userID)
+            if err != nil {
+                c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record perfume stock movement"})
+                return
+            }
+
             // Create order item, store color id in color field for traceability
             orderItemID := uuid.New()
             totalPrice := item.Price * float64(item.Quantity)
             _, err = tx.Exec(`
-                INSERT INTO order_items (id, order_id, product_id, sku_id, quantity, unit_price, total_price, size, color, created_at)
-                VALUES ($1,$2,NULL,NULL,$3,$4,$5,$6,$7, now())
-            `, orderItemID, orderID, item.Quantity, item.Price, totalPrice, item.Size, item.MaisonAdrarColorID)
+                INSERT INTO order_items (id, order_id, product_id, sku_id, quantity, unit_price, total_price, size, color, item_type, item_id, unit_cost, created_at)
+                VALUES ($1,$2,NULL,NULL,$3,$4,$5,$6,$7,'perfume_variant',$8,$9, now())
+            `, orderItemID, orderID, item.Quantity, item.Price, totalPrice, item.Size, item.MaisonAdrarColorID, colorUUID, unitCost)
             if err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order item"})
                 return
@@ -257,7 +289,8 @@ func CreateOrder(c *gin.Context) {
 			err = tx.QueryRow(`
 				SELECT COALESCE(available, 0), COALESCE(reserved, 0) 
 				FROM melhaf_inventory 
-				WHERE color_id = $1`, productID).Scan(&currentQuantity, &reservedQuantity)
+				WHERE color_id = $1
+				FOR UPDATE`, productID).Scan(&currentQuantity, &reservedQuantity)
 			
 			if err != nil {
 				if err == sql.ErrNoRows {
@@ -318,6 +351,13 @@ func CreateOrder(c *gin.Context) {
 				return
 			}
 
+			unitCost, err := recordSale(tx, "melhaf_color", productID, currentQuantity, item.Quantity, orderID, userID)
+			if err != nil {
+				fmt.Printf("❌ Failed to record Melhaf stock movement: %v\n", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record Melhaf stock movement"})
+				return
+			}
+
 			// Create order item for Melhaf (use NULL for product_id since it's not in product_models)
 			orderItemID := uuid.New()
 			fmt.Printf("🆔 Creating Melhaf order item: ID=%s, OrderID=%s, ColorID=%s\n", 
@@ -345,8 +385,9 @@ func CreateOrder(c *gin.Context) {
             orderItemQuery := `
                 INSERT INTO order_items (
                     id, order_id, product_id, sku_id, quantity,
-                    unit_price, total_price, size, color, created_at
-                ) VALUES ($1, $2, NULL, NULL, $3, $4, $5, NULL, $6, $7)`
+                    unit_price, total_price, size, color,
+                    item_type, item_id, unit_cost, created_at
+                ) VALUES ($1, $2, NULL, NULL, $3, $4, $5, NULL, $6, 'melhaf_color', $7, $8, $9)`
 
 			totalPrice := item.Price * float64(item.Quantity)
 			fmt.Printf("💰 Melhaf order item prices: Unit=%.2f, Quantity=%d, Total=%.2f\n", 
@@ -356,7 +397,7 @@ func CreateOrder(c *gin.Context) {
                 orderItemID, orderID,
                 item.Quantity,
                 item.Price, totalPrice,
-                melhafName.String, now,
+                melhafName.String, productID, unitCost, now,
             )
 
 			if err != nil {
@@ -440,7 +481,8 @@ func CreateOrder(c *gin.Context) {
 		err = tx.QueryRow(`
 			SELECT COALESCE(available, 0), COALESCE(reserved, 0) 
 			FROM inventory 
-			WHERE sku_id = $1`, skuID).Scan(&currentQuantity, &reservedQuantity)
+			WHERE sku_id = $1
+			FOR UPDATE`, skuID).Scan(&currentQuantity, &reservedQuantity)
 		
 		fmt.Printf("📦 Inventory check: SKUID=%s, ProductID=%s, Available=%d, Reserved=%d, Requested=%d\n", 
 			skuID, productID, currentQuantity, reservedQuantity, item.Quantity)
@@ -505,6 +547,13 @@ func CreateOrder(c *gin.Context) {
 			return
 		}
 
+		unitCost, err := recordSale(tx, "sku", skuID, currentQuantity, item.Quantity, orderID, userID)
+		if err != nil {
+			fmt.Printf("❌ Failed to record stock movement: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement"})
+			return
+		}
+
 		// Create order item
 		orderItemID := uuid.New()
 		fmt.Printf("🆔 Creating order item: ID=%s, OrderID=%s, ProductID=%s, SKUID=%s\n", 
@@ -513,8 +562,9 @@ func CreateOrder(c *gin.Context) {
 		orderItemQuery := `
 			INSERT INTO order_items (
 				id, order_id, product_id, sku_id, quantity, 
-				unit_price, total_price, size, color, created_at
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+				unit_price, total_price, size, color,
+				item_type, item_id, unit_cost, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'sku', $4, $10, $11)`
 
 		totalPrice := item.Price * float64(item.Quantity)
 		fmt.Printf("💰 Order item prices: Unit=%.2f, Quantity=%d, Total=%.2f\n", 
@@ -522,7 +572,7 @@ func CreateOrder(c *gin.Context) {
 
 		_, err = tx.Exec(orderItemQuery,
 			orderItemID, orderID, productID, skuID, item.Quantity,
-			item.Price, totalPrice, item.Size, item.Color, now,
+			item.Price, totalPrice, item.Size, item.Color, unitCost, now,
 		)
 
 		if err != nil {
@@ -750,6 +800,9 @@ func GetOrder(c *gin.Context) {
 	}
 
 	order.Items = items
+	if bundles, err := orderBundleItems(orderID); err == nil && len(bundles) > 0 {
+		order.Bundles = bundles
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
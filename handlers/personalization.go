@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// personalizationWeight is how far a customer's affinity can move an item from its
+// default position
+const personalizationWeight = 0.35
+
+// personalizationPool is how many popular products a list of limit is picked from
+// when re-ranked for a customer
+func personalizationPool(limit int) int {
+	if limit*3 > 150 {
+		return 150
+	}
+	return limit * 3
+}
+
+// customerAffinity returns the signed-in customer's affinity profile, or nil to
+// keep the default ranking: for guests, customers without history and
+// ?personalize=false
+func customerAffinity(c *gin.Context) *services.UserAffinity {
+	userID := c.GetString("user_id")
+	if userID == "" || c.Query("personalize") == "false" {
+		return nil
+	}
+	affinity, err := services.LoadUserAffinity(userID)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to load affinity of user %s: %v\n", userID, err)
+		return nil
+	}
+	if affinity.Empty() {
+		return nil
+	}
+	return affinity
+}
+
+// personalizeOrder re-ranks products given in their default order: each keeps
+// 1-personalizationWeight of a score from its position and gains
+// personalizationWeight of its affinity. It returns the new order as indexes into
+// productIDs and the reason for each product that has one.
+func personalizeOrder(affinity *services.UserAffinity, productIDs []string) ([]int, map[string]string) {
+	order := make([]int, len(productIDs))
+	for i := range order {
+		order[i] = i
+	}
+	reasons := map[string]string{}
+	traits, err := services.LoadProductTraits(productIDs)
+	if err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+		return order, reasons
+	}
+
+	scores := make([]float64, len(productIDs))
+	for i, id := range productIDs {
+		match := affinity.Match(traits[id])
+		position := 1 - float64(i)/float64(len(productIDs))
+		scores[i] = (1-personalizationWeight)*position + personalizationWeight*match.Score
+		if match.Reason != "" {
+			reasons[id] = match.Reason
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	return order, reasons
+}
+
+// personalizeProductMaps re-ranks product maps (with an "id") for the customer and
+// adds each one's "reason"
+func personalizeProductMaps(affinity *services.UserAffinity, products []map[string]interface{}) []map[string]interface{} {
+	if len(products) < 2 {
+		return products
+	}
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i], _ = p["id"].(string)
+	}
+	order, reasons := personalizeOrder(affinity, ids)
+	ranked := make([]map[string]interface{}, len(products))
+	for i, j := range order {
+		ranked[i] = products[j]
+		if reason, ok := reasons[ids[j]]; ok {
+			ranked[i]["reason"] = reason
+		}
+	}
+	return ranked
+}
+
+// personalizeSearchResults re-ranks a page of search results for the customer and
+// sets each one's Reason
+func personalizeSearchResults(affinity *services.UserAffinity, products []ProductSearchResult) []ProductSearchResult {
+	if len(products) < 2 {
+		return products
+	}
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	order, reasons := personalizeOrder(affinity, ids)
+	ranked := make([]ProductSearchResult, len(products))
+	for i, j := range order {
+		ranked[i] = products[j]
+		ranked[i].Reason = reasons[ids[j]]
+	}
+	return ranked
+}
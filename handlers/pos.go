@@ -43,8 +43,9 @@ func GetPOSCatalog(c *gin.Context) {
 		args = append(args, colorID)
 	}
 	if size != "" {
-		conditions = append(conditions, "s.size = $"+strconv.Itoa(len(args)+1))
-		args = append(args, size)
+		sizeCond, sizeArgs := sizeFilterSQL("s", size, len(args)+1)
+		conditions = append(conditions, sizeCond)
+		args = append(args, sizeArgs...)
 	}
 	if query != "" {
 		conditions = append(conditions, "(pm.title ILIKE $"+strconv.Itoa(len(args)+1)+" OR b.name ILIKE $"+strconv.Itoa(len(args)+2)+")")
@@ -69,7 +70,7 @@ func GetPOSCatalog(c *gin.Context) {
         JOIN product_colors pc ON s.product_color_id = pc.id
         JOIN brands b ON pm.brand_id = b.id
         LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-        LEFT JOIN prices p ON p.sku_id = s.id
+        LEFT JOIN active_prices p ON p.sku_id = s.id
         LEFT JOIN inventory i ON i.sku_id = s.id
         LEFT JOIN LATERAL (
            SELECT url as image_url FROM product_images pi
@@ -78,7 +79,7 @@ func GetPOSCatalog(c *gin.Context) {
            LIMIT 1
         ) img ON true
         WHERE ` + strings.Join(conditions, " AND ") + `
-        ORDER BY pm.title, b.name, pc.color_name, s.size_normalized
+        ORDER BY pm.title, b.name, pc.color_name, s.size_rank NULLS LAST, s.size_normalized
     `
 
 	rows, err := DB.Query(querySQL, args...)
@@ -251,10 +252,11 @@ func GetProductVariants(c *gin.Context) {
 	
 	// Get available sizes for this product model
 	sizesQuery := `
-		SELECT DISTINCT s.size, s.size_normalized
+		SELECT s.size, s.size_normalized
 		FROM skus s
 		WHERE s.product_model_id = $1 AND s.size IS NOT NULL
-		ORDER BY s.size_normalized
+		GROUP BY s.size, s.size_normalized
+		ORDER BY MIN(s.size_rank) NULLS LAST, s.size_normalized
 	`
 	
 	rows, err = DB.Query(sizesQuery, productModelID)
@@ -288,9 +290,10 @@ func CreatePOSOrder(c *gin.Context) {
 		CustomerID       *string `json:"customer_id"`
 		Items            []struct {
 			SKUID    string  `json:"sku_id"`
-			Quantity int     `json:"quantity"`
+			BundleID string  `json:"bundle_id"`
+			Quantity int     `json:"quantity" binding:"required,min=1"`
 			UnitPrice float64 `json:"unit_price"`
-		} `json:"items"`
+		} `json:"items" binding:"dive"`
 		Currency         string  `json:"currency"`
 		PaymentMethodID  string  `json:"payment_method_id"`
 		TenderedAmount   float64 `json:"tendered_amount"`
@@ -358,29 +361,37 @@ func CreatePOSOrder(c *gin.Context) {
 		return
 	}
 
-	// Calculate totals
-	total := 0.0
-	for _, it := range req.Items { total += float64(it.Quantity) * it.UnitPrice }
-	change := req.TenderedAmount - total
-	if change < 0 { change = 0 }
-
 	tx, err := DB.Begin()
     if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()}); return }
 	defer tx.Rollback()
 
-	// Insert order
+	// Insert order; the totals are set once the lines are priced
 	insertOrder := `INSERT INTO orders (id, user_id, order_number, status, total_amount, currency, payment_method_id, tendered_amount, change_due, created_at, updated_at, source) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now(), 'pos')`
-    _, err = tx.Exec(insertOrder, orderID, userID, orderNumber, "paid", total, req.Currency, pmID, req.TenderedAmount, change)
+    _, err = tx.Exec(insertOrder, orderID, userID, orderNumber, "paid", 0, req.Currency, pmID, req.TenderedAmount, 0)
     if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order", "details": err.Error()}); return }
 
 	// Insert items and update inventory
+	total := 0.0
 	for _, it := range req.Items {
+		if it.BundleID != "" {
+			bundleID, err := uuid.Parse(it.BundleID)
+			if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bundle id"}); return }
+			line, err := sellBundle(tx, bundleID, it.Quantity, it.UnitPrice, orderID, c.GetString("user_id"))
+			if err != nil {
+				bundleSaleErrorResponse(c, it.BundleID, err)
+				return
+			}
+			total += line.TotalPrice
+			continue
+		}
+
 		skuID, err := uuid.Parse(it.SKUID)
 		if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sku id"}); return }
 		
-		// Check available inventory before updating
+		// Check available inventory before updating, locking the row so the movement
+		// records the stock this sale started from
 		var available int
-		checkQuery := `SELECT available FROM inventory WHERE sku_id = $1`
+		checkQuery := `SELECT available FROM inventory WHERE sku_id = $1 FOR UPDATE`
 		err = tx.QueryRow(checkQuery, skuID).Scan(&available)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check inventory"})
@@ -392,9 +403,15 @@ func CreatePOSOrder(c *gin.Context) {
 			return
 		}
 		
+		unitCost, err := recordSale(tx, "sku", skuID, available, it.Quantity, orderID, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record stock movement", "sku_id": skuID})
+			return
+		}
+
 		itemID := uuid.New()
 		totalPrice := float64(it.Quantity) * it.UnitPrice
-        _, err = tx.Exec(`INSERT INTO order_items (id, order_id, sku_id, quantity, unit_price, total_price) VALUES ($1,$2,$3,$4,$5,$6)`, itemID, orderID, skuID, it.Quantity, it.UnitPrice, totalPrice)
+        _, err = tx.Exec(`INSERT INTO order_items (id, order_id, sku_id, quantity, unit_price, total_price, item_type, item_id, unit_cost) VALUES ($1,$2,$3,$4,$5,$6,'sku',$3,$7)`, itemID, orderID, skuID, it.Quantity, it.UnitPrice, totalPrice, unitCost)
         if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order item", "details": err.Error(), "sku_id": skuID}); return }
 		
 		// Update inventory with atomic operation
@@ -405,6 +422,14 @@ func CreatePOSOrder(c *gin.Context) {
             c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient inventory or SKU not found", "sku_id": skuID})
             return
         }
+		total += totalPrice
+	}
+
+	change := req.TenderedAmount - total
+	if change < 0 { change = 0 }
+	if _, err := tx.Exec(`UPDATE orders SET total_amount = $2, change_due = $3 WHERE id = $1`, orderID, total, change); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set order total", "details": err.Error()})
+		return
 	}
 
     if err := tx.Commit(); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit order", "details": err.Error()}); return }
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// priceView is a price row of a SKU. Status is relative to the resolution time:
+// active (the price customers pay), scheduled, expired or overridden.
+type priceView struct {
+	ID          uuid.UUID  `json:"id"`
+	SKUID       uuid.UUID  `json:"sku_id"`
+	Currency    string     `json:"currency"`
+	ListPrice   float64    `json:"list_price"`
+	SalePrice   *float64   `json:"sale_price"`
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at"`
+	SaleEventID *uuid.UUID `json:"sale_event_id"`
+	IsBase      bool       `json:"is_base"`
+	Status      string     `json:"status,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// resolvedPrice is the price of a SKU at a given time, as picked by prices_at
+type resolvedPrice struct {
+	PriceID        uuid.UUID  `json:"price_id"`
+	Currency       string     `json:"currency"`
+	ListPrice      float64    `json:"list_price"`
+	SalePrice      *float64   `json:"sale_price"`
+	EffectivePrice float64    `json:"effective_price"`
+	StartAt        *time.Time `json:"start_at"`
+	EndAt          *time.Time `json:"end_at"`
+	SaleEventID    *uuid.UUID `json:"sale_event_id"`
+	At             time.Time  `json:"at"`
+}
+
+const priceColumns = `id, sku_id, currency, list_price, sale_price, start_at, end_at, sale_event_id, created_at`
+
+func scanPrice(row interface{ Scan(...interface{}) error }) (*priceView, error) {
+	var p priceView
+	if err := row.Scan(&p.ID, &p.SKUID, &p.Currency, &p.ListPrice, &p.SalePrice, &p.StartAt, &p.EndAt,
+		&p.SaleEventID, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.Currency = strings.TrimSpace(p.Currency)
+	p.IsBase = p.StartAt == nil && p.EndAt == nil
+	return &p, nil
+}
+
+// setPriceChangeContext tags the price changes of a transaction for price_history:
+// where they come from (admin, import, sale_event) and which admin made them
+func setPriceChangeContext(q sqlHandle, source, userID string) error {
+	if _, err := uuid.Parse(userID); err != nil {
+		userID = ""
+	}
+	_, err := q.Exec(`SELECT set_config('fmbq.price_source', $1, true), set_config('fmbq.price_actor', $2, true)`, source, userID)
+	return err
+}
+
+// setBasePrice updates the base price of a SKU, creating it when the SKU has none.
+// Scheduled rows are left alone so an edit never wipes a planned sale.
+func setBasePrice(q sqlHandle, skuID interface{}, currency string, listPrice float64, salePrice *float64) error {
+	res, err := q.Exec(`
+		UPDATE prices SET list_price = $3, sale_price = $4, updated_at = now()
+		WHERE sku_id = $1 AND currency = $2 AND start_at IS NULL AND end_at IS NULL`,
+		skuID, currency, listPrice, salePrice)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	_, err = q.Exec(`
+		INSERT INTO prices (id, sku_id, currency, list_price, sale_price, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, now(), now())`, skuID, currency, listPrice, salePrice)
+	return err
+}
+
+// resolvePrice returns the price of a SKU in a currency at a time, nil if it has none
+func resolvePrice(q sqlHandle, skuID, currency string, at time.Time) (*resolvedPrice, error) {
+	p := resolvedPrice{At: at}
+	err := q.QueryRow(`
+		SELECT id, currency, list_price, sale_price, effective_price, start_at, end_at, sale_event_id
+		FROM prices_at($3)
+		WHERE sku_id::text = $1 AND currency = $2`, skuID, currency, at).Scan(
+		&p.PriceID, &p.Currency, &p.ListPrice, &p.SalePrice, &p.EffectivePrice, &p.StartAt, &p.EndAt, &p.SaleEventID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.Currency = strings.TrimSpace(p.Currency)
+	return &p, nil
+}
+
+// priceQueryTime reads the optional ?at= (RFC 3339 or YYYY-MM-DD) resolution time
+func priceQueryTime(c *gin.Context) (time.Time, bool) {
+	at := c.Query("at")
+	if at == "" {
+		return time.Now(), true
+	}
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse("2006-01-02", at); err == nil {
+		return t, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time or a YYYY-MM-DD date"})
+	return time.Time{}, false
+}
+
+type priceRequest struct {
+	Currency  string     `json:"currency"`
+	ListPrice float64    `json:"list_price" binding:"required"`
+	SalePrice *float64   `json:"sale_price"`
+	StartAt   *time.Time `json:"start_at"`
+	EndAt     *time.Time `json:"end_at"`
+}
+
+func bindPriceRequest(c *gin.Context) (*priceRequest, bool) {
+	var req priceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = "MRO"
+	}
+	if len(req.Currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter code"})
+		return nil, false
+	}
+	if req.ListPrice <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "list_price must be positive"})
+		return nil, false
+	}
+	if req.SalePrice != nil && (*req.SalePrice < 0 || *req.SalePrice > req.ListPrice) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sale_price must be between 0 and list_price"})
+		return nil, false
+	}
+	if req.StartAt != nil && req.EndAt != nil && !req.EndAt.After(*req.StartAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// AdminListSKUPrices handles GET /api/v1/admin/skus/:id/prices
+// Lists every price row of the SKU and the price resolved at ?at= (default now).
+func AdminListSKUPrices(c *gin.Context) {
+	at, ok := priceQueryTime(c)
+	if !ok {
+		return
+	}
+	currency := strings.ToUpper(c.DefaultQuery("currency", "MRO"))
+
+	var exists bool
+	DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM skus WHERE id::text = $1)`, c.Param("id")).Scan(&exists)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU not found"})
+		return
+	}
+
+	resolved, err := resolvePrice(DB, c.Param("id"), currency, at)
+	if err != nil {
+		fmt.Printf("❌ Failed to resolve price: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve price"})
+		return
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+priceColumns+` FROM prices
+		WHERE sku_id::text = $1 AND currency = $2
+		ORDER BY (start_at IS NULL AND end_at IS NULL) DESC, start_at NULLS FIRST, created_at`, c.Param("id"), currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prices"})
+		return
+	}
+	defer rows.Close()
+
+	prices := []*priceView{}
+	for rows.Next() {
+		p, err := scanPrice(rows)
+		if err != nil {
+			continue
+		}
+		switch {
+		case resolved != nil && p.ID == resolved.PriceID:
+			p.Status = "active"
+		case p.StartAt != nil && p.StartAt.After(at):
+			p.Status = "scheduled"
+		case p.EndAt != nil && !p.EndAt.After(at):
+			p.Status = "expired"
+		default:
+			p.Status = "overridden"
+		}
+		prices = append(prices, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"prices": prices, "resolved": resolved})
+}
+
+// AdminCreateSKUPrice handles POST /api/v1/admin/skus/:id/prices
+// A price with a start_at and/or end_at is scheduled; without a window it replaces the base price.
+func AdminCreateSKUPrice(c *gin.Context) {
+	req, ok := bindPriceRequest(c)
+	if !ok {
+		return
+	}
+	if req.EndAt != nil && !req.EndAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at is in the past"})
+		return
+	}
+
+	var skuID uuid.UUID
+	if err := DB.QueryRow(`SELECT id FROM skus WHERE id::text = $1`, c.Param("id")).Scan(&skuID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU not found"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	if err := setPriceChangeContext(tx, "admin", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	if req.StartAt == nil && req.EndAt == nil {
+		err = setBasePrice(tx, skuID, req.Currency, req.ListPrice, req.SalePrice)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO prices (id, sku_id, currency, list_price, sale_price, start_at, end_at, created_at, updated_at)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, now(), now())`,
+			skuID, req.Currency, req.ListPrice, req.SalePrice, req.StartAt, req.EndAt)
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to save price for SKU %s: %v\n", skuID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save price"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save price"})
+		return
+	}
+
+	resolved, _ := resolvePrice(DB, skuID.String(), req.Currency, time.Now())
+	c.JSON(http.StatusCreated, gin.H{"message": "Price saved", "resolved": resolved})
+}
+
+// AdminUpdatePrice handles PUT /api/v1/admin/prices/:id
+// Rows created by a sale event are changed through the event.
+func AdminUpdatePrice(c *gin.Context) {
+	req, ok := bindPriceRequest(c)
+	if !ok {
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	if err := setPriceChangeContext(tx, "admin", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	current, err := scanPrice(tx.QueryRow(`SELECT `+priceColumns+` FROM prices WHERE id::text = $1 FOR UPDATE`, c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
+		return
+	}
+	if current.SaleEventID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This price belongs to a sale event; edit or cancel the event instead"})
+		return
+	}
+	if current.IsBase != (req.StartAt == nil && req.EndAt == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A base price cannot be given a window, nor a scheduled price lose it"})
+		return
+	}
+
+	p, err := scanPrice(tx.QueryRow(`
+		UPDATE prices SET currency = $2, list_price = $3, sale_price = $4, start_at = $5, end_at = $6, updated_at = now()
+		WHERE id = $1
+		RETURNING `+priceColumns, current.ID, req.Currency, req.ListPrice, req.SalePrice, req.StartAt, req.EndAt))
+	if err != nil {
+		fmt.Printf("❌ Failed to update price %s: %v\n", current.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update price"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"price": p})
+}
+
+// AdminDeletePrice handles DELETE /api/v1/admin/prices/:id
+// Only scheduled prices can be deleted; the base price is edited instead.
+func AdminDeletePrice(c *gin.Context) {
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	if err := setPriceChangeContext(tx, "admin", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	current, err := scanPrice(tx.QueryRow(`SELECT `+priceColumns+` FROM prices WHERE id::text = $1 FOR UPDATE`, c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price"})
+		return
+	}
+	if current.IsBase {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The base price cannot be deleted"})
+		return
+	}
+	if current.SaleEventID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This price belongs to a sale event; cancel the event instead"})
+		return
+	}
+
+	if _, err := tx.Exec(`DELETE FROM prices WHERE id = $1`, current.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete price"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete price"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Price deleted"})
+}
+
+// AdminGetSKUPriceHistory handles GET /api/v1/admin/skus/:id/price-history
+func AdminGetSKUPriceHistory(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := DB.Query(`
+		SELECT h.id, h.price_id, h.currency, h.action, h.old_list_price, h.old_sale_price,
+		       h.new_list_price, h.new_sale_price, h.start_at, h.end_at, h.sale_event_id, se.name,
+		       h.source, h.changed_by, COALESCE(u.full_name, u.email, ''), h.created_at
+		FROM price_history h
+		LEFT JOIN sale_events se ON se.id = h.sale_event_id
+		LEFT JOIN users u ON u.id = h.changed_by
+		WHERE h.sku_id::text = $1
+		ORDER BY h.created_at DESC
+		LIMIT $2`, c.Param("id"), limit)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch price history: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history"})
+		return
+	}
+	defer rows.Close()
+
+	history := []gin.H{}
+	for rows.Next() {
+		var id, priceID uuid.UUID
+		var currency, action, source, changedByName string
+		var oldList, oldSale, newList, newSale *float64
+		var startAt, endAt *time.Time
+		var saleEventID, changedBy *uuid.UUID
+		var saleEventName sql.NullString
+		var createdAt time.Time
+		if err := rows.Scan(&id, &priceID, &currency, &action, &oldList, &oldSale, &newList, &newSale,
+			&startAt, &endAt, &saleEventID, &saleEventName, &source, &changedBy, &changedByName, &createdAt); err != nil {
+			continue
+		}
+		history = append(history, gin.H{
+			"id":              id,
+			"price_id":        priceID,
+			"currency":        strings.TrimSpace(currency),
+			"action":          action,
+			"old_list_price":  oldList,
+			"old_sale_price":  oldSale,
+			"new_list_price":  newList,
+			"new_sale_price":  newSale,
+			"start_at":        startAt,
+			"end_at":          endAt,
+			"sale_event_id":   saleEventID,
+			"sale_event_name": saleEventName.String,
+			"source":          source,
+			"changed_by":      changedBy,
+			"changed_by_name": changedByName,
+			"created_at":      createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPreviewTokenTTL is how long a draft preview link works unless asked otherwise
+const defaultPreviewTokenTTL = 7 * 24 * time.Hour
+
+// productLifecycleView is the workflow state of a product model
+type productLifecycleView struct {
+	ProductID             string     `json:"product_id"`
+	Status                string     `json:"status"`
+	IsActive              bool       `json:"is_active"`
+	PublishAt             *time.Time `json:"publish_at"`
+	UnpublishAt           *time.Time `json:"unpublish_at"`
+	PublishedAt           *time.Time `json:"published_at"`
+	SubmittedAt           *time.Time `json:"submitted_at"`
+	SubmittedBy           *string    `json:"submitted_by"`
+	ReviewedAt            *time.Time `json:"reviewed_at"`
+	ReviewedBy            *string    `json:"reviewed_by"`
+	ReviewNote            *string    `json:"review_note"`
+	PreviewToken          *string    `json:"preview_token,omitempty"`
+	PreviewTokenExpiresAt *time.Time `json:"preview_token_expires_at,omitempty"`
+	Issues                []string   `json:"completeness_issues"`
+}
+
+func loadProductLifecycle(q sqlHandle, productID string) (*productLifecycleView, error) {
+	var v productLifecycleView
+	err := q.QueryRow(`
+		SELECT id::text, status, is_active, publish_at, unpublish_at, published_at, submitted_at, submitted_by::text,
+		       reviewed_at, reviewed_by::text, review_note, preview_token, preview_token_expires_at
+		FROM product_models WHERE id::text = $1`, productID).Scan(
+		&v.ProductID, &v.Status, &v.IsActive, &v.PublishAt, &v.UnpublishAt, &v.PublishedAt, &v.SubmittedAt, &v.SubmittedBy,
+		&v.ReviewedAt, &v.ReviewedBy, &v.ReviewNote, &v.PreviewToken, &v.PreviewTokenExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if v.Issues, err = services.ProductCompletenessIssues(q, productID); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+var productStatuses = map[string]bool{
+	models.ProductStatusDraft: true, models.ProductStatusReview: true, models.ProductStatusScheduled: true,
+	models.ProductStatusPublished: true, models.ProductStatusArchived: true,
+}
+
+// productTransitions lists, per action, the statuses a product can be in for it
+var productTransitions = map[string][]string{
+	"submit":    {models.ProductStatusDraft},
+	"reject":    {models.ProductStatusReview},
+	"approve":   {models.ProductStatusReview},
+	"publish":   {models.ProductStatusDraft, models.ProductStatusReview, models.ProductStatusScheduled, models.ProductStatusArchived},
+	"schedule":  {models.ProductStatusDraft, models.ProductStatusReview, models.ProductStatusScheduled},
+	"unpublish": {models.ProductStatusPublished, models.ProductStatusScheduled},
+	"archive":   {models.ProductStatusDraft, models.ProductStatusReview, models.ProductStatusScheduled, models.ProductStatusPublished},
+	"restore":   {models.ProductStatusArchived},
+}
+
+type productTransitionRequest struct {
+	Action      string     `json:"action" binding:"required"`
+	PublishAt   *time.Time `json:"publish_at"`
+	UnpublishAt *time.Time `json:"unpublish_at"`
+	Note        string     `json:"note"`
+}
+
+// AdminGetProductLifecycle handles GET /api/v1/admin/products/:id/lifecycle
+func AdminGetProductLifecycle(c *gin.Context) {
+	v, err := loadProductLifecycle(DB, c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lifecycle": v})
+}
+
+// AdminTransitionProduct handles POST /api/v1/admin/products/:id/lifecycle
+// Actions: submit (draft → review), reject (review → draft, needs a note),
+// approve/publish (→ published, or scheduled when publish_at is in the future),
+// schedule (→ scheduled), unpublish (→ draft), archive and restore (archived → draft).
+// Publishing and scheduling require the product to be complete.
+func AdminTransitionProduct(c *gin.Context) {
+	var req productTransitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, ok := productTransitions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action " + req.Action})
+		return
+	}
+	now := time.Now()
+	if req.UnpublishAt != nil && !req.UnpublishAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be in the future"})
+		return
+	}
+	if req.UnpublishAt != nil && req.PublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unpublish_at must be after publish_at"})
+		return
+	}
+	if req.Action == "schedule" && (req.PublishAt == nil || !req.PublishAt.After(now)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "schedule needs a publish_at in the future"})
+		return
+	}
+	if req.Action == "reject" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required to reject a product"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM product_models WHERE id::text = $1 FOR UPDATE`, c.Param("id")).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product status"})
+		return
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s a product that is %s", req.Action, status)})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	var query string
+	args := []interface{}{c.Param("id")}
+	switch req.Action {
+	case "submit":
+		query = `UPDATE product_models SET status = 'review', submitted_at = now(), submitted_by = $2::uuid, review_note = NULLIF($3, '')`
+		args = append(args, nullableString(userID), req.Note)
+	case "reject":
+		query = `UPDATE product_models SET status = 'draft', reviewed_at = now(), reviewed_by = $2::uuid, review_note = $3`
+		args = append(args, nullableString(userID), req.Note)
+	case "approve", "publish", "schedule":
+		issues, err := services.ProductCompletenessIssues(tx, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check product completeness"})
+			return
+		}
+		if len(issues) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Product is not complete", "completeness_issues": issues})
+			return
+		}
+		next := models.ProductStatusPublished
+		if req.PublishAt != nil && req.PublishAt.After(now) {
+			next = models.ProductStatusScheduled
+		}
+		query = `UPDATE product_models SET status = $2, publish_at = $3, unpublish_at = $4, review_note = NULLIF($5, '')`
+		args = append(args, next, req.PublishAt, req.UnpublishAt, req.Note)
+		if next == models.ProductStatusPublished {
+			query += `, published_at = now()`
+		}
+		if status == models.ProductStatusReview {
+			query += `, reviewed_at = now(), reviewed_by = $6::uuid`
+			args = append(args, nullableString(userID))
+		}
+	case "unpublish", "restore":
+		query = `UPDATE product_models SET status = 'draft', publish_at = NULL, unpublish_at = NULL`
+	case "archive":
+		query = `UPDATE product_models SET status = 'archived', publish_at = NULL`
+	}
+
+	if _, err := tx.Exec(query+`, updated_at = now() WHERE id::text = $1`, args...); err != nil {
+		fmt.Printf("❌ Failed to %s product %s: %v\n", req.Action, c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product status"})
+		return
+	}
+	v, err := loadProductLifecycle(tx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product status"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lifecycle": v})
+}
+
+// AdminCreatePreviewToken handles POST /api/v1/admin/products/:id/preview-token
+// The token lets the app open the product while it is not published:
+// GET /api/v1/products/:id?preview_token=... (optional body: {"hours": 48}).
+func AdminCreatePreviewToken(c *gin.Context) {
+	var req struct {
+		Hours int `json:"hours"`
+	}
+	c.ShouldBindJSON(&req)
+	ttl := defaultPreviewTokenTTL
+	if req.Hours > 0 && req.Hours <= 24*30 {
+		ttl = time.Duration(req.Hours) * time.Hour
+	}
+
+	token := generateRandomString(24)
+	expiresAt := time.Now().Add(ttl)
+	res, err := DB.Exec(`
+		UPDATE product_models SET preview_token = $2, preview_token_expires_at = $3
+		WHERE id::text = $1`, c.Param("id"), token, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create preview token"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"preview_token": token,
+		"expires_at":    expiresAt,
+		"preview_path":  "/api/v1/products/" + c.Param("id") + "?preview_token=" + token,
+	})
+}
+
+// AdminRevokePreviewToken handles DELETE /api/v1/admin/products/:id/preview-token
+func AdminRevokePreviewToken(c *gin.Context) {
+	res, err := DB.Exec(`
+		UPDATE product_models SET preview_token = NULL, preview_token_expires_at = NULL
+		WHERE id::text = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke preview token"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Preview token revoked"})
+}
+
+// productVisible tells whether a product may be shown to this request: published
+// products always, others only with a valid preview token (query or X-Preview-Token)
+func productVisible(c *gin.Context, productID string, isActive bool) bool {
+	if isActive {
+		return true
+	}
+	token := c.Query("preview_token")
+	if token == "" {
+		token = c.GetHeader("X-Preview-Token")
+	}
+	if token == "" {
+		return false
+	}
+	var ok bool
+	DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM product_models
+		              WHERE id::text = $1 AND preview_token = $2 AND preview_token_expires_at > now())`,
+		productID, token).Scan(&ok)
+	return ok
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,622 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	questionMinLen = 10
+	questionMaxLen = 1000
+	answerMaxLen   = 2000
+)
+
+// qaItemTables are the catalogs customers can ask about, by item type
+var qaItemTables = map[string]string{
+	"product":           "product_models",
+	"melhaf_collection": "melhaf_collections",
+	"perfume":           "maison_adrar_perfumes",
+}
+
+// qaActions maps a moderation action to the status it sets
+var qaActions = map[string]string{
+	"approve": models.QAStatusApproved,
+	"reject":  models.QAStatusRejected,
+}
+
+// qaPurchaseSQL tells whether customer $1 received item $3 of type $2 in a delivered
+// order, on its own or in a bundle
+const qaPurchaseSQL = `
+	SELECT EXISTS (
+		SELECT 1
+		FROM orders o
+		JOIN order_items oi ON oi.order_id = o.id
+		LEFT JOIN order_item_components oic ON oic.order_item_id = oi.id
+		CROSS JOIN LATERAL (VALUES (oi.item_type, oi.item_id), (oic.item_type, oic.item_id)) bought(item_type, item_id)
+		WHERE o.user_id::text = $1 AND o.status = 'delivered'
+		  AND CASE $2
+		      WHEN 'product' THEN EXISTS (SELECT 1 FROM skus s
+		          WHERE bought.item_type = 'sku' AND s.id = bought.item_id AND s.product_model_id::text = $3)
+		      WHEN 'melhaf_collection' THEN EXISTS (SELECT 1 FROM melhaf_colors mc
+		          WHERE bought.item_type = 'melhaf_color' AND mc.id = bought.item_id AND mc.collection_id::text = $3)
+		      ELSE EXISTS (SELECT 1 FROM maison_adrar_perfume_colors pc
+		          WHERE bought.item_type = 'perfume_variant' AND pc.id = bought.item_id AND pc.perfume_id::text = $3)
+		      END
+	)`
+
+// publicAnswer is an approved answer as shown with its question
+type publicAnswer struct {
+	ID            uuid.UUID `json:"id"`
+	Answer        string    `json:"answer"`
+	Author        string    `json:"author"`
+	IsStaff       bool      `json:"is_staff"`
+	VerifiedBuyer bool      `json:"verified_buyer"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// publicQuestion is an approved question with its approved answers
+type publicQuestion struct {
+	ID        uuid.UUID      `json:"id"`
+	Question  string         `json:"question"`
+	Author    string         `json:"author"`
+	Answers   []publicAnswer `json:"answers"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// adminQuestion is a question with what a moderator needs to judge it
+type adminQuestion struct {
+	models.ProductQuestion
+	ItemName    string                 `json:"item_name"`
+	AuthorName  string                 `json:"author_name"`
+	AuthorPhone string                 `json:"author_phone"`
+	Answers     []models.ProductAnswer `json:"answers"`
+}
+
+// adminAnswer is a customer answer waiting for moderation, with its question
+type adminAnswer struct {
+	models.ProductAnswer
+	Question    string `json:"question"`
+	ItemType    string `json:"item_type"`
+	ItemID      string `json:"item_id"`
+	AuthorName  string `json:"author_name"`
+	AuthorPhone string `json:"author_phone"`
+}
+
+// qaItemNameSQL names the item question q is about
+const qaItemNameSQL = `CASE q.item_type
+	WHEN 'product' THEN (SELECT title FROM product_models WHERE id = q.item_id)
+	WHEN 'melhaf_collection' THEN (SELECT name FROM melhaf_collections WHERE id = q.item_id)
+	ELSE (SELECT name FROM maison_adrar_perfumes WHERE id = q.item_id)
+END`
+
+const questionColumns = `q.id, q.item_type, q.item_id, q.user_id, q.question, q.status, q.moderation_note,
+	q.moderated_by, q.moderated_at, q.created_at, q.updated_at`
+
+func scanQuestion(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.ProductQuestion, error) {
+	var q models.ProductQuestion
+	dest := append([]interface{}{&q.ID, &q.ItemType, &q.ItemID, &q.UserID, &q.Question, &q.Status, &q.ModerationNote,
+		&q.ModeratedBy, &q.ModeratedAt, &q.CreatedAt, &q.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+const answerColumns = `a.id, a.question_id, a.user_id, a.answer, a.is_staff, a.verified_buyer, a.status,
+	a.moderated_by, a.moderated_at, a.notified_at, a.created_at, a.updated_at`
+
+func scanAnswer(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.ProductAnswer, error) {
+	var a models.ProductAnswer
+	dest := append([]interface{}{&a.ID, &a.QuestionID, &a.UserID, &a.Answer, &a.IsStaff, &a.VerifiedBuyer, &a.Status,
+		&a.ModeratedBy, &a.ModeratedAt, &a.NotifiedAt, &a.CreatedAt, &a.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// approvedAnswers returns the approved answers of the given questions, staff first
+func approvedAnswers(questionIDs []string) map[string][]publicAnswer {
+	answers := map[string][]publicAnswer{}
+	if len(questionIDs) == 0 {
+		return answers
+	}
+	rows, err := DB.Query(`
+		SELECT a.question_id::text, a.id, a.answer, COALESCE(u.full_name, ''), a.is_staff, a.verified_buyer, a.created_at
+		FROM product_answers a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.question_id = ANY($1::uuid[]) AND a.status = $2
+		ORDER BY a.is_staff DESC, a.created_at ASC`, pgTextArray(questionIDs), models.QAStatusApproved)
+	if err != nil {
+		return answers
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var questionID, fullName string
+		var a publicAnswer
+		if err := rows.Scan(&questionID, &a.ID, &a.Answer, &fullName, &a.IsStaff, &a.VerifiedBuyer, &a.CreatedAt); err != nil {
+			continue
+		}
+		if a.IsStaff {
+			a.Author = "FMBQ"
+		} else {
+			a.Author = reviewAuthorName(fullName)
+		}
+		answers[questionID] = append(answers[questionID], a)
+	}
+	return answers
+}
+
+// itemQuestions lists the approved questions about an item: answered ones, most
+// recent first, or those still waiting for an answer
+func itemQuestions(itemType, itemID string, unanswered bool, limit, offset int) ([]publicQuestion, int, error) {
+	rows, err := DB.Query(`
+		SELECT q.id, q.question, COALESCE(u.full_name, ''), q.created_at, COUNT(*) OVER ()
+		FROM product_questions q
+		LEFT JOIN users u ON u.id = q.user_id
+		WHERE q.item_type = $1 AND q.item_id::text = $2 AND q.status = $3
+		  AND EXISTS (SELECT 1 FROM product_answers a WHERE a.question_id = q.id AND a.status = $3) <> $4
+		ORDER BY q.created_at DESC
+		LIMIT $5 OFFSET $6`, itemType, itemID, models.QAStatusApproved, unanswered, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	questions := []publicQuestion{}
+	var ids []string
+	total := 0
+	for rows.Next() {
+		var q publicQuestion
+		var fullName string
+		if err := rows.Scan(&q.ID, &q.Question, &fullName, &q.CreatedAt, &total); err != nil {
+			continue
+		}
+		q.Author = reviewAuthorName(fullName)
+		questions = append(questions, q)
+		ids = append(ids, q.ID.String())
+	}
+	answers := approvedAnswers(ids)
+	for i := range questions {
+		questions[i].Answers = answers[questions[i].ID.String()]
+		if questions[i].Answers == nil {
+			questions[i].Answers = []publicAnswer{}
+		}
+	}
+	return questions, total, rows.Err()
+}
+
+// GetItemQuestions handles GET /api/v1/questions?item_type=product&item_id=...
+// Answered questions about a product model, Melhaf collection or perfume.
+// ?unanswered=true lists the approved questions still waiting for an answer instead.
+func GetItemQuestions(c *gin.Context) {
+	itemType := c.Query("item_type")
+	if _, ok := qaItemTables[itemType]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item_type must be product, melhaf_collection or perfume"})
+		return
+	}
+	itemID := c.Query("item_id")
+	if _, err := uuid.Parse(itemID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item_id"})
+		return
+	}
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+
+	questions, total, err := itemQuestions(itemType, itemID, c.Query("unanswered") == "true", limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch questions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch questions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"questions": questions,
+		"total":     total,
+		"page":      page,
+		"limit":     limit,
+		"has_next":  offset+len(questions) < total,
+	})
+}
+
+// AskQuestion handles POST /api/v1/questions
+// The question is published once staff approve it and shown when answered.
+func AskQuestion(c *gin.Context) {
+	var req struct {
+		ItemType string `json:"item_type" binding:"required"`
+		ItemID   string `json:"item_id" binding:"required"`
+		Question string `json:"question" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	table, ok := qaItemTables[req.ItemType]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "item_type must be product, melhaf_collection or perfume"})
+		return
+	}
+	if _, err := uuid.Parse(req.ItemID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item_id"})
+		return
+	}
+	question := strings.TrimSpace(req.Question)
+	if n := len([]rune(question)); n < questionMinLen || n > questionMaxLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("question must be between %d and %d characters", questionMinLen, questionMaxLen)})
+		return
+	}
+	var exists bool
+	if err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1 AND is_active = true)`, req.ItemID).Scan(&exists); err != nil {
+		fmt.Printf("❌ Failed to check question item: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send question"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	now := time.Now()
+	q, err := scanQuestion(DB.QueryRow(`
+		INSERT INTO product_questions AS q (id, item_type, item_id, user_id, question, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING `+questionColumns,
+		uuid.New(), req.ItemType, req.ItemID, c.GetString("user_id"), question, models.QAStatusPending, now))
+	if err != nil {
+		fmt.Printf("❌ Failed to create question: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send question"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"question": q, "message": "Thank you! We will notify you when your question is answered."})
+}
+
+// GetMyQuestions handles GET /api/v1/questions/mine
+// The customer's questions, whatever their status, with their published answers.
+func GetMyQuestions(c *gin.Context) {
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+	rows, err := DB.Query(`
+		SELECT `+questionColumns+`, COALESCE(`+qaItemNameSQL+`, ''), COUNT(*) OVER ()
+		FROM product_questions q
+		WHERE q.user_id::text = $1
+		ORDER BY q.created_at DESC
+		LIMIT $2 OFFSET $3`, c.GetString("user_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch questions"})
+		return
+	}
+	defer rows.Close()
+
+	type myQuestion struct {
+		models.ProductQuestion
+		ItemName string         `json:"item_name"`
+		Answers  []publicAnswer `json:"answers"`
+	}
+	questions := []myQuestion{}
+	var ids []string
+	total := 0
+	for rows.Next() {
+		var v myQuestion
+		q, err := scanQuestion(rows, &v.ItemName, &total)
+		if err != nil {
+			continue
+		}
+		v.ProductQuestion = *q
+		questions = append(questions, v)
+		ids = append(ids, q.ID.String())
+	}
+	answers := approvedAnswers(ids)
+	for i := range questions {
+		questions[i].Answers = answers[questions[i].ID.String()]
+		if questions[i].Answers == nil {
+			questions[i].Answers = []publicAnswer{}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"questions": questions, "total": total, "page": page, "limit": limit})
+}
+
+// AnswerQuestion handles POST /api/v1/questions/:id/answers
+// Customers who received the item can answer; their answer is moderated first.
+func AnswerQuestion(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req struct {
+		Answer string `json:"answer" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	answer := strings.TrimSpace(req.Answer)
+	if answer == "" || len([]rune(answer)) > answerMaxLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("answer must be between 1 and %d characters", answerMaxLen)})
+		return
+	}
+
+	var itemType, itemID string
+	err := DB.QueryRow(`SELECT item_type, item_id::text FROM product_questions WHERE id::text = $1 AND status = $2`,
+		c.Param("id"), models.QAStatusApproved).Scan(&itemType, &itemID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question"})
+		return
+	}
+	var purchased bool
+	if err := DB.QueryRow(qaPurchaseSQL, userID, itemType, itemID).Scan(&purchased); err != nil {
+		fmt.Printf("❌ Failed to check purchase: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check your orders"})
+		return
+	}
+	if !purchased {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only customers who bought this item can answer"})
+		return
+	}
+
+	now := time.Now()
+	a, err := scanAnswer(DB.QueryRow(`
+		INSERT INTO product_answers AS a (id, question_id, user_id, answer, is_staff, verified_buyer, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, false, true, $5, $6, $6)
+		RETURNING `+answerColumns,
+		uuid.New(), c.Param("id"), userID, answer, models.QAStatusPending, now))
+	if err != nil {
+		fmt.Printf("❌ Failed to create answer: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send answer"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"answer": a, "message": "Thank you! Your answer will appear once it has been checked."})
+}
+
+// AdminListQuestions handles GET /api/v1/admin/questions
+// The moderation queue: ?status= pending (default), approved, rejected or all,
+// oldest first, with every answer. ?item_type= and ?item_id= narrow to one item;
+// ?unanswered=true keeps questions without an approved answer.
+func AdminListQuestions(c *gin.Context) {
+	status := c.DefaultQuery("status", models.QAStatusPending)
+	if status == "all" {
+		status = ""
+	}
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+
+	rows, err := DB.Query(`
+		SELECT `+questionColumns+`, COALESCE(`+qaItemNameSQL+`, ''), COALESCE(u.full_name, ''), COALESCE(u.phone, ''),
+		       COUNT(*) OVER ()
+		FROM product_questions q
+		LEFT JOIN users u ON u.id = q.user_id
+		WHERE ($1 = '' OR q.status = $1) AND ($2 = '' OR q.item_type = $2) AND ($3 = '' OR q.item_id::text = $3)
+		  AND (NOT $4 OR NOT EXISTS (SELECT 1 FROM product_answers a WHERE a.question_id = q.id AND a.status = 'approved'))
+		ORDER BY q.created_at ASC
+		LIMIT $5 OFFSET $6`,
+		status, c.Query("item_type"), c.Query("item_id"), c.Query("unanswered") == "true", limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch questions: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch questions"})
+		return
+	}
+	defer rows.Close()
+
+	questions := []adminQuestion{}
+	index := map[string]int{}
+	var ids []string
+	total := 0
+	for rows.Next() {
+		var v adminQuestion
+		q, err := scanQuestion(rows, &v.ItemName, &v.AuthorName, &v.AuthorPhone, &total)
+		if err != nil {
+			continue
+		}
+		v.ProductQuestion = *q
+		v.Answers = []models.ProductAnswer{}
+		index[q.ID.String()] = len(questions)
+		questions = append(questions, v)
+		ids = append(ids, q.ID.String())
+	}
+	rows.Close()
+
+	if len(ids) > 0 {
+		answerRows, err := DB.Query(`SELECT `+answerColumns+` FROM product_answers a
+			WHERE a.question_id = ANY($1::uuid[])
+			ORDER BY a.created_at ASC`, pgTextArray(ids))
+		if err == nil {
+			defer answerRows.Close()
+			for answerRows.Next() {
+				if a, err := scanAnswer(answerRows); err == nil {
+					i := index[a.QuestionID.String()]
+					questions[i].Answers = append(questions[i].Answers, *a)
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"questions": questions, "total": total, "page": page, "limit": limit})
+}
+
+// AdminModerateQuestion handles POST /api/v1/admin/questions/:id/moderate
+// Actions: approve and reject (needs a note, shown to the asker).
+func AdminModerateQuestion(c *gin.Context) {
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status, ok := qaActions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action " + req.Action})
+		return
+	}
+	if req.Action == "reject" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required to reject a question"})
+		return
+	}
+
+	q, err := scanQuestion(DB.QueryRow(`
+		UPDATE product_questions q SET status = $2, moderation_note = $3, moderated_by = $4, moderated_at = now(), updated_at = now()
+		WHERE q.id::text = $1
+		RETURNING `+questionColumns,
+		c.Param("id"), status, nullableString(strings.TrimSpace(req.Note)), nullableString(c.GetString("user_id"))))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to moderate question: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate question"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"question": q})
+}
+
+// AdminAnswerQuestion handles POST /api/v1/admin/questions/:id/answers
+// Staff answers are published at once, approving a pending question with them, and
+// the asker is notified.
+func AdminAnswerQuestion(c *gin.Context) {
+	var req struct {
+		Answer string `json:"answer" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	answer := strings.TrimSpace(req.Answer)
+	if answer == "" || len([]rune(answer)) > answerMaxLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("answer must be between 1 and %d characters", answerMaxLen)})
+		return
+	}
+	staffID := nullableString(c.GetString("user_id"))
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM product_questions WHERE id::text = $1 FOR UPDATE`, c.Param("id")).Scan(&status)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question"})
+		return
+	}
+	if status == models.QAStatusRejected {
+		c.JSON(http.StatusConflict, gin.H{"error": "Question was rejected; approve it before answering"})
+		return
+	}
+	if status == models.QAStatusPending {
+		if _, err := tx.Exec(`
+			UPDATE product_questions SET status = $2, moderated_by = $3, moderated_at = now(), updated_at = now()
+			WHERE id::text = $1`, c.Param("id"), models.QAStatusApproved, staffID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve question"})
+			return
+		}
+	}
+
+	now := time.Now()
+	a, err := scanAnswer(tx.QueryRow(`
+		INSERT INTO product_answers AS a (id, question_id, user_id, answer, is_staff, verified_buyer, status,
+		                                 moderated_by, moderated_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, true, false, $5, $3, $6, $6, $6)
+		RETURNING `+answerColumns,
+		uuid.New(), c.Param("id"), staffID, answer, models.QAStatusApproved, now))
+	if err != nil {
+		fmt.Printf("❌ Failed to create answer: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save answer"})
+		return
+	}
+
+	go services.NotifyQuestionAnswered(a.ID.String())
+	c.JSON(http.StatusCreated, gin.H{"answer": a})
+}
+
+// AdminListAnswers handles GET /api/v1/admin/answers
+// Customer answers by ?status= pending (default), approved, rejected or all, oldest
+// first, with their question.
+func AdminListAnswers(c *gin.Context) {
+	status := c.DefaultQuery("status", models.QAStatusPending)
+	if status == "all" {
+		status = ""
+	}
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+
+	rows, err := DB.Query(`
+		SELECT `+answerColumns+`, q.question, q.item_type, q.item_id::text,
+		       COALESCE(u.full_name, ''), COALESCE(u.phone, ''), COUNT(*) OVER ()
+		FROM product_answers a
+		JOIN product_questions q ON q.id = a.question_id
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE a.is_staff = false AND ($1 = '' OR a.status = $1)
+		ORDER BY a.created_at ASC
+		LIMIT $2 OFFSET $3`, status, limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch answers: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch answers"})
+		return
+	}
+	defer rows.Close()
+
+	answers := []adminAnswer{}
+	total := 0
+	for rows.Next() {
+		var v adminAnswer
+		a, err := scanAnswer(rows, &v.Question, &v.ItemType, &v.ItemID, &v.AuthorName, &v.AuthorPhone, &total)
+		if err != nil {
+			continue
+		}
+		v.ProductAnswer = *a
+		answers = append(answers, v)
+	}
+	c.JSON(http.StatusOK, gin.H{"answers": answers, "total": total, "page": page, "limit": limit})
+}
+
+// AdminModerateAnswer handles POST /api/v1/admin/answers/:id/moderate
+// Approving a customer answer publishes it and notifies the asker.
+func AdminModerateAnswer(c *gin.Context) {
+	var req struct {
+		Action string `json:"action" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status, ok := qaActions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action " + req.Action})
+		return
+	}
+
+	a, err := scanAnswer(DB.QueryRow(`
+		UPDATE product_answers a SET status = $2, moderated_by = $3, moderated_at = now(), updated_at = now()
+		WHERE a.id::text = $1
+		RETURNING `+answerColumns,
+		c.Param("id"), status, nullableString(c.GetString("user_id"))))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Answer not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to moderate answer: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate answer"})
+		return
+	}
+
+	if a.Status == models.QAStatusApproved {
+		go services.NotifyQuestionAnswered(a.ID.String())
+	}
+	c.JSON(http.StatusOK, gin.H{"answer": a})
+}
@@ -15,6 +15,7 @@ type ProductSearchResponse struct {
 	Brand      *Brand `json:"brand"`
 	ImageURL   string `json:"image_url"`
 	SKUs       []SKU  `json:"skus"`
+	SearchID   string `json:"search_id,omitempty"`
 }
 
 type Brand struct {
@@ -127,9 +128,11 @@ func SearchProductByCode(c *gin.Context) {
 	}
 
 	if product == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		searchID := logSearchQuery(c, searchLogEntry{Source: "product_code", Query: code})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found", "search_id": searchID})
 		return
 	}
+	product.SearchID = logSearchQuery(c, searchLogEntry{Source: "product_code", Query: code, ResultCount: 1})
 
 	// Fetch SKU data for the product
 	skuQuery := `
@@ -139,7 +142,7 @@ func SearchProductByCode(c *gin.Context) {
 			COALESCE(i.available, 0) as available,
 			pc.color_name, pc.color_hex
 		FROM skus s
-		LEFT JOIN prices p ON p.sku_id = s.id
+		LEFT JOIN active_prices p ON p.sku_id = s.id
 		LEFT JOIN inventory i ON i.sku_id = s.id
 		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
 		WHERE s.product_model_id = $1
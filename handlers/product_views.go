@@ -9,93 +9,47 @@ import (
 	"time"
 
 	"fmbq-server/database"
+	"fmbq-server/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // RegisterProductView handles POST /api/v1/products/:id/view
+// Views are buffered and written in batches; bots and repeat views by the same
+// visitor within the session window are dropped. Guests may send their session in
+// X-Session-ID. "recorded" and "reason" tell whether the view was kept. The product
+// is not looked up per view: views of unknown products are answered like any other
+// and dropped when the batch is written.
 func RegisterProductView(c *gin.Context) {
-	productIDStr := c.Param("id")
-	productID, err := uuid.Parse(productIDStr)
+	productID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
 		return
 	}
 
-	// Verify product exists
-	var productExists bool
-	err = database.Database.QueryRow("SELECT EXISTS(SELECT 1 FROM product_models WHERE id = $1)", productID).Scan(&productExists)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify product"})
-		return
+	view := services.ProductViewEvent{
+		ProductID: productID.String(),
+		ViewedAt:  time.Now(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
 	}
-	if !productExists {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
-		return
-	}
-
-	// Get user ID from auth context (if authenticated)
-	var userID uuid.NullUUID
-	if userIDInterface, exists := c.Get("user_id"); exists {
-		if userIDStr, ok := userIDInterface.(string); ok {
-			if parsedUserID, err := uuid.Parse(userIDStr); err == nil {
-				userID = uuid.NullUUID{UUID: parsedUserID, Valid: true}
-			}
-		}
-	}
-
-	// Generate anonymous session ID if not authenticated
-	var anonymousSessionID string
-	if !userID.Valid {
-		anonymousSessionID = uuid.New().String()
-	}
-
-	// Get client info
-	ipAddress := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-
-	// Check for recent duplicate view (within 5 minutes)
-	var duplicateExists bool
-	if userID.Valid {
-		err = database.Database.QueryRow(`
-			SELECT EXISTS(
-				SELECT 1 FROM product_views 
-				WHERE product_id = $1 AND user_id = $2 
-				AND view_timestamp > NOW() - INTERVAL '5 minutes'
-			)`, productID, userID.UUID).Scan(&duplicateExists)
+	if userID := c.GetString("user_id"); userID != "" {
+		view.UserID = &userID
 	} else {
-		err = database.Database.QueryRow(`
-			SELECT EXISTS(
-				SELECT 1 FROM product_views 
-				WHERE product_id = $1 AND anonymous_session_id = $2 
-				AND view_timestamp > NOW() - INTERVAL '5 minutes'
-			)`, productID, anonymousSessionID).Scan(&duplicateExists)
-	}
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicate views"})
-		return
-	}
-
-	if duplicateExists {
-		c.JSON(http.StatusOK, gin.H{"message": "View already registered recently", "success": true})
-		return
+		session := services.AnonymousViewSession(c.GetHeader("X-Session-ID"), view.IPAddress, view.UserAgent)
+		view.AnonymousSessionID = &session
 	}
 
-	// Insert the view
-	viewID := uuid.New()
-	_, err = database.Database.Exec(`
-		INSERT INTO product_views (id, product_id, user_id, anonymous_session_id, view_timestamp, ip_address, user_agent)
-		VALUES ($1, $2, $3, $4, NOW(), $5, $6)
-	`, viewID, productID, userID, anonymousSessionID, ipAddress, userAgent)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register view"})
-		return
+	recorded, reason := services.RecordProductView(view)
+	switch reason {
+	case "duplicate":
+		c.JSON(http.StatusOK, gin.H{"message": "View already registered recently", "success": true, "recorded": false, "reason": reason})
+	case "bot":
+		c.JSON(http.StatusOK, gin.H{"message": "View not registered: bot user agent", "success": true, "recorded": false, "reason": reason})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "View registered successfully", "success": true, "recorded": recorded})
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "View registered successfully", "success": true})
 }
 
 // GetMostViewedProducts handles GET /api/v1/products/most-viewed
@@ -106,6 +60,13 @@ func GetMostViewedProducts(c *gin.Context) {
 		limit = 10
 	}
 
+	// Signed-in customers get their picks from a wider pool of popular products
+	affinity := customerAffinity(c)
+	pool := limit
+	if affinity != nil {
+		pool = personalizationPool(limit)
+	}
+
 	categoryIDStr := c.Query("category_id")
 	var categoryFilter string
 	var args []interface{}
@@ -123,9 +84,9 @@ func GetMostViewedProducts(c *gin.Context) {
 				WHERE pmc.product_model_id = pm.id 
 				AND c.parent_id = $2
 			)`
-		args = []interface{}{limit, categoryID}
+		args = []interface{}{pool, categoryID}
 	} else {
-		args = []interface{}{limit}
+		args = []interface{}{pool}
 	}
 
 	// Simplified query for debugging
@@ -142,11 +103,11 @@ func GetMostViewedProducts(c *gin.Context) {
 			 JOIN product_colors pc ON pi.product_color_id = pc.id 
 			 WHERE pc.product_model_id = pm.id AND pi.url IS NOT NULL 
 			 ORDER BY pi.created_at LIMIT 1) as color_image_url,
-			(SELECT p.sale_price FROM prices p 
+			(SELECT p.sale_price FROM active_prices p 
 			 JOIN skus s ON p.sku_id = s.id 
 			 WHERE s.product_model_id = pm.id AND p.sale_price IS NOT NULL 
 			 ORDER BY p.created_at DESC LIMIT 1) as price,
-			(SELECT p.list_price FROM prices p 
+			(SELECT p.list_price FROM active_prices p 
 			 JOIN skus s ON p.sku_id = s.id 
 			 WHERE s.product_model_id = pm.id 
 			 ORDER BY p.created_at DESC LIMIT 1) as original_price
@@ -155,9 +116,9 @@ func GetMostViewedProducts(c *gin.Context) {
 		LEFT JOIN (
 			SELECT 
 				product_id,
-				COUNT(*) as view_count
-			FROM product_views
-			WHERE view_timestamp > NOW() - INTERVAL '30 days'
+				SUM(views) as view_count
+			FROM product_view_daily
+			WHERE day > CURRENT_DATE - 30
 			GROUP BY product_id
 		) view_counts ON pm.id = view_counts.product_id
 		WHERE pm.id IS NOT NULL
@@ -220,10 +181,18 @@ func GetMostViewedProducts(c *gin.Context) {
 		products = append(products, product)
 	}
 
+	if affinity != nil {
+		products = personalizeProductMaps(affinity, products)
+	}
+	if len(products) > limit {
+		products = products[:limit]
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    products,
-		"count":   len(products),
+		"success":      true,
+		"data":         products,
+		"count":        len(products),
+		"personalized": affinity != nil,
 	})
 }
 
@@ -282,7 +251,7 @@ func GetUserRecentlyViewedProducts(c *gin.Context) {
 		INNER JOIN product_models pm ON lv.product_id = pm.id
 		LEFT JOIN brands b ON pm.brand_id = b.id
 		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+		LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 		LEFT JOIN LATERAL (
 			SELECT url 
 			FROM product_images 
@@ -418,6 +387,13 @@ func GetMostViewedProductsByCategory(c *gin.Context) {
 		limit = 10
 	}
 
+	// Signed-in customers get their picks from a wider pool of popular products
+	affinity := customerAffinity(c)
+	pool := limit
+	if affinity != nil {
+		pool = personalizationPool(limit)
+	}
+
 	query := `
 		SELECT 
 			pm.id,
@@ -434,11 +410,11 @@ func GetMostViewedProductsByCategory(c *gin.Context) {
 			 WHERE pc.product_model_id = pm.id AND pi.url IS NOT NULL 
 			 ORDER BY pi.created_at LIMIT 1) as color_image_url,
 			-- Get price info
-			(SELECT p.sale_price FROM prices p 
+			(SELECT p.sale_price FROM active_prices p 
 			 JOIN skus s ON p.sku_id = s.id 
 			 WHERE s.product_model_id = pm.id AND p.sale_price IS NOT NULL 
 			 ORDER BY p.created_at DESC LIMIT 1) as price,
-			(SELECT p.list_price FROM prices p 
+			(SELECT p.list_price FROM active_prices p 
 			 JOIN skus s ON p.sku_id = s.id 
 			 WHERE s.product_model_id = pm.id 
 			 ORDER BY p.created_at DESC LIMIT 1) as original_price
@@ -447,9 +423,9 @@ func GetMostViewedProductsByCategory(c *gin.Context) {
 		LEFT JOIN (
 			SELECT 
 				product_id,
-				COUNT(*) as view_count
-			FROM product_views
-			WHERE view_timestamp > NOW() - INTERVAL '30 days'
+				SUM(views) as view_count
+			FROM product_view_daily
+			WHERE day > CURRENT_DATE - 30
 			GROUP BY product_id
 		) view_counts ON pm.id = view_counts.product_id
 		WHERE EXISTS (
@@ -469,7 +445,7 @@ func GetMostViewedProductsByCategory(c *gin.Context) {
 		LIMIT $2
 	`
 
-	rows, err := database.Database.Query(query, categoryID, limit)
+	rows, err := database.Database.Query(query, categoryID, pool)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch most viewed products by category"})
 		return
@@ -524,9 +500,17 @@ func GetMostViewedProductsByCategory(c *gin.Context) {
 		products = append(products, product)
 	}
 
+	if affinity != nil {
+		products = personalizeProductMaps(affinity, products)
+	}
+	if len(products) > limit {
+		products = products[:limit]
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    products,
-		"count":   len(products),
+		"success":      true,
+		"data":         products,
+		"count":        len(products),
+		"personalized": affinity != nil,
 	})
 }
@@ -212,11 +212,11 @@ func getProductColorsWithVariants(productID string) []gin.H {
 // getProductSizes fetches all available sizes for a product
 func getProductSizes(productID string) []gin.H {
 	query := `
-		SELECT DISTINCT s.size, s.size_normalized, COUNT(*) as variant_count
+		SELECT s.size, s.size_normalized, COUNT(*) as variant_count
 		FROM skus s
 		WHERE s.product_model_id = $1 AND s.size IS NOT NULL
 		GROUP BY s.size, s.size_normalized
-		ORDER BY s.size_normalized
+		ORDER BY MIN(s.size_rank) NULLS LAST, s.size_normalized
 	`
 	
 	rows, err := DB.Query(query, productID)
@@ -263,7 +263,7 @@ func getProductVariants(productID string) []gin.H {
 			COALESCE(pi.url, '') as image_url
 		FROM skus s
 		LEFT JOIN product_colors pc ON s.product_color_id = pc.id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
+		LEFT JOIN active_prices pr ON s.id = pr.sku_id
 		LEFT JOIN LATERAL (
 			SELECT url 
 			FROM product_images 
@@ -334,6 +334,7 @@ func GetProducts(c *gin.Context) {
 	categoryID := c.Query("category_id")
 	brandID := c.Query("brand_id")
 	search := c.Query("search")
+	colorFamily := c.Query("color_family")
 
 	offset := (page - 1) * limit
 
@@ -371,6 +372,13 @@ func GetProducts(c *gin.Context) {
 		argIndex += 2
 	}
 
+	if colorFamily != "" {
+		cond, arg := colorFamilyFilterSQL(colorFamily, argIndex)
+		query += ` AND ` + cond
+		args = append(args, arg)
+		argIndex++
+	}
+
 	query += ` ORDER BY pm.created_at DESC LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
 	args = append(args, limit, offset)
 
@@ -412,10 +420,13 @@ func GetProducts(c *gin.Context) {
 		products = append(products, product)
 	}
 
+	colorFamilies, _ := getColorFamilyFilters(SearchRequest{})
+
 	c.JSON(http.StatusOK, gin.H{
 		"products": products,
 		"page":     page,
 		"limit":    limit,
+		"facets":   gin.H{"color_families": colorFamilies},
 	})
 }
 
@@ -427,7 +438,7 @@ func GetProduct(c *gin.Context) {
 	var brandName sql.NullString
 	query := `
 		SELECT pm.id, pm.brand_id, pm.title, pm.description, pm.short_description, 
-		       pm.model_code, pm.is_active, pm.attributes, pm.created_at, pm.updated_at,
+		       pm.model_code, pm.is_active, pm.status, pm.attributes, pm.created_at, pm.updated_at,
 		       b.name as brand_name
 		FROM product_models pm
 		LEFT JOIN brands b ON pm.brand_id = b.id
@@ -436,7 +447,7 @@ func GetProduct(c *gin.Context) {
 	
 	err := DB.QueryRow(query, productID).Scan(
 		&pm.ID, &pm.BrandID, &pm.Title, &pm.Description, &pm.ShortDescription,
-		&pm.ModelCode, &pm.IsActive, &pm.Attributes, &pm.CreatedAt, &pm.UpdatedAt,
+		&pm.ModelCode, &pm.IsActive, &pm.Status, &pm.Attributes, &pm.CreatedAt, &pm.UpdatedAt,
 		&brandName,
 	)
 	
@@ -448,6 +459,11 @@ func GetProduct(c *gin.Context) {
 		}
 		return
 	}
+	// Unpublished products are only visible through a preview link
+	if !productVisible(c, productID, pm.IsActive) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
 
 	// Get product colors
 	colorsQuery := `SELECT id, color_name, color_code, external_color_id, default_image_id, created_at 
@@ -507,10 +523,10 @@ func GetProduct(c *gin.Context) {
 
 	// Get SKUs with prices
 	skusQuery := `
-		SELECT s.id, s.sku_code, s.ean, s.size, s.size_normalized, s.attributes, s.created_at,
+		SELECT s.id, s.sku_code, s.ean, s.size, s.size_normalized, s.size_chart_id, s.attributes, s.created_at,
 		       p.list_price, p.sale_price, p.currency, i.available, i.reserved
 		FROM skus s
-		LEFT JOIN prices p ON s.id = p.sku_id AND p.currency = 'MRO'
+		LEFT JOIN active_prices p ON s.id = p.sku_id AND p.currency = 'MRO'
 		LEFT JOIN inventory i ON s.id = i.sku_id
 		WHERE s.product_model_id = $1
 		ORDER BY s.created_at
@@ -531,7 +547,7 @@ func GetProduct(c *gin.Context) {
 		var ean sql.NullString
 		
 		err := skusRows.Scan(
-			&sku.ID, &sku.SKUCode, &ean, &sku.Size, &sku.SizeNormalized, 
+			&sku.ID, &sku.SKUCode, &ean, &sku.Size, &sku.SizeNormalized, &sku.SizeChartID,
 			&sku.Attributes, &sku.CreatedAt, &listPrice, &salePrice, &currency,
 			&available, &reserved,
 		)
@@ -545,6 +561,7 @@ func GetProduct(c *gin.Context) {
 			"ean":             ean.String,
 			"size":            sku.Size,
 			"size_normalized": sku.SizeNormalized,
+			"size_chart_id":   sku.SizeChartID,
 			"attributes":      sku.Attributes,
 			"created_at":      sku.CreatedAt,
 			"available":       available.Int64,
@@ -572,6 +589,7 @@ func GetProduct(c *gin.Context) {
 		"short_description": pm.ShortDescription,
 		"model_code":        pm.ModelCode,
 		"is_active":         pm.IsActive,
+		"status":            pm.Status,
 		"attributes":        pm.Attributes,
 		"created_at":        pm.CreatedAt,
 		"updated_at":        pm.UpdatedAt,
@@ -581,6 +599,20 @@ func GetProduct(c *gin.Context) {
 		"skus":              skus,
 	}
 
+	// SKU-specific charts are listed on each SKU; this is the product-level one
+	if sizeChart, err := resolveSizeChart(productID, ""); err == nil && sizeChart != nil {
+		product["size_chart"] = sizeChart
+	}
+
+	if rating, err := productRatingSummary(productID); err == nil {
+		product["rating"] = rating
+	}
+
+	// Latest answered questions; the rest are paged from /questions
+	if questions, total, err := itemQuestions("product", productID, false, 3, 0); err == nil {
+		product["questions"] = gin.H{"items": questions, "total": total}
+	}
+
 	c.JSON(http.StatusOK, product)
 }
 
@@ -770,7 +802,7 @@ LEFT JOIN LATERAL (
 LEFT JOIN LATERAL (
   SELECT MIN(COALESCE(p.sale_price, p.list_price)) AS price
   FROM skus s
-  LEFT JOIN prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+  LEFT JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
   WHERE s.product_model_id = pm2.id
 ) pr ON true
 WHERE pm2.id <> $1
@@ -907,7 +939,7 @@ func GetProductSuggestions(c *gin.Context) {
 			INNER JOIN categories c ON pmc.category_id = c.id AND c.id::text IN (%s) AND c.level = 2
 			LEFT JOIN brands b ON pm.brand_id = b.id
 			LEFT JOIN skus s ON pm.id = s.product_model_id
-			LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+			LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 			LEFT JOIN LATERAL (
 				SELECT url 
 				FROM product_images 
@@ -978,7 +1010,7 @@ func GetProductSuggestions(c *gin.Context) {
 			INNER JOIN categories c ON pmc.category_id = c.id AND (c.id::text IN (%s) OR c.parent_id::text IN (%s))
 			LEFT JOIN brands b ON pm.brand_id = b.id
 			LEFT JOIN skus s ON pm.id = s.product_model_id
-			LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+			LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 			LEFT JOIN LATERAL (
 				SELECT url 
 				FROM product_images 
@@ -1032,7 +1064,7 @@ func GetProductSuggestions(c *gin.Context) {
 			FROM product_models pm
 			LEFT JOIN brands b ON pm.brand_id = b.id
 			LEFT JOIN skus s ON pm.id = s.product_model_id
-			LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+			LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 			LEFT JOIN LATERAL (
 				SELECT url 
 				FROM product_images 
@@ -1112,7 +1144,7 @@ func GetProductSuggestions(c *gin.Context) {
 				FROM product_models pm
 				LEFT JOIN brands b ON pm.brand_id = b.id
 				LEFT JOIN skus s ON pm.id = s.product_model_id
-				LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+				LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 				LEFT JOIN LATERAL (
 					SELECT url 
 					FROM product_images 
@@ -1139,7 +1171,7 @@ func GetProductSuggestions(c *gin.Context) {
 				FROM product_models pm
 				LEFT JOIN brands b ON pm.brand_id = b.id
 				LEFT JOIN skus s ON pm.id = s.product_model_id
-				LEFT JOIN prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
+				LEFT JOIN active_prices pr ON s.id = pr.sku_id AND pr.currency = 'MRO'
 				LEFT JOIN LATERAL (
 					SELECT url 
 					FROM product_images 
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// recommendedProduct is a product card in a recommendation list
+type recommendedProduct struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	BrandName     string  `json:"brand_name"`
+	ImageURL      string  `json:"image_url"`
+	Price         float64 `json:"price"`
+	OriginalPrice float64 `json:"original_price"`
+	InStock       bool    `json:"in_stock"`
+	Score         float64 `json:"score"`
+	Source        string  `json:"source"` // co_purchase, co_view, category
+}
+
+// recommendationLimit reads ?limit= for a recommendation list
+func recommendationLimit(c *gin.Context, defaultLimit int) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit < 1 || limit > 50 {
+		return defaultLimit
+	}
+	return limit
+}
+
+// recommendedProducts returns the published products recommended for the given
+// ones, scores summed across them, leaving the given products out. inStockOnly
+// keeps what can be bought right away.
+func recommendedProducts(kind string, productIDs []string, inStockOnly bool, limit int) ([]recommendedProduct, error) {
+	rows, err := DB.Query(`
+		WITH picks AS (
+			SELECT r.related_id, SUM(r.score) AS score, (array_agg(r.source ORDER BY r.score DESC))[1] AS source
+			FROM product_recommendations r
+			WHERE r.product_id = ANY($1::uuid[]) AND r.kind = $2 AND NOT r.related_id = ANY($1::uuid[])
+			GROUP BY r.related_id
+		)
+		SELECT pm.id, pm.title, COALESCE(b.name, ''), COALESCE(pi.url, ''),
+		       COALESCE(pr.price, 0), COALESCE(pr.original_price, 0), COALESCE(st.available, 0) > 0,
+		       picks.score, picks.source
+		FROM picks
+		JOIN product_models pm ON pm.id = picks.related_id AND pm.is_active = true
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		LEFT JOIN LATERAL (
+			SELECT url FROM product_images
+			WHERE product_model_id = pm.id
+			ORDER BY position, created_at
+			LIMIT 1
+		) pi ON true
+		LEFT JOIN LATERAL (
+			SELECT MIN(p.effective_price) AS price, MAX(p.list_price) AS original_price
+			FROM skus s JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+			WHERE s.product_model_id = pm.id
+		) pr ON true
+		LEFT JOIN LATERAL (
+			SELECT SUM(i.available) AS available
+			FROM skus s JOIN inventory i ON i.sku_id = s.id
+			WHERE s.product_model_id = pm.id
+		) st ON true
+		WHERE NOT $3 OR COALESCE(st.available, 0) > 0
+		ORDER BY picks.score DESC, pm.created_at DESC
+		LIMIT $4`, pgTextArray(productIDs), kind, inStockOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []recommendedProduct{}
+	for rows.Next() {
+		var p recommendedProduct
+		if err := rows.Scan(&p.ID, &p.Title, &p.BrandName, &p.ImageURL, &p.Price, &p.OriginalPrice,
+			&p.InStock, &p.Score, &p.Source); err != nil {
+			continue
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+// productRecommendations answers a recommendation list for one product
+func productRecommendations(c *gin.Context, kind string, defaultLimit int) {
+	productID := c.Param("id")
+	if _, err := uuid.Parse(productID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+		return
+	}
+	products, err := recommendedProducts(kind, []string{productID}, false, recommendationLimit(c, defaultLimit))
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch %s recommendations for product %s: %v\n", kind, productID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
+
+// GetFrequentlyBoughtTogether handles GET /api/v1/products/:id/bought-together
+// Products most often in the same orders (web and POS) as this one.
+func GetFrequentlyBoughtTogether(c *gin.Context) {
+	productRecommendations(c, "bought_together", 8)
+}
+
+// GetAlsoViewedProducts handles GET /api/v1/products/:id/also-viewed
+// Products customers looked at in the same browsing sessions as this one.
+func GetAlsoViewedProducts(c *gin.Context) {
+	productRecommendations(c, "viewed_together", 12)
+}
+
+// GetCartCrossSell handles GET /api/v1/cart/cross-sell
+// In-stock products that go with what is in the customer's cart.
+func GetCartCrossSell(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT DISTINCT s.product_model_id::text
+		FROM carts ct
+		JOIN cart_items ci ON ci.cart_id = ct.id
+		JOIN skus s ON s.id = ci.sku_id
+		WHERE ct.user_id::text = $1`, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cart"})
+		return
+	}
+	var productIDs []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			productIDs = append(productIDs, id)
+		}
+	}
+	rows.Close()
+	if len(productIDs) == 0 {
+		c.JSON(http.StatusOK, gin.H{"products": []recommendedProduct{}})
+		return
+	}
+
+	products, err := recommendedProducts("bought_together", productIDs, true, recommendationLimit(c, 8))
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch cart cross-sell: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recommendations"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
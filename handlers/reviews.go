@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	reviewMaxPhotos   = 6
+	reviewMaxTitleLen = 120
+	reviewMaxBodyLen  = 4000
+)
+
+// reviewActions maps a moderation action to the status it sets
+var reviewActions = map[string]string{
+	"approve": models.ReviewStatusApproved,
+	"reject":  models.ReviewStatusRejected,
+	"flag":    models.ReviewStatusFlagged,
+}
+
+// reviewPurchaseSQL finds the latest delivered order line of customer $1 for product
+// $2, bought on its own or in a bundle
+const reviewPurchaseSQL = `
+	SELECT oi.id
+	FROM order_items oi
+	JOIN orders o ON o.id = oi.order_id
+	WHERE o.user_id::text = $1 AND o.status = 'delivered'
+	  AND (oi.product_id::text = $2 OR EXISTS (
+	      SELECT 1 FROM order_item_components oic JOIN skus s ON s.id = oic.item_id
+	      WHERE oic.order_item_id = oi.id AND oic.item_type = 'sku' AND s.product_model_id::text = $2))
+	ORDER BY o.created_at DESC
+	LIMIT 1`
+
+type reviewRequest struct {
+	Rating int      `json:"rating" binding:"required,min=1,max=5"`
+	Title  *string  `json:"title"`
+	Body   *string  `json:"body"`
+	Photos []string `json:"photos"` // URLs from POST /reviews/photos
+}
+
+func (req *reviewRequest) validate() string {
+	req.Title = nullableString(strings.TrimSpace(stringValue(req.Title)))
+	req.Body = nullableString(strings.TrimSpace(stringValue(req.Body)))
+	if req.Title != nil && len([]rune(*req.Title)) > reviewMaxTitleLen {
+		return fmt.Sprintf("title must be at most %d characters", reviewMaxTitleLen)
+	}
+	if req.Body != nil && len([]rune(*req.Body)) > reviewMaxBodyLen {
+		return fmt.Sprintf("body must be at most %d characters", reviewMaxBodyLen)
+	}
+	if len(req.Photos) > reviewMaxPhotos {
+		return fmt.Sprintf("at most %d photos", reviewMaxPhotos)
+	}
+	// Only photos uploaded through POST /reviews/photos are shown on product pages
+	for _, url := range req.Photos {
+		if services.Cloudinary == nil || !services.Cloudinary.IsUploadedImage(url, "reviews") {
+			return "photos must be uploaded with POST /reviews/photos"
+		}
+	}
+	return ""
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// reviewAuthorName shows a reviewer as first name and last initial
+func reviewAuthorName(fullName string) string {
+	parts := strings.Fields(fullName)
+	switch len(parts) {
+	case 0:
+		return "Client"
+	case 1:
+		return parts[0]
+	default:
+		return parts[0] + " " + string([]rune(parts[len(parts)-1])[0]) + "."
+	}
+}
+
+// publicReview is an approved review as shown on the product page
+type publicReview struct {
+	ID               uuid.UUID  `json:"id"`
+	Rating           int        `json:"rating"`
+	Title            *string    `json:"title"`
+	Body             *string    `json:"body"`
+	Author           string     `json:"author"`
+	VerifiedPurchase bool       `json:"verified_purchase"`
+	Photos           []string   `json:"photos"`
+	Reply            *string    `json:"reply"`
+	RepliedAt        *time.Time `json:"replied_at"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// adminReview is a review with what a moderator needs to judge it
+type adminReview struct {
+	models.Review
+	ProductTitle string   `json:"product_title"`
+	AuthorName   string   `json:"author_name"`
+	AuthorPhone  string   `json:"author_phone"`
+	Photos       []string `json:"photos"`
+}
+
+// ratingSummary aggregates the approved reviews of a product
+type ratingSummary struct {
+	Average      float64     `json:"average"`
+	Count        int         `json:"count"`
+	Distribution map[int]int `json:"distribution"` // stars -> reviews
+}
+
+func productRatingSummary(productID string) (ratingSummary, error) {
+	summary := ratingSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	rows, err := DB.Query(`
+		SELECT rating, COUNT(*) FROM reviews
+		WHERE product_model_id::text = $1 AND status = $2
+		GROUP BY rating`, productID, models.ReviewStatusApproved)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	total := 0
+	for rows.Next() {
+		var rating, count int
+		if err := rows.Scan(&rating, &count); err != nil {
+			continue
+		}
+		summary.Distribution[rating] = count
+		summary.Count += count
+		total += rating * count
+	}
+	if summary.Count > 0 {
+		summary.Average = roundPrice(float64(total) / float64(summary.Count))
+	}
+	return summary, rows.Err()
+}
+
+// reviewPhotos returns the photo URLs of the given reviews, in order
+func reviewPhotos(ids []string) map[string][]string {
+	photos := map[string][]string{}
+	if len(ids) == 0 {
+		return photos
+	}
+	rows, err := DB.Query(`
+		SELECT review_id::text, url FROM review_photos
+		WHERE review_id = ANY($1::uuid[])
+		ORDER BY position`, pgTextArray(ids))
+	if err != nil {
+		return photos
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, url string
+		if err := rows.Scan(&id, &url); err == nil {
+			photos[id] = append(photos[id], url)
+		}
+	}
+	return photos
+}
+
+func saveReviewPhotos(tx *sql.Tx, reviewID uuid.UUID, urls []string) error {
+	if _, err := tx.Exec(`DELETE FROM review_photos WHERE review_id = $1`, reviewID); err != nil {
+		return err
+	}
+	for i, url := range urls {
+		if _, err := tx.Exec(`
+			INSERT INTO review_photos (id, review_id, url, position, created_at)
+			VALUES ($1, $2, $3, $4, now())`, uuid.New(), reviewID, url, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const reviewColumns = `r.id, r.product_model_id, r.user_id, r.order_item_id, r.rating, r.title, r.body, r.status,
+	r.moderation_note, r.moderated_by, r.moderated_at, r.reply, r.replied_by, r.replied_at, r.created_at, r.updated_at`
+
+func scanReview(row interface{ Scan(...interface{}) error }, extra ...interface{}) (*models.Review, error) {
+	var r models.Review
+	dest := append([]interface{}{&r.ID, &r.ProductModelID, &r.UserID, &r.OrderItemID, &r.Rating, &r.Title, &r.Body, &r.Status,
+		&r.ModerationNote, &r.ModeratedBy, &r.ModeratedAt, &r.Reply, &r.RepliedBy, &r.RepliedAt, &r.CreatedAt, &r.UpdatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetProductReviews handles GET /api/v1/products/:id/reviews
+// Approved reviews with the rating summary. ?rating= keeps one star level;
+// ?sort= newest (default), highest, lowest or with_photos.
+func GetProductReviews(c *gin.Context) {
+	productID := c.Param("id")
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+	orderBy := map[string]string{
+		"newest":      "r.created_at DESC",
+		"highest":     "r.rating DESC, r.created_at DESC",
+		"lowest":      "r.rating ASC, r.created_at DESC",
+		"with_photos": "EXISTS (SELECT 1 FROM review_photos rp WHERE rp.review_id = r.id) DESC, r.created_at DESC",
+	}[c.DefaultQuery("sort", "newest")]
+	if orderBy == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be newest, highest, lowest or with_photos"})
+		return
+	}
+	rating := 0
+	if v := c.Query("rating"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &rating); err != nil || rating < 1 || rating > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be between 1 and 5"})
+			return
+		}
+	}
+
+	summary, err := productRatingSummary(productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+
+	rows, err := DB.Query(`
+		SELECT r.id, r.rating, r.title, r.body, COALESCE(u.full_name, ''), r.order_item_id IS NOT NULL,
+		       r.reply, r.replied_at, r.created_at, COUNT(*) OVER ()
+		FROM reviews r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.product_model_id::text = $1 AND r.status = $2 AND ($3 = 0 OR r.rating = $3)
+		ORDER BY `+orderBy+`
+		LIMIT $4 OFFSET $5`, productID, models.ReviewStatusApproved, rating, limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch reviews: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+	defer rows.Close()
+
+	reviews := []publicReview{}
+	var ids []string
+	total := 0
+	for rows.Next() {
+		var r publicReview
+		var fullName string
+		if err := rows.Scan(&r.ID, &r.Rating, &r.Title, &r.Body, &fullName, &r.VerifiedPurchase,
+			&r.Reply, &r.RepliedAt, &r.CreatedAt, &total); err != nil {
+			continue
+		}
+		r.Author = reviewAuthorName(fullName)
+		reviews = append(reviews, r)
+		ids = append(ids, r.ID.String())
+	}
+	photos := reviewPhotos(ids)
+	for i := range reviews {
+		reviews[i].Photos = photos[reviews[i].ID.String()]
+		if reviews[i].Photos == nil {
+			reviews[i].Photos = []string{}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"summary":  summary,
+		"reviews":  reviews,
+		"total":    total,
+		"page":     page,
+		"limit":    limit,
+		"has_next": offset+len(reviews) < total,
+	})
+}
+
+// GetMyProductReview handles GET /api/v1/products/:id/reviews/mine
+// The customer's review of the product, whatever its status, and whether they can
+// write one (they need a delivered order with the product).
+func GetMyProductReview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	productID := c.Param("id")
+
+	r, err := scanReview(DB.QueryRow(`SELECT `+reviewColumns+` FROM reviews r
+		WHERE r.product_model_id::text = $1 AND r.user_id::text = $2`, productID, userID))
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review"})
+		return
+	}
+	var orderItemID string
+	purchased := DB.QueryRow(reviewPurchaseSQL, userID, productID).Scan(&orderItemID) == nil
+
+	response := gin.H{"review": nil, "can_review": r == nil && purchased}
+	if r != nil {
+		response["review"] = r
+		response["photos"] = reviewPhotos([]string{r.ID.String()})[r.ID.String()]
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateProductReview handles POST /api/v1/products/:id/reviews
+// Customers review products from their delivered orders, once per product. The
+// review waits for moderation before it shows.
+func CreateProductReview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	productID := c.Param("id")
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var orderItemID uuid.UUID
+	err := DB.QueryRow(reviewPurchaseSQL, userID, productID).Scan(&orderItemID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only review products from your delivered orders"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check your orders"})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	r, err := scanReview(tx.QueryRow(`
+		INSERT INTO reviews AS r (id, product_model_id, user_id, order_item_id, rating, title, body, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		RETURNING `+reviewColumns,
+		uuid.New(), productID, userID, orderItemID, req.Rating, req.Title, req.Body, models.ReviewStatusPending, now))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reviewed this product"})
+			return
+		}
+		fmt.Printf("❌ Failed to create review: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		return
+	}
+	if err := saveReviewPhotos(tx, r.ID, req.Photos); err != nil {
+		fmt.Printf("❌ Failed to save review photos: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review photos"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create review"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"review": r, "message": "Thank you! Your review will appear once it has been checked."})
+}
+
+// UpdateMyProductReview handles PUT /api/v1/products/:id/reviews/mine
+// Edits go back through moderation.
+func UpdateMyProductReview(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req reviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	r, err := scanReview(tx.QueryRow(`
+		UPDATE reviews r SET rating = $3, title = $4, body = $5, status = $6,
+		       moderation_note = NULL, moderated_by = NULL, moderated_at = NULL, updated_at = now()
+		WHERE r.product_model_id::text = $1 AND r.user_id::text = $2
+		RETURNING `+reviewColumns,
+		c.Param("id"), userID, req.Rating, req.Title, req.Body, models.ReviewStatusPending))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	if err := saveReviewPhotos(tx, r.ID, req.Photos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review photos"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update review"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"review": r})
+}
+
+// DeleteMyProductReview handles DELETE /api/v1/products/:id/reviews/mine
+func DeleteMyProductReview(c *gin.Context) {
+	res, err := DB.Exec(`DELETE FROM reviews WHERE product_model_id::text = $1 AND user_id::text = $2`,
+		c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete review"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Review deleted"})
+}
+
+// UploadReviewPhoto handles POST /api/v1/reviews/photos
+// Uploads one photo (form field "photo"); its URL goes in the review's photos.
+func UploadReviewPhoto(c *gin.Context) {
+	file, err := c.FormFile("photo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No photo provided"})
+		return
+	}
+	if file.Size > 10<<20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Photo must be under 10 MB"})
+		return
+	}
+	if services.Cloudinary == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Image upload service not available"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	result, err := services.Cloudinary.UploadImageFromBytes(data, "reviews", file.Filename)
+	if err != nil {
+		fmt.Printf("❌ Review photo upload failed: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload photo"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"url": result.SecureURL})
+}
+
+// AdminListReviews handles GET /api/v1/admin/reviews
+// The moderation queue: ?status= pending (default), flagged, approved, rejected or
+// all, oldest first; ?product_id= narrows to one product.
+func AdminListReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReviewStatusPending)
+	if status == "all" {
+		status = ""
+	}
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+
+	rows, err := DB.Query(`
+		SELECT `+reviewColumns+`, pm.title, COALESCE(u.full_name, ''), COALESCE(u.phone, ''), COUNT(*) OVER ()
+		FROM reviews r
+		JOIN product_models pm ON pm.id = r.product_model_id
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE ($1 = '' OR r.status = $1) AND ($2 = '' OR r.product_model_id::text = $2)
+		ORDER BY r.updated_at ASC
+		LIMIT $3 OFFSET $4`, status, c.Query("product_id"), limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch reviews: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reviews"})
+		return
+	}
+	defer rows.Close()
+
+	reviews := []adminReview{}
+	var ids []string
+	total := 0
+	for rows.Next() {
+		var v adminReview
+		r, err := scanReview(rows, &v.ProductTitle, &v.AuthorName, &v.AuthorPhone, &total)
+		if err != nil {
+			continue
+		}
+		v.Review = *r
+		reviews = append(reviews, v)
+		ids = append(ids, r.ID.String())
+	}
+	photos := reviewPhotos(ids)
+	for i := range reviews {
+		reviews[i].Photos = photos[reviews[i].ID.String()]
+	}
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews, "total": total, "page": page, "limit": limit})
+}
+
+// AdminModerateReview handles POST /api/v1/admin/reviews/:id/moderate
+// Actions: approve (shown and counted in the rating), reject (needs a note) and
+// flag (held aside for a second look).
+func AdminModerateReview(c *gin.Context) {
+	var req struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	status, ok := reviewActions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown action " + req.Action})
+		return
+	}
+	if req.Action == "reject" && strings.TrimSpace(req.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A note is required to reject a review"})
+		return
+	}
+
+	r, err := scanReview(DB.QueryRow(`
+		UPDATE reviews r SET status = $2, moderation_note = $3, moderated_by = $4, moderated_at = now(), updated_at = now()
+		WHERE r.id::text = $1
+		RETURNING `+reviewColumns,
+		c.Param("id"), status, nullableString(strings.TrimSpace(req.Note)), nullableString(c.GetString("user_id"))))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to moderate review: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to moderate review"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"review": r})
+}
+
+// AdminReplyToReview handles PUT /api/v1/admin/reviews/:id/reply
+// Sets the merchant's public reply; an empty reply removes it.
+func AdminReplyToReview(c *gin.Context) {
+	var req struct {
+		Reply string `json:"reply"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reply := nullableString(strings.TrimSpace(req.Reply))
+	if reply != nil && len([]rune(*reply)) > reviewMaxBodyLen {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("reply must be at most %d characters", reviewMaxBodyLen)})
+		return
+	}
+
+	r, err := scanReview(DB.QueryRow(`
+		UPDATE reviews r SET reply = $2,
+		       replied_by = CASE WHEN $2::text IS NULL THEN NULL ELSE $3::uuid END,
+		       replied_at = CASE WHEN $2::text IS NULL THEN NULL ELSE now() END
+		WHERE r.id::text = $1
+		RETURNING `+reviewColumns,
+		c.Param("id"), reply, nullableString(c.GetString("user_id"))))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to reply to review: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reply"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"review": r})
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// saleEventView is a sale event with its status derived from the window:
+// scheduled, running, ended or cancelled
+type saleEventView struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	ScopeType  string     `json:"scope_type"`
+	ScopeID    uuid.UUID  `json:"scope_id"`
+	ScopeName  string     `json:"scope_name"`
+	Percentage float64    `json:"percentage"`
+	Currency   string     `json:"currency"`
+	StartAt    time.Time  `json:"start_at"`
+	EndAt      time.Time  `json:"end_at"`
+	SKUCount   int        `json:"sku_count"`
+	Status     string     `json:"status"`
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+const saleEventColumns = `se.id, se.name, se.scope_type, se.scope_id,
+	COALESCE(CASE se.scope_type WHEN 'brand' THEN (SELECT name FROM brands WHERE id = se.scope_id)
+	                            ELSE (SELECT name FROM categories WHERE id = se.scope_id) END, ''),
+	se.percentage, se.currency, se.start_at, se.end_at, se.sku_count, se.status, se.created_by, se.created_at`
+
+func scanSaleEvent(row interface{ Scan(...interface{}) error }) (*saleEventView, error) {
+	var e saleEventView
+	if err := row.Scan(&e.ID, &e.Name, &e.ScopeType, &e.ScopeID, &e.ScopeName, &e.Percentage, &e.Currency,
+		&e.StartAt, &e.EndAt, &e.SKUCount, &e.Status, &e.CreatedBy, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.Currency = strings.TrimSpace(e.Currency)
+	if e.Status != "cancelled" {
+		now := time.Now()
+		switch {
+		case now.Before(e.StartAt):
+			e.Status = "scheduled"
+		case now.Before(e.EndAt):
+			e.Status = "running"
+		default:
+			e.Status = "ended"
+		}
+	}
+	return &e, nil
+}
+
+// saleEventSKUsSQL selects the SKUs in a sale event's scope ($1 scope type, $2 scope
+// id); a category includes its subcategories
+const saleEventSKUsSQL = `
+	WITH RECURSIVE category_tree AS (
+		SELECT id FROM categories WHERE $1 = 'category' AND id = $2
+		UNION
+		SELECT c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+	)
+	SELECT s.id
+	FROM skus s
+	JOIN product_models pm ON pm.id = s.product_model_id
+	WHERE ($1 = 'brand' AND pm.brand_id = $2)
+	   OR ($1 = 'category' AND EXISTS (
+	       SELECT 1 FROM product_model_categories pmc
+	       WHERE pmc.product_model_id = pm.id AND pmc.category_id IN (SELECT id FROM category_tree)))`
+
+type saleEventRequest struct {
+	Name       string    `json:"name" binding:"required"`
+	ScopeType  string    `json:"scope_type" binding:"required"`
+	ScopeID    uuid.UUID `json:"scope_id" binding:"required"`
+	Percentage float64   `json:"percentage" binding:"required"`
+	Currency   string    `json:"currency"`
+	StartAt    time.Time `json:"start_at" binding:"required"`
+	EndAt      time.Time `json:"end_at" binding:"required"`
+}
+
+// saleEventStatusFilters selects sale events by derived status, matching scanSaleEvent
+var saleEventStatusFilters = map[string]string{
+	"scheduled": `se.status <> 'cancelled' AND now() < se.start_at`,
+	"running":   `se.status <> 'cancelled' AND now() >= se.start_at AND now() < se.end_at`,
+	"ended":     `se.status <> 'cancelled' AND now() >= se.end_at`,
+	"cancelled": `se.status = 'cancelled'`,
+}
+
+// AdminListSaleEvents handles GET /api/v1/admin/sale-events
+// Optional ?status= (scheduled, running, ended or cancelled), ?limit= and ?offset=.
+func AdminListSaleEvents(c *gin.Context) {
+	where := "true"
+	if status := c.Query("status"); status != "" {
+		filter, ok := saleEventStatusFilters[status]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be scheduled, running, ended or cancelled"})
+			return
+		}
+		where = filter
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	rows, err := DB.Query(`SELECT `+saleEventColumns+` FROM sale_events se
+		WHERE `+where+`
+		ORDER BY se.start_at DESC
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch sale events: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sale events"})
+		return
+	}
+	defer rows.Close()
+
+	events := []*saleEventView{}
+	for rows.Next() {
+		e, err := scanSaleEvent(rows)
+		if err != nil {
+			continue
+		}
+		events = append(events, e)
+	}
+	c.JSON(http.StatusOK, gin.H{"sale_events": events, "limit": limit, "offset": offset})
+}
+
+// AdminGetSaleEvent handles GET /api/v1/admin/sale-events/:id
+func AdminGetSaleEvent(c *gin.Context) {
+	e, err := scanSaleEvent(DB.QueryRow(`SELECT `+saleEventColumns+` FROM sale_events se WHERE se.id::text = $1`, c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sale event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sale_event": e})
+}
+
+// AdminCreateSaleEvent handles POST /api/v1/admin/sale-events
+// Every SKU of the brand or category gets a price row for the event window at its
+// base list price minus the percentage. A sale event never raises a price: SKUs
+// already on a lower base sale price keep it.
+func AdminCreateSaleEvent(c *gin.Context) {
+	var req saleEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		req.Currency = "MRO"
+	}
+	if req.ScopeType != "brand" && req.ScopeType != "category" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope_type must be brand or category"})
+		return
+	}
+	if req.Percentage <= 0 || req.Percentage >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "percentage must be between 0 and 100"})
+		return
+	}
+	if !req.EndAt.After(req.StartAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at must be after start_at"})
+		return
+	}
+	if !req.EndAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_at is in the past"})
+		return
+	}
+
+	var scopeExists bool
+	scopeTable := "brands"
+	if req.ScopeType == "category" {
+		scopeTable = "categories"
+	}
+	DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM `+scopeTable+` WHERE id = $1)`, req.ScopeID).Scan(&scopeExists)
+	if !scopeExists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown " + req.ScopeType})
+		return
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	if err := setPriceChangeContext(tx, "sale_event", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	var createdBy *string
+	if userID := c.GetString("user_id"); userID != "" {
+		createdBy = &userID
+	}
+	eventID := uuid.New()
+	if _, err := tx.Exec(`
+		INSERT INTO sale_events (id, name, scope_type, scope_id, percentage, currency, start_at, end_at, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())`,
+		eventID, req.Name, req.ScopeType, req.ScopeID, req.Percentage, req.Currency, req.StartAt, req.EndAt, createdBy); err != nil {
+		fmt.Printf("❌ Failed to create sale event: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sale event"})
+		return
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO prices (id, sku_id, currency, list_price, sale_price, start_at, end_at, sale_event_id, created_at, updated_at)
+		SELECT gen_random_uuid(), b.sku_id, b.currency, b.list_price,
+		       LEAST(ROUND(b.list_price * (100 - $4::numeric) / 100, 2),
+		             CASE WHEN b.sale_price > 0 THEN b.sale_price ELSE b.list_price END),
+		       $5, $6, $7, now(), now()
+		FROM (
+			SELECT DISTINCT ON (p.sku_id) p.sku_id, p.currency, p.list_price, p.sale_price
+			FROM prices p
+			WHERE p.sku_id IN (`+saleEventSKUsSQL+`)
+			  AND p.currency = $3 AND p.start_at IS NULL AND p.end_at IS NULL
+			ORDER BY p.sku_id, p.created_at DESC
+		) b`, req.ScopeType, req.ScopeID, req.Currency, req.Percentage, req.StartAt, req.EndAt, eventID)
+	if err != nil {
+		fmt.Printf("❌ Failed to create sale event prices: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sale event prices"})
+		return
+	}
+	skuCount, _ := res.RowsAffected()
+	if skuCount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No priced SKUs in this " + req.ScopeType})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE sale_events SET sku_count = $2 WHERE id = $1`, eventID, skuCount); err != nil {
+		fmt.Printf("❌ Failed to record sale event SKU count: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record sale event SKU count"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sale event"})
+		return
+	}
+	fmt.Printf("🏷️ Sale event %s created: %.0f%% off %d SKUs\n", req.Name, req.Percentage, skuCount)
+
+	e, _ := scanSaleEvent(DB.QueryRow(`SELECT `+saleEventColumns+` FROM sale_events se WHERE se.id = $1`, eventID))
+	c.JSON(http.StatusCreated, gin.H{"sale_event": e})
+}
+
+// AdminCancelSaleEvent handles DELETE /api/v1/admin/sale-events/:id
+// Removes the event's prices; ended events are kept as they are.
+func AdminCancelSaleEvent(c *gin.Context) {
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+	if err := setPriceChangeContext(tx, "sale_event", c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+
+	e, err := scanSaleEvent(tx.QueryRow(`SELECT `+saleEventColumns+` FROM sale_events se WHERE se.id::text = $1 FOR UPDATE`, c.Param("id")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sale event not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sale event"})
+		return
+	}
+	if e.Status == "ended" || e.Status == "cancelled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sale event is already " + e.Status})
+		return
+	}
+
+	if _, err := tx.Exec(`DELETE FROM prices WHERE sale_event_id = $1`, e.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove sale event prices"})
+		return
+	}
+	if _, err := tx.Exec(`UPDATE sale_events SET status = 'cancelled', updated_at = now() WHERE id = $1`, e.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel sale event"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel sale event"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sale event cancelled"})
+}
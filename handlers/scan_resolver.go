@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"fmbq-server/utils"
+
+	"github.com/google/uuid"
+)
+
+// sellable is the normalised answer to a scan, whatever kind of item was scanned.
+// Perfume- and product-level codes carry the sellable variants in Variants.
+type sellable struct {
+	Type      string     `json:"type"` // sku, melhaf_color, perfume, perfume_variant, product
+	ItemID    string     `json:"item_id"`
+	ParentID  string     `json:"parent_id"` // product model, melhaf collection or perfume
+	Title     string     `json:"title"`
+	Variant   string     `json:"variant"`
+	Size      string     `json:"size,omitempty"`
+	Color     string     `json:"color,omitempty"`
+	Brand     string     `json:"brand,omitempty"`
+	EAN       string     `json:"ean,omitempty"`
+	SKUCode   string     `json:"sku_code,omitempty"`
+	ListPrice float64    `json:"list_price"`
+	Price     float64    `json:"price"` // what the customer pays today
+	Currency  string     `json:"currency"`
+	Stock     int        `json:"stock"`
+	Images    []string   `json:"images"`
+	Variants  []sellable `json:"variants,omitempty"`
+	MatchedBy string     `json:"matched_by,omitempty"` // ean, sku_code, qr
+}
+
+// scanItemTypes maps the item kinds that may appear in QR payloads to item types
+var scanItemTypes = map[string]string{
+	"sku":             "sku",
+	"product":         "product",
+	"melhaf":          "melhaf_color",
+	"melhaf_color":    "melhaf_color",
+	"perfume":         "perfume",
+	"perfume_variant": "perfume_variant",
+}
+
+// scanRef is what a QR payload points at: either an item directly or a code to resolve
+type scanRef struct {
+	ItemType string
+	ItemID   uuid.UUID
+	Code     string
+}
+
+// resolveScan turns a scanned string into a sellable. It understands QR payloads
+// (deep links, URLs and small JSON objects), EAN-8/UPC-A/EAN-13 codes through the
+// barcode registry, legacy codes stored verbatim and internal SKU codes.
+// It returns sql.ErrNoRows when nothing matches.
+func resolveScan(code string) (*sellable, error) {
+	code = strings.TrimSpace(code)
+	matchedBy := ""
+	if ref, ok := parseScanPayload(code); ok {
+		if ref.ItemType != "" {
+			item, err := loadSellable(ref.ItemType, ref.ItemID)
+			if err == nil {
+				item.MatchedBy = "qr"
+			}
+			return item, err
+		}
+		code = ref.Code
+		matchedBy = "qr"
+	}
+	if code == "" {
+		return nil, sql.ErrNoRows
+	}
+
+	found := func(itemType string, itemID uuid.UUID, by string) (*sellable, error) {
+		item, err := loadSellable(itemType, itemID)
+		if err != nil {
+			return nil, err
+		}
+		item.MatchedBy = by
+		if matchedBy != "" {
+			item.MatchedBy = matchedBy
+		}
+		return item, nil
+	}
+
+	var itemType string
+	var itemID uuid.UUID
+	if normalized, err := utils.NormalizeBarcode(code); err == nil {
+		err := DB.QueryRow(`SELECT item_type, item_id FROM barcode_registry WHERE ean = $1`, normalized).Scan(&itemType, &itemID)
+		if err == nil {
+			return found(itemType, itemID, "ean")
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+	}
+
+	// Codes the registry does not hold (invalid or duplicated legacy EANs) are matched verbatim
+	err := DB.QueryRow(`
+		SELECT item_type, id FROM (
+			SELECT 'sku' AS item_type, id, created_at FROM skus WHERE ean = $1
+			UNION ALL
+			SELECT 'melhaf_color', id, created_at FROM melhaf_colors WHERE ean = $1
+			UNION ALL
+			SELECT 'perfume', id, created_at FROM maison_adrar_perfumes WHERE ean = $1
+		) matches
+		ORDER BY created_at
+		LIMIT 1`, code).Scan(&itemType, &itemID)
+	if err == nil {
+		return found(itemType, itemID, "ean")
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	err = DB.QueryRow(`SELECT id FROM skus WHERE UPPER(sku_code) = UPPER($1) LIMIT 1`, code).Scan(&itemID)
+	if err != nil {
+		return nil, err
+	}
+	return found("sku", itemID, "sku_code")
+}
+
+// parseScanPayload recognises QR payloads. Supported forms:
+//   - deep links and URLs ending in /<kind>/<id>, e.g. fmbq://perfume/<id> or https://.../product/<id>
+//   - URLs with an ean, sku or code query parameter
+//   - JSON such as {"type": "melhaf_color", "id": "..."}, {"ean": "..."} or {"sku": "..."}
+func parseScanPayload(payload string) (scanRef, bool) {
+	if strings.HasPrefix(payload, "{") {
+		var body struct {
+			Type string `json:"type"`
+			ID   string `json:"id"`
+			EAN  string `json:"ean"`
+			SKU  string `json:"sku"`
+		}
+		if err := json.Unmarshal([]byte(payload), &body); err != nil {
+			return scanRef{}, false
+		}
+		if id, err := uuid.Parse(body.ID); err == nil && scanItemTypes[body.Type] != "" {
+			return scanRef{ItemType: scanItemTypes[body.Type], ItemID: id}, true
+		}
+		if body.EAN != "" {
+			return scanRef{Code: body.EAN}, true
+		}
+		return scanRef{Code: body.SKU}, body.SKU != ""
+	}
+
+	u, err := url.Parse(payload)
+	if err != nil || u.Scheme == "" || u.Opaque != "" {
+		return scanRef{}, false
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Scheme != "http" && u.Scheme != "https" {
+		// In app deep links (fmbq://perfume/<id>) the kind is parsed as the host
+		segments = append([]string{u.Host}, segments...)
+	}
+	for i := len(segments) - 2; i >= 0; i-- {
+		if itemType := scanItemTypes[segments[i]]; itemType != "" {
+			if id, err := uuid.Parse(segments[i+1]); err == nil {
+				return scanRef{ItemType: itemType, ItemID: id}, true
+			}
+		}
+	}
+	for _, key := range []string{"ean", "sku", "code"} {
+		if v := u.Query().Get(key); v != "" {
+			return scanRef{Code: v}, true
+		}
+	}
+	return scanRef{}, false
+}
+
+// loadSellable loads any sellable item type by ID
+func loadSellable(itemType string, id uuid.UUID) (*sellable, error) {
+	switch itemType {
+	case "sku":
+		return loadSKUSellable(id)
+	case "melhaf_color":
+		return loadMelhafSellable(id)
+	case "perfume":
+		return loadPerfumeSellable(id)
+	case "perfume_variant":
+		return loadPerfumeVariantSellable(id)
+	case "product":
+		return loadProductSellable(id)
+	default:
+		return nil, fmt.Errorf("unknown item type %q", itemType)
+	}
+}
+
+func loadSKUSellable(id uuid.UUID) (*sellable, error) {
+	item := sellable{Type: "sku", ItemID: id.String(), Currency: "MRO"}
+	var colorID sql.NullString
+	err := DB.QueryRow(`
+		SELECT s.product_model_id, pm.title, COALESCE(b.name, ''), COALESCE(s.size, ''),
+		       COALESCE(pc.color_name, ''), s.product_color_id, COALESCE(s.ean, ''), COALESCE(s.sku_code, ''),
+		       COALESCE(p.list_price, 0), COALESCE(p.effective_price, 0), COALESCE(i.available, 0)
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		LEFT JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		WHERE s.id = $1
+		LIMIT 1`, id).Scan(&item.ParentID, &item.Title, &item.Brand, &item.Size, &item.Color, &colorID,
+		&item.EAN, &item.SKUCode, &item.ListPrice, &item.Price, &item.Stock)
+	if err != nil {
+		return nil, err
+	}
+	item.Variant = joinVariant(item.Size, item.Color)
+	// Images of this SKU or its color come first, then the rest of the product's gallery
+	item.Images = queryImageURLs(`
+		SELECT url FROM product_images
+		WHERE product_model_id = $1
+		ORDER BY (sku_id IS NOT NULL AND sku_id = $2) DESC,
+		         (product_color_id IS NOT NULL AND product_color_id::text = $3) DESC,
+		         position ASC`, item.ParentID, id, colorID.String)
+	return &item, nil
+}
+
+func loadProductSellable(id uuid.UUID) (*sellable, error) {
+	item := sellable{Type: "product", ItemID: id.String(), ParentID: id.String(), Currency: "MRO"}
+	err := DB.QueryRow(`
+		SELECT pm.title, COALESCE(b.name, '')
+		FROM product_models pm
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		WHERE pm.id = $1`, id).Scan(&item.Title, &item.Brand)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT id FROM skus WHERE product_model_id = $1 ORDER BY size, created_at`, id)
+	if err != nil {
+		return nil, err
+	}
+	var skuIDs []uuid.UUID
+	for rows.Next() {
+		var skuID uuid.UUID
+		if err := rows.Scan(&skuID); err == nil {
+			skuIDs = append(skuIDs, skuID)
+		}
+	}
+	rows.Close()
+
+	for _, skuID := range skuIDs {
+		variant, err := loadSKUSellable(skuID)
+		if err != nil {
+			continue
+		}
+		item.Variants = append(item.Variants, *variant)
+	}
+	// A single-variant product scans straight to its SKU
+	if len(item.Variants) == 1 {
+		return &item.Variants[0], nil
+	}
+	summariseVariants(&item)
+	item.Images = queryImageURLs(`SELECT url FROM product_images WHERE product_model_id = $1 ORDER BY position ASC`, id)
+	return &item, nil
+}
+
+func loadMelhafSellable(id uuid.UUID) (*sellable, error) {
+	item := sellable{Type: "melhaf_color", ItemID: id.String(), Currency: "MRO"}
+	var discount float64
+	err := DB.QueryRow(`
+		SELECT mc.collection_id, mcol.name, mc.name, COALESCE(mc.ean, ''),
+		       mc.price, COALESCE(mc.discount, 0), COALESCE(mi.available, 0)
+		FROM melhaf_colors mc
+		JOIN melhaf_collections mcol ON mcol.id = mc.collection_id
+		LEFT JOIN melhaf_inventory mi ON mi.color_id = mc.id
+		WHERE mc.id = $1`, id).Scan(&item.ParentID, &item.Title, &item.Variant, &item.EAN,
+		&item.ListPrice, &discount, &item.Stock)
+	if err != nil {
+		return nil, err
+	}
+	item.Price = discountedPrice(item.ListPrice, discount)
+	item.Images = queryImageURLs(`SELECT url FROM melhaf_color_images WHERE color_id = $1 ORDER BY position ASC`, id)
+	return &item, nil
+}
+
+func loadPerfumeSellable(id uuid.UUID) (*sellable, error) {
+	item := sellable{Type: "perfume", ItemID: id.String(), Brand: "Maison Adrar", Currency: "MRO"}
+	var discount float64
+	err := DB.QueryRow(`
+		SELECT collection_id, name, COALESCE(size, ''), COALESCE(ean, ''), price, COALESCE(discount, 0)
+		FROM maison_adrar_perfumes
+		WHERE id = $1`, id).Scan(&item.ParentID, &item.Title, &item.Variant, &item.EAN, &item.ListPrice, &discount)
+	if err != nil {
+		return nil, err
+	}
+	item.Price = discountedPrice(item.ListPrice, discount)
+	item.Images = queryImageURLs(`SELECT url FROM maison_adrar_perfume_images WHERE perfume_id = $1 ORDER BY position ASC`, id)
+
+	rows, err := DB.Query(`
+		SELECT id FROM maison_adrar_perfume_colors
+		WHERE perfume_id = $1 AND is_active = true
+		ORDER BY sort_order ASC`, id)
+	if err != nil {
+		return nil, err
+	}
+	var variantIDs []uuid.UUID
+	for rows.Next() {
+		var variantID uuid.UUID
+		if err := rows.Scan(&variantID); err == nil {
+			variantIDs = append(variantIDs, variantID)
+		}
+	}
+	rows.Close()
+
+	for _, variantID := range variantIDs {
+		variant, err := loadPerfumeVariantSellable(variantID)
+		if err != nil {
+			continue
+		}
+		item.Variants = append(item.Variants, *variant)
+		item.Stock += variant.Stock
+	}
+	return &item, nil
+}
+
+func loadPerfumeVariantSellable(id uuid.UUID) (*sellable, error) {
+	item := sellable{Type: "perfume_variant", ItemID: id.String(), Brand: "Maison Adrar", Currency: "MRO"}
+	var discount float64
+	err := DB.QueryRow(`
+		SELECT pc.perfume_id, p.name, pc.name, COALESCE(p.ean, ''),
+		       COALESCE(pc.price_override, NULLIF(pc.price, 0), p.price),
+		       COALESCE(pc.discount, p.discount, 0), COALESCE(pc.stock, 0)
+		FROM maison_adrar_perfume_colors pc
+		JOIN maison_adrar_perfumes p ON p.id = pc.perfume_id
+		WHERE pc.id = $1`, id).Scan(&item.ParentID, &item.Title, &item.Variant, &item.EAN,
+		&item.ListPrice, &discount, &item.Stock)
+	if err != nil {
+		return nil, err
+	}
+	item.Price = discountedPrice(item.ListPrice, discount)
+	item.Images = queryImageURLs(`SELECT url FROM maison_adrar_perfume_images WHERE perfume_id = $1 ORDER BY position ASC`, item.ParentID)
+	return &item, nil
+}
+
+// summariseVariants sets a parent's stock to the variants' total and its prices to the lowest variant's
+func summariseVariants(item *sellable) {
+	for i, v := range item.Variants {
+		item.Stock += v.Stock
+		if i == 0 || v.Price < item.Price {
+			item.Price, item.ListPrice = v.Price, v.ListPrice
+		}
+	}
+}
+
+// discountedPrice applies an optional percentage discount
+func discountedPrice(price, discountPercent float64) float64 {
+	if discountPercent <= 0 || discountPercent >= 100 {
+		return price
+	}
+	return price * (100 - discountPercent) / 100
+}
+
+func joinVariant(size, color string) string {
+	if size != "" && color != "" {
+		return size + " / " + color
+	}
+	return size + color
+}
+
+func queryImageURLs(query string, args ...interface{}) []string {
+	images := []string{}
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return images
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var imageURL string
+		if err := rows.Scan(&imageURL); err == nil {
+			images = append(images, imageURL)
+		}
+	}
+	return images
+}
+
+// logScan records a scan for analytics. Logging never fails the scan itself.
+func logScan(code string, item *sellable, userID, source string) {
+	var matchedBy, itemType, itemID interface{}
+	if item != nil {
+		matchedBy, itemType, itemID = item.MatchedBy, item.Type, item.ItemID
+	}
+	_, err := DB.Exec(`
+		INSERT INTO barcode_scans (id, code, matched_by, item_type, item_id, user_id, source, created_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, NULLIF($5, '')::uuid, $6, now())`,
+		code, matchedBy, itemType, itemID, userID, source)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to log barcode scan: %v\n", err)
+	}
+}
@@ -5,37 +5,46 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
+	"fmbq-server/services"
+
 	"github.com/gin-gonic/gin"
 )
 
 // SearchRequest represents the search parameters
 type SearchRequest struct {
-	Query      string `form:"q" json:"q"`                    // Text search query
-	Category   string `form:"category" json:"category"`      // Category ID filter
-	Brand      string `form:"brand" json:"brand"`            // Brand ID filter
-	Color      string `form:"color" json:"color"`            // Color filter
-	Size       string `form:"size" json:"size"`              // Size filter
-	MinPrice   string `form:"min_price" json:"min_price"`
-	MaxPrice   string `form:"max_price" json:"max_price"`
-	SortBy     string `form:"sort_by" json:"sort_by"`        // price_asc, price_desc, name_asc, name_desc, newest
-	Page       string `form:"page" json:"page"`
-	Limit      string `form:"limit" json:"limit"`
-	InStock    string `form:"in_stock" json:"in_stock"`      // true, false, all
+	Query       string `form:"q" json:"q"`                       // Text search query
+	Category    string `form:"category" json:"category"`         // Category IDs, comma separated
+	Brand       string `form:"brand" json:"brand"`               // Brand IDs, comma separated
+	Color       string `form:"color" json:"color"`               // Color names, comma separated
+	Size        string `form:"size" json:"size"`                 // Size filter, canonical across brands; comma separated
+	ColorFamily string `form:"color_family" json:"color_family"` // Color family slugs, comma separated
+	MinPrice    string `form:"min_price" json:"min_price"`
+	MaxPrice    string `form:"max_price" json:"max_price"`
+	Price       string `form:"price" json:"price"`               // Price bucket keys from the price facet (e.g. 1000-2500,50000-), comma separated
+	OnSale      string `form:"on_sale" json:"on_sale"`           // true, false
+	Rating      string `form:"rating" json:"rating"`             // Average rating in whole stars, comma separated
+	SortBy      string `form:"sort_by" json:"sort_by"`           // relevance (default with q), price_asc, price_desc, name_asc, name_desc, newest, size_asc, size_desc, rating_desc
+	Page        string `form:"page" json:"page"`
+	Limit       string `form:"limit" json:"limit"`
+	InStock     string `form:"in_stock" json:"in_stock"`         // true, false, all
 }
 
 // SearchResponse represents the search results
 type SearchResponse struct {
-	Products    []ProductSearchResult `json:"products"`
-	TotalCount  int                   `json:"total_count"`
-	Page        int                   `json:"page"`
-	Limit       int                   `json:"limit"`
-	TotalPages  int                   `json:"total_pages"`
-	HasNext     bool                  `json:"has_next"`
-	HasPrevious bool                  `json:"has_previous"`
-	Filters     SearchFilters         `json:"filters"`
+	Products     []ProductSearchResult    `json:"products"`
+	TotalCount   int                      `json:"total_count"`
+	Page         int                      `json:"page"`
+	Limit        int                      `json:"limit"`
+	TotalPages   int                      `json:"total_pages"`
+	HasNext      bool                     `json:"has_next"`
+	HasPrevious  bool                     `json:"has_previous"`
+	Filters      SearchFilters            `json:"filters"`
+	SearchID     string                   `json:"search_id,omitempty"`      // send back with clicked results
+	Searched     string                   `json:"searched_query,omitempty"` // query searched, when synonyms changed it
+	Redirect     *services.SearchRedirect `json:"redirect,omitempty"`
+	Personalized bool                     `json:"personalized,omitempty"` // page re-ranked for the customer
 }
 
 // ProductSearchResult represents a product in search results
@@ -59,14 +68,23 @@ type ProductSearchResult struct {
 	AvailableSizes    []string `json:"available_sizes"`
 	AvailableColors   []string `json:"available_colors"`
 	TotalStock        int      `json:"total_stock"`
+	Rating            *float64 `json:"rating"` // approved reviews only
+	ReviewCount       int      `json:"review_count"`
 	Badges            []string `json:"badges"`
+	Reason            string   `json:"reason,omitempty"` // why it was ranked up for the customer
 }
 
 // SearchFilters represents available filters
 type SearchFilters struct {
-	Categories []CategoryFilter `json:"categories"`
-	Brands     []BrandFilter     `json:"brands"`
-	PriceRange PriceRange        `json:"price_range"`
+	Categories    []CategoryFilter    `json:"categories"`
+	Brands        []BrandFilter       `json:"brands"`
+	PriceRange    PriceRange          `json:"price_range"`
+	ColorFamilies []ColorFamilyFilter `json:"color_families"`
+	Sizes         []SizeFilter        `json:"sizes"`
+	PriceBuckets  []PriceBucketFilter `json:"price_buckets"`
+	OnSale        int                 `json:"on_sale"`
+	Stock         StockFilter         `json:"stock"`
+	Ratings       []RatingFilter      `json:"ratings"`
 }
 
 // CategoryFilter represents a category filter option
@@ -94,6 +112,17 @@ type PriceRange struct {
 
 // SearchProducts handles product search with filters and pagination
 func SearchProducts(c *gin.Context) {
+	searchProducts(c, "products")
+}
+
+// EnhancedSearchProducts provides advanced search with color and size filtering
+func EnhancedSearchProducts(c *gin.Context) {
+	searchProducts(c, "products_enhanced")
+}
+
+// searchProducts runs a product search; source tells which endpoint it is in the
+// search log
+func searchProducts(c *gin.Context, source string) {
 	var req SearchRequest
 	if err := c.ShouldBindQuery(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -106,18 +135,23 @@ func SearchProducts(c *gin.Context) {
 	// Validate and set defaults
 	page, limit, offset := validatePagination(req.Page, req.Limit)
 	sortBy := validateSortBy(req.SortBy)
-	inStock := validateInStock(req.InStock)
 
-	// Build the search query
-	query, args, err := buildSearchQuery(req, sortBy, inStock, limit, offset)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to build search query",
-			"details": err.Error(),
-		})
-		return
+	// Apply synonyms and redirects; a redirect to a category or brand lands on its products
+	typed, logFilters := req.Query, req.logFilters()
+	rewrite := services.RewriteSearch(req.Query)
+	req.Query = rewrite.Query
+	if r := rewrite.Redirect; r != nil {
+		switch r.TargetType {
+		case "category":
+			req.Query, req.Category = "", r.Target
+		case "brand":
+			req.Query, req.Brand = "", r.Target
+		}
 	}
 
+	// Build the search query
+	query, args := buildSearchQuery(req, sortBy, limit, offset)
+
 	// Execute search query
 	rows, err := DB.Query(query, args...)
 	if err != nil {
@@ -140,7 +174,7 @@ func SearchProducts(c *gin.Context) {
 	}
 
 	// Get total count for pagination
-	totalCount, err := getSearchTotalCount(req, inStock)
+	totalCount, err := getSearchTotalCount(req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get total count",
@@ -159,190 +193,39 @@ func SearchProducts(c *gin.Context) {
 		return
 	}
 
-	// Calculate pagination info
-	totalPages := (totalCount + limit - 1) / limit
-	hasNext := page < totalPages
-	hasPrevious := page > 1
-
-	response := SearchResponse{
-		Products:    products,
-		TotalCount:  totalCount,
-		Page:        page,
-		Limit:       limit,
-		TotalPages:  totalPages,
-		HasNext:     hasNext,
-		HasPrevious: hasPrevious,
-		Filters:     filters,
-	}
-
-	c.JSON(http.StatusOK, response)
-}
-
-// EnhancedSearchProducts provides advanced search with color and size filtering
-func EnhancedSearchProducts(c *gin.Context) {
-	var req SearchRequest
-	if err := c.ShouldBindQuery(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "Invalid search parameters",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	// Validate and set defaults
-	page, limit, offset := validatePagination(req.Page, req.Limit)
-	sortBy := validateSortBy(req.SortBy)
-	// inStock := validateInStock(req.InStock)
-
-	// Build simple search query that works
-	query := `
-		SELECT DISTINCT
-			pm.id,
-			pm.title,
-			pm.description,
-			pm.short_description,
-			pm.model_code,
-			pm.is_active,
-			pm.created_at,
-			pm.updated_at,
-			b.id as brand_id,
-			b.name as brand_name,
-			COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) as min_price,
-			COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) as max_price,
-			COALESCE(MAX(pr.list_price), 0) as original_price,
-			0 as total_stock,
-			COALESCE(pi.url, '') as image_url
-		FROM product_models pm
-		LEFT JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-		LEFT JOIN categories c ON pmc.category_id = c.id
-		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
-		LEFT JOIN LATERAL (
-			SELECT url 
-			FROM product_images 
-			WHERE product_model_id = pm.id
-			ORDER BY position 
-			LIMIT 1
-		) pi ON true
-		WHERE pm.is_active = true
-	`
-
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
-
-	// Add search conditions
-	if req.Query != "" {
-		searchTerm := "%" + strings.ToLower(req.Query) + "%"
-		conditions = append(conditions, fmt.Sprintf("(LOWER(pm.title) LIKE $%d OR LOWER(pm.description) LIKE $%d OR LOWER(pm.model_code) LIKE $%d OR LOWER(b.name) LIKE $%d)", 
-			argIndex, argIndex, argIndex, argIndex))
-		args = append(args, searchTerm)
-		argIndex++
-	}
-
-	if req.Category != "" {
-		conditions = append(conditions, fmt.Sprintf("c.id = $%d", argIndex))
-		args = append(args, req.Category)
-		argIndex++
-	}
-
-	if req.Brand != "" {
-		conditions = append(conditions, fmt.Sprintf("b.id = $%d", argIndex))
-		args = append(args, req.Brand)
-		argIndex++
-	}
-
-	if req.Color != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s2 JOIN product_colors pc ON s2.product_color_id = pc.id WHERE s2.product_model_id = pm.id AND LOWER(pc.color_name) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Color+"%")
-		argIndex++
-	}
-
-	if req.Size != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s3 WHERE s3.product_model_id = pm.id AND LOWER(s3.size) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Size+"%")
-		argIndex++
-	}
-
-	if req.MinPrice != "" {
-		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) >= $%d", argIndex))
-			args = append(args, minPrice)
-			argIndex++
-		}
-	}
-
-	if req.MaxPrice != "" {
-		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) <= $%d", argIndex))
-			args = append(args, maxPrice)
-			argIndex++
+	// Re-rank the page for signed-in customers unless they chose a sort order
+	personalized := false
+	if req.SortBy == "" || req.SortBy == "relevance" {
+		if affinity := customerAffinity(c); affinity != nil {
+			products, personalized = personalizeSearchResults(affinity, products), true
 		}
 	}
 
-	// Skip inventory filtering for now to avoid errors
-	// if inStock == "true" {
-	// 	conditions = append(conditions, "COALESCE(inv.available, 0) > 0")
-	// } else if inStock == "false" {
-	// 	conditions = append(conditions, "COALESCE(inv.available, 0) = 0")
-	// }
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		query += " AND " + strings.Join(conditions, " AND ")
-	}
-
-	// Add GROUP BY and ORDER BY
-	query += `
-		GROUP BY pm.id, pm.title, pm.description, pm.short_description, pm.model_code, 
-				 pm.is_active, pm.created_at, pm.updated_at, b.id, b.name, pi.url
-		ORDER BY ` + sortBy + `
-		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
-	
-	args = append(args, limit, offset)
-
-	// Execute search query
-	rows, err := DB.Query(query, args...)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Database query failed",
-			"details": err.Error(),
-		})
-		return
-	}
-	defer rows.Close()
-
-	// Parse results
-	products, err := parseSearchResults(rows)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to parse search results",
-			"details": err.Error(),
-		})
-		return
+	var searchID string
+	if page == 1 {
+		searchID = logSearchQuery(c, searchLogEntry{Source: source, Query: typed, Filters: logFilters, ResultCount: totalCount, Rewrite: rewrite})
 	}
 
-	// Get total count for pagination
-	totalCount := len(products) // Simple count for now
-
-	// Skip filters for now to avoid errors
-	filters := SearchFilters{}
-
 	// Calculate pagination info
 	totalPages := (totalCount + limit - 1) / limit
 	hasNext := page < totalPages
 	hasPrevious := page > 1
 
 	response := SearchResponse{
-		Products:    products,
-		TotalCount:  totalCount,
-		Page:        page,
-		Limit:       limit,
-		TotalPages:  totalPages,
-		HasNext:     hasNext,
-		HasPrevious: hasPrevious,
-		Filters:     filters,
+		Products:     products,
+		TotalCount:   totalCount,
+		Page:         page,
+		Limit:        limit,
+		TotalPages:   totalPages,
+		HasNext:      hasNext,
+		HasPrevious:  hasPrevious,
+		Filters:      filters,
+		SearchID:     searchID,
+		Redirect:     rewrite.Redirect,
+		Personalized: personalized,
+	}
+	if rewrite.Rewritten {
+		response.Searched = rewrite.Query
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -443,6 +326,9 @@ func validateSortBy(sortBy string) string {
 		"name_desc":   "pm.title DESC",
 		"newest":      "pm.created_at DESC",
 		"oldest":      "pm.created_at ASC",
+		"size_asc":    "MIN(s.size_rank) ASC NULLS LAST, pm.title ASC",
+		"size_desc":   "MAX(s.size_rank) DESC NULLS LAST, pm.title ASC",
+		"rating_desc": "pm.rating_average DESC NULLS LAST, pm.rating_count DESC",
 	}
 
 	if validSort, exists := validSorts[sortBy]; exists {
@@ -462,14 +348,13 @@ func validateInStock(inStock string) string {
 	}
 }
 
-func buildSearchQuery(req SearchRequest, sortBy, inStock string, limit, offset int) (string, []interface{}, error) {
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
+func buildSearchQuery(req SearchRequest, sortBy string, limit, offset int) (string, []interface{}) {
+	conditions, args := searchConditions(req, "")
+	argIndex := len(args) + 1
 
 	// Base query
 	baseQuery := `
-		SELECT DISTINCT
+		SELECT
 			pm.id,
 			pm.title,
 			pm.description,
@@ -484,13 +369,14 @@ func buildSearchQuery(req SearchRequest, sortBy, inStock string, limit, offset i
 			COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) as max_price,
 			COALESCE(MAX(pr.list_price), 0) as original_price,
 			COALESCE(SUM(inv.available), 0) as total_stock,
-			COALESCE(pi.url, '') as image_url
+			COALESCE(pi.url, '') as image_url,
+			pm.rating_average,
+			pm.rating_count
 		FROM product_models pm
 		LEFT JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-		LEFT JOIN categories c ON pmc.category_id = c.id
 		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
+		LEFT JOIN active_prices pr ON s.id = pr.sku_id
+		LEFT JOIN inventory inv ON s.id = inv.sku_id
 		LEFT JOIN LATERAL (
 			SELECT url 
 			FROM product_images 
@@ -498,262 +384,18 @@ func buildSearchQuery(req SearchRequest, sortBy, inStock string, limit, offset i
 			ORDER BY position 
 			LIMIT 1
 		) pi ON true
-		WHERE pm.is_active = true
-	`
-
-	// Add search conditions
-	if req.Query != "" {
-		searchTerm := "%" + strings.ToLower(req.Query) + "%"
-		conditions = append(conditions, fmt.Sprintf("(LOWER(pm.title) LIKE $%d OR LOWER(pm.description) LIKE $%d OR LOWER(pm.model_code) LIKE $%d OR LOWER(b.name) LIKE $%d)", 
-			argIndex, argIndex, argIndex, argIndex))
-		args = append(args, searchTerm)
-		argIndex++
-	}
-
-	if req.Category != "" {
-		conditions = append(conditions, fmt.Sprintf("c.id = $%d", argIndex))
-		args = append(args, req.Category)
-		argIndex++
-	}
-
-	if req.Brand != "" {
-		conditions = append(conditions, fmt.Sprintf("b.id = $%d", argIndex))
-		args = append(args, req.Brand)
-		argIndex++
-	}
-
-	if req.MinPrice != "" {
-		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) >= $%d", argIndex))
-			args = append(args, minPrice)
-			argIndex++
-		}
-	}
-
-	if req.MaxPrice != "" {
-		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) <= $%d", argIndex))
-			args = append(args, maxPrice)
-			argIndex++
-		}
-	}
-
-	if inStock == "true" {
-		conditions = append(conditions, "inv.available > 0")
-	} else if inStock == "false" {
-		conditions = append(conditions, "inv.available = 0")
-	}
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
-	}
+		WHERE ` + searchWhereSQL(conditions)
 
 	// Add GROUP BY and ORDER BY
 	baseQuery += `
 		GROUP BY pm.id, pm.title, pm.description, pm.short_description, pm.model_code, 
 				 pm.is_active, pm.created_at, pm.updated_at, b.id, b.name, pi.url
-		ORDER BY ` + sortBy + `
+		ORDER BY ` + searchOrderBy(req, sortBy) + `
 		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
 	
 	args = append(args, limit, offset)
 
-	return baseQuery, args, nil
-}
-
-// buildEnhancedSearchQuery builds a search query with color and size filtering support
-func buildEnhancedSearchQuery(req SearchRequest, sortBy, inStock string, limit, offset int) (string, []interface{}, error) {
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
-
-	baseQuery := `
-		SELECT DISTINCT
-			pm.id,
-			pm.title,
-			pm.description,
-			pm.short_description,
-			pm.model_code,
-			pm.is_active,
-			pm.created_at,
-			pm.updated_at,
-			b.id as brand_id,
-			b.name as brand_name,
-			COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) as min_price,
-			COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) as max_price,
-			COALESCE(MAX(pr.list_price), 0) as original_price,
-			COALESCE(SUM(inv.available), 0) as total_stock,
-			COALESCE(pi.url, '') as image_url
-		FROM product_models pm
-		LEFT JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-		LEFT JOIN categories c ON pmc.category_id = c.id
-		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
-		LEFT JOIN LATERAL (
-			SELECT url 
-			FROM product_images 
-			WHERE product_model_id = pm.id
-			ORDER BY position 
-			LIMIT 1
-		) pi ON true
-		WHERE pm.is_active = true
-	`
-
-	// Add search conditions
-	if req.Query != "" {
-		searchTerm := "%" + strings.ToLower(req.Query) + "%"
-		conditions = append(conditions, fmt.Sprintf("(LOWER(pm.title) LIKE $%d OR LOWER(pm.description) LIKE $%d OR LOWER(pm.model_code) LIKE $%d OR LOWER(b.name) LIKE $%d)", 
-			argIndex, argIndex, argIndex, argIndex))
-		args = append(args, searchTerm)
-		argIndex++
-	}
-
-	if req.Category != "" {
-		conditions = append(conditions, fmt.Sprintf("c.id = $%d", argIndex))
-		args = append(args, req.Category)
-		argIndex++
-	}
-
-	if req.Brand != "" {
-		conditions = append(conditions, fmt.Sprintf("b.id = $%d", argIndex))
-		args = append(args, req.Brand)
-		argIndex++
-	}
-
-	// Enhanced color filtering
-	if req.Color != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s2 JOIN product_colors pc ON s2.product_color_id = pc.id WHERE s2.product_model_id = pm.id AND LOWER(pc.color_name) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Color+"%")
-		argIndex++
-	}
-
-	// Enhanced size filtering
-	if req.Size != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s3 WHERE s3.product_model_id = pm.id AND LOWER(s3.size) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Size+"%")
-		argIndex++
-	}
-
-	if req.MinPrice != "" {
-		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) >= $%d", argIndex))
-			args = append(args, minPrice)
-			argIndex++
-		}
-	}
-
-	if req.MaxPrice != "" {
-		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) <= $%d", argIndex))
-			args = append(args, maxPrice)
-			argIndex++
-		}
-	}
-
-	if inStock == "true" {
-		conditions = append(conditions, "inv.available > 0")
-	} else if inStock == "false" {
-		conditions = append(conditions, "inv.available = 0")
-	}
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
-	}
-
-	// Add GROUP BY and ORDER BY
-	baseQuery += `
-		GROUP BY pm.id, pm.title, pm.description, pm.short_description, pm.model_code, 
-				 pm.is_active, pm.created_at, pm.updated_at, b.id, b.name, pi.url
-		ORDER BY ` + sortBy + `
-		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
-	
-	args = append(args, limit, offset)
-
-	return baseQuery, args, nil
-}
-
-// getEnhancedSearchTotalCount gets the total count for enhanced search
-func getEnhancedSearchTotalCount(req SearchRequest, inStock string) (int, error) {
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
-
-	baseQuery := `
-		SELECT COUNT(DISTINCT pm.id)
-		FROM product_models pm
-		LEFT JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-		LEFT JOIN categories c ON pmc.category_id = c.id
-		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
-		WHERE pm.is_active = true
-	`
-
-	// Add the same conditions as the main query
-	if req.Query != "" {
-		searchTerm := "%" + strings.ToLower(req.Query) + "%"
-		conditions = append(conditions, fmt.Sprintf("(LOWER(pm.title) LIKE $%d OR LOWER(pm.description) LIKE $%d OR LOWER(pm.model_code) LIKE $%d OR LOWER(b.name) LIKE $%d)", 
-			argIndex, argIndex, argIndex, argIndex))
-		args = append(args, searchTerm)
-		argIndex++
-	}
-
-	if req.Category != "" {
-		conditions = append(conditions, fmt.Sprintf("c.id = $%d", argIndex))
-		args = append(args, req.Category)
-		argIndex++
-	}
-
-	if req.Brand != "" {
-		conditions = append(conditions, fmt.Sprintf("b.id = $%d", argIndex))
-		args = append(args, req.Brand)
-		argIndex++
-	}
-
-	if req.Color != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s2 JOIN product_colors pc ON s2.product_color_id = pc.id WHERE s2.product_model_id = pm.id AND LOWER(pc.color_name) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Color+"%")
-		argIndex++
-	}
-
-	if req.Size != "" {
-		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM skus s3 WHERE s3.product_model_id = pm.id AND LOWER(s3.size) LIKE LOWER($%d))", argIndex))
-		args = append(args, "%"+req.Size+"%")
-		argIndex++
-	}
-
-	if req.MinPrice != "" {
-		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) >= $%d", argIndex))
-			args = append(args, minPrice)
-			argIndex++
-		}
-	}
-
-	if req.MaxPrice != "" {
-		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) <= $%d", argIndex))
-			args = append(args, maxPrice)
-			argIndex++
-		}
-	}
-
-	if inStock == "true" {
-		conditions = append(conditions, "inv.available > 0")
-	} else if inStock == "false" {
-		conditions = append(conditions, "inv.available = 0")
-	}
-
-	// Combine conditions
-	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
-	}
-
-	var count int
-	err := DB.QueryRow(baseQuery, args...).Scan(&count)
-	return count, err
+	return baseQuery, args
 }
 
 func parseSearchResults(rows *sql.Rows) ([]ProductSearchResult, error) {
@@ -780,6 +422,8 @@ func parseSearchResults(rows *sql.Rows) ([]ProductSearchResult, error) {
 			&product.OriginalPrice,
 			&product.TotalStock,
 			&imageURL,
+			&product.Rating,
+			&product.ReviewCount,
 		)
 		if err != nil {
 			return nil, err
@@ -815,74 +459,15 @@ func parseSearchResults(rows *sql.Rows) ([]ProductSearchResult, error) {
 	return products, nil
 }
 
-func getSearchTotalCount(req SearchRequest, inStock string) (int, error) {
-	var conditions []string
-	var args []interface{}
-	argIndex := 1
-
-	baseQuery := `
-		SELECT COUNT(DISTINCT pm.id)
-		FROM product_models pm
-		LEFT JOIN brands b ON pm.brand_id = b.id
-		LEFT JOIN product_model_categories pmc ON pm.id = pmc.product_model_id
-		LEFT JOIN categories c ON pmc.category_id = c.id
-		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
-		WHERE pm.is_active = true
-	`
-
-	// Add same conditions as main query
-	if req.Query != "" {
-		searchTerm := "%" + strings.ToLower(req.Query) + "%"
-		conditions = append(conditions, fmt.Sprintf("(LOWER(pm.title) LIKE $%d OR LOWER(pm.description) LIKE $%d OR LOWER(pm.model_code) LIKE $%d OR LOWER(b.name) LIKE $%d)", 
-			argIndex, argIndex, argIndex, argIndex))
-		args = append(args, searchTerm)
-		argIndex++
-	}
-
-	if req.Category != "" {
-		conditions = append(conditions, fmt.Sprintf("c.id = $%d", argIndex))
-		args = append(args, req.Category)
-		argIndex++
-	}
-
-	if req.Brand != "" {
-		conditions = append(conditions, fmt.Sprintf("b.id = $%d", argIndex))
-		args = append(args, req.Brand)
-		argIndex++
-	}
-
-	if req.MinPrice != "" {
-		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) >= $%d", argIndex))
-			args = append(args, minPrice)
-			argIndex++
-		}
-	}
-
-	if req.MaxPrice != "" {
-		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
-			conditions = append(conditions, fmt.Sprintf("COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) <= $%d", argIndex))
-			args = append(args, maxPrice)
-			argIndex++
-		}
-	}
-
-	if inStock == "true" {
-		conditions = append(conditions, "inv.available > 0")
-	} else if inStock == "false" {
-		conditions = append(conditions, "inv.available = 0")
-	}
-
-	if len(conditions) > 0 {
-		baseQuery += " AND " + strings.Join(conditions, " AND ")
-	}
-
+func getSearchTotalCount(req SearchRequest) (int, error) {
+	conditions, args := searchConditions(req, "")
 	var count int
-	err := DB.QueryRow(baseQuery, args...).Scan(&count)
+	err := DB.QueryRow(`SELECT COUNT(*) FROM product_models pm WHERE `+searchWhereSQL(conditions), args...).Scan(&count)
 	return count, err
 }
 
+// getSearchFilters returns the facets of a search. Each facet counts the products
+// matching every filter but its own, so its other values stay selectable.
 func getSearchFilters(req SearchRequest) (SearchFilters, error) {
 	filters := SearchFilters{}
 
@@ -900,17 +485,48 @@ func getSearchFilters(req SearchRequest) (SearchFilters, error) {
 	}
 	filters.Brands = brands
 
-	// Get price range
-	priceRange, err := getPriceRange(req)
+	// Get price range and buckets
+	priceRange, priceBuckets, err := getPriceFacet(req)
 	if err != nil {
 		return filters, err
 	}
 	filters.PriceRange = priceRange
+	filters.PriceBuckets = priceBuckets
+
+	// Get color family facet
+	colorFamilies, err := getColorFamilyFilters(req)
+	if err != nil {
+		return filters, err
+	}
+	filters.ColorFamilies = colorFamilies
+
+	// Get size facet
+	sizes, err := getSizeFilters(req)
+	if err != nil {
+		return filters, err
+	}
+	filters.Sizes = sizes
+
+	// Get on-sale and availability counts
+	if filters.OnSale, err = getOnSaleCount(req); err != nil {
+		return filters, err
+	}
+	if filters.Stock, err = getStockFilter(req); err != nil {
+		return filters, err
+	}
+
+	// Get rating facet
+	ratings, err := getRatingFilters(req)
+	if err != nil {
+		return filters, err
+	}
+	filters.Ratings = ratings
 
 	return filters, nil
 }
 
 func getCategoryFilters(req SearchRequest) ([]CategoryFilter, error) {
+	conditions, args := searchConditions(req, facetCategory)
 	query := `
 		SELECT 
 			c.id,
@@ -919,14 +535,14 @@ func getCategoryFilters(req SearchRequest) ([]CategoryFilter, error) {
 			c.level,
 			COUNT(DISTINCT pm.id) as product_count
 		FROM categories c
-		LEFT JOIN product_model_categories pmc ON c.id = pmc.category_id
-		LEFT JOIN product_models pm ON pmc.product_model_id = pm.id AND pm.is_active = true
-		WHERE c.is_active = true
+		JOIN product_model_categories pmc ON c.id = pmc.category_id
+		JOIN product_models pm ON pmc.product_model_id = pm.id
+		WHERE c.is_active = true AND ` + searchWhereSQL(conditions) + `
 		GROUP BY c.id, c.name, c.parent_id, c.level
 		ORDER BY c.level ASC, c.name ASC
 	`
 
-	rows, err := DB.Query(query)
+	rows, err := DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -957,18 +573,20 @@ func getCategoryFilters(req SearchRequest) ([]CategoryFilter, error) {
 }
 
 func getBrandFilters(req SearchRequest) ([]BrandFilter, error) {
+	conditions, args := searchConditions(req, facetBrand)
 	query := `
 		SELECT 
 			b.id,
 			b.name,
 			COUNT(DISTINCT pm.id) as product_count
 		FROM brands b
-		LEFT JOIN product_models pm ON b.id = pm.brand_id AND pm.is_active = true
+		JOIN product_models pm ON b.id = pm.brand_id
+		WHERE ` + searchWhereSQL(conditions) + `
 		GROUP BY b.id, b.name
 		ORDER BY b.name ASC
 	`
 
-	rows, err := DB.Query(query)
+	rows, err := DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -987,20 +605,23 @@ func getBrandFilters(req SearchRequest) ([]BrandFilter, error) {
 	return brands, nil
 }
 
-func getPriceRange(req SearchRequest) (PriceRange, error) {
+func getColorFamilyFilters(req SearchRequest) ([]ColorFamilyFilter, error) {
+	conditions, args := searchConditions(req, facetColorFamily)
 	query := `
-		SELECT 
-			COALESCE(MIN(pr.sale_price), MIN(pr.list_price), 0) as min_price,
-			COALESCE(MAX(pr.sale_price), MAX(pr.list_price), 0) as max_price
-		FROM product_models pm
-		LEFT JOIN skus s ON pm.id = s.product_model_id
-		LEFT JOIN prices pr ON s.id = pr.sku_id
-		WHERE pm.is_active = true
+		SELECT pc.color_family, COUNT(DISTINCT pm.id) as product_count
+		FROM product_colors pc
+		JOIN product_models pm ON pc.product_model_id = pm.id
+		WHERE pc.color_family IS NOT NULL AND ` + searchWhereSQL(conditions) + `
+		GROUP BY pc.color_family
 	`
 
-	var priceRange PriceRange
-	err := DB.QueryRow(query).Scan(&priceRange.Min, &priceRange.Max)
-	return priceRange, err
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return colorFamilyFacet(rows), nil
 }
 
 func getProductCategoriesForSearch(productID string) []string {
@@ -1031,10 +652,11 @@ func getProductCategoriesForSearch(productID string) []string {
 
 func getProductSizesForSearch(productID string) []string {
 	query := `
-		SELECT DISTINCT s.size
+		SELECT s.size
 		FROM skus s
 		WHERE s.product_model_id = $1 AND s.size IS NOT NULL AND s.size != ''
-		ORDER BY s.size
+		GROUP BY s.size
+		ORDER BY MIN(s.size_rank) NULLS LAST, s.size
 	`
 
 	rows, err := DB.Query(query, productID)
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// searchLogEntry is a customer search as recorded in search_queries
+type searchLogEntry struct {
+	Source      string // search, products, products_enhanced, product_code
+	Query       string // as typed
+	Filters     map[string]string
+	ResultCount int
+	Rewrite     services.SearchRewrite
+}
+
+// logSearchQuery records a customer search and returns its id, which the client
+// sends back with the results it opens; the row is written before the id is handed
+// out so clicks always find it. It returns no id when the search could not be
+// logged. Popular queries feed the suggestions and the search reports.
+func logSearchQuery(c *gin.Context, entry searchLogEntry) string {
+	query := strings.TrimSpace(entry.Query)
+	if query == "" {
+		return ""
+	}
+	filters := []byte("{}")
+	if len(entry.Filters) > 0 {
+		filters, _ = json.Marshal(entry.Filters)
+	}
+	var rewritten, redirectID *string
+	if entry.Rewrite.Rewritten {
+		rewritten = &entry.Rewrite.Query
+	}
+	if entry.Rewrite.Redirect != nil {
+		redirectID = &entry.Rewrite.Redirect.RuleID
+	}
+	id := uuid.New()
+	userID := nullableString(c.GetString("user_id"))
+	if _, err := DB.Exec(`
+		INSERT INTO search_queries (id, query, normalized_query, source, filters, rewritten_query, redirect_id, result_count, user_id, created_at)
+		VALUES ($1, $2, search_normalize($2), $3, $4, $5, $6, $7, $8, now())`,
+		id, query, entry.Source, string(filters), rewritten, redirectID, entry.ResultCount, userID); err != nil {
+		fmt.Printf("⚠️ Failed to log search query: %v\n", err)
+		return ""
+	}
+	return id.String()
+}
+
+// logFilters lists the filters of a product search that were set
+func (req SearchRequest) logFilters() map[string]string {
+	filters := map[string]string{}
+	for key, value := range map[string]string{
+		"category": req.Category, "brand": req.Brand, "color": req.Color, "size": req.Size,
+		"color_family": req.ColorFamily, "min_price": req.MinPrice, "max_price": req.MaxPrice,
+		"price": req.Price, "on_sale": req.OnSale, "rating": req.Rating, "in_stock": req.InStock,
+		"sort_by": req.SortBy,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			filters[key] = value
+		}
+	}
+	return filters
+}
+
+// TrackSearchClick handles POST /api/v1/search/click
+// Records a result opened from a search, by the search_id the search returned.
+func TrackSearchClick(c *gin.Context) {
+	var req struct {
+		SearchID string `json:"search_id" binding:"required"`
+		ItemType string `json:"item_type" binding:"required"`
+		ItemID   string `json:"item_id" binding:"required"`
+		Position *int   `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !searchDocTypes[req.ItemType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown item_type " + req.ItemType})
+		return
+	}
+	searchID, err := uuid.Parse(req.SearchID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search_id"})
+		return
+	}
+	itemID, err := uuid.Parse(req.ItemID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item_id"})
+		return
+	}
+	if req.Position != nil && *req.Position < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position starts at 1"})
+		return
+	}
+
+	res, err := DB.Exec(`
+		INSERT INTO search_clicks (id, search_query_id, item_type, item_id, position, created_at)
+		SELECT gen_random_uuid(), sq.id, $2, $3, $4, now()
+		FROM search_queries sq WHERE sq.id = $1`,
+		searchID, req.ItemType, itemID, req.Position)
+	if err != nil {
+		fmt.Printf("❌ Failed to record search click: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record click"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Search not found"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Click recorded"})
+}
+
+// searchQueryStat sums up the searches for one normalized query
+type searchQueryStat struct {
+	Query          string    `json:"query"` // latest spelling typed
+	Normalized     string    `json:"normalized_query"`
+	Searches       int       `json:"searches"`
+	Users          int       `json:"users"`
+	AvgResults     float64   `json:"avg_results"`
+	ZeroResults    int       `json:"zero_results"`
+	Clicked        int       `json:"clicked"` // searches with at least one result opened
+	CTR            float64   `json:"ctr"`     // clicked / searches
+	LastSearchedAt time.Time `json:"last_searched_at"`
+}
+
+// searchReport answers one of the search reports: queries over the last ?days=
+// (default 30), optionally from one ?source=, grouped by normalized text. Queries
+// searched fewer than ?min_searches= times, or not kept by having, are left out.
+func searchReport(c *gin.Context, minSearchesDefault, having, orderBy string) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+	minSearches, err := strconv.Atoi(c.DefaultQuery("min_searches", minSearchesDefault))
+	if err != nil || minSearches < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_searches must be a positive number"})
+		return
+	}
+	source := c.Query("source")
+	since := time.Now().AddDate(0, 0, -days)
+
+	rows, err := DB.Query(`
+		WITH searches AS (
+			SELECT sq.query, sq.normalized_query, sq.user_id, sq.result_count, sq.created_at,
+			       EXISTS (SELECT 1 FROM search_clicks sc WHERE sc.search_query_id = sq.id) AS clicked
+			FROM search_queries sq
+			WHERE sq.created_at >= $1 AND sq.normalized_query <> '' AND ($2 = '' OR sq.source = $2)
+		)
+		SELECT (array_agg(query ORDER BY created_at DESC))[1], normalized_query,
+		       COUNT(*), COUNT(DISTINCT user_id), ROUND(AVG(result_count), 1),
+		       COUNT(*) FILTER (WHERE result_count = 0), COUNT(*) FILTER (WHERE clicked),
+		       ROUND(COUNT(*) FILTER (WHERE clicked)::numeric / COUNT(*), 4), MAX(created_at)
+		FROM searches
+		GROUP BY normalized_query
+		HAVING COUNT(*) >= $4 AND `+having+`
+		ORDER BY `+orderBy+`
+		LIMIT $3`, since, source, limit, minSearches)
+	if err != nil {
+		fmt.Printf("❌ Failed to build search report: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build search report"})
+		return
+	}
+	defer rows.Close()
+
+	queries := []searchQueryStat{}
+	for rows.Next() {
+		var s searchQueryStat
+		if err := rows.Scan(&s.Query, &s.Normalized, &s.Searches, &s.Users, &s.AvgResults,
+			&s.ZeroResults, &s.Clicked, &s.CTR, &s.LastSearchedAt); err != nil {
+			continue
+		}
+		queries = append(queries, s)
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "source": source, "queries": queries})
+}
+
+// AdminSearchTopQueries handles GET /api/v1/admin/search/reports/top-queries?days=30
+// The most searched queries.
+func AdminSearchTopQueries(c *gin.Context) {
+	searchReport(c, "1", "true", "COUNT(*) DESC, MAX(created_at) DESC")
+}
+
+// AdminSearchZeroResultQueries handles GET /api/v1/admin/search/reports/zero-results?days=30
+// Queries that found nothing, most frequent first: candidates for synonyms,
+// redirects or new products.
+func AdminSearchZeroResultQueries(c *gin.Context) {
+	searchReport(c, "1", "COUNT(*) FILTER (WHERE result_count = 0) > 0",
+		"COUNT(*) FILTER (WHERE result_count = 0) DESC, MAX(created_at) DESC")
+}
+
+// AdminSearchLowCTRQueries handles GET /api/v1/admin/search/reports/low-ctr?days=30&min_searches=5
+// Queries that find results customers do not open, worst click-through first.
+func AdminSearchLowCTRQueries(c *gin.Context) {
+	searchReport(c, "5", "COUNT(*) FILTER (WHERE result_count > 0) > 0",
+		"COUNT(*) FILTER (WHERE clicked)::numeric / COUNT(*) ASC, COUNT(*) DESC")
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Facets a product search filter belongs to. A facet's counts are computed without
+// its own filter (disjunctive faceting), so they show what selecting more of its
+// values would add rather than collapsing to the current selection.
+const (
+	facetCategory    = "category"
+	facetBrand       = "brand"
+	facetColor       = "color"
+	facetColorFamily = "color_family"
+	facetSize        = "size"
+	facetPrice       = "price"
+	facetOnSale      = "on_sale"
+	facetInStock     = "in_stock"
+	facetRating      = "rating"
+)
+
+// searchPriceSQL is the lowest current MRO price of product model pm
+const searchPriceSQL = `(SELECT MIN(ap.effective_price) FROM skus sp
+	JOIN active_prices ap ON ap.sku_id = sp.id AND ap.currency = 'MRO'
+	WHERE sp.product_model_id = pm.id)`
+
+// searchOnSaleSQL tells whether product model pm has a SKU currently sold below its list price
+const searchOnSaleSQL = `EXISTS (SELECT 1 FROM skus so
+	JOIN active_prices ap ON ap.sku_id = so.id
+	WHERE so.product_model_id = pm.id AND ap.sale_price > 0 AND ap.sale_price < ap.list_price)`
+
+// searchInStockSQL tells whether product model pm has a SKU available
+const searchInStockSQL = `EXISTS (SELECT 1 FROM skus si
+	JOIN inventory iv ON iv.sku_id = si.id
+	WHERE si.product_model_id = pm.id AND iv.available > 0)`
+
+// searchRatingSQL is the average approved review rating of product model pm, to
+// the nearest star
+const searchRatingSQL = `ROUND(pm.rating_average)::int`
+
+// searchPriceBuckets are the lower bounds, in MRO, of the price facet's buckets; the
+// last bucket is open ended
+var searchPriceBuckets = []float64{0, 1000, 2500, 5000, 10000, 25000, 50000}
+
+// SizeFilter represents a canonical size filter option
+type SizeFilter struct {
+	Code  string `json:"code"`
+	Scale string `json:"scale"`
+	Count int    `json:"count"`
+}
+
+// PriceBucketFilter represents a price bucket filter option; Key is the value to
+// send back in ?price=
+type PriceBucketFilter struct {
+	Key   string   `json:"key"`
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+// StockFilter counts products with and without stock
+type StockFilter struct {
+	InStock    int `json:"in_stock"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+// RatingFilter represents an average rating filter option, in whole stars
+type RatingFilter struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+// filterValues splits a comma separated filter into its non-empty values
+func filterValues(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// filterUUIDs keeps the values of a comma separated filter that are valid ids; a
+// filter with none left matches nothing
+func filterUUIDs(s string) []string {
+	var ids []string
+	for _, v := range filterValues(s) {
+		if _, err := uuid.Parse(v); err == nil {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
+
+// parsePriceBucket reads a price bucket key such as "1000-2500" or "25000-"
+func parsePriceBucket(key string) (min float64, max *float64, ok bool) {
+	lo, hi, found := strings.Cut(key, "-")
+	if !found {
+		return 0, nil, false
+	}
+	min, err := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+	if err != nil {
+		return 0, nil, false
+	}
+	if hi = strings.TrimSpace(hi); hi != "" {
+		v, err := strconv.ParseFloat(hi, 64)
+		if err != nil || v <= min {
+			return 0, nil, false
+		}
+		max = &v
+	}
+	return min, max, true
+}
+
+func priceBucketKey(min float64, max *float64) string {
+	key := strconv.FormatFloat(min, 'f', -1, 64) + "-"
+	if max != nil {
+		key += strconv.FormatFloat(*max, 'f', -1, 64)
+	}
+	return key
+}
+
+// searchConditions builds the filters of a product search as conditions on product
+// models pm, with arguments numbered from $1; the search text, when present, is
+// always $1. Every filter takes comma separated values, matching any of them. The
+// filter of the facet named by exclude is left out, for that facet's counts.
+func searchConditions(req SearchRequest, exclude string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	next := func() int { return len(args) + 1 }
+
+	if req.Query != "" {
+		conditions = append(conditions, productSearchMatchSQL(next()))
+		args = append(args, req.Query)
+	}
+
+	if req.Category != "" && exclude != facetCategory {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM product_model_categories pmcf
+			WHERE pmcf.product_model_id = pm.id AND pmcf.category_id = ANY($%d::uuid[]))`, next()))
+		args = append(args, pgTextArray(filterUUIDs(req.Category)))
+	}
+
+	if req.Brand != "" && exclude != facetBrand {
+		conditions = append(conditions, fmt.Sprintf("pm.brand_id = ANY($%d::uuid[])", next()))
+		args = append(args, pgTextArray(filterUUIDs(req.Brand)))
+	}
+
+	if req.Color != "" && exclude != facetColor {
+		var patterns []string
+		for _, color := range filterValues(req.Color) {
+			patterns = append(patterns, "%"+color+"%")
+		}
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM skus s2 JOIN product_colors pc ON s2.product_color_id = pc.id
+			WHERE s2.product_model_id = pm.id AND pc.color_name ILIKE ANY($%d::text[]))`, next()))
+		args = append(args, pgTextArray(patterns))
+	}
+
+	if req.Size != "" && exclude != facetSize {
+		sizeCond, sizeArgs := sizeFilterSQL("s3", req.Size, next())
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM skus s3 WHERE s3.product_model_id = pm.id AND "+sizeCond+")")
+		args = append(args, sizeArgs...)
+	}
+
+	if req.ColorFamily != "" && exclude != facetColorFamily {
+		familyCond, familyArg := colorFamilyFilterSQL(req.ColorFamily, next())
+		conditions = append(conditions, familyCond)
+		args = append(args, familyArg)
+	}
+
+	if exclude != facetPrice {
+		// min_price and max_price bound the price; ?price= buckets are alternatives
+		var bounds, buckets []string
+		if minPrice, err := strconv.ParseFloat(req.MinPrice, 64); err == nil {
+			bounds = append(bounds, fmt.Sprintf("p.price >= $%d", next()))
+			args = append(args, minPrice)
+		}
+		if maxPrice, err := strconv.ParseFloat(req.MaxPrice, 64); err == nil {
+			bounds = append(bounds, fmt.Sprintf("p.price <= $%d", next()))
+			args = append(args, maxPrice)
+		}
+		for _, key := range filterValues(req.Price) {
+			min, max, ok := parsePriceBucket(key)
+			if !ok {
+				continue
+			}
+			bucket := fmt.Sprintf("p.price >= $%d", next())
+			args = append(args, min)
+			if max != nil {
+				bucket += fmt.Sprintf(" AND p.price < $%d", next())
+				args = append(args, *max)
+			}
+			buckets = append(buckets, "("+bucket+")")
+		}
+		if len(buckets) > 0 {
+			bounds = append(bounds, "("+strings.Join(buckets, " OR ")+")")
+		}
+		if len(bounds) > 0 {
+			conditions = append(conditions, "EXISTS (SELECT 1 FROM (SELECT "+searchPriceSQL+" AS price) p WHERE "+strings.Join(bounds, " AND ")+")")
+		}
+	}
+
+	if exclude != facetOnSale {
+		switch req.OnSale {
+		case "true":
+			conditions = append(conditions, searchOnSaleSQL)
+		case "false":
+			conditions = append(conditions, "NOT "+searchOnSaleSQL)
+		}
+	}
+
+	if exclude != facetInStock {
+		switch validateInStock(req.InStock) {
+		case "true":
+			conditions = append(conditions, searchInStockSQL)
+		case "false":
+			conditions = append(conditions, "NOT "+searchInStockSQL)
+		}
+	}
+
+	if req.Rating != "" && exclude != facetRating {
+		var ratings []string
+		for _, v := range filterValues(req.Rating) {
+			if r, err := strconv.Atoi(v); err == nil && r >= 1 && r <= 5 {
+				ratings = append(ratings, v)
+			}
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = ANY($%d::int[])", searchRatingSQL, next()))
+		args = append(args, pgTextArray(ratings))
+	}
+
+	return conditions, args
+}
+
+// searchWhereSQL selects the active product models pm passing the conditions
+func searchWhereSQL(conditions []string) string {
+	return strings.Join(append([]string{"pm.is_active = true"}, conditions...), " AND ")
+}
+
+func getSizeFilters(req SearchRequest) ([]SizeFilter, error) {
+	conditions, args := searchConditions(req, facetSize)
+	rows, err := DB.Query(`
+		SELECT s.size_normalized, MIN(s.size_scale), COUNT(DISTINCT pm.id)
+		FROM product_models pm
+		JOIN skus s ON s.product_model_id = pm.id
+		WHERE s.size_normalized IS NOT NULL AND `+searchWhereSQL(conditions)+`
+		GROUP BY s.size_normalized
+		ORDER BY MIN(s.size_rank) NULLS LAST, s.size_normalized`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := []SizeFilter{}
+	for rows.Next() {
+		var size SizeFilter
+		var scale *string
+		if err := rows.Scan(&size.Code, &scale, &size.Count); err != nil {
+			continue
+		}
+		if scale != nil {
+			size.Scale = *scale
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// getPriceFacet returns the price range and the counts per price bucket of the
+// products matching every filter but the price
+func getPriceFacet(req SearchRequest) (PriceRange, []PriceBucketFilter, error) {
+	conditions, args := searchConditions(req, facetPrice)
+	bounds := make([]string, len(searchPriceBuckets))
+	for i, b := range searchPriceBuckets {
+		bounds[i] = strconv.FormatFloat(b, 'f', -1, 64)
+	}
+	args = append(args, pgTextArray(bounds))
+
+	// width_bucket numbers the bucket starting at searchPriceBuckets[i] as i+1
+	rows, err := DB.Query(fmt.Sprintf(`
+		SELECT width_bucket(p.price, $%d::numeric[]), COUNT(*), MIN(p.price), MAX(p.price)
+		FROM (SELECT `+searchPriceSQL+` AS price FROM product_models pm WHERE `+searchWhereSQL(conditions)+`) p
+		WHERE p.price IS NOT NULL
+		GROUP BY 1`, len(args)), args...)
+	if err != nil {
+		return PriceRange{}, nil, err
+	}
+	defer rows.Close()
+
+	var priceRange PriceRange
+	counts := map[int]int{}
+	first := true
+	for rows.Next() {
+		var bucket, count int
+		var min, max float64
+		if err := rows.Scan(&bucket, &count, &min, &max); err != nil {
+			continue
+		}
+		counts[bucket] += count
+		if first || min < priceRange.Min {
+			priceRange.Min = min
+		}
+		if first || max > priceRange.Max {
+			priceRange.Max = max
+		}
+		first = false
+	}
+
+	buckets := []PriceBucketFilter{}
+	for i, min := range searchPriceBuckets {
+		if counts[i+1] == 0 {
+			continue
+		}
+		var max *float64
+		if i+1 < len(searchPriceBuckets) {
+			v := searchPriceBuckets[i+1]
+			max = &v
+		}
+		buckets = append(buckets, PriceBucketFilter{Key: priceBucketKey(min, max), Min: min, Max: max, Count: counts[i+1]})
+	}
+	return priceRange, buckets, nil
+}
+
+// getOnSaleCount counts the products on sale among those matching every other filter
+func getOnSaleCount(req SearchRequest) (int, error) {
+	conditions, args := searchConditions(req, facetOnSale)
+	var count int
+	err := DB.QueryRow(`SELECT COUNT(*) FROM product_models pm WHERE `+searchWhereSQL(conditions)+` AND `+searchOnSaleSQL, args...).Scan(&count)
+	return count, err
+}
+
+func getStockFilter(req SearchRequest) (StockFilter, error) {
+	conditions, args := searchConditions(req, facetInStock)
+	var stock StockFilter
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FILTER (WHERE in_stock), COUNT(*) FILTER (WHERE NOT in_stock)
+		FROM (SELECT `+searchInStockSQL+` AS in_stock FROM product_models pm WHERE `+searchWhereSQL(conditions)+`) st`,
+		args...).Scan(&stock.InStock, &stock.OutOfStock)
+	return stock, err
+}
+
+func getRatingFilters(req SearchRequest) ([]RatingFilter, error) {
+	conditions, args := searchConditions(req, facetRating)
+	rows, err := DB.Query(`
+		SELECT r.rating, COUNT(*)
+		FROM (SELECT `+searchRatingSQL+` AS rating FROM product_models pm WHERE `+searchWhereSQL(conditions)+`) r
+		WHERE r.rating IS NOT NULL
+		GROUP BY r.rating
+		ORDER BY r.rating DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := []RatingFilter{}
+	for rows.Next() {
+		var rating RatingFilter
+		if err := rows.Scan(&rating.Rating, &rating.Count); err == nil {
+			ratings = append(ratings, rating)
+		}
+	}
+	return ratings, nil
+}
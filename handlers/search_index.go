@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchDocTypes are the kinds of items in the search index
+var searchDocTypes = map[string]bool{"product": true, "melhaf_collection": true, "melhaf_color": true, "perfume": true}
+
+// searchMatchSQL matches the search document sd against the text in $n: full-text
+// on the weighted document, or trigram word similarity for misspellings
+func searchMatchSQL(n int) string {
+	return fmt.Sprintf(`(sd.document @@ websearch_to_tsquery('french', search_normalize($%d))
+		OR search_normalize($%d) <%% sd.search_text)`, n, n)
+}
+
+// searchScoreSQL is the relevance of sd for the text in $n, blended with its popularity
+func searchScoreSQL(n int) string {
+	return fmt.Sprintf(`((ts_rank_cd(sd.document, websearch_to_tsquery('french', search_normalize($%d)), 32)
+		+ 0.5 * word_similarity(search_normalize($%d), sd.search_text)) * (1 + 0.1 * sd.popularity))`, n, n)
+}
+
+// productSearchMatchSQL restricts product models pm to those matching the text in $n
+func productSearchMatchSQL(n int) string {
+	return `EXISTS (SELECT 1 FROM search_documents sd
+		WHERE sd.doc_type = 'product' AND sd.doc_id = pm.id AND ` + searchMatchSQL(n) + `)`
+}
+
+// searchOrderBy ranks product models by relevance when there is a search text and
+// no explicit sort, with sortBy as tie-breaker. The search text is always $1.
+func searchOrderBy(req SearchRequest, sortBy string) string {
+	if req.Query == "" || (req.SortBy != "" && req.SortBy != "relevance") {
+		return sortBy
+	}
+	return `(SELECT ` + searchScoreSQL(1) + ` FROM search_documents sd
+		WHERE sd.doc_type = 'product' AND sd.doc_id = pm.id) DESC NULLS LAST, ` + sortBy
+}
+
+// searchHit is one result of the catalog-wide search
+type searchHit struct {
+	Type     string   `json:"type"` // product, melhaf_collection, melhaf_color, perfume
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Subtitle string   `json:"subtitle"`
+	ImageURL string   `json:"image_url"`
+	Price    *float64 `json:"price"`
+	Score    float64  `json:"score"`
+}
+
+// GlobalSearch handles GET /api/v1/search
+// Searches products, Melhaf collections and colors and Maison Adrar perfumes at once,
+// ranked by relevance and popularity. ?type= narrows to some kinds (comma separated).
+// Synonyms apply; a redirect rule for the query is returned for the client to follow.
+func GlobalSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	var types []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			if !searchDocTypes[t] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown type " + t})
+				return
+			}
+			types = append(types, t)
+		}
+	}
+	page, limit, offset := validatePagination(c.Query("page"), c.Query("limit"))
+	rewrite := services.RewriteSearch(q)
+
+	rows, err := DB.Query(`
+		SELECT sd.doc_type, sd.doc_id::text, sd.title, sd.subtitle, `+searchScoreSQL(1)+` AS score,
+		       COALESCE(CASE sd.doc_type
+		           WHEN 'product' THEN (SELECT url FROM product_images WHERE product_model_id = sd.doc_id ORDER BY position LIMIT 1)
+		           WHEN 'melhaf_color' THEN (SELECT url FROM melhaf_color_images WHERE color_id = sd.doc_id ORDER BY position LIMIT 1)
+		           WHEN 'melhaf_collection' THEN (SELECT mci.url FROM melhaf_colors mc JOIN melhaf_color_images mci ON mci.color_id = mc.id
+		                                          WHERE mc.collection_id = sd.doc_id ORDER BY mc.sort_order, mci.position LIMIT 1)
+		           WHEN 'perfume' THEN (SELECT url FROM maison_adrar_perfume_images WHERE perfume_id = sd.doc_id ORDER BY position LIMIT 1)
+		       END, ''),
+		       CASE sd.doc_type
+		           WHEN 'product' THEN (SELECT MIN(p.effective_price) FROM skus s JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		                                WHERE s.product_model_id = sd.doc_id)
+		           WHEN 'melhaf_color' THEN (SELECT price * (100 - COALESCE(discount, 0)) / 100 FROM melhaf_colors WHERE id = sd.doc_id)
+		           WHEN 'melhaf_collection' THEN (SELECT MIN(price * (100 - COALESCE(discount, 0)) / 100) FROM melhaf_colors
+		                                          WHERE collection_id = sd.doc_id AND is_active = true)
+		           WHEN 'perfume' THEN (SELECT price * (100 - COALESCE(discount, 0)) / 100 FROM maison_adrar_perfumes WHERE id = sd.doc_id)
+		       END,
+		       COUNT(*) OVER ()
+		FROM search_documents sd
+		WHERE sd.is_active = true AND `+searchMatchSQL(1)+`
+		  AND (cardinality($2::text[]) = 0 OR sd.doc_type = ANY($2::text[]))
+		ORDER BY score DESC, sd.title
+		LIMIT $3 OFFSET $4`, rewrite.Query, pgTextArray(types), limit+1, offset)
+	if err != nil {
+		fmt.Printf("❌ Search failed for %q: %v\n", q, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Search failed"})
+		return
+	}
+	defer rows.Close()
+
+	hits := []searchHit{}
+	total := 0 // matches on every page, counted before LIMIT
+	for rows.Next() {
+		var h searchHit
+		if err := rows.Scan(&h.Type, &h.ID, &h.Title, &h.Subtitle, &h.Score, &h.ImageURL, &h.Price, &total); err != nil {
+			continue
+		}
+		hits = append(hits, h)
+	}
+	hasNext := len(hits) > limit
+	if hasNext {
+		hits = hits[:limit]
+	}
+	response := gin.H{
+		"query":    q,
+		"results":  hits,
+		"page":     page,
+		"limit":    limit,
+		"total":    total,
+		"has_next": hasNext,
+	}
+	if page == 1 {
+		var filters map[string]string
+		if len(types) > 0 {
+			filters = map[string]string{"type": strings.Join(types, ",")}
+		}
+		response["search_id"] = logSearchQuery(c, searchLogEntry{Source: "search", Query: q, Filters: filters, ResultCount: total, Rewrite: rewrite})
+	}
+	if rewrite.Rewritten {
+		response["searched_query"] = rewrite.Query
+	}
+	if rewrite.Redirect != nil {
+		response["redirect"] = rewrite.Redirect
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminReindexSearch handles POST /api/v1/admin/search/reindex
+// Rebuilds the whole search index and its popularity scores.
+func AdminReindexSearch(c *gin.Context) {
+	indexer := services.NewSearchIndexer()
+	count, err := indexer.Reindex()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rebuild search index"})
+		return
+	}
+	if err := indexer.RefreshPopularity(); err != nil {
+		fmt.Printf("⚠️ %v\n", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Search index rebuilt", "documents": count})
+}
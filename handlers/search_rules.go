@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// searchRedirectTables are the tables a redirect rule can point into, by target type
+var searchRedirectTables = map[string]string{
+	"category":          "categories",
+	"brand":             "brands",
+	"product":           "product_models",
+	"melhaf_collection": "melhaf_collections",
+	"perfume":           "maison_adrar_perfumes",
+}
+
+type searchRuleRequest struct {
+	Kind        string  `json:"kind" binding:"required"` // synonym, redirect
+	Term        string  `json:"term" binding:"required"`
+	Replacement *string `json:"replacement"`
+	TargetType  *string `json:"target_type"`
+	Target      *string `json:"target"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+// validate checks the rule and clears the fields that do not apply to its kind
+func (req *searchRuleRequest) validate() string {
+	if services.SearchTermKey(req.Term) == "" {
+		return "term must contain a word"
+	}
+	switch req.Kind {
+	case "synonym":
+		if req.Replacement == nil || services.SearchTermKey(*req.Replacement) == "" {
+			return "replacement is required for a synonym"
+		}
+		if services.SearchTermKey(*req.Replacement) == services.SearchTermKey(req.Term) {
+			return "replacement must differ from the term"
+		}
+		req.TargetType, req.Target = nil, nil
+	case "redirect":
+		if req.TargetType == nil || req.Target == nil || strings.TrimSpace(*req.Target) == "" {
+			return "target_type and target are required for a redirect"
+		}
+		target := strings.TrimSpace(*req.Target)
+		req.Target = &target
+		if *req.TargetType == "url" {
+			if !strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "https://") && !strings.HasPrefix(target, "http://") {
+				return "target must be a path or an http(s) URL"
+			}
+		} else {
+			if _, ok := searchRedirectTables[*req.TargetType]; !ok {
+				return "target_type must be category, brand, product, melhaf_collection, perfume or url"
+			}
+			if _, err := uuid.Parse(target); err != nil {
+				return "target must be the id of the " + *req.TargetType
+			}
+		}
+		req.Replacement = nil
+	default:
+		return "kind must be synonym or redirect"
+	}
+	return ""
+}
+
+// checkTarget answers 400 when a redirect points at a missing item, or 500 when it
+// cannot be checked, and reports whether the rule can be saved
+func (req *searchRuleRequest) checkTarget(c *gin.Context) bool {
+	if req.Kind != "redirect" || *req.TargetType == "url" {
+		return true
+	}
+	var exists bool
+	err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM `+searchRedirectTables[*req.TargetType]+` WHERE id = $1)`, *req.Target).Scan(&exists)
+	if err != nil {
+		fmt.Printf("❌ Failed to check search redirect target: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check redirect target"})
+		return false
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target " + *req.TargetType + " not found"})
+		return false
+	}
+	return true
+}
+
+const searchRuleColumns = `id, kind, term, normalized_term, replacement, target_type, target, is_active, created_by, created_at, updated_at`
+
+func scanSearchRule(row interface{ Scan(...interface{}) error }) (*models.SearchRule, error) {
+	var r models.SearchRule
+	if err := row.Scan(&r.ID, &r.Kind, &r.Term, &r.NormalizedTerm, &r.Replacement, &r.TargetType, &r.Target,
+		&r.IsActive, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// AdminListSearchRules handles GET /api/v1/admin/search/rules?kind=synonym
+func AdminListSearchRules(c *gin.Context) {
+	rows, err := DB.Query(`SELECT `+searchRuleColumns+` FROM search_rules
+		WHERE ($1 = '' OR kind = $1)
+		ORDER BY kind, normalized_term`, c.Query("kind"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch search rules"})
+		return
+	}
+	defer rows.Close()
+
+	rules := []models.SearchRule{}
+	for rows.Next() {
+		if r, err := scanSearchRule(rows); err == nil {
+			rules = append(rules, *r)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// AdminCreateSearchRule handles POST /api/v1/admin/search/rules
+func AdminCreateSearchRule(c *gin.Context) {
+	var req searchRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !req.checkTarget(c) {
+		return
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	now := time.Now()
+	r, err := scanSearchRule(DB.QueryRow(`
+		INSERT INTO search_rules (id, kind, term, normalized_term, replacement, target_type, target, is_active, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		RETURNING `+searchRuleColumns,
+		uuid.New(), req.Kind, strings.TrimSpace(req.Term), services.SearchTermKey(req.Term), req.Replacement,
+		req.TargetType, req.Target, isActive, nullableString(c.GetString("user_id")), now))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + req.Kind + " already exists for this term"})
+			return
+		}
+		fmt.Printf("❌ Failed to create search rule: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create search rule"})
+		return
+	}
+	services.InvalidateSearchRules()
+	c.JSON(http.StatusCreated, gin.H{"rule": r})
+}
+
+// AdminUpdateSearchRule handles PUT /api/v1/admin/search/rules/:id
+func AdminUpdateSearchRule(c *gin.Context) {
+	var req searchRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if !req.checkTarget(c) {
+		return
+	}
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	r, err := scanSearchRule(DB.QueryRow(`
+		UPDATE search_rules
+		SET kind = $2, term = $3, normalized_term = $4, replacement = $5, target_type = $6, target = $7,
+		    is_active = $8, updated_at = now()
+		WHERE id::text = $1
+		RETURNING `+searchRuleColumns,
+		c.Param("id"), req.Kind, strings.TrimSpace(req.Term), services.SearchTermKey(req.Term), req.Replacement,
+		req.TargetType, req.Target, isActive))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Search rule not found"})
+		return
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + req.Kind + " already exists for this term"})
+			return
+		}
+		fmt.Printf("❌ Failed to update search rule: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update search rule"})
+		return
+	}
+	services.InvalidateSearchRules()
+	c.JSON(http.StatusOK, gin.H{"rule": r})
+}
+
+// AdminDeleteSearchRule handles DELETE /api/v1/admin/search/rules/:id
+func AdminDeleteSearchRule(c *gin.Context) {
+	res, err := DB.Exec(`DELETE FROM search_rules WHERE id::text = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete search rule"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Search rule not found"})
+		return
+	}
+	services.InvalidateSearchRules()
+	c.JSON(http.StatusOK, gin.H{"message": "Search rule deleted"})
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchSuggest handles GET /api/v1/search/suggest?q=...&limit=...
+// Search-as-you-type: product, brand, category, Melhaf collection and perfume names
+// plus popular past queries, answered from memory.
+func SearchSuggest(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	limit := 10
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 20 {
+		limit = l
+	}
+	if q == "" {
+		c.JSON(http.StatusOK, gin.H{"query": q, "suggestions": []services.Suggestion{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"query": q, "suggestions": services.Suggest(q, limit)})
+}
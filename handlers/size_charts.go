@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"fmbq-server/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sizeChartView is a size chart with its decoded chart data. Source tells how the
+// chart was picked for a product: sku, product, brand_category, brand or category.
+type sizeChartView struct {
+	ID          uuid.UUID            `json:"id"`
+	Name        string               `json:"name"`
+	Description *string              `json:"description"`
+	BrandID     *uuid.UUID           `json:"brand_id"`
+	CategoryID  *uuid.UUID           `json:"category_id"`
+	IsDefault   bool                 `json:"is_default"`
+	Unit        string               `json:"unit"`
+	Chart       models.SizeChartData `json:"chart"`
+	Source      string               `json:"source,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+const sizeChartColumns = `id, name, description, brand_id, category_id, is_default, unit, chart_json, created_at, COALESCE(updated_at, created_at)`
+
+func scanSizeChart(row interface{ Scan(...interface{}) error }) (*sizeChartView, error) {
+	var chart sizeChartView
+	var chartJSON []byte
+	if err := row.Scan(&chart.ID, &chart.Name, &chart.Description, &chart.BrandID, &chart.CategoryID,
+		&chart.IsDefault, &chart.Unit, &chartJSON, &chart.CreatedAt, &chart.UpdatedAt); err != nil {
+		return nil, err
+	}
+	// Charts created before the structured format may hold anything; they decode to an empty chart
+	json.Unmarshal(chartJSON, &chart.Chart)
+	if chart.Chart.Sizes == nil {
+		chart.Chart.Sizes = []models.SizeChartRow{}
+	}
+	return &chart, nil
+}
+
+func loadSizeChart(id string) (*sizeChartView, error) {
+	return scanSizeChart(DB.QueryRow(`SELECT `+sizeChartColumns+` FROM size_charts WHERE id::text = $1`, id))
+}
+
+// resolveSizeChart picks the chart for a product: the SKU's own chart, then the
+// product's, then the most specific brand/category default (categories include
+// ancestors of the product's categories). It returns nil when nothing applies.
+func resolveSizeChart(productID, skuID string) (*sizeChartView, error) {
+	var chartID, source string
+	err := DB.QueryRow(`
+		WITH RECURSIVE product_category_tree AS (
+			SELECT c.id, c.parent_id FROM categories c
+			JOIN product_model_categories pmc ON pmc.category_id = c.id
+			WHERE pmc.product_model_id::text = $1
+			UNION
+			SELECT p.id, p.parent_id FROM categories p
+			JOIN product_category_tree t ON p.id = t.parent_id
+		)
+		SELECT chart_id::text, source FROM (
+			SELECT s.size_chart_id AS chart_id, 'sku' AS source, 1 AS rank, now() AS created_at
+			FROM skus s
+			WHERE s.id::text = $2 AND s.product_model_id::text = $1 AND s.size_chart_id IS NOT NULL
+			UNION ALL
+			SELECT pm.size_chart_id, 'product', 2, now()
+			FROM product_models pm
+			WHERE pm.id::text = $1 AND pm.size_chart_id IS NOT NULL
+			UNION ALL
+			SELECT sc.id,
+			       CASE WHEN sc.brand_id IS NOT NULL AND sc.category_id IS NOT NULL THEN 'brand_category'
+			            WHEN sc.brand_id IS NOT NULL THEN 'brand' ELSE 'category' END,
+			       CASE WHEN sc.brand_id IS NOT NULL AND sc.category_id IS NOT NULL THEN 3
+			            WHEN sc.brand_id IS NOT NULL THEN 4 ELSE 5 END,
+			       sc.created_at
+			FROM size_charts sc
+			JOIN product_models pm ON pm.id::text = $1
+			WHERE sc.is_default
+			  AND (sc.brand_id IS NOT NULL OR sc.category_id IS NOT NULL)
+			  AND (sc.brand_id IS NULL OR sc.brand_id = pm.brand_id)
+			  AND (sc.category_id IS NULL OR sc.category_id IN (SELECT id FROM product_category_tree))
+		) candidates
+		ORDER BY rank, created_at DESC
+		LIMIT 1`, productID, skuID).Scan(&chartID, &source)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	chart, err := loadSizeChart(chartID)
+	if err != nil {
+		return nil, err
+	}
+	chart.Source = source
+	return chart, nil
+}
+
+// validateSizeChartData checks sizes are unique and measurement ranges are sane
+func validateSizeChartData(data models.SizeChartData) error {
+	if len(data.Sizes) == 0 {
+		return fmt.Errorf("a size chart needs at least one size")
+	}
+	known := map[string]bool{}
+	for _, m := range data.Measurements {
+		known[m] = true
+	}
+	seen := map[string]bool{}
+	for _, row := range data.Sizes {
+		key := strings.ToLower(strings.TrimSpace(row.Size))
+		if key == "" {
+			return fmt.Errorf("every row needs a size")
+		}
+		if seen[key] {
+			return fmt.Errorf("size %s is listed twice", row.Size)
+		}
+		seen[key] = true
+		for name, r := range row.Measurements {
+			if !known[name] {
+				return fmt.Errorf("size %s uses measurement %q, which is not in the measurements list", row.Size, name)
+			}
+			if r.Min < 0 || r.Max < r.Min {
+				return fmt.Errorf("size %s has an invalid %s range", row.Size, name)
+			}
+		}
+	}
+	return nil
+}
+
+type sizeChartRequest struct {
+	Name        string               `json:"name" binding:"required"`
+	Description *string              `json:"description"`
+	BrandID     *uuid.UUID           `json:"brand_id"`
+	CategoryID  *uuid.UUID           `json:"category_id"`
+	IsDefault   bool                 `json:"is_default"`
+	Unit        string               `json:"unit" binding:"omitempty,oneof=cm in"`
+	Chart       models.SizeChartData `json:"chart" binding:"required"`
+}
+
+func bindSizeChartRequest(c *gin.Context) (*sizeChartRequest, []byte, bool) {
+	var req sizeChartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	if err := validateSizeChartData(req.Chart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	if req.IsDefault && req.BrandID == nil && req.CategoryID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A default chart needs a brand_id or category_id"})
+		return nil, nil, false
+	}
+	if req.Unit == "" {
+		req.Unit = "cm"
+	}
+	chartJSON, _ := json.Marshal(req.Chart)
+	return &req, chartJSON, true
+}
+
+// AdminListSizeCharts handles GET /api/v1/admin/size-charts
+// Optional filters: brand_id, category_id
+func AdminListSizeCharts(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT `+sizeChartColumns+`
+		FROM size_charts
+		WHERE ($1 = '' OR brand_id::text = $1)
+		  AND ($2 = '' OR category_id::text = $2)
+		ORDER BY name`, c.Query("brand_id"), c.Query("category_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch size charts"})
+		return
+	}
+	defer rows.Close()
+
+	charts := []*sizeChartView{}
+	for rows.Next() {
+		chart, err := scanSizeChart(rows)
+		if err != nil {
+			continue
+		}
+		charts = append(charts, chart)
+	}
+	c.JSON(http.StatusOK, gin.H{"size_charts": charts})
+}
+
+// AdminGetSizeChart handles GET /api/v1/admin/size-charts/:id
+func AdminGetSizeChart(c *gin.Context) {
+	chart, err := loadSizeChart(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Size chart not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch size chart"})
+		return
+	}
+
+	var products, skus int
+	DB.QueryRow(`SELECT COUNT(*) FROM product_models WHERE size_chart_id = $1`, chart.ID).Scan(&products)
+	DB.QueryRow(`SELECT COUNT(*) FROM skus WHERE size_chart_id = $1`, chart.ID).Scan(&skus)
+	c.JSON(http.StatusOK, gin.H{"size_chart": chart, "assigned_products": products, "assigned_skus": skus})
+}
+
+// AdminCreateSizeChart handles POST /api/v1/admin/size-charts
+func AdminCreateSizeChart(c *gin.Context) {
+	req, chartJSON, ok := bindSizeChartRequest(c)
+	if !ok {
+		return
+	}
+
+	chart, err := scanSizeChart(DB.QueryRow(`
+		INSERT INTO size_charts (id, name, description, brand_id, category_id, is_default, unit, chart_json, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, now(), now())
+		RETURNING `+sizeChartColumns,
+		req.Name, req.Description, req.BrandID, req.CategoryID, req.IsDefault, req.Unit, string(chartJSON)))
+	if err != nil {
+		fmt.Printf("❌ Failed to create size chart: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create size chart"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"size_chart": chart})
+}
+
+// AdminUpdateSizeChart handles PUT /api/v1/admin/size-charts/:id
+func AdminUpdateSizeChart(c *gin.Context) {
+	req, chartJSON, ok := bindSizeChartRequest(c)
+	if !ok {
+		return
+	}
+
+	chart, err := scanSizeChart(DB.QueryRow(`
+		UPDATE size_charts
+		SET name = $2, description = $3, brand_id = $4, category_id = $5, is_default = $6,
+		    unit = $7, chart_json = $8, updated_at = now()
+		WHERE id::text = $1
+		RETURNING `+sizeChartColumns,
+		c.Param("id"), req.Name, req.Description, req.BrandID, req.CategoryID, req.IsDefault, req.Unit, string(chartJSON)))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Size chart not found"})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to update size chart: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update size chart"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"size_chart": chart})
+}
+
+// AdminDeleteSizeChart handles DELETE /api/v1/admin/size-charts/:id
+// Products and SKUs using the chart fall back to their brand/category default.
+func AdminDeleteSizeChart(c *gin.Context) {
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE skus SET size_chart_id = NULL WHERE size_chart_id::text = $1`, c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign size chart"})
+		return
+	}
+	res, err := tx.Exec(`DELETE FROM size_charts WHERE id::text = $1`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete size chart"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Size chart not found"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete size chart"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Size chart deleted"})
+}
+
+// AdminAssignSizeChart handles PUT /api/v1/admin/products/:id/size-chart
+// Body: {"size_chart_id": "..." | null, "sku_ids": [...]}. Without sku_ids the chart is
+// set on the product model; with sku_ids only those SKUs get it. null clears it.
+func AdminAssignSizeChart(c *gin.Context) {
+	productID := c.Param("id")
+	var req struct {
+		SizeChartID *uuid.UUID  `json:"size_chart_id"`
+		SKUIDs      []uuid.UUID `json:"sku_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.SizeChartID != nil {
+		var exists bool
+		DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM size_charts WHERE id = $1)`, *req.SizeChartID).Scan(&exists)
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Size chart not found"})
+			return
+		}
+	}
+
+	var res sql.Result
+	var err error
+	if len(req.SKUIDs) == 0 {
+		res, err = DB.Exec(`UPDATE product_models SET size_chart_id = $2, updated_at = now() WHERE id::text = $1`, productID, req.SizeChartID)
+	} else {
+		ids := make([]string, len(req.SKUIDs))
+		for i, id := range req.SKUIDs {
+			ids[i] = id.String()
+		}
+		res, err = DB.Exec(`
+			UPDATE skus SET size_chart_id = $2
+			WHERE product_model_id::text = $1 AND id::text = ANY(string_to_array($3, ','))`,
+			productID, req.SizeChartID, strings.Join(ids, ","))
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to assign size chart: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign size chart"})
+		return
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product or SKUs not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Size chart assigned", "updated": n})
+}
+
+// GetProductSizeChart handles GET /api/v1/products/:id/size-chart?sku_id=
+func GetProductSizeChart(c *gin.Context) {
+	chart, err := resolveSizeChart(c.Param("id"), c.Query("sku_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch size chart"})
+		return
+	}
+	if chart == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No size chart for this product"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"size_chart": chart})
+}
+
+// sizeRowMatches reports whether a size label (as printed on a SKU or an old order)
+// names this chart row in any of its size systems
+func sizeRowMatches(row models.SizeChartRow, label string) bool {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" {
+		return false
+	}
+	for _, name := range []string{row.Size, row.EU, row.US, row.UK, row.FR} {
+		if name != "" && strings.ToLower(name) == label {
+			return true
+		}
+	}
+	return false
+}
+
+// RecommendSize handles POST /api/v1/products/:id/size-recommendation
+// Body: {"measurements": {"chest": 94, "waist": 78}, "unit": "cm", "sku_id": "..."}.
+// Without measurements, a signed-in customer gets a suggestion from the sizes they
+// kept (delivered, not returned) in earlier orders, favouring the same brand.
+func RecommendSize(c *gin.Context) {
+	productID := c.Param("id")
+	var req struct {
+		Measurements map[string]float64 `json:"measurements"`
+		Unit         string             `json:"unit" binding:"omitempty,oneof=cm in"`
+		SKUID        string             `json:"sku_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chart, err := resolveSizeChart(productID, req.SKUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch size chart"})
+		return
+	}
+	if chart == nil || len(chart.Chart.Sizes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No size chart for this product"})
+		return
+	}
+
+	var best int
+	var method, fit string
+	var confidence float64
+	if len(req.Measurements) > 0 {
+		factor := 1.0
+		if req.Unit != "" && req.Unit != chart.Unit {
+			factor = 2.54 // in -> cm
+			if req.Unit == "cm" {
+				factor = 1 / 2.54
+			}
+		}
+		best, fit, confidence = recommendByMeasurements(chart.Chart, req.Measurements, factor)
+		if best < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "None of the measurements are used by this size chart", "measurements": chart.Chart.Measurements})
+			return
+		}
+		method = "measurements"
+	} else {
+		userID := c.GetString("user_id")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send measurements or sign in to use your order history"})
+			return
+		}
+		best, confidence, err = recommendByHistory(chart.Chart, userID, productID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read order history"})
+			return
+		}
+		if best < 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No earlier purchases to base a recommendation on; send measurements instead"})
+			return
+		}
+		method, fit = "purchase_history", "as_previously_kept"
+	}
+
+	row := chart.Chart.Sizes[best]
+	resp := gin.H{
+		"recommended_size": row.Size,
+		"size":             row,
+		"method":           method,
+		"fit":              fit,
+		"confidence":       confidence,
+		"size_chart_id":    chart.ID,
+		"available":        false,
+	}
+
+	// Point at the matching SKU so the app can preselect it
+	rows, err := DB.Query(`
+		SELECT s.id, COALESCE(s.size, ''), COALESCE(i.available, 0)
+		FROM skus s
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		WHERE s.product_model_id::text = $1
+		ORDER BY COALESCE(i.available, 0) DESC`, productID)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var skuID, size string
+			var available int
+			if err := rows.Scan(&skuID, &size, &available); err != nil || !sizeRowMatches(row, size) {
+				continue
+			}
+			resp["sku_id"] = skuID
+			resp["available"] = available > 0
+			break
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// recommendByMeasurements scores each size by how far the body measurements fall
+// outside its ranges (relative to the range width) and returns the best row index.
+// Ties go to the larger size. factor converts the customer's unit to the chart's.
+func recommendByMeasurements(chart models.SizeChartData, measurements map[string]float64, factor float64) (int, string, float64) {
+	best, bestScore, bestUsed := -1, 0.0, 0
+	fit := ""
+	for i, row := range chart.Sizes {
+		score, used := 0.0, 0
+		tight, loose := false, false
+		for name, value := range measurements {
+			r, ok := row.Measurements[name]
+			if !ok {
+				continue
+			}
+			used++
+			value *= factor
+			width := r.Max - r.Min
+			if width <= 0 {
+				width = 1
+			}
+			switch {
+			case value > r.Max:
+				score += (value - r.Max) / width
+				tight = true
+			case value < r.Min:
+				score += (r.Min - value) / width
+				loose = true
+			}
+		}
+		if used == 0 {
+			continue
+		}
+		if best < 0 || used > bestUsed || (used == bestUsed && score <= bestScore) {
+			best, bestScore, bestUsed = i, score, used
+			switch {
+			case score == 0:
+				fit = "true_to_size"
+			case tight && !loose:
+				fit = "snug"
+			case loose && !tight:
+				fit = "loose"
+			default:
+				fit = "between_sizes"
+			}
+		}
+	}
+	confidence := 1 / (1 + bestScore)
+	return best, fit, confidence
+}
+
+// recommendByHistory maps the sizes a customer kept onto the chart, counting
+// same-brand purchases double, and returns the most common row
+func recommendByHistory(chart models.SizeChartData, userID, productID string) (int, float64, error) {
+	rows, err := DB.Query(`
+		SELECT oi.size, pm.brand_id IS NOT DISTINCT FROM cur.brand_id, SUM(oi.quantity)
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		JOIN product_models pm ON pm.id = oi.product_id
+		JOIN product_models cur ON cur.id::text = $2
+		WHERE o.user_id::text = $1 AND o.status = 'delivered' AND COALESCE(oi.size, '') <> ''
+		GROUP BY oi.size, 2`, userID, productID)
+	if err != nil {
+		return -1, 0, err
+	}
+	defer rows.Close()
+
+	weights := make([]float64, len(chart.Sizes))
+	total := 0.0
+	for rows.Next() {
+		var size string
+		var sameBrand bool
+		var quantity float64
+		if err := rows.Scan(&size, &sameBrand, &quantity); err != nil {
+			continue
+		}
+		weight := quantity
+		if sameBrand {
+			weight *= 2
+		}
+		for i, row := range chart.Sizes {
+			if sizeRowMatches(row, size) {
+				weights[i] += weight
+				total += weight
+				break
+			}
+		}
+	}
+
+	best := -1
+	for i, w := range weights {
+		if w > 0 && (best < 0 || w > weights[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return -1, 0, nil
+	}
+	return best, weights[best] / total, nil
+}
+
+// skuHasSize reports whether size names the SKU's own size or, when the SKU has a
+// size chart, the same chart row in another size system (e.g. "40" for an "M")
+func skuHasSize(skuID, size string) (bool, error) {
+	var productID string
+	var skuSize sql.NullString
+	err := DB.QueryRow(`SELECT product_model_id::text, size FROM skus WHERE id::text = $1`, skuID).Scan(&productID, &skuSize)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(strings.TrimSpace(skuSize.String), strings.TrimSpace(size)) {
+		return true, nil
+	}
+
+	chart, err := resolveSizeChart(productID, skuID)
+	if err != nil || chart == nil {
+		return false, err
+	}
+	for _, row := range chart.Chart.Sizes {
+		if sizeRowMatches(row, size) && sizeRowMatches(row, skuSize.String) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fmbq-server/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// sqlHandle is satisfied by both the DB handle and a transaction
+type sqlHandle interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Category names that tell how bare numeric sizes of a product should be read
+var (
+	shoeCategoryPatterns = []string{"%chaussure%", "%shoe%", "%sandal%", "%basket%", "%sneaker%", "%botte%", "%boot%", "%escarpin%", "%mule%", "%حذاء%", "%أحذية%"}
+	kidsCategoryPatterns = []string{"%enfant%", "%kid%", "%bébé%", "%bebe%", "%baby%", "%junior%", "%أطفال%"}
+)
+
+type sizeProductInfo struct {
+	brandID string
+	hint    string
+}
+
+// sizeNormalizer resolves canonical sizes for SKUs, applying size aliases (brand
+// specific first, then catalog-wide) and the scale hint of the product's categories
+type sizeNormalizer struct {
+	q        sqlHandle
+	aliases  map[string]string // brand_id|alias -> canonical; brand_id is empty for catalog-wide aliases
+	products map[string]sizeProductInfo
+}
+
+func newSizeNormalizer(q sqlHandle) (*sizeNormalizer, error) {
+	n := &sizeNormalizer{q: q, aliases: map[string]string{}, products: map[string]sizeProductInfo{}}
+	rows, err := q.Query(`SELECT COALESCE(brand_id::text, ''), alias, canonical FROM size_aliases`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var brandID, alias, canonical string
+		if err := rows.Scan(&brandID, &alias, &canonical); err != nil {
+			return nil, err
+		}
+		n.aliases[brandID+"|"+strings.ToLower(strings.TrimSpace(alias))] = canonical
+	}
+	return n, rows.Err()
+}
+
+func (n *sizeNormalizer) product(productID string) sizeProductInfo {
+	if info, ok := n.products[productID]; ok {
+		return info
+	}
+	var info sizeProductInfo
+	var shoe, kids bool
+	n.q.QueryRow(`
+		SELECT COALESCE(pm.brand_id::text, ''),
+		       EXISTS(SELECT 1 FROM product_model_categories pmc JOIN categories c ON c.id = pmc.category_id
+		              WHERE pmc.product_model_id = pm.id AND (c.name ILIKE ANY($2::text[]) OR c.slug ILIKE ANY($2::text[]))),
+		       EXISTS(SELECT 1 FROM product_model_categories pmc JOIN categories c ON c.id = pmc.category_id
+		              WHERE pmc.product_model_id = pm.id AND (c.name ILIKE ANY($3::text[]) OR c.slug ILIKE ANY($3::text[])))
+		FROM product_models pm WHERE pm.id::text = $1`,
+		productID, pgTextArray(shoeCategoryPatterns), pgTextArray(kidsCategoryPatterns)).Scan(&info.brandID, &shoe, &kids)
+	switch {
+	case shoe:
+		info.hint = utils.SizeScaleShoe
+	case kids:
+		info.hint = utils.SizeScaleKids
+	}
+	n.products[productID] = info
+	return info
+}
+
+// canonical returns the canonical size of a size label on the given product
+func (n *sizeNormalizer) canonical(productID, size string) utils.CanonicalSize {
+	info := n.product(productID)
+	key := strings.ToLower(strings.TrimSpace(size))
+	canonical, ok := n.aliases[info.brandID+"|"+key]
+	if !ok {
+		canonical, ok = n.aliases["|"+key]
+	}
+	if ok {
+		// Aliases hold a canonical code, which means the same size on every product
+		if code, isCode := utils.ParseSizeCode(canonical); isCode {
+			return code
+		}
+		size = canonical
+	}
+	return utils.NormalizeSize(size, info.hint)
+}
+
+// refreshSKUSizes recomputes the canonical size columns of a product's SKUs. Write
+// paths call it after creating or updating SKUs so search and POS filters see them.
+func refreshSKUSizes(q sqlHandle, productID string) error {
+	n, err := newSizeNormalizer(q)
+	if err != nil {
+		return err
+	}
+	rows, err := q.Query(`SELECT id::text, COALESCE(size, '') FROM skus WHERE product_model_id::text = $1`, productID)
+	if err != nil {
+		return err
+	}
+	type skuSize struct{ id, size string }
+	var skus []skuSize
+	for rows.Next() {
+		var s skuSize
+		if err := rows.Scan(&s.id, &s.size); err != nil {
+			rows.Close()
+			return err
+		}
+		skus = append(skus, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range skus {
+		if err := n.apply(s.id, n.canonical(productID, s.size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *sizeNormalizer) apply(skuID string, size utils.CanonicalSize) error {
+	_, err := n.q.Exec(`
+		UPDATE skus SET size_normalized = NULLIF($2, ''), size_scale = $3, size_rank = $4, size_group = NULLIF($5, '')
+		WHERE id::text = $1`, skuID, size.Code, size.Scale, size.Rank, size.Group)
+	return err
+}
+
+// pgTextArray formats strings as a Postgres text[] literal
+func pgTextArray(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}"
+}
+
+// sizeFilterSQL builds a condition matching SKUs (table alias given) of any of the
+// comma separated sizes, across brands and scales: "M" also matches EU38 and
+// "Medium". It returns the condition and its two arguments, numbered from argIndex.
+func sizeFilterSQL(alias, sizes string, argIndex int) (string, []interface{}) {
+	var codes, groups []string
+	for _, size := range strings.Split(sizes, ",") {
+		c, g := utils.SizeFilterMatch(size)
+		codes = append(codes, c...)
+		groups = append(groups, g...)
+	}
+	cond := fmt.Sprintf("(%s.size_normalized = ANY($%d::text[]) OR %s.size_group = ANY($%d::text[]))", alias, argIndex, alias, argIndex+1)
+	return cond, []interface{}{pgTextArray(codes), pgTextArray(groups)}
+}
+
+// sizeBackfillStatus tracks the background job recomputing canonical sizes
+type sizeBackfillStatus struct {
+	Running    bool       `json:"running"`
+	BrandID    string     `json:"brand_id,omitempty"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Changed    int        `json:"changed"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+var (
+	sizeBackfillMu    sync.Mutex
+	sizeBackfillState sizeBackfillStatus
+)
+
+// startSizeBackfill starts recomputing canonical sizes for every SKU, or the SKUs of
+// one brand. It returns false when a backfill is already running.
+func startSizeBackfill(brandID string) bool {
+	sizeBackfillMu.Lock()
+	defer sizeBackfillMu.Unlock()
+	if sizeBackfillState.Running {
+		return false
+	}
+	now := time.Now()
+	sizeBackfillState = sizeBackfillStatus{Running: true, BrandID: brandID, StartedAt: &now}
+	go runSizeBackfill(brandID)
+	return true
+}
+
+func runSizeBackfill(brandID string) {
+	fmt.Printf("📏 Size backfill started (brand: %q)\n", brandID)
+	finish := func(err error) {
+		sizeBackfillMu.Lock()
+		defer sizeBackfillMu.Unlock()
+		now := time.Now()
+		sizeBackfillState.Running = false
+		sizeBackfillState.FinishedAt = &now
+		if err != nil {
+			sizeBackfillState.Error = err.Error()
+			fmt.Printf("❌ Size backfill failed: %v\n", err)
+			return
+		}
+		fmt.Printf("✅ Size backfill done: %d SKUs, %d changed, %d failed\n",
+			sizeBackfillState.Processed, sizeBackfillState.Changed, sizeBackfillState.Failed)
+	}
+
+	n, err := newSizeNormalizer(DB)
+	if err != nil {
+		finish(err)
+		return
+	}
+	rows, err := DB.Query(`
+		SELECT s.id::text, s.product_model_id::text, COALESCE(s.size, ''),
+		       COALESCE(s.size_normalized, ''), COALESCE(s.size_scale, ''), COALESCE(s.size_rank, 0), COALESCE(s.size_group, '')
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		WHERE $1 = '' OR pm.brand_id::text = $1
+		ORDER BY s.product_model_id`, brandID)
+	if err != nil {
+		finish(err)
+		return
+	}
+	type skuRow struct {
+		id, productID, size string
+		current             utils.CanonicalSize
+	}
+	var skus []skuRow
+	for rows.Next() {
+		var r skuRow
+		if err := rows.Scan(&r.id, &r.productID, &r.size, &r.current.Code, &r.current.Scale, &r.current.Rank, &r.current.Group); err == nil {
+			skus = append(skus, r)
+		}
+	}
+	rows.Close()
+
+	sizeBackfillMu.Lock()
+	sizeBackfillState.Total = len(skus)
+	sizeBackfillMu.Unlock()
+
+	for _, r := range skus {
+		size := n.canonical(r.productID, r.size)
+		changed, failed := 0, 0
+		if size != r.current {
+			if err := n.apply(r.id, size); err != nil {
+				failed = 1
+			} else {
+				changed = 1
+			}
+		}
+		sizeBackfillMu.Lock()
+		sizeBackfillState.Processed++
+		sizeBackfillState.Changed += changed
+		sizeBackfillState.Failed += failed
+		sizeBackfillMu.Unlock()
+	}
+	finish(nil)
+}
+
+// AdminStartSizeBackfill handles POST /api/v1/admin/sizes/backfill?brand_id=
+func AdminStartSizeBackfill(c *gin.Context) {
+	if !startSizeBackfill(c.Query("brand_id")) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A size backfill is already running"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Size backfill started"})
+}
+
+// AdminGetSizeBackfill handles GET /api/v1/admin/sizes/backfill
+func AdminGetSizeBackfill(c *gin.Context) {
+	sizeBackfillMu.Lock()
+	status := sizeBackfillState
+	sizeBackfillMu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+// GetSizeScales handles GET /api/v1/sizes/scales
+func GetSizeScales(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"scales": utils.SizeScales()})
+}
+
+// AdminNormalizeSize handles GET /api/v1/admin/sizes/normalize?size=&product_id=
+// Shows how a label would be stored, including aliases and category hints of the product.
+func AdminNormalizeSize(c *gin.Context) {
+	size := c.Query("size")
+	if strings.TrimSpace(size) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size is required"})
+		return
+	}
+	n, err := newSizeNormalizer(DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load size aliases"})
+		return
+	}
+	codes, groups := utils.SizeFilterMatch(size)
+	c.JSON(http.StatusOK, gin.H{
+		"size":          size,
+		"canonical":     n.canonical(c.Query("product_id"), size),
+		"filter_codes":  codes,
+		"filter_groups": groups,
+	})
+}
+
+// AdminListSizeAliases handles GET /api/v1/admin/sizes/aliases?brand_id=
+func AdminListSizeAliases(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT sa.id, sa.brand_id, b.name, sa.alias, sa.canonical, sa.created_at
+		FROM size_aliases sa
+		LEFT JOIN brands b ON b.id = sa.brand_id
+		WHERE $1 = '' OR sa.brand_id::text = $1
+		ORDER BY b.name NULLS FIRST, lower(sa.alias)`, c.Query("brand_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch size aliases"})
+		return
+	}
+	defer rows.Close()
+
+	aliases := []gin.H{}
+	for rows.Next() {
+		var id uuid.UUID
+		var brandID *uuid.UUID
+		var brandName sql.NullString
+		var alias, canonical string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &brandID, &brandName, &alias, &canonical, &createdAt); err != nil {
+			continue
+		}
+		aliases = append(aliases, gin.H{
+			"id":         id,
+			"brand_id":   brandID,
+			"brand_name": brandName.String,
+			"alias":      alias,
+			"canonical":  canonical,
+			"created_at": createdAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"aliases": aliases})
+}
+
+// AdminCreateSizeAlias handles POST /api/v1/admin/sizes/aliases
+// Body: {"brand_id": "..." (optional), "alias": "2", "canonical": "M"}. The affected
+// SKUs are re-normalized in the background.
+func AdminCreateSizeAlias(c *gin.Context) {
+	var req struct {
+		BrandID   *uuid.UUID `json:"brand_id"`
+		Alias     string     `json:"alias" binding:"required"`
+		Canonical string     `json:"canonical" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Store the validated code so every product reads the alias as the same size
+	canonical, ok := utils.ParseSizeCode(req.Canonical)
+	if !ok {
+		canonical = utils.NormalizeSize(req.Canonical, "")
+	}
+	if canonical.Scale == utils.SizeScaleOther {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "canonical must be a known size such as M, EU40, SHOE42 or 4 ans"})
+		return
+	}
+
+	var id uuid.UUID
+	err := DB.QueryRow(`
+		INSERT INTO size_aliases (id, brand_id, alias, canonical, created_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, now())
+		RETURNING id`, req.BrandID, strings.TrimSpace(req.Alias), canonical.Code).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "This alias already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create size alias"})
+		return
+	}
+
+	brandID := ""
+	if req.BrandID != nil {
+		brandID = req.BrandID.String()
+	}
+	started := startSizeBackfill(brandID)
+	c.JSON(http.StatusCreated, gin.H{"id": id, "canonical": canonical, "backfill_started": started})
+}
+
+// AdminDeleteSizeAlias handles DELETE /api/v1/admin/sizes/aliases/:id
+func AdminDeleteSizeAlias(c *gin.Context) {
+	var brandID sql.NullString
+	err := DB.QueryRow(`DELETE FROM size_aliases WHERE id::text = $1 RETURNING brand_id::text`, c.Param("id")).Scan(&brandID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Size alias not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete size alias"})
+		return
+	}
+	started := startSizeBackfill(brandID.String)
+	c.JSON(http.StatusOK, gin.H{"message": "Size alias deleted", "backfill_started": started})
+}
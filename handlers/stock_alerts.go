@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminListStockAlerts handles GET /api/v1/admin/inventory/alerts
+// Lists unresolved alerts. Optional filters: alert_type, item_type, status
+func AdminListStockAlerts(c *gin.Context) {
+	alerts, err := services.NewStockAlertService().UnresolvedAlerts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock alerts"})
+		return
+	}
+
+	alertType, itemType, status := c.Query("alert_type"), c.Query("item_type"), c.Query("status")
+	out := []services.StockAlertItem{}
+	for _, a := range alerts {
+		if (alertType != "" && a.AlertType != alertType) ||
+			(itemType != "" && a.ItemType != itemType) ||
+			(status != "" && a.Status != status) {
+			continue
+		}
+		out = append(out, a)
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": out})
+}
+
+// AdminStockAlertDigest handles GET /api/v1/admin/inventory/alerts/digest
+// Returns the daily digest as it would be sent right now
+func AdminStockAlertDigest(c *gin.Context) {
+	alerts, err := services.NewStockAlertService().UnresolvedAlerts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock alerts"})
+		return
+	}
+	subject, body := services.BuildStockDigest(alerts, c.Query("out_of_stock_only") == "true")
+	c.JSON(http.StatusOK, gin.H{"subject": subject, "body": body, "alerts": len(alerts)})
+}
+
+// AdminAcknowledgeStockAlert handles POST /api/v1/admin/inventory/alerts/:id/acknowledge
+// An acknowledged alert is not re-sent unless the item goes from low to out of stock.
+func AdminAcknowledgeStockAlert(c *gin.Context) {
+	res, err := DB.Exec(`
+		UPDATE stock_alerts
+		SET status = 'acknowledged', notify_pending = FALSE, acknowledged_by = $1, acknowledged_at = now(), updated_at = now()
+		WHERE id::text = $2 AND status = 'open'`, c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge stock alert"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Open stock alert not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": "acknowledged"})
+}
+
+// AdminSnoozeStockAlert handles POST /api/v1/admin/inventory/alerts/:id/snooze
+// Body: {"hours": 24} or {"until": "2024-01-01T08:00:00Z"}. Notifications for the
+// item are held until the snooze ends.
+func AdminSnoozeStockAlert(c *gin.Context) {
+	var req struct {
+		Hours int        `json:"hours"`
+		Until *time.Time `json:"until"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	until := time.Now().Add(24 * time.Hour)
+	if req.Until != nil {
+		until = *req.Until
+	} else if req.Hours > 0 {
+		until = time.Now().Add(time.Duration(req.Hours) * time.Hour)
+	}
+	if !until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Snooze must end in the future"})
+		return
+	}
+
+	res, err := DB.Exec(`
+		UPDATE stock_alerts SET snoozed_until = $1, updated_at = now()
+		WHERE id::text = $2 AND status <> 'resolved'`, until, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snooze stock alert"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock alert not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "snoozed_until": until})
+}
+
+// AdminGetStockAlertSubscription handles GET /api/v1/admin/inventory/alerts/subscription
+func AdminGetStockAlertSubscription(c *gin.Context) {
+	var push, email, sms, instant, digest, outOfStockOnly bool
+	err := DB.QueryRow(`
+		SELECT push, email, sms, instant, daily_digest, out_of_stock_only
+		FROM stock_alert_subscriptions WHERE user_id = $1`, c.GetString("user_id")).
+		Scan(&push, &email, &sms, &instant, &digest, &outOfStockOnly)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, gin.H{"subscribed": false})
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch stock alert subscription: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock alert subscription"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"subscribed":        true,
+		"push":              push,
+		"email":             email,
+		"sms":               sms,
+		"instant":           instant,
+		"daily_digest":      digest,
+		"out_of_stock_only": outOfStockOnly,
+	})
+}
+
+// AdminUpdateStockAlertSubscription handles PUT /api/v1/admin/inventory/alerts/subscription
+func AdminUpdateStockAlertSubscription(c *gin.Context) {
+	var req struct {
+		Push           bool `json:"push"`
+		Email          bool `json:"email"`
+		SMS            bool `json:"sms"`
+		Instant        bool `json:"instant"`
+		DailyDigest    bool `json:"daily_digest"`
+		OutOfStockOnly bool `json:"out_of_stock_only"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := DB.Exec(`
+		INSERT INTO stock_alert_subscriptions (id, user_id, push, email, sms, instant, daily_digest, out_of_stock_only, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, now(), now())
+		ON CONFLICT (user_id) DO UPDATE SET
+			push = EXCLUDED.push, email = EXCLUDED.email, sms = EXCLUDED.sms,
+			instant = EXCLUDED.instant, daily_digest = EXCLUDED.daily_digest,
+			out_of_stock_only = EXCLUDED.out_of_stock_only, updated_at = now()`,
+		c.GetString("user_id"), req.Push, req.Email, req.SMS, req.Instant, req.DailyDigest, req.OutOfStockOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save stock alert subscription"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminDeleteStockAlertSubscription handles DELETE /api/v1/admin/inventory/alerts/subscription
+func AdminDeleteStockAlertSubscription(c *gin.Context) {
+	if _, err := DB.Exec(`DELETE FROM stock_alert_subscriptions WHERE user_id = $1`, c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove stock alert subscription"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// AdminRunStockAlertCheck handles POST /api/v1/admin/inventory/alerts/check
+// Runs the detection job immediately instead of waiting for the next tick
+func AdminRunStockAlertCheck(c *gin.Context) {
+	if err := services.NewStockAlertService().CheckStockLevels(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stock alert check failed", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,553 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// stockTakeScopeSQL returns the WHERE clause used to freeze the SKUs of a session scope.
+// $2 is always the scope value.
+var stockTakeScopeSQL = map[string]string{
+	"location": `i.location = $2`,
+	"brand":    `pm.brand_id::text = $2`,
+	"category": `s.product_model_id IN (
+		WITH RECURSIVE scope_categories AS (
+			SELECT id FROM categories WHERE id::text = $2
+			UNION ALL
+			SELECT ch.id FROM categories ch JOIN scope_categories sc ON ch.parent_id = sc.id
+		)
+		SELECT pmc.product_model_id FROM product_model_categories pmc
+		WHERE pmc.category_id IN (SELECT id FROM scope_categories)
+	)`,
+}
+
+// AdminCreateStockTake handles POST /api/v1/admin/inventory/stock-takes
+// Opens a session and freezes the expected quantity of every SKU in scope.
+func AdminCreateStockTake(c *gin.Context) {
+	var req struct {
+		Name              string `json:"name" binding:"required"`
+		ScopeType         string `json:"scope_type" binding:"required,oneof=location category brand"`
+		ScopeValue        string `json:"scope_value" binding:"required"`
+		ApprovalThreshold *int   `json:"approval_threshold"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	threshold := 5
+	if req.ApprovalThreshold != nil {
+		if *req.ApprovalThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "approval_threshold must be >= 0"})
+			return
+		}
+		threshold = *req.ApprovalThreshold
+	}
+
+	userID := c.GetString("user_id")
+
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	sessionID := uuid.New()
+	_, err = tx.Exec(`
+		INSERT INTO stock_take_sessions (id, name, scope_type, scope_value, status, approval_threshold, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 'open', $5, $6, now(), now())
+	`, sessionID, req.Name, req.ScopeType, req.ScopeValue, threshold, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock-take session"})
+		return
+	}
+
+	freeze := `
+		INSERT INTO stock_take_lines (id, session_id, sku_id, expected_quantity, counted_quantity, in_scope, updated_at)
+		SELECT gen_random_uuid(), $1, s.id, COALESCE(i.available, 0), 0, TRUE, now()
+		FROM skus s
+		JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		WHERE ` + stockTakeScopeSQL[req.ScopeType]
+	res, err := tx.Exec(freeze, sessionID, req.ScopeValue)
+	if err != nil {
+		fmt.Printf("❌ Failed to freeze stock-take lines: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to freeze expected quantities"})
+		return
+	}
+	frozen, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit stock-take session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":            true,
+		"id":                 sessionID,
+		"status":             "open",
+		"approval_threshold": threshold,
+		"frozen_skus":        frozen,
+	})
+}
+
+// AdminListStockTakes handles GET /api/v1/admin/inventory/stock-takes
+func AdminListStockTakes(c *gin.Context) {
+	rows, err := DB.Query(`
+		SELECT st.id, st.name, st.scope_type, st.scope_value, st.status, st.approval_threshold, st.created_at,
+		       COUNT(l.id), COUNT(l.id) FILTER (WHERE l.counted_quantity <> l.expected_quantity)
+		FROM stock_take_sessions st
+		LEFT JOIN stock_take_lines l ON l.session_id = st.id
+		WHERE ($1 = '' OR st.status = $1)
+		GROUP BY st.id
+		ORDER BY st.created_at DESC
+		LIMIT 200`, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock-take sessions"})
+		return
+	}
+	defer rows.Close()
+
+	sessions := []gin.H{}
+	for rows.Next() {
+		var id, name, scopeType, scopeValue, status string
+		var threshold, lines, varianceLines int
+		var createdAt time.Time
+		if err := rows.Scan(&id, &name, &scopeType, &scopeValue, &status, &threshold, &createdAt, &lines, &varianceLines); err != nil {
+			continue
+		}
+		sessions = append(sessions, gin.H{
+			"id":                 id,
+			"name":               name,
+			"scope_type":         scopeType,
+			"scope_value":        scopeValue,
+			"status":             status,
+			"approval_threshold": threshold,
+			"lines":              lines,
+			"variance_lines":     varianceLines,
+			"created_at":         createdAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// AdminGetStockTake handles GET /api/v1/admin/inventory/stock-takes/:id
+func AdminGetStockTake(c *gin.Context) {
+	session, err := loadStockTakeSession(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock-take session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock-take session"})
+		return
+	}
+
+	var scans, devices int
+	DB.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT device_id) FROM stock_take_scans WHERE session_id = $1`, session.ID).Scan(&scans, &devices)
+
+	c.JSON(http.StatusOK, gin.H{"session": session, "scans": scans, "devices": devices})
+}
+
+// AdminRecordStockTakeScans handles POST /api/v1/admin/inventory/stock-takes/:id/scans
+// Accepts a single scan ({ean, quantity, device_id}) or a batch ({scans: [...]})
+// so several devices can stream counts into the same session.
+func AdminRecordStockTakeScans(c *gin.Context) {
+	type scanInput struct {
+		EAN      string  `json:"ean"`
+		Quantity int     `json:"quantity"`
+		DeviceID *string `json:"device_id"`
+	}
+	var req struct {
+		scanInput
+		Scans []scanInput `json:"scans"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	scans := req.Scans
+	if req.EAN != "" {
+		scans = append(scans, req.scanInput)
+	}
+	if len(scans) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No scans provided"})
+		return
+	}
+
+	session, err := loadStockTakeSession(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock-take session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock-take session"})
+		return
+	}
+	if session.Status != "open" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session is not open for counting", "status": session.Status})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	var recorded []gin.H
+	var unknown []string
+	for _, scan := range scans {
+		ean := strings.TrimSpace(scan.EAN)
+		if scan.Quantity == 0 {
+			scan.Quantity = 1
+		}
+
+		sku, err := lookupSKUByEAN(ean)
+		if err == sql.ErrNoRows {
+			unknown = append(unknown, ean)
+			continue
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO stock_take_scans (id, session_id, sku_id, ean, quantity, device_id, scanned_by, created_at)
+			VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NULLIF($6, '')::uuid, now())
+		`, session.ID, sku.SKUID, ean, scan.Quantity, scan.DeviceID, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record scan"})
+			return
+		}
+
+		// SKUs outside the frozen scope are kept for the report but never adjusted
+		var counted int
+		var inScope bool
+		err = tx.QueryRow(`
+			INSERT INTO stock_take_lines (id, session_id, sku_id, expected_quantity, counted_quantity, in_scope, updated_at)
+			VALUES (gen_random_uuid(), $1, $2, 0, GREATEST($3, 0), FALSE, now())
+			ON CONFLICT (session_id, sku_id) DO UPDATE
+			SET counted_quantity = GREATEST(stock_take_lines.counted_quantity + $3, 0), updated_at = now()
+			RETURNING counted_quantity, in_scope
+		`, session.ID, sku.SKUID, scan.Quantity).Scan(&counted, &inScope)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update count"})
+			return
+		}
+
+		recorded = append(recorded, gin.H{
+			"ean":        ean,
+			"sku_id":     sku.SKUID,
+			"sku_code":   sku.SKUCode,
+			"title":      sku.ProductTitle,
+			"size":       sku.Size,
+			"color_name": sku.ColorName,
+			"counted":    counted,
+			"in_scope":   inScope,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit scans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "recorded": recorded, "unknown_eans": unknown})
+}
+
+// AdminCompleteStockTake handles POST /api/v1/admin/inventory/stock-takes/:id/complete
+// Stops counting; the variance report is final from this point.
+func AdminCompleteStockTake(c *gin.Context) {
+	res, err := DB.Exec(`UPDATE stock_take_sessions SET status = 'counted', updated_at = now() WHERE id::text = $1 AND status = 'open'`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete stock-take session"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session not found or not open"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": "counted"})
+}
+
+// AdminCancelStockTake handles POST /api/v1/admin/inventory/stock-takes/:id/cancel
+func AdminCancelStockTake(c *gin.Context) {
+	res, err := DB.Exec(`UPDATE stock_take_sessions SET status = 'cancelled', updated_at = now() WHERE id::text = $1 AND status IN ('open', 'counted', 'approved')`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock-take session"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session not found or already closed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": "cancelled"})
+}
+
+// AdminStockTakeVariance handles GET /api/v1/admin/inventory/stock-takes/:id/variance
+// Optional filter: only=variances to hide lines that match.
+func AdminStockTakeVariance(c *gin.Context) {
+	session, err := loadStockTakeSession(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock-take session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock-take session"})
+		return
+	}
+
+	rows, err := DB.Query(`
+		SELECT l.sku_id, s.sku_code, COALESCE(s.ean, ''), COALESCE(pm.title, ''), COALESCE(pc.color_name, ''), COALESCE(s.size, ''),
+		       l.expected_quantity, l.counted_quantity, l.in_scope,
+		       COALESCE(p.sale_price, p.list_price, 0)
+		FROM stock_take_lines l
+		JOIN skus s ON s.id = l.sku_id
+		LEFT JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		WHERE l.session_id = $1
+		ORDER BY ABS(l.counted_quantity - l.expected_quantity) DESC, pm.title, s.size`, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build variance report"})
+		return
+	}
+	defer rows.Close()
+
+	onlyVariances := c.Query("only") == "variances"
+	lines := []gin.H{}
+	var totalLines, varianceLines, approvalLines, unitsShort, unitsOver int
+	var valueVariance float64
+	for rows.Next() {
+		var skuID, skuCode, ean, title, color, size string
+		var expected, counted int
+		var inScope bool
+		var price float64
+		if err := rows.Scan(&skuID, &skuCode, &ean, &title, &color, &size, &expected, &counted, &inScope, &price); err != nil {
+			continue
+		}
+		variance := counted - expected
+		requiresApproval := inScope && stockTakeNeedsApproval(variance, session.ApprovalThreshold)
+
+		totalLines++
+		if variance != 0 {
+			varianceLines++
+			if variance < 0 {
+				unitsShort -= variance
+			} else {
+				unitsOver += variance
+			}
+			if inScope {
+				valueVariance += float64(variance) * price
+			}
+		}
+		if requiresApproval {
+			approvalLines++
+		}
+		if onlyVariances && variance == 0 {
+			continue
+		}
+		lines = append(lines, gin.H{
+			"sku_id":            skuID,
+			"sku_code":          skuCode,
+			"ean":               ean,
+			"title":             strings.TrimSpace(title),
+			"color":             color,
+			"size":              size,
+			"expected":          expected,
+			"counted":           counted,
+			"variance":          variance,
+			"variance_value":    float64(variance) * price,
+			"in_scope":          inScope,
+			"requires_approval": requiresApproval,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session": session,
+		"summary": gin.H{
+			"lines":             totalLines,
+			"variance_lines":    varianceLines,
+			"approval_required": approvalLines > 0 && session.Status != "approved",
+			"approval_lines":    approvalLines,
+			"units_short":       unitsShort,
+			"units_over":        unitsOver,
+			"value_variance":    valueVariance,
+		},
+		"lines": lines,
+	})
+}
+
+// AdminApproveStockTake handles POST /api/v1/admin/inventory/stock-takes/:id/approve
+// Manager (admin) sign-off required before large variances can be applied. Only a
+// session whose count is finished can be approved.
+func AdminApproveStockTake(c *gin.Context) {
+	userID := c.GetString("user_id")
+	res, err := DB.Exec(`
+		UPDATE stock_take_sessions
+		SET status = 'approved', approved_by = $1, approved_at = now(), updated_at = now()
+		WHERE id::text = $2 AND status = 'counted'
+	`, userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve stock-take session"})
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session not found or not counted yet"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": "approved"})
+}
+
+// AdminApplyStockTake handles POST /api/v1/admin/inventory/stock-takes/:id/apply
+// Posts one adjustment movement per in-scope SKU whose count differs from the
+// frozen expectation. The variance is applied as a delta so sales made during
+// the count are preserved. Only a counted or approved session can be applied.
+func AdminApplyStockTake(c *gin.Context) {
+	session, err := loadStockTakeSession(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock-take session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock-take session"})
+		return
+	}
+	if session.Status == "applied" || session.Status == "cancelled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session is already closed", "status": session.Status})
+		return
+	}
+	if session.Status != "counted" && session.Status != "approved" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session is not counted yet", "status": session.Status})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	tx, err := DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the session so two devices cannot apply it twice
+	var status string
+	if err := tx.QueryRow(`SELECT status FROM stock_take_sessions WHERE id = $1 AND status IN ('counted', 'approved') FOR UPDATE`, session.ID).Scan(&status); err != nil || status != session.Status {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stock-take session changed, retry"})
+		return
+	}
+
+	rows, err := tx.Query(`
+		SELECT sku_id, counted_quantity - expected_quantity
+		FROM stock_take_lines
+		WHERE session_id = $1 AND in_scope = TRUE AND counted_quantity <> expected_quantity`, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load variances"})
+		return
+	}
+	type adjustment struct {
+		SKUID    uuid.UUID
+		Variance int
+	}
+	var adjustments []adjustment
+	needsApproval := false
+	for rows.Next() {
+		var a adjustment
+		if err := rows.Scan(&a.SKUID, &a.Variance); err != nil {
+			continue
+		}
+		if stockTakeNeedsApproval(a.Variance, session.ApprovalThreshold) {
+			needsApproval = true
+		}
+		adjustments = append(adjustments, a)
+	}
+	rows.Close()
+
+	if needsApproval && session.Status != "approved" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Manager approval required for large variances"})
+		return
+	}
+
+	applied := 0
+	for _, a := range adjustments {
+		var before int
+		err := tx.QueryRow(`SELECT available FROM inventory WHERE sku_id = $1 FOR UPDATE`, a.SKUID).Scan(&before)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec(`INSERT INTO inventory (sku_id, available, reserved, updated_at) VALUES ($1, 0, 0, now())`, a.SKUID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read inventory", "sku_id": a.SKUID})
+			return
+		}
+		after := before + a.Variance
+		if after < 0 {
+			after = 0
+		}
+		if _, err := tx.Exec(`UPDATE inventory SET available = $1, updated_at = now() WHERE sku_id = $2`, after, a.SKUID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory", "sku_id": a.SKUID})
+			return
+		}
+		if err := recordInventoryMovement(tx, "sku", a.SKUID, before, after, "stock_take", "stock_take_session", &session.ID, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post adjustment movement", "sku_id": a.SKUID})
+			return
+		}
+		applied++
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE stock_take_sessions
+		SET status = 'applied', applied_by = NULLIF($1, '')::uuid, applied_at = now(), updated_at = now()
+		WHERE id = $2`, userID, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close stock-take session"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit adjustments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": "applied", "adjustments": applied})
+}
+
+// stockTakeSession is the subset of a session the handlers need
+type stockTakeSession struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	ScopeType         string    `json:"scope_type"`
+	ScopeValue        string    `json:"scope_value"`
+	Status            string    `json:"status"`
+	ApprovalThreshold int       `json:"approval_threshold"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+func loadStockTakeSession(id string) (*stockTakeSession, error) {
+	var s stockTakeSession
+	err := DB.QueryRow(`
+		SELECT id, name, scope_type, scope_value, status, approval_threshold, created_at
+		FROM stock_take_sessions WHERE id::text = $1`, id).Scan(
+		&s.ID, &s.Name, &s.ScopeType, &s.ScopeValue, &s.Status, &s.ApprovalThreshold, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// stockTakeNeedsApproval reports whether a variance is large enough to need a manager
+func stockTakeNeedsApproval(variance, threshold int) bool {
+	if variance < 0 {
+		variance = -variance
+	}
+	return variance > 0 && variance >= threshold
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"fmbq-server/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// risingMinSpike is how many times its usual pace a product must be getting
+	// attention at to be rising
+	risingMinSpike = 2.0
+	// risingMinVelocity keeps a handful of views on a quiet product from counting as
+	// a spike
+	risingMinVelocity = 3.0
+)
+
+// trendingProduct is a product card in a trending list
+type trendingProduct struct {
+	ID            string  `json:"id"`
+	Title         string  `json:"title"`
+	BrandName     string  `json:"brand_name"`
+	ImageURL      string  `json:"image_url"`
+	Price         float64 `json:"price"`
+	OriginalPrice float64 `json:"original_price"`
+	InStock       bool    `json:"in_stock"`
+	Score         float64 `json:"score"`
+	Spike         float64 `json:"spike"` // recent pace over usual pace
+}
+
+// trendingProducts answers a trending list: by score, or for rising ones by how
+// far recent activity outruns the usual pace. ?category_id= (a category or its
+// subcategories) and ?brand_id= narrow it down.
+func trendingProducts(c *gin.Context, rising bool) {
+	var categoryID, brandID interface{}
+	if id := c.Query("category_id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+		categoryID = id
+	}
+	if id := c.Query("brand_id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid brand ID"})
+			return
+		}
+		brandID = id
+	}
+
+	// Spike compares velocity, put on the score's scale, with the score; rising
+	// products are ranked by how far velocity is ahead
+	args := []interface{}{categoryID, brandID, recommendationLimit(c, 20), services.TrendingVelocityScale}
+	order, rankFilter := "t.score DESC", ""
+	if rising {
+		order = "t.velocity * $4 - t.score DESC"
+		rankFilter = "AND t.velocity >= $5 AND t.velocity * $4 >= t.score * $6"
+		args = append(args, risingMinVelocity, risingMinSpike)
+	}
+
+	rows, err := DB.Query(`
+		SELECT pm.id, pm.title, COALESCE(b.name, ''), COALESCE(pi.url, ''),
+		       COALESCE(pr.price, 0), COALESCE(pr.original_price, 0), COALESCE(st.available, 0) > 0,
+		       ROUND(t.score, 2), ROUND(t.velocity * $4 / NULLIF(t.score, 0), 2)
+		FROM product_trending_scores t
+		JOIN product_models pm ON pm.id = t.product_id AND pm.is_active = true
+		LEFT JOIN brands b ON b.id = pm.brand_id
+		LEFT JOIN LATERAL (
+			SELECT url FROM product_images
+			WHERE product_model_id = pm.id
+			ORDER BY position, created_at
+			LIMIT 1
+		) pi ON true
+		LEFT JOIN LATERAL (
+			SELECT MIN(p.effective_price) AS price, MAX(p.list_price) AS original_price
+			FROM skus s JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+			WHERE s.product_model_id = pm.id
+		) pr ON true
+		LEFT JOIN LATERAL (
+			SELECT SUM(i.available) AS available
+			FROM skus s JOIN inventory i ON i.sku_id = s.id
+			WHERE s.product_model_id = pm.id
+		) st ON true
+		WHERE ($1::uuid IS NULL OR EXISTS (
+			SELECT 1 FROM product_model_categories pmc
+			JOIN categories c ON c.id = pmc.category_id
+			WHERE pmc.product_model_id = pm.id AND (c.id = $1::uuid OR c.parent_id = $1::uuid)
+		))
+		AND ($2::uuid IS NULL OR pm.brand_id = $2::uuid)
+		`+rankFilter+`
+		ORDER BY `+order+`, pm.created_at DESC
+		LIMIT $3`, args...)
+	if err != nil {
+		fmt.Printf("❌ Failed to fetch trending products: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trending products"})
+		return
+	}
+	defer rows.Close()
+
+	products := []trendingProduct{}
+	for rows.Next() {
+		var p trendingProduct
+		var spike *float64
+		if err := rows.Scan(&p.ID, &p.Title, &p.BrandName, &p.ImageURL, &p.Price, &p.OriginalPrice,
+			&p.InStock, &p.Score, &spike); err != nil {
+			continue
+		}
+		if spike != nil {
+			p.Spike = *spike
+		}
+		products = append(products, p)
+	}
+	c.JSON(http.StatusOK, gin.H{"products": products})
+}
+
+// GetTrendingProducts handles GET /api/v1/products/trending
+// Products drawing the most views, cart and wishlist adds and purchases lately,
+// recent activity weighing most.
+func GetTrendingProducts(c *gin.Context) {
+	trendingProducts(c, false)
+}
+
+// GetRisingProducts handles GET /api/v1/products/trending/rising
+// Products whose activity suddenly picked up compared with their usual pace.
+func GetRisingProducts(c *gin.Context) {
+	trendingProducts(c, true)
+}
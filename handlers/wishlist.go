@@ -290,11 +290,11 @@ func AddToWishlist(c *gin.Context) {
 				''
 			) as image_url,
 			COALESCE(
-				(SELECT p.sale_price FROM prices p 
+				(SELECT p.sale_price FROM active_prices p 
 				 JOIN skus s ON p.sku_id = s.id 
 				 WHERE s.product_model_id = pm.id AND p.currency = 'MRO' 
 				 ORDER BY p.sale_price ASC LIMIT 1),
-				(SELECT p.list_price FROM prices p 
+				(SELECT p.list_price FROM active_prices p 
 				 JOIN skus s ON p.sku_id = s.id 
 				 WHERE s.product_model_id = pm.id AND p.currency = 'MRO' 
 				 ORDER BY p.list_price ASC LIMIT 1),
@@ -219,17 +219,30 @@ func main() {
 			products.GET("/:id", handlers.GetProduct)
 			products.GET("/:id/similar", handlers.GetSimilarProducts)
 			products.GET("/:id/suggestions", handlers.GetProductSuggestions)
+			products.GET("/:id/bought-together", handlers.GetFrequentlyBoughtTogether)
+			products.GET("/:id/also-viewed", handlers.GetAlsoViewedProducts)
+			products.GET("/:id/size-chart", handlers.GetProductSizeChart)
+			products.POST("/:id/size-recommendation", handlers.OptionalAuthMiddleware(), handlers.RecommendSize)
 			products.GET("/search", handlers.SearchProductByCode)
 			products.GET("/brand/:brandId", handlers.GetProductsByBrand)
 			products.POST("/", handlers.CreateProduct)
 			products.PUT("/:id", handlers.UpdateProduct)
 			products.DELETE("/:id", handlers.DeleteProduct)
 			
-			// Product view tracking routes
-			products.POST("/:id/view", handlers.RegisterProductView)
-			products.GET("/most-viewed", handlers.GetMostViewedProducts)
-			products.GET("/most-viewed/category/:categoryId", handlers.GetMostViewedProductsByCategory)
+			// Product view tracking routes; signed-in customers' views shape their ranking
+			products.POST("/:id/view", handlers.OptionalAuthMiddleware(), handlers.RegisterProductView)
+			products.GET("/most-viewed", handlers.OptionalAuthMiddleware(), handlers.GetMostViewedProducts)
+			products.GET("/most-viewed/category/:categoryId", handlers.OptionalAuthMiddleware(), handlers.GetMostViewedProductsByCategory)
 			products.GET("/recently-viewed", handlers.AuthMiddleware(), handlers.GetUserRecentlyViewedProducts)
+			products.GET("/trending", handlers.GetTrendingProducts)
+			products.GET("/trending/rising", handlers.GetRisingProducts)
+
+			// Reviews: approved ones are public; customers review what they bought
+			products.GET("/:id/reviews", handlers.GetProductReviews)
+			products.POST("/:id/reviews", handlers.AuthMiddleware(), handlers.CreateProductReview)
+			products.GET("/:id/reviews/mine", handlers.AuthMiddleware(), handlers.GetMyProductReview)
+			products.PUT("/:id/reviews/mine", handlers.AuthMiddleware(), handlers.UpdateMyProductReview)
+			products.DELETE("/:id/reviews/mine", handlers.AuthMiddleware(), handlers.DeleteMyProductReview)
 		}
 
 		// Category routes
@@ -242,13 +255,27 @@ func main() {
 			categories.DELETE("/:id", handlers.DeleteCategory)
 		}
 
-		// Public catalog routes (no auth)
+		// Public catalog routes (no auth; product search is personalized for signed-in customers)
 		api.GET("/public/categories", handlers.PublicTopCategories)
 		api.GET("/banners", handlers.GetBanners)
 		api.GET("/backgrounds", handlers.GetBackgrounds)
-		api.GET("/public/products", handlers.SearchProducts)
-		api.GET("/public/products/enhanced", handlers.EnhancedSearchProducts)
+		api.GET("/public/products", handlers.OptionalAuthMiddleware(), handlers.SearchProducts)
+		api.GET("/public/products/enhanced", handlers.OptionalAuthMiddleware(), handlers.EnhancedSearchProducts)
 		api.GET("/public/category-hierarchy", handlers.GetCategoryHierarchy)
+		api.GET("/sizes/scales", handlers.GetSizeScales)
+		api.GET("/colors/families", handlers.GetColorFamilies)
+		api.GET("/search", handlers.GlobalSearch)
+		api.GET("/search/suggest", handlers.SearchSuggest)
+		api.POST("/search/click", handlers.TrackSearchClick)
+		api.POST("/reviews/photos", handlers.AuthMiddleware(), handlers.UploadReviewPhoto)
+
+		// Questions & answers on products, Melhaf collections and perfumes
+		api.GET("/questions", handlers.GetItemQuestions)
+		api.POST("/questions", handlers.AuthMiddleware(), handlers.AskQuestion)
+		api.GET("/questions/mine", handlers.AuthMiddleware(), handlers.GetMyQuestions)
+		api.POST("/questions/:id/answers", handlers.AuthMiddleware(), handlers.AnswerQuestion)
+		api.GET("/bundles", handlers.GetBundles)
+		api.GET("/bundles/:id", handlers.GetBundle)
 
 		// Brand routes
 		brands := api.Group("/brands")
@@ -309,6 +336,7 @@ func main() {
 			cart.DELETE("/remove/:id", handlers.RemoveFromCart)
 			cart.DELETE("/clear", handlers.ClearCart)
 			cart.POST("/validate", handlers.ValidateCartItems)
+			cart.GET("/cross-sell", handlers.GetCartCrossSell)
 		}
 
 		// Order routes (protected)
@@ -340,6 +368,15 @@ func main() {
 			wishlist.DELETE("/clear", handlers.ClearWishlist)
 		}
 
+		// Back-in-stock "notify me" subscriptions (protected)
+		backInStock := api.Group("/back-in-stock")
+		backInStock.Use(handlers.AuthMiddleware())
+		{
+			backInStock.GET("/", handlers.GetBackInStockSubscriptions)
+			backInStock.POST("/", handlers.SubscribeBackInStock)
+			backInStock.DELETE("/:id", handlers.CancelBackInStockSubscription)
+		}
+
 		// Promotional codes routes
 		promo := api.Group("/promotional-codes")
 		{
@@ -383,11 +420,89 @@ func main() {
 			admin.POST("/products", handlers.CreateProduct)
 			admin.PUT("/products/:id", handlers.UpdateProduct)
 			admin.DELETE("/products/:id", handlers.DeleteProduct)
+			admin.GET("/products/:id/lifecycle", handlers.AdminGetProductLifecycle)
+			admin.POST("/products/:id/lifecycle", handlers.AdminTransitionProduct)
+			admin.POST("/products/:id/preview-token", handlers.AdminCreatePreviewToken)
+			admin.DELETE("/products/:id/preview-token", handlers.AdminRevokePreviewToken)
 			admin.POST("/upload", handlers.UploadImage)
 			
 		// Barcode management
 		admin.POST("/barcode/scan", handlers.ScanBarcode)
 		admin.GET("/barcode/generate/:ean", handlers.GenerateBarcodeImage)
+		admin.POST("/barcodes/validate", handlers.AdminValidateBarcode)
+		admin.POST("/barcodes/allocate", handlers.AdminAllocateBarcode)
+		admin.POST("/barcodes/reissue", handlers.AdminReissueInvalidBarcodes)
+		admin.GET("/barcode/scans/stats", handlers.AdminBarcodeScanStats)
+
+		// Catalog import/export
+		admin.POST("/catalog/import", handlers.AdminImportCatalog)
+		admin.GET("/catalog/import/jobs", handlers.AdminListCatalogImports)
+		admin.GET("/catalog/import/jobs/:id", handlers.AdminGetCatalogImport)
+		admin.GET("/catalog/export", handlers.AdminExportCatalog)
+
+		// Size charts
+		admin.GET("/size-charts", handlers.AdminListSizeCharts)
+		admin.POST("/size-charts", handlers.AdminCreateSizeChart)
+		admin.GET("/size-charts/:id", handlers.AdminGetSizeChart)
+		admin.PUT("/size-charts/:id", handlers.AdminUpdateSizeChart)
+		admin.DELETE("/size-charts/:id", handlers.AdminDeleteSizeChart)
+		admin.PUT("/products/:id/size-chart", handlers.AdminAssignSizeChart)
+
+		// Canonical sizes
+		admin.GET("/sizes/normalize", handlers.AdminNormalizeSize)
+		admin.GET("/sizes/aliases", handlers.AdminListSizeAliases)
+		admin.POST("/sizes/aliases", handlers.AdminCreateSizeAlias)
+		admin.DELETE("/sizes/aliases/:id", handlers.AdminDeleteSizeAlias)
+		admin.POST("/sizes/backfill", handlers.AdminStartSizeBackfill)
+		admin.GET("/sizes/backfill", handlers.AdminGetSizeBackfill)
+
+		// Color families
+		admin.GET("/colors", handlers.AdminListColors)
+		admin.PUT("/colors/:type/:id/family", handlers.AdminSetColorFamily)
+		admin.POST("/colors/backfill", handlers.AdminStartColorBackfill)
+		admin.GET("/colors/backfill", handlers.AdminGetColorBackfill)
+
+		// Scheduled prices, price history and sale events
+		admin.GET("/skus/:id/prices", handlers.AdminListSKUPrices)
+		admin.POST("/skus/:id/prices", handlers.AdminCreateSKUPrice)
+		admin.GET("/skus/:id/price-history", handlers.AdminGetSKUPriceHistory)
+		admin.PUT("/prices/:id", handlers.AdminUpdatePrice)
+		admin.DELETE("/prices/:id", handlers.AdminDeletePrice)
+		admin.GET("/sale-events", handlers.AdminListSaleEvents)
+		admin.GET("/sale-events/:id", handlers.AdminGetSaleEvent)
+		admin.POST("/sale-events", handlers.AdminCreateSaleEvent)
+		admin.DELETE("/sale-events/:id", handlers.AdminCancelSaleEvent)
+
+		// Bundles and kits
+		admin.GET("/bundles", handlers.AdminListBundles)
+		admin.GET("/bundles/:id", handlers.AdminGetBundle)
+		admin.POST("/bundles", handlers.AdminCreateBundle)
+		admin.PUT("/bundles/:id", handlers.AdminUpdateBundle)
+		admin.DELETE("/bundles/:id", handlers.AdminDeleteBundle)
+
+		// Search index
+		admin.POST("/search/reindex", handlers.AdminReindexSearch)
+
+		// Search analytics, synonyms and redirects
+		admin.GET("/search/reports/top-queries", handlers.AdminSearchTopQueries)
+		admin.GET("/search/reports/zero-results", handlers.AdminSearchZeroResultQueries)
+		admin.GET("/search/reports/low-ctr", handlers.AdminSearchLowCTRQueries)
+		admin.GET("/search/rules", handlers.AdminListSearchRules)
+		admin.POST("/search/rules", handlers.AdminCreateSearchRule)
+		admin.PUT("/search/rules/:id", handlers.AdminUpdateSearchRule)
+		admin.DELETE("/search/rules/:id", handlers.AdminDeleteSearchRule)
+
+		// Review moderation and merchant replies
+		admin.GET("/reviews", handlers.AdminListReviews)
+		admin.POST("/reviews/:id/moderate", handlers.AdminModerateReview)
+		admin.PUT("/reviews/:id/reply", handlers.AdminReplyToReview)
+
+		// Questions & answers moderation
+		admin.GET("/questions", handlers.AdminListQuestions)
+		admin.POST("/questions/:id/moderate", handlers.AdminModerateQuestion)
+		admin.POST("/questions/:id/answers", handlers.AdminAnswerQuestion)
+		admin.GET("/answers", handlers.AdminListAnswers)
+		admin.POST("/answers/:id/moderate", handlers.AdminModerateAnswer)
 		
 		// Public barcode scan (no auth required)
 		api.POST("/barcode/scan", handlers.ScanBarcode)
@@ -465,6 +580,10 @@ func main() {
 		admin.POST("/maison-adrar/perfumes/:id/images", handlers.AdminUploadMaisonAdrarPerfumeImage)
 		admin.GET("/maison-adrar/banners", handlers.AdminGetMaisonAdrarBanners)
 		admin.POST("/maison-adrar/banners", handlers.AdminCreateMaisonAdrarBanner)
+		
+		// Inventory valuation and margin reports
+		admin.GET("/reports/inventory-valuation", handlers.AdminInventoryValuationReport)
+		admin.GET("/reports/margins", handlers.AdminMarginReport)
 		}
 
 		// Admin POS routes (protected with admin or employee)
@@ -484,6 +603,40 @@ func main() {
 			inventory.GET("/all", handlers.AdminAllInventory)
 			inventory.PUT("/:sku_id/reorder-point", handlers.AdminSetReorderPoint)
 			inventory.PUT("/:sku_id/quantity", handlers.AdminUpdateQuantity)
+			inventory.GET("/movements", handlers.AdminListInventoryMovements)
+
+			// Stock-take / cycle-count sessions
+			inventory.GET("/stock-takes", handlers.AdminListStockTakes)
+			inventory.POST("/stock-takes", handlers.AdminCreateStockTake)
+			inventory.GET("/stock-takes/:id", handlers.AdminGetStockTake)
+			inventory.POST("/stock-takes/:id/scans", handlers.AdminRecordStockTakeScans)
+			inventory.POST("/stock-takes/:id/complete", handlers.AdminCompleteStockTake)
+			inventory.POST("/stock-takes/:id/cancel", handlers.AdminCancelStockTake)
+			inventory.GET("/stock-takes/:id/variance", handlers.AdminStockTakeVariance)
+			inventory.POST("/stock-takes/:id/approve", handlers.AdminMiddleware(), handlers.AdminApproveStockTake)
+			inventory.POST("/stock-takes/:id/apply", handlers.AdminApplyStockTake)
+
+			// Goods receipts and item costs
+			inventory.GET("/receipts", handlers.AdminListStockReceipts)
+			inventory.POST("/receipts", handlers.AdminCreateStockReceipt)
+			inventory.GET("/costs", handlers.AdminMiddleware(), handlers.AdminListItemCosts)
+
+			// Low-stock / out-of-stock alerts
+			inventory.GET("/alerts", handlers.AdminListStockAlerts)
+			inventory.GET("/alerts/digest", handlers.AdminStockAlertDigest)
+			inventory.POST("/alerts/check", handlers.AdminRunStockAlertCheck)
+			inventory.GET("/alerts/subscription", handlers.AdminGetStockAlertSubscription)
+			inventory.PUT("/alerts/subscription", handlers.AdminUpdateStockAlertSubscription)
+			inventory.DELETE("/alerts/subscription", handlers.AdminDeleteStockAlertSubscription)
+			inventory.POST("/alerts/:id/acknowledge", handlers.AdminAcknowledgeStockAlert)
+			inventory.POST("/alerts/:id/snooze", handlers.AdminSnoozeStockAlert)
+
+			// Back-in-stock demand
+			inventory.GET("/back-in-stock/demand", handlers.AdminBackInStockDemand)
+
+			// Barcode labels
+			inventory.GET("/labels/templates", handlers.GetLabelTemplates)
+			inventory.POST("/labels", handlers.GenerateLabelSheet)
 		}
 
 		// CRM routes (protected with admin or employee middleware)
@@ -517,6 +670,7 @@ func main() {
 			pos.GET("/customers", handlers.GetPOSCustomers)
 			pos.GET("/product-models/:product_model_id/variants", handlers.GetProductVariants)
 			pos.GET("/payment-methods", handlers.GetActivePaymentMethods)
+			pos.GET("/bundles", handlers.GetBundles)
 			pos.POST("/orders", handlers.CreatePOSOrder)
 		}
 	}
@@ -533,12 +687,159 @@ func main() {
 		if err := scheduler.ProcessScheduledNotifications(); err != nil {
 			log.Printf("⚠️ Error processing scheduled notifications: %v", err)
 		}
+		if err := scheduler.ProcessBackInStockNotifications(); err != nil {
+			log.Printf("⚠️ Error processing back-in-stock notifications: %v", err)
+		}
+		if err := scheduler.ProcessWishlistPriceDrops(); err != nil {
+			log.Printf("⚠️ Error processing wishlist price drops: %v", err)
+		}
+		if err := scheduler.RetryQuestionAnsweredNotifications(); err != nil {
+			log.Printf("⚠️ Error retrying question answered notifications: %v", err)
+		}
 		
 		// Process periodically
 		for range ticker.C {
 			if err := scheduler.ProcessScheduledNotifications(); err != nil {
 				log.Printf("⚠️ Error processing scheduled notifications: %v", err)
 			}
+			if err := scheduler.ProcessBackInStockNotifications(); err != nil {
+				log.Printf("⚠️ Error processing back-in-stock notifications: %v", err)
+			}
+			if err := scheduler.ProcessWishlistPriceDrops(); err != nil {
+				log.Printf("⚠️ Error processing wishlist price drops: %v", err)
+			}
+			if err := scheduler.RetryQuestionAnsweredNotifications(); err != nil {
+				log.Printf("⚠️ Error retrying question answered notifications: %v", err)
+			}
+		}
+	}()
+
+	// Start background stock alert job (low-stock / out-of-stock detection and daily digest)
+	go func() {
+		alerts := services.NewStockAlertService()
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
+		log.Println("📦 Background stock alert job started")
+
+		for {
+			if err := alerts.CheckStockLevels(); err != nil {
+				log.Printf("⚠️ Error checking stock levels: %v", err)
+			}
+			if err := alerts.SendDueDigests(); err != nil {
+				log.Printf("⚠️ Error sending stock digests: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background product scheduler (scheduled go-live and take-down)
+	go func() {
+		products := services.NewProductScheduler()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		log.Println("🗓️ Background product scheduler started")
+
+		for {
+			if err := products.ProcessSchedule(); err != nil {
+				log.Printf("⚠️ Error processing product schedule: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background search popularity job
+	go func() {
+		indexer := services.NewSearchIndexer()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		log.Println("🔎 Background search popularity job started")
+
+		for {
+			if err := indexer.RefreshPopularity(); err != nil {
+				log.Printf("⚠️ Error refreshing search popularity: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background recommendation job (co-purchases and co-views since the last run)
+	go func() {
+		recommendations := services.NewRecommendationJob()
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		log.Println("🧲 Background recommendation job started")
+
+		for {
+			if err := recommendations.Refresh(); err != nil {
+				log.Printf("⚠️ Error refreshing recommendations: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background product view flush (views are buffered by RegisterProductView)
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		log.Println("👁️ Background product view flush started")
+
+		for {
+			<-ticker.C
+			if err := services.FlushProductViews(); err != nil {
+				log.Printf("⚠️ Error flushing product views: %v", err)
+			}
+		}
+	}()
+
+	// Start background product view job (daily rollups and raw view retention)
+	go func() {
+		views := services.NewProductViewJob()
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+
+		log.Println("👁️ Background product view job started")
+
+		for {
+			if err := views.Run(); err != nil {
+				log.Printf("⚠️ Error maintaining product views: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background trending job (decays scores and adds activity since the last run)
+	go func() {
+		trending := services.NewTrendingJob()
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		log.Println("📈 Background trending job started")
+
+		for {
+			if err := trending.Refresh(); err != nil {
+				log.Printf("⚠️ Error refreshing trending scores: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+
+	// Start background suggestion index refresh (rebuilt when the catalog changes)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+
+		log.Println("🔤 Background suggestion index job started")
+
+		for {
+			if err := services.RefreshSuggestIndex(); err != nil {
+				log.Printf("⚠️ Error refreshing suggestion index: %v", err)
+			}
+			<-ticker.C
 		}
 	}()
 
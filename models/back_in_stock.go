@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackInStockSubscription is a customer's "notify me" request for a sold-out item.
+// It fires once: active -> triggered (stock went from zero to positive) -> notified.
+type BackInStockSubscription struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	UserID      uuid.UUID  `json:"user_id" db:"user_id"`
+	ItemType    string     `json:"item_type" db:"item_type"` // sku, melhaf_color, perfume_variant
+	ItemID      uuid.UUID  `json:"item_id" db:"item_id"`
+	Status      string     `json:"status" db:"status"` // active, triggered, notified, cancelled
+	TriggeredAt *time.Time `json:"triggered_at" db:"triggered_at"`
+	NotifiedAt  *time.Time `json:"notified_at" db:"notified_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (BackInStockSubscription) TableName() string {
+	return "back_in_stock_subscriptions"
+}
+
+func (BackInStockSubscription) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS back_in_stock_subscriptions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'triggered', 'notified', 'cancelled')),
+		triggered_at TIMESTAMP WITH TIME ZONE,
+		notified_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_back_in_stock_pending ON back_in_stock_subscriptions(user_id, item_type, item_id) WHERE status IN ('active', 'triggered');
+	CREATE INDEX IF NOT EXISTS idx_back_in_stock_item ON back_in_stock_subscriptions(item_type, item_id, status);`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BarcodeRegistry holds every EAN in use across skus, melhaf_colors and
+// maison_adrar_perfumes. Its primary key is the global uniqueness guarantee;
+// catalog triggers keep it in sync.
+type BarcodeRegistry struct {
+	EAN       string    `json:"ean" db:"ean"`
+	ItemType  string    `json:"item_type" db:"item_type"` // sku, melhaf_color, perfume
+	ItemID    uuid.UUID `json:"item_id" db:"item_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (BarcodeRegistry) TableName() string {
+	return "barcode_registry"
+}
+
+func (BarcodeRegistry) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS barcode_registry (
+		ean TEXT PRIMARY KEY,
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume')),
+		item_id UUID NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_barcode_registry_item ON barcode_registry(item_type, item_id);
+	CREATE SEQUENCE IF NOT EXISTS ean_item_reference_seq START 1;`
+}
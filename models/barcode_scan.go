@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BarcodeScan logs every scan sent to the scan resolver, matched or not
+type BarcodeScan struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Code      string     `json:"code" db:"code"`
+	MatchedBy *string    `json:"matched_by" db:"matched_by"` // ean, sku_code, qr; NULL when nothing matched
+	ItemType  *string    `json:"item_type" db:"item_type"`   // sku, melhaf_color, perfume, perfume_variant
+	ItemID    *uuid.UUID `json:"item_id" db:"item_id"`
+	UserID    *uuid.UUID `json:"user_id" db:"user_id"`
+	Source    string     `json:"source" db:"source"` // pos, app, admin, stock_take
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (BarcodeScan) TableName() string {
+	return "barcode_scans"
+}
+
+func (BarcodeScan) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS barcode_scans (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		code TEXT NOT NULL,
+		matched_by VARCHAR(20),
+		item_type VARCHAR(20),
+		item_id UUID,
+		user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		source VARCHAR(20) NOT NULL DEFAULT 'app',
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_barcode_scans_created ON barcode_scans(created_at);
+	CREATE INDEX IF NOT EXISTS idx_barcode_scans_item ON barcode_scans(item_type, item_id);`
+}
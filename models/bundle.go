@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bundle is a set sold as one item, such as a melhaf + perfume gift box or an
+// outfit. Its stock is whatever its components allow.
+type Bundle struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	Name            string    `json:"name" db:"name"`
+	NameAr          *string   `json:"name_ar" db:"name_ar"`
+	Description     *string   `json:"description" db:"description"`
+	ImageURL        *string   `json:"image_url" db:"image_url"`
+	PricingMode     string    `json:"pricing_mode" db:"pricing_mode"` // fixed, percent_off
+	Price           *float64  `json:"price" db:"price"`               // fixed mode
+	DiscountPercent *float64  `json:"discount_percent" db:"discount_percent"`
+	Currency        string    `json:"currency" db:"currency"`
+	IsActive        bool      `json:"is_active" db:"is_active"`
+	SortOrder       int       `json:"sort_order" db:"sort_order"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (Bundle) TableName() string {
+	return "bundles"
+}
+
+func (Bundle) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS bundles (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		name_ar TEXT,
+		description TEXT,
+		image_url TEXT,
+		pricing_mode VARCHAR(20) NOT NULL DEFAULT 'fixed' CHECK (pricing_mode IN ('fixed', 'percent_off')),
+		price NUMERIC(12,2),
+		discount_percent NUMERIC(5,2) CHECK (discount_percent IS NULL OR (discount_percent > 0 AND discount_percent < 100)),
+		currency CHAR(3) NOT NULL DEFAULT 'MRO',
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);`
+}
+
+// BundleComponent is one sellable item in a bundle, quantity units per bundle
+type BundleComponent struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	BundleID  uuid.UUID `json:"bundle_id" db:"bundle_id"`
+	ItemType  string    `json:"item_type" db:"item_type"` // sku, melhaf_color, perfume_variant
+	ItemID    uuid.UUID `json:"item_id" db:"item_id"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	SortOrder int       `json:"sort_order" db:"sort_order"`
+}
+
+func (BundleComponent) TableName() string {
+	return "bundle_components"
+}
+
+func (BundleComponent) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS bundle_components (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (bundle_id, item_type, item_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_bundle_components_item ON bundle_components(item_type, item_id);`
+}
+
+// OrderItemComponent is what a bundle order line took from stock, kept for
+// picking and costing even if the bundle is later changed
+type OrderItemComponent struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	OrderItemID uuid.UUID `json:"order_item_id" db:"order_item_id"`
+	ItemType    string    `json:"item_type" db:"item_type"`
+	ItemID      uuid.UUID `json:"item_id" db:"item_id"`
+	Name        string    `json:"name" db:"name"`
+	Variant     string    `json:"variant" db:"variant"`
+	SKUCode     string    `json:"sku_code,omitempty" db:"sku_code"`
+	Quantity    int       `json:"quantity" db:"quantity"` // total units for the line
+	UnitCost    float64   `json:"unit_cost" db:"unit_cost"`
+}
+
+func (OrderItemComponent) TableName() string {
+	return "order_item_components"
+}
+
+func (OrderItemComponent) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS order_item_components (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+		item_type VARCHAR(20) NOT NULL,
+		item_id UUID NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		variant TEXT NOT NULL DEFAULT '',
+		sku_code TEXT NOT NULL DEFAULT '',
+		quantity INTEGER NOT NULL,
+		unit_cost NUMERIC(12,2) NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_order_item_components_item ON order_item_components(order_item_id);`
+}
+
+// OrderBundleItem is a bundle line on an order with the components to pick
+type OrderBundleItem struct {
+	ID         uuid.UUID            `json:"id"`
+	BundleID   uuid.UUID            `json:"bundle_id"`
+	Name       string               `json:"name"`
+	ImageURL   *string              `json:"image_url"`
+	Quantity   int                  `json:"quantity"`
+	UnitPrice  float64              `json:"unit_price"`
+	TotalPrice float64              `json:"total_price"`
+	Components []OrderItemComponent `json:"components"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CatalogImportJob tracks a spreadsheet import applied in the background.
+// The validated rows are kept on the job so the worker needs no access to the upload.
+type CatalogImportJob struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	Filename      string     `json:"filename" db:"filename"`
+	Status        string     `json:"status" db:"status"` // pending, running, completed, failed
+	TotalRows     int        `json:"total_rows" db:"total_rows"`
+	ProcessedRows int        `json:"processed_rows" db:"processed_rows"`
+	CreatedCount  int        `json:"created_count" db:"created_count"`
+	UpdatedCount  int        `json:"updated_count" db:"updated_count"`
+	ErrorCount    int        `json:"error_count" db:"error_count"`
+	Rows          string     `json:"-" db:"rows"`
+	Errors        string     `json:"errors" db:"errors"` // JSON array of row errors
+	CreatedBy     *uuid.UUID `json:"created_by" db:"created_by"`
+	StartedAt     *time.Time `json:"started_at" db:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at" db:"finished_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (CatalogImportJob) TableName() string {
+	return "catalog_import_jobs"
+}
+
+func (CatalogImportJob) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS catalog_import_jobs (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		filename TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+		total_rows INTEGER NOT NULL DEFAULT 0,
+		processed_rows INTEGER NOT NULL DEFAULT 0,
+		created_count INTEGER NOT NULL DEFAULT 0,
+		updated_count INTEGER NOT NULL DEFAULT 0,
+		error_count INTEGER NOT NULL DEFAULT 0,
+		rows JSONB NOT NULL DEFAULT '[]',
+		errors JSONB NOT NULL DEFAULT '[]',
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		started_at TIMESTAMP WITH TIME ZONE,
+		finished_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_catalog_import_jobs_created ON catalog_import_jobs(created_at);`
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockReceipt is a goods receipt (supplier delivery) that brings stock in at a known cost
+type StockReceipt struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Supplier   *string    `json:"supplier" db:"supplier"`
+	Reference  *string    `json:"reference" db:"reference"` // Supplier invoice / delivery note number
+	Location   *string    `json:"location" db:"location"`
+	Notes      *string    `json:"notes" db:"notes"`
+	ReceivedAt time.Time  `json:"received_at" db:"received_at"`
+	CreatedBy  *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (StockReceipt) TableName() string {
+	return "stock_receipts"
+}
+
+func (StockReceipt) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_receipts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		supplier TEXT,
+		reference TEXT,
+		location TEXT,
+		notes TEXT,
+		received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);`
+}
+
+// StockReceiptLine is one received item. Lines double as FIFO cost layers:
+// RemainingQuantity is consumed oldest-first as the item is sold.
+type StockReceiptLine struct {
+	ID                uuid.UUID `json:"id" db:"id"`
+	ReceiptID         uuid.UUID `json:"receipt_id" db:"receipt_id"`
+	ItemType          string    `json:"item_type" db:"item_type"` // sku, melhaf_color, perfume_variant
+	ItemID            uuid.UUID `json:"item_id" db:"item_id"`
+	Quantity          int       `json:"quantity" db:"quantity"`
+	UnitCost          float64   `json:"unit_cost" db:"unit_cost"`
+	RemainingQuantity int       `json:"remaining_quantity" db:"remaining_quantity"`
+	ReceivedAt        time.Time `json:"received_at" db:"received_at"`
+}
+
+func (StockReceiptLine) TableName() string {
+	return "stock_receipt_lines"
+}
+
+func (StockReceiptLine) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_receipt_lines (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		receipt_id UUID NOT NULL REFERENCES stock_receipts(id) ON DELETE CASCADE,
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		quantity INTEGER NOT NULL CHECK (quantity > 0),
+		unit_cost NUMERIC(12,2) NOT NULL CHECK (unit_cost >= 0),
+		remaining_quantity INTEGER NOT NULL,
+		received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_stock_receipt_lines_item ON stock_receipt_lines(item_type, item_id, received_at);`
+}
+
+// ItemCost holds the running weighted-average cost of a sellable item
+type ItemCost struct {
+	ItemType    string    `json:"item_type" db:"item_type"`
+	ItemID      uuid.UUID `json:"item_id" db:"item_id"`
+	AverageCost float64   `json:"average_cost" db:"average_cost"`
+	LastCost    float64   `json:"last_cost" db:"last_cost"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (ItemCost) TableName() string {
+	return "item_costs"
+}
+
+func (ItemCost) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS item_costs (
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		average_cost NUMERIC(12,4) NOT NULL DEFAULT 0,
+		last_cost NUMERIC(12,2) NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		PRIMARY KEY (item_type, item_id)
+	);`
+}
@@ -11,6 +11,7 @@ type Inventory struct {
 	Available  int       `json:"available" db:"available"`
 	Reserved   int       `json:"reserved" db:"reserved"`
     ReorderPoint int     `json:"reorder_point" db:"reorder_point"`
+	Location   *string   `json:"location" db:"location"` // Boutique / stockroom code used to scope stock-takes
 	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
 }
 
@@ -25,6 +26,7 @@ func (Inventory) CreateTableSQL() string {
 		available INT NOT NULL DEFAULT 0,
 		reserved INT NOT NULL DEFAULT 0,
         reorder_point INT NOT NULL DEFAULT 0,
+		location TEXT,
 		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 	);`
 }
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InventoryMovement is a ledger entry for every change applied to stock.
+// ItemType is "sku", "melhaf_color" or "perfume_variant" and ItemID points
+// at skus.id, melhaf_colors.id or maison_adrar_perfume_colors.id.
+type InventoryMovement struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ItemType       string     `json:"item_type" db:"item_type"`
+	ItemID         uuid.UUID  `json:"item_id" db:"item_id"`
+	QuantityChange int        `json:"quantity_change" db:"quantity_change"`
+	QuantityBefore int        `json:"quantity_before" db:"quantity_before"`
+	QuantityAfter  int        `json:"quantity_after" db:"quantity_after"`
+	Reason         string     `json:"reason" db:"reason"` // stock_take, manual_adjustment, sale, receipt...
+	ReferenceType  *string    `json:"reference_type" db:"reference_type"`
+	ReferenceID    *uuid.UUID `json:"reference_id" db:"reference_id"`
+	CreatedBy      *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (InventoryMovement) TableName() string {
+	return "inventory_movements"
+}
+
+func (InventoryMovement) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS inventory_movements (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		quantity_change INTEGER NOT NULL,
+		quantity_before INTEGER NOT NULL DEFAULT 0,
+		quantity_after INTEGER NOT NULL DEFAULT 0,
+		reason VARCHAR(50) NOT NULL,
+		reference_type VARCHAR(50),
+		reference_id UUID,
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_inventory_movements_item ON inventory_movements(item_type, item_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_inventory_movements_reference ON inventory_movements(reference_type, reference_id);`
+}
@@ -74,6 +74,9 @@ type MelhafColor struct {
 	Price        float64    `json:"price" db:"price"`
 	Discount     *float64   `json:"discount" db:"discount"` // Optional discount percentage
 	EAN          *string    `json:"ean" db:"ean"`           // EAN code for barcode
+	ColorFamily  *string    `json:"color_family" db:"color_family"`
+	FamilySource *string    `json:"color_family_source" db:"color_family_source"` // manual, hex, name, image
+	Dominant     *string    `json:"dominant_color" db:"dominant_color"`           // extracted from the first image
 	IsActive     bool       `json:"is_active" db:"is_active"`
 	SortOrder    int        `json:"sort_order" db:"sort_order"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
@@ -95,6 +98,9 @@ func (MelhafColor) CreateTableSQL() string {
 		price NUMERIC(12,2) NOT NULL DEFAULT 0,
 		discount NUMERIC(5,2),
 		ean TEXT,
+		color_family VARCHAR(20),
+		color_family_source VARCHAR(10),
+		dominant_color VARCHAR(7),
 		is_active BOOLEAN DEFAULT TRUE,
 		sort_order INTEGER DEFAULT 0,
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
@@ -24,6 +24,7 @@ type Order struct {
 	CreatedAt        time.Time      `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at" db:"updated_at"`
 	Items            []OrderItem    `json:"items,omitempty"`
+	Bundles          []OrderBundleItem `json:"bundles,omitempty"`
 }
 
 // OrderItem represents an item within an order
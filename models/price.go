@@ -6,15 +6,20 @@ import (
 	"github.com/google/uuid"
 )
 
+// Price is a price row for a SKU in one currency. A row without start_at/end_at is
+// the SKU's base price; rows with a window are scheduled prices that take over
+// while the window is open (see the prices_at resolver in the migrations).
 type Price struct {
-	ID        uuid.UUID  `json:"id" db:"id"`
-	SKUID     uuid.UUID  `json:"sku_id" db:"sku_id"`
-	Currency  string     `json:"currency" db:"currency"`
-	ListPrice float64    `json:"list_price" db:"list_price"`
-	SalePrice *float64   `json:"sale_price" db:"sale_price"`
-	StartAt   *time.Time `json:"start_at" db:"start_at"`
-	EndAt     *time.Time `json:"end_at" db:"end_at"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	SKUID       uuid.UUID  `json:"sku_id" db:"sku_id"`
+	Currency    string     `json:"currency" db:"currency"`
+	ListPrice   float64    `json:"list_price" db:"list_price"`
+	SalePrice   *float64   `json:"sale_price" db:"sale_price"`
+	StartAt     *time.Time `json:"start_at" db:"start_at"`
+	EndAt       *time.Time `json:"end_at" db:"end_at"`
+	SaleEventID *uuid.UUID `json:"sale_event_id" db:"sale_event_id"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 func (Price) TableName() string {
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PriceDropNotification logs a wishlist price-drop push; it is also used to
+// throttle how often a user hears about price drops
+type PriceDropNotification struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	OldPrice  float64   `json:"old_price" db:"old_price"`
+	NewPrice  float64   `json:"new_price" db:"new_price"`
+	SentAt    time.Time `json:"sent_at" db:"sent_at"`
+}
+
+func (PriceDropNotification) TableName() string {
+	return "price_drop_notifications"
+}
+
+func (PriceDropNotification) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS price_drop_notifications (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		product_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		old_price NUMERIC(12,2) NOT NULL,
+		new_price NUMERIC(12,2) NOT NULL,
+		sent_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_price_drop_notifications_user ON price_drop_notifications(user_id, sent_at);`
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PriceHistory is one change to a price row (create, update or delete). Rows are
+// written by a trigger on prices so every writer is covered; the source and the
+// admin come from the transaction settings fmbq.price_source and fmbq.price_actor.
+type PriceHistory struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	PriceID      uuid.UUID  `json:"price_id" db:"price_id"`
+	SKUID        uuid.UUID  `json:"sku_id" db:"sku_id"`
+	Currency     string     `json:"currency" db:"currency"`
+	Action       string     `json:"action" db:"action"` // create, update, delete
+	OldListPrice *float64   `json:"old_list_price" db:"old_list_price"`
+	OldSalePrice *float64   `json:"old_sale_price" db:"old_sale_price"`
+	NewListPrice *float64   `json:"new_list_price" db:"new_list_price"`
+	NewSalePrice *float64   `json:"new_sale_price" db:"new_sale_price"`
+	StartAt      *time.Time `json:"start_at" db:"start_at"`
+	EndAt        *time.Time `json:"end_at" db:"end_at"`
+	SaleEventID  *uuid.UUID `json:"sale_event_id" db:"sale_event_id"`
+	Source       string     `json:"source" db:"source"` // admin, import, sale_event, system
+	ChangedBy    *uuid.UUID `json:"changed_by" db:"changed_by"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (PriceHistory) TableName() string {
+	return "price_history"
+}
+
+// No foreign key on sku_id: the log outlives deleted SKUs and prices
+func (PriceHistory) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS price_history (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		price_id UUID NOT NULL,
+		sku_id UUID NOT NULL,
+		currency CHAR(3) NOT NULL,
+		action VARCHAR(10) NOT NULL,
+		old_list_price NUMERIC(12,2),
+		old_sale_price NUMERIC(12,2),
+		new_list_price NUMERIC(12,2),
+		new_sale_price NUMERIC(12,2),
+		start_at TIMESTAMP WITH TIME ZONE,
+		end_at TIMESTAMP WITH TIME ZONE,
+		sale_event_id UUID,
+		source VARCHAR(20) NOT NULL DEFAULT 'system',
+		changed_by UUID,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_price_history_sku ON price_history(sku_id, created_at DESC);`
+}
@@ -13,6 +13,9 @@ type ProductColor struct {
 	ColorCode         *string   `json:"color_code" db:"color_code"`
 	ExternalColorID   *string   `json:"external_color_id" db:"external_color_id"`
 	DefaultImageID    *uuid.UUID `json:"default_image_id" db:"default_image_id"`
+	ColorFamily       *string   `json:"color_family" db:"color_family"`               // red, blue, ... (see utils.ColorFamilies)
+	ColorFamilySource *string   `json:"color_family_source" db:"color_family_source"` // manual, hex, name, image
+	DominantColor     *string   `json:"dominant_color" db:"dominant_color"`           // extracted from the color's first image
 	CreatedAt         time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -29,6 +32,9 @@ func (ProductColor) CreateTableSQL() string {
 		color_code TEXT,
 		external_color_id TEXT,
 		default_image_id UUID,
+		color_family VARCHAR(20),
+		color_family_source VARCHAR(10),
+		dominant_color VARCHAR(7),
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 	);`
 }
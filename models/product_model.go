@@ -6,17 +6,33 @@ import (
 	"github.com/google/uuid"
 )
 
+// Product lifecycle states. Only published products are visible in the shop
+// (is_active follows the status).
+const (
+	ProductStatusDraft     = "draft"
+	ProductStatusReview    = "review"
+	ProductStatusScheduled = "scheduled"
+	ProductStatusPublished = "published"
+	ProductStatusArchived  = "archived"
+)
+
 type ProductModel struct {
-	ID               uuid.UUID `json:"id" db:"id"`
-	BrandID          uuid.UUID `json:"brand_id" db:"brand_id"`
-	Title            string    `json:"title" db:"title"`
-	Description      *string   `json:"description" db:"description"`
-	ShortDescription *string   `json:"short_description" db:"short_description"`
-	ModelCode        *string   `json:"model_code" db:"model_code"`
-	IsActive         bool      `json:"is_active" db:"is_active"`
-	Attributes       string    `json:"attributes" db:"attributes"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+	ID               uuid.UUID  `json:"id" db:"id"`
+	BrandID          uuid.UUID  `json:"brand_id" db:"brand_id"`
+	Title            string     `json:"title" db:"title"`
+	Description      *string    `json:"description" db:"description"`
+	ShortDescription *string    `json:"short_description" db:"short_description"`
+	ModelCode        *string    `json:"model_code" db:"model_code"`
+	IsActive         bool       `json:"is_active" db:"is_active"`
+	Status           string     `json:"status" db:"status"`
+	PublishAt        *time.Time `json:"publish_at" db:"publish_at"`
+	UnpublishAt      *time.Time `json:"unpublish_at" db:"unpublish_at"`
+	PublishedAt      *time.Time `json:"published_at" db:"published_at"`
+	Attributes       string     `json:"attributes" db:"attributes"`
+	RatingAverage    *float64   `json:"rating_average" db:"rating_average"` // approved reviews only
+	RatingCount      int        `json:"rating_count" db:"rating_count"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 func (ProductModel) TableName() string {
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Q&A moderation states, for questions and answers alike. Only approved questions
+// with an approved answer are shown.
+const (
+	QAStatusPending  = "pending"
+	QAStatusApproved = "approved"
+	QAStatusRejected = "rejected"
+)
+
+// ProductQuestion is a customer question about a product model, Melhaf collection
+// or perfume
+type ProductQuestion struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ItemType       string     `json:"item_type" db:"item_type"` // product, melhaf_collection, perfume
+	ItemID         uuid.UUID  `json:"item_id" db:"item_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Question       string     `json:"question" db:"question"`
+	Status         string     `json:"status" db:"status"`
+	ModerationNote *string    `json:"moderation_note" db:"moderation_note"`
+	ModeratedBy    *uuid.UUID `json:"moderated_by" db:"moderated_by"`
+	ModeratedAt    *time.Time `json:"moderated_at" db:"moderated_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (ProductQuestion) TableName() string {
+	return "product_questions"
+}
+
+func (ProductQuestion) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_questions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('product', 'melhaf_collection', 'perfume')),
+		item_id UUID NOT NULL,
+		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		question TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		moderation_note TEXT,
+		moderated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		moderated_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_questions_item ON product_questions(item_type, item_id, status);
+	CREATE INDEX IF NOT EXISTS idx_product_questions_status ON product_questions(status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_product_questions_user ON product_questions(user_id);`
+}
+
+// ProductAnswer answers a question, from staff (published at once) or from a
+// customer who bought the item (moderated first)
+type ProductAnswer struct {
+	ID            uuid.UUID  `json:"id" db:"id"`
+	QuestionID    uuid.UUID  `json:"question_id" db:"question_id"`
+	UserID        *uuid.UUID `json:"user_id" db:"user_id"`
+	Answer        string     `json:"answer" db:"answer"`
+	IsStaff       bool       `json:"is_staff" db:"is_staff"`
+	VerifiedBuyer bool       `json:"verified_buyer" db:"verified_buyer"`
+	Status        string     `json:"status" db:"status"`
+	ModeratedBy   *uuid.UUID `json:"moderated_by" db:"moderated_by"`
+	ModeratedAt   *time.Time `json:"moderated_at" db:"moderated_at"`
+	NotifiedAt    *time.Time `json:"notified_at" db:"notified_at"` // asker was pushed about it
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (ProductAnswer) TableName() string {
+	return "product_answers"
+}
+
+func (ProductAnswer) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_answers (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		question_id UUID NOT NULL REFERENCES product_questions(id) ON DELETE CASCADE,
+		user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		answer TEXT NOT NULL,
+		is_staff BOOLEAN NOT NULL DEFAULT false,
+		verified_buyer BOOLEAN NOT NULL DEFAULT false,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+		moderated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		moderated_at TIMESTAMP WITH TIME ZONE,
+		notified_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_answers_question ON product_answers(question_id, status);
+	CREATE INDEX IF NOT EXISTS idx_product_answers_status ON product_answers(status, created_at);`
+}
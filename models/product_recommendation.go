@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductCoOccurrence counts how often two product models were bought in the same
+// order (signal purchase) or viewed in the same session (signal view). Counts are
+// kept in both directions; the row of a product with itself holds how many orders
+// or sessions it appeared in.
+type ProductCoOccurrence struct {
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	RelatedID uuid.UUID `json:"related_id" db:"related_id"`
+	Signal    string    `json:"signal" db:"signal"` // purchase, view
+	Count     int       `json:"count" db:"count"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (ProductCoOccurrence) TableName() string {
+	return "product_co_occurrences"
+}
+
+func (ProductCoOccurrence) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_co_occurrences (
+		product_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		related_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		signal VARCHAR(20) NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		PRIMARY KEY (product_id, signal, related_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_co_occurrences_updated ON product_co_occurrences(signal, updated_at);`
+}
+
+// ProductRecommendation is a ranked related product, as served by the
+// recommendation endpoints. Rows come from co-occurrences (source co_purchase or
+// co_view) or, for products without enough history, from category similarity.
+type ProductRecommendation struct {
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	Kind      string    `json:"kind" db:"kind"` // bought_together, viewed_together
+	RelatedID uuid.UUID `json:"related_id" db:"related_id"`
+	Score     float64   `json:"score" db:"score"`
+	CoCount   int       `json:"co_count" db:"co_count"`
+	Source    string    `json:"source" db:"source"` // co_purchase, co_view, category
+	Rank      int       `json:"rank" db:"rank"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (ProductRecommendation) TableName() string {
+	return "product_recommendations"
+}
+
+func (ProductRecommendation) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_recommendations (
+		product_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		kind VARCHAR(20) NOT NULL,
+		related_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		score NUMERIC(10,6) NOT NULL,
+		co_count INTEGER NOT NULL DEFAULT 0,
+		source VARCHAR(20) NOT NULL,
+		rank INTEGER NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		PRIMARY KEY (product_id, kind, related_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_recommendations_rank ON product_recommendations(product_id, kind, rank);`
+}
+
+// RecommendationWatermark is how far the recommendation, trending and product view
+// jobs have read each signal
+type RecommendationWatermark struct {
+	Signal         string    `json:"signal" db:"signal"` // purchase, view, trending, view_rollup
+	ProcessedUntil time.Time `json:"processed_until" db:"processed_until"`
+}
+
+func (RecommendationWatermark) TableName() string {
+	return "recommendation_watermarks"
+}
+
+func (RecommendationWatermark) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS recommendation_watermarks (
+		signal VARCHAR(20) PRIMARY KEY,
+		processed_until TIMESTAMP WITH TIME ZONE NOT NULL
+	);`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProductTrendingScore is a product's recent activity (views, cart adds, wishlist
+// adds and purchases), each weighted and decayed exponentially with its age. Score
+// fades slowly and ranks what is trending; Velocity fades fast, and outgrowing
+// Score marks a sudden spike. Both are decayed up to ScoredUntil.
+type ProductTrendingScore struct {
+	ProductID   uuid.UUID  `json:"product_id" db:"product_id"`
+	Score       float64    `json:"score" db:"score"`
+	Velocity    float64    `json:"velocity" db:"velocity"`
+	LastEventAt *time.Time `json:"last_event_at" db:"last_event_at"`
+	ScoredUntil time.Time  `json:"scored_until" db:"scored_until"`
+}
+
+func (ProductTrendingScore) TableName() string {
+	return "product_trending_scores"
+}
+
+func (ProductTrendingScore) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_trending_scores (
+		product_id UUID PRIMARY KEY REFERENCES product_models(id) ON DELETE CASCADE,
+		score NUMERIC(14,6) NOT NULL DEFAULT 0,
+		velocity NUMERIC(14,6) NOT NULL DEFAULT 0,
+		last_event_at TIMESTAMP WITH TIME ZONE,
+		scored_until TIMESTAMP WITH TIME ZONE NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_trending_scores_score ON product_trending_scores(score DESC);`
+}
@@ -61,3 +61,31 @@ func (ProductView) CreateTableSQL() string {
 	WHERE anonymous_session_id IS NOT NULL;
 	`
 }
+
+// ProductViewDaily is a product's views on one day, rolled up from product_views
+// and kept after the raw views are pruned. Visitors counts signed-in customers and
+// anonymous sessions once each.
+type ProductViewDaily struct {
+	ProductID uuid.UUID `json:"product_id" db:"product_id"`
+	Day       time.Time `json:"day" db:"day"`
+	Views     int       `json:"views" db:"views"`
+	Visitors  int       `json:"visitors" db:"visitors"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (ProductViewDaily) TableName() string {
+	return "product_view_daily"
+}
+
+func (ProductViewDaily) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS product_view_daily (
+		product_id UUID NOT NULL REFERENCES product_models(id) ON DELETE CASCADE,
+		day DATE NOT NULL,
+		views INTEGER NOT NULL DEFAULT 0,
+		visitors INTEGER NOT NULL DEFAULT 0,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		PRIMARY KEY (product_id, day)
+	);
+	CREATE INDEX IF NOT EXISTS idx_product_view_daily_day ON product_view_daily(day);`
+}
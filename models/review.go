@@ -6,14 +6,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// Review moderation states. Only approved reviews are shown and counted in a
+// product's rating.
+const (
+	ReviewStatusPending  = "pending"
+	ReviewStatusApproved = "approved"
+	ReviewStatusRejected = "rejected"
+	ReviewStatusFlagged  = "flagged"
+)
+
 type Review struct {
-	ID             uuid.UUID `json:"id" db:"id"`
-	ProductModelID uuid.UUID `json:"product_model_id" db:"product_model_id"`
-	UserID         uuid.UUID `json:"user_id" db:"user_id"`
-	Rating         int       `json:"rating" db:"rating"`
-	Title          *string   `json:"title" db:"title"`
-	Body           *string   `json:"body" db:"body"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ProductModelID uuid.UUID  `json:"product_model_id" db:"product_model_id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	OrderItemID    *uuid.UUID `json:"order_item_id" db:"order_item_id"` // delivered purchase the review is for
+	Rating         int        `json:"rating" db:"rating"`
+	Title          *string    `json:"title" db:"title"`
+	Body           *string    `json:"body" db:"body"`
+	Status         string     `json:"status" db:"status"`
+	ModerationNote *string    `json:"moderation_note" db:"moderation_note"`
+	ModeratedBy    *uuid.UUID `json:"moderated_by" db:"moderated_by"`
+	ModeratedAt    *time.Time `json:"moderated_at" db:"moderated_at"`
+	Reply          *string    `json:"reply" db:"reply"` // merchant's public answer
+	RepliedBy      *uuid.UUID `json:"replied_by" db:"replied_by"`
+	RepliedAt      *time.Time `json:"replied_at" db:"replied_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 func (Review) TableName() string {
@@ -32,3 +50,28 @@ func (Review) CreateTableSQL() string {
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 	);`
 }
+
+// ReviewPhoto is a photo a customer attached to a review
+type ReviewPhoto struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	ReviewID  uuid.UUID `json:"review_id" db:"review_id"`
+	URL       string    `json:"url" db:"url"`
+	Position  int       `json:"position" db:"position"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (ReviewPhoto) TableName() string {
+	return "review_photos"
+}
+
+func (ReviewPhoto) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS review_photos (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		review_id UUID NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+		url TEXT NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_review_photos_review ON review_photos(review_id);`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SaleEvent is a bulk markdown over a brand or category: while it runs, every SKU
+// in scope gets a scheduled price row at list price minus Percentage
+type SaleEvent struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	ScopeType  string     `json:"scope_type" db:"scope_type"` // brand, category
+	ScopeID    uuid.UUID  `json:"scope_id" db:"scope_id"`
+	Percentage float64    `json:"percentage" db:"percentage"`
+	Currency   string     `json:"currency" db:"currency"`
+	StartAt    time.Time  `json:"start_at" db:"start_at"`
+	EndAt      time.Time  `json:"end_at" db:"end_at"`
+	SKUCount   int        `json:"sku_count" db:"sku_count"`
+	Status     string     `json:"status" db:"status"` // scheduled, cancelled (running/ended follow from the window)
+	CreatedBy  *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (SaleEvent) TableName() string {
+	return "sale_events"
+}
+
+func (SaleEvent) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS sale_events (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		scope_type VARCHAR(20) NOT NULL CHECK (scope_type IN ('brand', 'category')),
+		scope_id UUID NOT NULL,
+		percentage NUMERIC(5,2) NOT NULL CHECK (percentage > 0 AND percentage < 100),
+		currency CHAR(3) NOT NULL DEFAULT 'MRO',
+		start_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		end_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		sku_count INTEGER NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'scheduled',
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_sale_events_window ON sale_events(start_at, end_at);`
+}
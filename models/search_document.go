@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchDocument is the search index entry of one searchable item: a product model,
+// a Melhaf collection or color, or a Maison Adrar perfume. Rows are maintained by
+// triggers on the source tables; popularity is refreshed by a background job.
+type SearchDocument struct {
+	DocType    string    `json:"doc_type" db:"doc_type"` // product, melhaf_collection, melhaf_color, perfume
+	DocID      uuid.UUID `json:"doc_id" db:"doc_id"`
+	Title      string    `json:"title" db:"title"`
+	Subtitle   string    `json:"subtitle" db:"subtitle"`
+	SearchText string    `json:"-" db:"search_text"` // normalized title, brand and codes, for typo tolerance
+	Popularity float64   `json:"popularity" db:"popularity"`
+	IsActive   bool      `json:"is_active" db:"is_active"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (SearchDocument) TableName() string {
+	return "search_documents"
+}
+
+func (SearchDocument) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS search_documents (
+		doc_type VARCHAR(20) NOT NULL,
+		doc_id UUID NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		subtitle TEXT NOT NULL DEFAULT '',
+		search_text TEXT NOT NULL DEFAULT '',
+		document TSVECTOR,
+		popularity NUMERIC(10,4) NOT NULL DEFAULT 0,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		PRIMARY KEY (doc_type, doc_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_search_documents_document ON search_documents USING GIN (document);
+	CREATE INDEX IF NOT EXISTS idx_search_documents_search_text ON search_documents USING GIN (search_text gin_trgm_ops);`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchQuery is one search typed by a customer; popular queries feed the
+// autocomplete suggestions and the search reports
+type SearchQuery struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	Query           string     `json:"query" db:"query"`
+	NormalizedQuery string     `json:"normalized_query" db:"normalized_query"`
+	Source          string     `json:"source" db:"source"`                   // search, products, products_enhanced, product_code
+	Filters         string     `json:"filters" db:"filters"`                 // JSON object of the filters applied with the query
+	RewrittenQuery  *string    `json:"rewritten_query" db:"rewritten_query"` // query searched after synonyms
+	RedirectID      *uuid.UUID `json:"redirect_id" db:"redirect_id"`         // redirect rule the query landed on
+	ResultCount     int        `json:"result_count" db:"result_count"`
+	UserID          *uuid.UUID `json:"user_id" db:"user_id"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (SearchQuery) TableName() string {
+	return "search_queries"
+}
+
+func (SearchQuery) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS search_queries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		query TEXT NOT NULL,
+		normalized_query TEXT NOT NULL DEFAULT '',
+		source VARCHAR(30) NOT NULL,
+		result_count INTEGER NOT NULL DEFAULT 0,
+		user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_search_queries_created_at ON search_queries(created_at);
+	CREATE INDEX IF NOT EXISTS idx_search_queries_normalized ON search_queries(normalized_query);`
+}
+
+// SearchClick is a result a customer opened from a search
+type SearchClick struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	SearchQueryID uuid.UUID `json:"search_query_id" db:"search_query_id"`
+	ItemType      string    `json:"item_type" db:"item_type"` // product, melhaf_collection, melhaf_color, perfume
+	ItemID        uuid.UUID `json:"item_id" db:"item_id"`
+	Position      *int      `json:"position" db:"position"` // 1-based rank in the results
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+func (SearchClick) TableName() string {
+	return "search_clicks"
+}
+
+func (SearchClick) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS search_clicks (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		search_query_id UUID NOT NULL REFERENCES search_queries(id) ON DELETE CASCADE,
+		item_type VARCHAR(20) NOT NULL,
+		item_id UUID NOT NULL,
+		position INTEGER,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_search_clicks_query ON search_clicks(search_query_id);`
+}
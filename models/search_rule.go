@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SearchRule rewrites customer searches. A synonym replaces a term wherever it
+// appears in a query ("malhfa" -> "melhaf"); a redirect sends a query that is
+// exactly the term to a category, brand, item or URL instead of the results.
+type SearchRule struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Kind           string     `json:"kind" db:"kind"` // synonym, redirect
+	Term           string     `json:"term" db:"term"`
+	NormalizedTerm string     `json:"normalized_term" db:"normalized_term"`
+	Replacement    *string    `json:"replacement" db:"replacement"` // synonym only
+	TargetType     *string    `json:"target_type" db:"target_type"` // redirect only: category, brand, product, melhaf_collection, perfume, url
+	Target         *string    `json:"target" db:"target"`           // redirect only: id, or the URL
+	IsActive       bool       `json:"is_active" db:"is_active"`
+	CreatedBy      *uuid.UUID `json:"created_by" db:"created_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (SearchRule) TableName() string {
+	return "search_rules"
+}
+
+func (SearchRule) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS search_rules (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		kind VARCHAR(10) NOT NULL CHECK (kind IN ('synonym', 'redirect')),
+		term TEXT NOT NULL,
+		normalized_term TEXT NOT NULL,
+		replacement TEXT,
+		target_type VARCHAR(20),
+		target TEXT,
+		is_active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		UNIQUE (kind, normalized_term)
+	);`
+}
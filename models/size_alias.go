@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SizeAlias maps a size label to a canonical size, for a single brand or, with no
+// brand, for the whole catalog (e.g. brand X labels its M as "2")
+type SizeAlias struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	BrandID   *uuid.UUID `json:"brand_id" db:"brand_id"`
+	Alias     string     `json:"alias" db:"alias"`
+	Canonical string     `json:"canonical" db:"canonical"` // canonical code such as M, EU40, SHOE42
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (SizeAlias) TableName() string {
+	return "size_aliases"
+}
+
+func (SizeAlias) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS size_aliases (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		brand_id UUID REFERENCES brands(id) ON DELETE CASCADE,
+		alias TEXT NOT NULL,
+		canonical TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_size_aliases_brand_alias
+		ON size_aliases(COALESCE(brand_id, '00000000-0000-0000-0000-000000000000'::uuid), lower(alias));`
+}
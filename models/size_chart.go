@@ -6,11 +6,42 @@ import (
 	"github.com/google/uuid"
 )
 
+// SizeChart maps a garment's sizes to body measurements and regional size labels.
+// A chart is used by the SKUs and product models it is assigned to; charts flagged
+// is_default apply to products of their brand and/or category that have no chart.
 type SizeChart struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	ChartJSON string    `json:"chart_json" db:"chart_json"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	Description *string    `json:"description" db:"description"`
+	BrandID     *uuid.UUID `json:"brand_id" db:"brand_id"`
+	CategoryID  *uuid.UUID `json:"category_id" db:"category_id"`
+	IsDefault   bool       `json:"is_default" db:"is_default"`
+	Unit        string     `json:"unit" db:"unit"` // cm, in
+	ChartJSON   string     `json:"chart_json" db:"chart_json"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// SizeChartData is the document stored in chart_json
+type SizeChartData struct {
+	Measurements []string       `json:"measurements"` // e.g. chest, waist, hips, foot_length
+	Sizes        []SizeChartRow `json:"sizes"`
+}
+
+// SizeChartRow is one size with its regional conversions and measurement ranges
+type SizeChartRow struct {
+	Size         string                      `json:"size"`
+	EU           string                      `json:"eu,omitempty"`
+	US           string                      `json:"us,omitempty"`
+	UK           string                      `json:"uk,omitempty"`
+	FR           string                      `json:"fr,omitempty"`
+	Measurements map[string]MeasurementRange `json:"measurements"`
+}
+
+// MeasurementRange is the body measurement range a size fits, in the chart's unit
+type MeasurementRange struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
 }
 
 func (SizeChart) TableName() string {
@@ -22,7 +53,13 @@ func (SizeChart) CreateTableSQL() string {
 	CREATE TABLE IF NOT EXISTS size_charts (
 		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 		name TEXT NOT NULL,
+		description TEXT,
+		brand_id UUID REFERENCES brands(id) ON DELETE SET NULL,
+		category_id UUID REFERENCES categories(id) ON DELETE SET NULL,
+		is_default BOOLEAN NOT NULL DEFAULT FALSE,
+		unit VARCHAR(5) NOT NULL DEFAULT 'cm',
 		chart_json JSONB NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 	);`
 }
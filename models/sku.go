@@ -13,7 +13,10 @@ type SKU struct {
 	SKUCode         string     `json:"sku_code" db:"sku_code"`
 	EAN             *string    `json:"ean" db:"ean"`
 	Size            *string    `json:"size" db:"size"`
-	SizeNormalized  *string    `json:"size_normalized" db:"size_normalized"`
+	SizeNormalized  *string    `json:"size_normalized" db:"size_normalized"` // canonical code, e.g. M, EU38, SHOE42, AGE4Y
+	SizeScale       *string    `json:"size_scale" db:"size_scale"`           // letter, eu, shoe, kids, numeric, one_size, other
+	SizeRank        *int       `json:"size_rank" db:"size_rank"`
+	SizeGroup       *string    `json:"size_group" db:"size_group"`           // equivalent letter size, e.g. M for EU38
 	SizeChartID     *uuid.UUID `json:"size_chart_id" db:"size_chart_id"`
 	Attributes      string     `json:"attributes" db:"attributes"`
 	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
@@ -33,6 +36,9 @@ func (SKU) CreateTableSQL() string {
 		ean TEXT,
 		size TEXT,
 		size_normalized TEXT,
+		size_scale VARCHAR(20),
+		size_rank INTEGER,
+		size_group VARCHAR(10),
 		size_chart_id UUID REFERENCES size_charts(id),
 		attributes JSONB DEFAULT '{}',
 		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockAlert is raised when an item crosses its reorder point or runs out.
+// Only one unresolved alert exists per item; it is resolved once stock recovers.
+type StockAlert struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	ItemType       string     `json:"item_type" db:"item_type"`   // sku, melhaf_color, perfume_variant
+	AlertType      string     `json:"alert_type" db:"alert_type"` // low_stock, out_of_stock
+	ItemID         uuid.UUID  `json:"item_id" db:"item_id"`
+	Available      int        `json:"available" db:"available"`
+	ReorderPoint   int        `json:"reorder_point" db:"reorder_point"`
+	Status         string     `json:"status" db:"status"` // open, acknowledged, resolved
+	NotifyPending  bool       `json:"notify_pending" db:"notify_pending"`
+	SnoozedUntil   *time.Time `json:"snoozed_until" db:"snoozed_until"`
+	AcknowledgedBy *uuid.UUID `json:"acknowledged_by" db:"acknowledged_by"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at" db:"acknowledged_at"`
+	NotifiedAt     *time.Time `json:"notified_at" db:"notified_at"`
+	ResolvedAt     *time.Time `json:"resolved_at" db:"resolved_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (StockAlert) TableName() string {
+	return "stock_alerts"
+}
+
+func (StockAlert) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_alerts (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		item_type VARCHAR(20) NOT NULL CHECK (item_type IN ('sku', 'melhaf_color', 'perfume_variant')),
+		item_id UUID NOT NULL,
+		alert_type VARCHAR(20) NOT NULL CHECK (alert_type IN ('low_stock', 'out_of_stock')),
+		available INTEGER NOT NULL DEFAULT 0,
+		reorder_point INTEGER NOT NULL DEFAULT 0,
+		status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'acknowledged', 'resolved')),
+		notify_pending BOOLEAN NOT NULL DEFAULT TRUE,
+		snoozed_until TIMESTAMP WITH TIME ZONE,
+		acknowledged_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		acknowledged_at TIMESTAMP WITH TIME ZONE,
+		notified_at TIMESTAMP WITH TIME ZONE,
+		resolved_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_alerts_unresolved ON stock_alerts(item_type, item_id) WHERE status <> 'resolved';
+	CREATE INDEX IF NOT EXISTS idx_stock_alerts_status ON stock_alerts(status, created_at);`
+}
+
+// StockAlertSubscription holds a staff member's stock alert preferences
+type StockAlertSubscription struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	Push           bool       `json:"push" db:"push"`
+	Email          bool       `json:"email" db:"email"`
+	SMS            bool       `json:"sms" db:"sms"`
+	Instant        bool       `json:"instant" db:"instant"`                     // Notify as soon as an alert is raised
+	DailyDigest    bool       `json:"daily_digest" db:"daily_digest"`           // Receive one summary per day
+	OutOfStockOnly bool       `json:"out_of_stock_only" db:"out_of_stock_only"` // Skip low-stock alerts
+	LastDigestAt   *time.Time `json:"last_digest_at" db:"last_digest_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (StockAlertSubscription) TableName() string {
+	return "stock_alert_subscriptions"
+}
+
+func (StockAlertSubscription) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_alert_subscriptions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+		push BOOLEAN NOT NULL DEFAULT TRUE,
+		email BOOLEAN NOT NULL DEFAULT FALSE,
+		sms BOOLEAN NOT NULL DEFAULT FALSE,
+		instant BOOLEAN NOT NULL DEFAULT TRUE,
+		daily_digest BOOLEAN NOT NULL DEFAULT FALSE,
+		out_of_stock_only BOOLEAN NOT NULL DEFAULT FALSE,
+		last_digest_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);`
+}
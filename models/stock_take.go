@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockTakeSession is a cycle count scoped to a location, category or brand.
+// Expected quantities are frozen into StockTakeLine rows when the session opens.
+type StockTakeSession struct {
+	ID                uuid.UUID  `json:"id" db:"id"`
+	Name              string     `json:"name" db:"name"`
+	ScopeType         string     `json:"scope_type" db:"scope_type"` // location, category, brand
+	ScopeValue        string     `json:"scope_value" db:"scope_value"`
+	Status            string     `json:"status" db:"status"` // open, counted, approved, applied, cancelled
+	ApprovalThreshold int        `json:"approval_threshold" db:"approval_threshold"`
+	CreatedBy         *uuid.UUID `json:"created_by" db:"created_by"`
+	ApprovedBy        *uuid.UUID `json:"approved_by" db:"approved_by"`
+	ApprovedAt        *time.Time `json:"approved_at" db:"approved_at"`
+	AppliedBy         *uuid.UUID `json:"applied_by" db:"applied_by"`
+	AppliedAt         *time.Time `json:"applied_at" db:"applied_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+func (StockTakeSession) TableName() string {
+	return "stock_take_sessions"
+}
+
+func (StockTakeSession) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_take_sessions (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		scope_type VARCHAR(20) NOT NULL CHECK (scope_type IN ('location', 'category', 'brand')),
+		scope_value TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'counted', 'approved', 'applied', 'cancelled')),
+		approval_threshold INTEGER NOT NULL DEFAULT 5,
+		created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		approved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		approved_at TIMESTAMP WITH TIME ZONE,
+		applied_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		applied_at TIMESTAMP WITH TIME ZONE,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_stock_take_sessions_status ON stock_take_sessions(status);`
+}
+
+// StockTakeLine holds the frozen expected quantity and the running count for one SKU
+type StockTakeLine struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	SessionID        uuid.UUID `json:"session_id" db:"session_id"`
+	SKUID            uuid.UUID `json:"sku_id" db:"sku_id"`
+	ExpectedQuantity int       `json:"expected_quantity" db:"expected_quantity"`
+	CountedQuantity  int       `json:"counted_quantity" db:"counted_quantity"`
+	InScope          bool      `json:"in_scope" db:"in_scope"` // false when a scanned SKU was not part of the frozen scope
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (StockTakeLine) TableName() string {
+	return "stock_take_lines"
+}
+
+func (StockTakeLine) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_take_lines (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		session_id UUID NOT NULL REFERENCES stock_take_sessions(id) ON DELETE CASCADE,
+		sku_id UUID NOT NULL REFERENCES skus(id) ON DELETE CASCADE,
+		expected_quantity INTEGER NOT NULL DEFAULT 0,
+		counted_quantity INTEGER NOT NULL DEFAULT 0,
+		in_scope BOOLEAN NOT NULL DEFAULT TRUE,
+		updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+		UNIQUE(session_id, sku_id)
+	);`
+}
+
+// StockTakeScan records every individual scan so counts from several devices can be audited
+type StockTakeScan struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	SessionID uuid.UUID  `json:"session_id" db:"session_id"`
+	SKUID     uuid.UUID  `json:"sku_id" db:"sku_id"`
+	EAN       string     `json:"ean" db:"ean"`
+	Quantity  int        `json:"quantity" db:"quantity"`
+	DeviceID  *string    `json:"device_id" db:"device_id"`
+	ScannedBy *uuid.UUID `json:"scanned_by" db:"scanned_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+func (StockTakeScan) TableName() string {
+	return "stock_take_scans"
+}
+
+func (StockTakeScan) CreateTableSQL() string {
+	return `
+	CREATE TABLE IF NOT EXISTS stock_take_scans (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		session_id UUID NOT NULL REFERENCES stock_take_sessions(id) ON DELETE CASCADE,
+		sku_id UUID NOT NULL REFERENCES skus(id) ON DELETE CASCADE,
+		ean TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		device_id TEXT,
+		scanned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+	);
+	CREATE INDEX IF NOT EXISTS idx_stock_take_scans_session ON stock_take_scans(session_id, created_at);`
+}
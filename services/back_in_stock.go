@@ -0,0 +1,138 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"fmbq-server/database"
+	"github.com/google/uuid"
+)
+
+// appDeepLinkScheme prefixes in-app links carried in push notification data
+const appDeepLinkScheme = "fmbq://"
+
+// ProcessBackInStockNotifications sends one push per triggered "notify me" subscription.
+// Subscriptions are armed by the inventory movement ledger when stock goes from zero
+// to positive; if the item sold out again before we got here, the subscription is
+// re-armed instead of sent.
+func (ns *NotificationScheduler) ProcessBackInStockNotifications() error {
+	rows, err := database.Database.Query(`
+		SELECT b.id, b.user_id, b.item_type, b.item_id, COALESCE(u.push_token, ''),
+		       CASE b.item_type
+		           WHEN 'sku' THEN COALESCE(i.available, 0)
+		           WHEN 'melhaf_color' THEN COALESCE(mi.available, 0)
+		           ELSE COALESCE(pvc.stock, 0)
+		       END,
+		       CASE b.item_type
+		           WHEN 'sku' THEN COALESCE(pm.title, '') || COALESCE(' - ' || pc.color_name, '') || COALESCE(' (' || s.size || ')', '')
+		           WHEN 'melhaf_color' THEN 'Melhaf ' || COALESCE(mc.name, '')
+		           ELSE COALESCE(p.name, 'Maison Adrar') || COALESCE(' - ' || pvc.name, '')
+		       END,
+		       COALESCE(s.product_model_id::text, pvc.perfume_id::text, '')
+		FROM back_in_stock_subscriptions b
+		JOIN users u ON u.id = b.user_id
+		LEFT JOIN skus s ON b.item_type = 'sku' AND s.id = b.item_id
+		LEFT JOIN inventory i ON i.sku_id = s.id
+		LEFT JOIN product_models pm ON pm.id = s.product_model_id
+		LEFT JOIN product_colors pc ON pc.id = s.product_color_id
+		LEFT JOIN melhaf_colors mc ON b.item_type = 'melhaf_color' AND mc.id = b.item_id
+		LEFT JOIN melhaf_inventory mi ON mi.color_id = mc.id
+		LEFT JOIN maison_adrar_perfume_colors pvc ON b.item_type = 'perfume_variant' AND pvc.id = b.item_id
+		LEFT JOIN maison_adrar_perfumes p ON p.id = pvc.perfume_id
+		WHERE b.status = 'triggered'
+		ORDER BY b.triggered_at ASC
+		LIMIT 500`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch back-in-stock subscriptions: %w", err)
+	}
+
+	type pending struct {
+		ID        uuid.UUID
+		UserID    uuid.UUID
+		ItemType  string
+		ItemID    uuid.UUID
+		PushToken string
+		Available int
+		Label     string
+		ParentID  string
+	}
+	var subs []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.ID, &p.UserID, &p.ItemType, &p.ItemID, &p.PushToken, &p.Available, &p.Label, &p.ParentID); err != nil {
+			continue
+		}
+		p.Label = strings.TrimSpace(p.Label)
+		subs = append(subs, p)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, sub := range subs {
+		if sub.Available <= 0 {
+			ns.setBackInStockStatus(sub.ID, "active")
+			continue
+		}
+		if sub.PushToken == "" {
+			// No push token, nothing to deliver; the subscription has still fired
+			ns.setBackInStockStatus(sub.ID, "notified")
+			continue
+		}
+
+		data := map[string]interface{}{
+			"type":      "back-in-stock",
+			"item_type": sub.ItemType,
+			"item_id":   sub.ItemID.String(),
+			"deep_link": backInStockDeepLink(sub.ItemType, sub.ItemID, sub.ParentID),
+			"timestamp": time.Now().Unix(),
+		}
+		if sub.ParentID != "" {
+			data["product_id"] = sub.ParentID
+		}
+
+		err := ns.notificationService.SendPushNotification(
+			sub.PushToken,
+			"Back in stock! 🎉",
+			fmt.Sprintf("%s is available again. Grab it before it sells out!", sub.Label),
+			data,
+		)
+		if err != nil {
+			fmt.Printf("❌ Failed to send back-in-stock notification %s: %v\n", sub.ID, err)
+			// Leave it triggered so it is retried on the next run
+			continue
+		}
+		ns.setBackInStockStatus(sub.ID, "notified")
+		sent++
+	}
+
+	if sent > 0 {
+		fmt.Printf("✅ Sent %d back-in-stock notifications\n", sent)
+	}
+	return nil
+}
+
+// backInStockDeepLink builds the in-app link for a restocked item
+func backInStockDeepLink(itemType string, itemID uuid.UUID, parentID string) string {
+	switch itemType {
+	case "sku":
+		return fmt.Sprintf("%sproduct/%s?sku=%s", appDeepLinkScheme, parentID, itemID)
+	case "melhaf_color":
+		return fmt.Sprintf("%smelhaf/colors/%s", appDeepLinkScheme, itemID)
+	default:
+		return fmt.Sprintf("%smaison-adrar/perfumes/%s?variant=%s", appDeepLinkScheme, parentID, itemID)
+	}
+}
+
+// setBackInStockStatus moves a subscription to its next state
+func (ns *NotificationScheduler) setBackInStockStatus(id uuid.UUID, status string) {
+	var notifiedAt sql.NullTime
+	if status == "notified" {
+		notifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	database.Database.Exec(
+		"UPDATE back_in_stock_subscriptions SET status = $1, notified_at = $2 WHERE id = $3",
+		status, notifiedAt, id,
+	)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"mime/multipart"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"time"
@@ -156,6 +157,20 @@ func (cs *CloudinaryService) GenerateTransformationURL(publicID string, width, h
     return forceHTTPS(cs.GetImageURL(publicID, transformations...))
 }
 
+// IsUploadedImage tells whether a URL is an image uploaded to this Cloudinary
+// account in the given folder
+func (cs *CloudinaryService) IsUploadedImage(rawURL, folder string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host != "res.cloudinary.com" || u.RawQuery != "" {
+		return false
+	}
+	cloudName := cs.cld.Config.Cloud.CloudName
+	if cloudName == "" || !strings.HasPrefix(u.Path, "/"+cloudName+"/image/upload/") || strings.Contains(u.Path, "..") {
+		return false
+	}
+	return strings.HasPrefix(ExtractPublicID(rawURL), folder+"/")
+}
+
 // Helper function to extract public ID from Cloudinary URL
 func ExtractPublicID(url string) string {
 	// Cloudinary URLs typically look like: https://res.cloudinary.com/account/image/upload/v1234567890/folder/filename.jpg
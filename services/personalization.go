@@ -0,0 +1,344 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fmbq-server/database"
+)
+
+const (
+	// affinityWindow is how far back a customer's activity shapes their profile
+	affinityWindow = "180 days"
+	// affinityHalfLife is how fast old activity fades from the profile
+	affinityHalfLife = 30 * 24 * time.Hour
+	// affinityMaxAge bounds how long a cached profile is served
+	affinityMaxAge = 10 * time.Minute
+	// affinityCacheSize is how many profiles are cached before expired ones are dropped
+	affinityCacheSize = 10000
+)
+
+// affinitySignals weighs what customers do with a product, strongest last
+var affinitySignals = map[string]float64{
+	"view":     1,
+	"cart":     3,
+	"wishlist": 4,
+	"order":    6,
+}
+
+// affinityReasons explains a pick by the product that led to it
+var affinityReasons = map[string]string{
+	"view":     "Because you viewed %s",
+	"cart":     "Because %s is in your cart",
+	"wishlist": "Because %s is on your wishlist",
+	"order":    "Because you bought %s",
+}
+
+// ProductTraits is what products are matched on
+type ProductTraits struct {
+	ProductID     string
+	Title         string
+	BrandID       string
+	CategoryIDs   []string // subcategories and their top categories
+	ColorFamilies []string
+	Price         float64 // lowest price today, 0 when unpriced
+}
+
+// AffinityAnchor is the product behind a preference, for the explanation
+type AffinityAnchor struct {
+	ProductID string `json:"product_id"`
+	Title     string `json:"title"`
+	Signal    string `json:"signal"` // view, cart, wishlist, order
+	weight    float64
+}
+
+// UserAffinity is a customer's taste, each preference scored from 0 to 1
+type UserAffinity struct {
+	Brands        map[string]float64
+	Categories    map[string]float64
+	ColorFamilies map[string]float64
+	PriceMin      float64 // price band of what they look at and buy; 0 when unknown
+	PriceMax      float64
+	anchors       map[string]*AffinityAnchor // "brand:<id>", "category:<id>", "color:<family>"
+	seen          map[string]bool            // products behind the profile
+}
+
+// Empty tells whether there is nothing to personalize with
+func (a *UserAffinity) Empty() bool {
+	return a == nil || len(a.seen) == 0
+}
+
+// AffinityMatch is how well a product fits a profile
+type AffinityMatch struct {
+	Score  float64         `json:"score"` // 0 to 1
+	Reason string          `json:"reason,omitempty"`
+	Anchor *AffinityAnchor `json:"anchor,omitempty"`
+}
+
+// Match scores a product against the profile: brand 40%, category 35%, color
+// family 15% and price band 10%. The reason names the product behind the strongest
+// of brand, category and color.
+func (a *UserAffinity) Match(p ProductTraits) AffinityMatch {
+	if a.Empty() {
+		return AffinityMatch{}
+	}
+	var match AffinityMatch
+	best := 0.0
+	consider := func(weight float64, key string, score float64) {
+		match.Score += weight * score
+		if contribution := weight * score; contribution > best {
+			if anchor := a.anchors[key]; anchor != nil && anchor.ProductID != p.ProductID {
+				best, match.Anchor = contribution, anchor
+			}
+		}
+	}
+
+	consider(0.40, "brand:"+p.BrandID, a.Brands[p.BrandID])
+	category, categoryKey := 0.0, ""
+	for _, id := range p.CategoryIDs {
+		if s := a.Categories[id]; s > category {
+			category, categoryKey = s, "category:"+id
+		}
+	}
+	consider(0.35, categoryKey, category)
+	color, colorKey := 0.0, ""
+	for _, family := range p.ColorFamilies {
+		if s := a.ColorFamilies[family]; s > color {
+			color, colorKey = s, "color:"+family
+		}
+	}
+	consider(0.15, colorKey, color)
+	if p.Price > 0 && a.PriceMax > 0 && p.Price >= a.PriceMin && p.Price <= a.PriceMax {
+		match.Score += 0.10
+	}
+
+	if match.Anchor != nil {
+		match.Reason = fmt.Sprintf(affinityReasons[match.Anchor.Signal], match.Anchor.Title)
+	}
+	match.Score = math.Round(match.Score*10000) / 10000
+	return match
+}
+
+type cachedAffinity struct {
+	affinity *UserAffinity
+	loadedAt time.Time
+}
+
+var affinityCache = struct {
+	sync.Mutex
+	profiles map[string]cachedAffinity
+}{profiles: map[string]cachedAffinity{}}
+
+// LoadUserAffinity returns the customer's profile, built from their product views,
+// wishlist, cart and orders over affinityWindow, recent activity weighing most
+func LoadUserAffinity(userID string) (*UserAffinity, error) {
+	affinityCache.Lock()
+	cached, ok := affinityCache.profiles[userID]
+	affinityCache.Unlock()
+	if ok && time.Since(cached.loadedAt) < affinityMaxAge {
+		return cached.affinity, nil
+	}
+
+	affinity, err := buildUserAffinity(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	affinityCache.Lock()
+	if len(affinityCache.profiles) >= affinityCacheSize {
+		for id, p := range affinityCache.profiles {
+			if time.Since(p.loadedAt) >= affinityMaxAge {
+				delete(affinityCache.profiles, id)
+			}
+		}
+	}
+	affinityCache.profiles[userID] = cachedAffinity{affinity: affinity, loadedAt: time.Now()}
+	affinityCache.Unlock()
+	return affinity, nil
+}
+
+func buildUserAffinity(userID string) (*UserAffinity, error) {
+	rows, err := database.Database.Query(`
+		SELECT product_id::text, signal, at FROM (
+			SELECT product_id, 'view' AS signal, view_timestamp AS at
+			FROM product_views
+			WHERE user_id::text = $1 AND view_timestamp > now() - $2::interval
+			UNION ALL
+			SELECT product_id, 'wishlist', created_at
+			FROM wishlist_items
+			WHERE user_id::text = $1
+			UNION ALL
+			SELECT s.product_model_id, 'cart', ci.added_at
+			FROM carts ct
+			JOIN cart_items ci ON ci.cart_id = ct.id
+			JOIN skus s ON s.id = ci.sku_id
+			WHERE ct.user_id::text = $1
+			UNION ALL
+			SELECT COALESCE(oi.product_id, s.product_model_id), 'order', o.created_at
+			FROM orders o
+			JOIN order_items oi ON oi.order_id = o.id
+			LEFT JOIN skus s ON oi.item_type = 'sku' AND s.id = oi.item_id
+			WHERE o.user_id::text = $1 AND o.created_at > now() - $2::interval
+			  AND o.status NOT IN ('cancelled', 'refunded')
+		) activity
+		WHERE product_id IS NOT NULL`, userID, affinityWindow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load customer activity: %w", err)
+	}
+
+	type productWeight struct {
+		weight float64
+		signal string  // strongest signal, for the explanation
+		top    float64 // its weight
+	}
+	weights := map[string]*productWeight{}
+	now := time.Now()
+	for rows.Next() {
+		var productID, signal string
+		var at time.Time
+		if err := rows.Scan(&productID, &signal, &at); err != nil {
+			continue
+		}
+		w := affinitySignals[signal] * math.Pow(0.5, now.Sub(at).Hours()/affinityHalfLife.Hours())
+		pw, ok := weights[productID]
+		if !ok {
+			pw = &productWeight{}
+			weights[productID] = pw
+		}
+		pw.weight += w
+		if w > pw.top {
+			pw.top, pw.signal = w, signal
+		}
+	}
+	rows.Close()
+
+	affinity := &UserAffinity{
+		Brands:        map[string]float64{},
+		Categories:    map[string]float64{},
+		ColorFamilies: map[string]float64{},
+		anchors:       map[string]*AffinityAnchor{},
+		seen:          map[string]bool{},
+	}
+	if len(weights) == 0 {
+		return affinity, nil
+	}
+
+	ids := make([]string, 0, len(weights))
+	for id := range weights {
+		ids = append(ids, id)
+	}
+	traits, err := LoadProductTraits(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	type pricePoint struct{ price, weight float64 }
+	var prices []pricePoint
+	add := func(scores map[string]float64, key, anchorKey string, t ProductTraits, pw *productWeight) {
+		if key == "" {
+			return
+		}
+		scores[key] += pw.weight
+		if anchor := affinity.anchors[anchorKey]; anchor == nil || pw.weight > anchor.weight {
+			affinity.anchors[anchorKey] = &AffinityAnchor{ProductID: t.ProductID, Title: t.Title, Signal: pw.signal, weight: pw.weight}
+		}
+	}
+	for id, t := range traits {
+		pw := weights[id]
+		affinity.seen[id] = true
+		add(affinity.Brands, t.BrandID, "brand:"+t.BrandID, t, pw)
+		for _, c := range t.CategoryIDs {
+			add(affinity.Categories, c, "category:"+c, t, pw)
+		}
+		for _, f := range t.ColorFamilies {
+			add(affinity.ColorFamilies, f, "color:"+f, t, pw)
+		}
+		if t.Price > 0 {
+			prices = append(prices, pricePoint{t.Price, pw.weight})
+		}
+	}
+	for _, scores := range []map[string]float64{affinity.Brands, affinity.Categories, affinity.ColorFamilies} {
+		normalizeAffinity(scores)
+	}
+
+	// Price band: around the weighted median of what they engaged with
+	if len(prices) > 0 {
+		sort.Slice(prices, func(i, j int) bool { return prices[i].price < prices[j].price })
+		total := 0.0
+		for _, p := range prices {
+			total += p.weight
+		}
+		median, running := prices[len(prices)-1].price, 0.0
+		for _, p := range prices {
+			if running += p.weight; running >= total/2 {
+				median = p.price
+				break
+			}
+		}
+		affinity.PriceMin, affinity.PriceMax = math.Round(median*0.6), math.Round(median*1.6)
+	}
+	return affinity, nil
+}
+
+// normalizeAffinity scales scores so the strongest preference is 1
+func normalizeAffinity(scores map[string]float64) {
+	max := 0.0
+	for _, s := range scores {
+		max = math.Max(max, s)
+	}
+	if max == 0 {
+		return
+	}
+	for k, s := range scores {
+		scores[k] = math.Round(s/max*10000) / 10000
+	}
+}
+
+// LoadProductTraits returns the brand, categories, color families and price of the
+// given product models
+func LoadProductTraits(productIDs []string) (map[string]ProductTraits, error) {
+	traits := map[string]ProductTraits{}
+	if len(productIDs) == 0 {
+		return traits, nil
+	}
+	rows, err := database.Database.Query(`
+		SELECT pm.id::text, pm.title, COALESCE(pm.brand_id::text, ''),
+		       COALESCE((SELECT string_agg(DISTINCT cat, ',') FROM (
+		           SELECT c.id::text AS cat FROM product_model_categories pmc
+		           JOIN categories c ON c.id = pmc.category_id WHERE pmc.product_model_id = pm.id
+		           UNION
+		           SELECT c.parent_id::text FROM product_model_categories pmc
+		           JOIN categories c ON c.id = pmc.category_id WHERE pmc.product_model_id = pm.id AND c.parent_id IS NOT NULL
+		       ) cats), ''),
+		       COALESCE((SELECT string_agg(DISTINCT pc.color_family, ',') FROM product_colors pc
+		                 WHERE pc.product_model_id = pm.id AND pc.color_family IS NOT NULL), ''),
+		       COALESCE((SELECT MIN(p.effective_price) FROM skus s
+		                 JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		                 WHERE s.product_model_id = pm.id), 0)
+		FROM product_models pm
+		WHERE pm.id = ANY(string_to_array($1, ',')::uuid[])`, strings.Join(productIDs, ","))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load product traits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t ProductTraits
+		var categories, colors string
+		if err := rows.Scan(&t.ProductID, &t.Title, &t.BrandID, &categories, &colors, &t.Price); err != nil {
+			continue
+		}
+		if categories != "" {
+			t.CategoryIDs = strings.Split(categories, ",")
+		}
+		if colors != "" {
+			t.ColorFamilies = strings.Split(colors, ",")
+		}
+		traits[t.ProductID] = t
+	}
+	return traits, rows.Err()
+}
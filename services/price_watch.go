@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"fmbq-server/database"
+	"github.com/google/uuid"
+)
+
+// priceDropThrottle is the minimum time between two price-drop pushes to the same user
+const priceDropThrottle = 24 * time.Hour
+
+// effectiveProductPriceSQL gives the lowest price a customer pays today for each
+// product model, from the prices active now (scheduled prices and sale events included)
+const effectiveProductPriceSQL = `
+	SELECT s.product_model_id, MIN(p.effective_price) AS price
+	FROM active_prices p
+	JOIN skus s ON s.id = p.sku_id
+	WHERE p.currency = 'MRO'
+	GROUP BY s.product_model_id`
+
+// ProcessWishlistPriceDrops compares wishlist price snapshots with current effective
+// prices and pushes one price-drop notification per user (the biggest drop), at most
+// once per priceDropThrottle. Snapshots are moved to the current price once notified,
+// and immediately when the price goes up, so each drop is announced only once.
+func (ns *NotificationScheduler) ProcessWishlistPriceDrops() error {
+	// Price increases: follow the price up silently so a later drop back is detected
+	if _, err := database.Database.Exec(`
+		UPDATE wishlist_items w
+		SET product_price = cp.price, updated_at = now()
+		FROM (` + effectiveProductPriceSQL + `) cp
+		WHERE cp.product_model_id = w.product_id AND cp.price > COALESCE(w.product_price, 0)`); err != nil {
+		return fmt.Errorf("failed to refresh wishlist price snapshots: %w", err)
+	}
+
+	rows, err := database.Database.Query(`
+		SELECT w.id, w.user_id, w.product_id, COALESCE(w.product_name, ''), w.product_price, cp.price,
+		       COALESCE(u.push_token, ''),
+		       EXISTS(SELECT 1 FROM price_drop_notifications pdn
+		              WHERE pdn.user_id = w.user_id AND pdn.sent_at > $1) AS throttled
+		FROM wishlist_items w
+		JOIN (`+effectiveProductPriceSQL+`) cp ON cp.product_model_id = w.product_id
+		JOIN users u ON u.id = w.user_id
+		WHERE cp.price > 0 AND w.product_price > 0 AND cp.price < w.product_price
+		ORDER BY w.user_id, (w.product_price - cp.price) / w.product_price DESC`, time.Now().Add(-priceDropThrottle))
+	if err != nil {
+		return fmt.Errorf("failed to fetch wishlist price drops: %w", err)
+	}
+
+	type priceDrop struct {
+		WishlistID  uuid.UUID
+		ProductID   uuid.UUID
+		ProductName string
+		OldPrice    float64
+		NewPrice    float64
+	}
+	type userDrops struct {
+		PushToken string
+		Drops     []priceDrop
+	}
+	byUser := map[uuid.UUID]*userDrops{}
+	var order []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		var d priceDrop
+		var pushToken string
+		var throttled bool
+		if err := rows.Scan(&d.WishlistID, &userID, &d.ProductID, &d.ProductName, &d.OldPrice, &d.NewPrice, &pushToken, &throttled); err != nil {
+			continue
+		}
+		if throttled {
+			// Keep the old snapshot so the drop is announced once the throttle window ends
+			continue
+		}
+		u, ok := byUser[userID]
+		if !ok {
+			u = &userDrops{PushToken: pushToken}
+			byUser[userID] = u
+			order = append(order, userID)
+		}
+		u.Drops = append(u.Drops, d)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, userID := range order {
+		u := byUser[userID]
+		best := u.Drops[0]
+
+		if u.PushToken != "" {
+			title := "Price drop on your wishlist! 💸"
+			body := fmt.Sprintf("%s is now %.0f MRU (was %.0f MRU)", best.ProductName, best.NewPrice, best.OldPrice)
+			if len(u.Drops) > 1 {
+				body += fmt.Sprintf(" — and %d more items on your wishlist dropped in price", len(u.Drops)-1)
+			}
+			data := map[string]interface{}{
+				"type":         "wishlist-price-drop",
+				"product_id":   best.ProductID.String(),
+				"product_name": best.ProductName,
+				"old_price":    best.OldPrice,
+				"new_price":    best.NewPrice,
+				"drops":        len(u.Drops),
+				"deep_link":    fmt.Sprintf("%sproduct/%s", appDeepLinkScheme, best.ProductID),
+				"timestamp":    time.Now().Unix(),
+			}
+			if err := ns.notificationService.SendPushNotification(u.PushToken, title, body, data); err != nil {
+				fmt.Printf("❌ Failed to send price-drop notification to user %s: %v\n", userID, err)
+				// Snapshots are left untouched so it is retried on the next run
+				continue
+			}
+			sent++
+		}
+
+		for _, d := range u.Drops {
+			database.Database.Exec(
+				"UPDATE wishlist_items SET product_price = $1, updated_at = now() WHERE id = $2",
+				d.NewPrice, d.WishlistID,
+			)
+		}
+		if u.PushToken != "" {
+			database.Database.Exec(`
+				INSERT INTO price_drop_notifications (id, user_id, product_id, old_price, new_price, sent_at)
+				VALUES (gen_random_uuid(), $1, $2, $3, $4, now())`,
+				userID, best.ProductID, best.OldPrice, best.NewPrice,
+			)
+		}
+	}
+
+	if sent > 0 {
+		fmt.Printf("✅ Sent %d wishlist price-drop notifications\n", sent)
+	}
+	return nil
+}
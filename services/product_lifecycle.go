@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"fmbq-server/database"
+)
+
+// RowQuerier is what the completeness check needs; *sql.DB, *sql.Tx and
+// database.Database all satisfy it
+type RowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ProductCompletenessIssues lists what keeps a product from being published: it
+// needs an image, a category, SKUs that all have a price today and some stock.
+// An empty list means the product can go live.
+func ProductCompletenessIssues(q RowQuerier, productID string) ([]string, error) {
+	var hasImage, hasCategory, hasSKU, allPriced, inStock bool
+	err := q.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM product_images WHERE product_model_id::text = $1),
+		       EXISTS(SELECT 1 FROM product_model_categories WHERE product_model_id::text = $1),
+		       EXISTS(SELECT 1 FROM skus WHERE product_model_id::text = $1),
+		       NOT EXISTS(SELECT 1 FROM skus s
+		                  LEFT JOIN active_prices p ON p.sku_id = s.id AND p.currency = 'MRO'
+		                  WHERE s.product_model_id::text = $1 AND COALESCE(p.effective_price, 0) <= 0),
+		       EXISTS(SELECT 1 FROM skus s JOIN inventory i ON i.sku_id = s.id
+		              WHERE s.product_model_id::text = $1 AND i.available > 0)`, productID).Scan(
+		&hasImage, &hasCategory, &hasSKU, &allPriced, &inStock)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []string{}
+	if !hasImage {
+		issues = append(issues, "missing_image")
+	}
+	if !hasCategory {
+		issues = append(issues, "missing_category")
+	}
+	if !hasSKU {
+		issues = append(issues, "missing_sku")
+	} else {
+		if !allPriced {
+			issues = append(issues, "missing_price")
+		}
+		if !inStock {
+			issues = append(issues, "out_of_stock")
+		}
+	}
+	return issues, nil
+}
+
+// ProductScheduler moves products through their scheduled go-live and take-down
+type ProductScheduler struct{}
+
+// NewProductScheduler creates a product scheduler
+func NewProductScheduler() *ProductScheduler {
+	return &ProductScheduler{}
+}
+
+// ProcessSchedule publishes scheduled products whose publish_at has passed and
+// archives products whose unpublish_at has passed. A product that is no longer
+// complete at go-live stays scheduled, with the reason in review_note, and is
+// retried on the next run.
+func (ps *ProductScheduler) ProcessSchedule() error {
+	archived, err := database.Database.Exec(`
+		UPDATE product_models SET status = 'archived', updated_at = now()
+		WHERE status IN ('published', 'scheduled') AND unpublish_at <= now()`)
+	if err != nil {
+		return fmt.Errorf("failed to archive expired products: %w", err)
+	}
+	if n, _ := archived.RowsAffected(); n > 0 {
+		log.Printf("🗄️ Archived %d products past their unpublish date", n)
+	}
+
+	rows, err := database.Database.Query(`
+		SELECT id, title FROM product_models
+		WHERE status = 'scheduled' AND publish_at <= now()`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch due products: %w", err)
+	}
+	type dueProduct struct{ ID, Title string }
+	var due []dueProduct
+	for rows.Next() {
+		var p dueProduct
+		if err := rows.Scan(&p.ID, &p.Title); err == nil {
+			due = append(due, p)
+		}
+	}
+	rows.Close()
+
+	for _, p := range due {
+		issues, err := ProductCompletenessIssues(database.Database, p.ID)
+		if err != nil {
+			log.Printf("⚠️ Failed to check product %s before publishing: %v", p.ID, err)
+			continue
+		}
+		if len(issues) > 0 {
+			if _, err := database.Database.Exec(`
+				UPDATE product_models SET review_note = $2
+				WHERE id::text = $1 AND review_note IS DISTINCT FROM $2`,
+				p.ID, "Scheduled publish on hold: "+strings.Join(issues, ", ")); err != nil {
+				log.Printf("⚠️ Failed to note why product %s is on hold: %v", p.ID, err)
+			}
+			continue
+		}
+		if _, err := database.Database.Exec(`
+			UPDATE product_models SET status = 'published', published_at = now(), review_note = NULL, updated_at = now()
+			WHERE id::text = $1 AND status = 'scheduled'`, p.ID); err != nil {
+			log.Printf("⚠️ Failed to publish product %s: %v", p.ID, err)
+			continue
+		}
+		log.Printf("🚀 Published scheduled product %s (%s)", p.Title, p.ID)
+	}
+	return nil
+}
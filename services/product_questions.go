@@ -0,0 +1,126 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"fmbq-server/database"
+)
+
+// questionNotifyRetryWindow is how long after its approval an answer whose push
+// failed keeps being retried
+const questionNotifyRetryWindow = 7 * 24 * time.Hour
+
+// NotifyQuestionAnswered pushes the asker of a question that an approved answer was
+// published. Each answer is announced at most once; answers to one's own question
+// are not. The answer is marked notified only once the push went out, so a failed
+// push is retried by RetryQuestionAnsweredNotifications.
+func NotifyQuestionAnswered(answerID string) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		fmt.Printf("❌ Failed to start question answered notification for answer %s: %v\n", answerID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the answer so a concurrent retry does not push it twice
+	var questionID, itemType, itemID, itemName, pushToken string
+	err = tx.QueryRow(`
+		SELECT q.id::text, q.item_type, q.item_id::text,
+		       CASE q.item_type
+		           WHEN 'product' THEN (SELECT title FROM product_models WHERE id = q.item_id)
+		           WHEN 'melhaf_collection' THEN (SELECT 'Melhaf ' || name FROM melhaf_collections WHERE id = q.item_id)
+		           ELSE (SELECT name FROM maison_adrar_perfumes WHERE id = q.item_id)
+		       END,
+		       COALESCE(u.push_token, '')
+		FROM product_answers a
+		JOIN product_questions q ON q.id = a.question_id
+		JOIN users u ON u.id = q.user_id
+		WHERE a.id::text = $1
+		  AND a.status = 'approved' AND a.notified_at IS NULL
+		  AND a.user_id IS DISTINCT FROM q.user_id
+		FOR UPDATE OF a SKIP LOCKED`, answerID).Scan(&questionID, &itemType, &itemID, &itemName, &pushToken)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		fmt.Printf("❌ Failed to prepare question answered notification for answer %s: %v\n", answerID, err)
+		return
+	}
+
+	if pushToken != "" {
+		data := map[string]interface{}{
+			"type":        "question-answered",
+			"question_id": questionID,
+			"item_type":   itemType,
+			"item_id":     itemID,
+			"deep_link":   questionDeepLink(itemType, itemID, questionID),
+			"timestamp":   time.Now().Unix(),
+		}
+		if err := NewNotificationService().SendPushNotification(
+			pushToken,
+			"Your question was answered 💬",
+			fmt.Sprintf("Someone answered your question about %s.", itemName),
+			data,
+		); err != nil {
+			fmt.Printf("❌ Failed to send question answered notification for answer %s: %v\n", answerID, err)
+			return
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE product_answers SET notified_at = now() WHERE id::text = $1`, answerID); err != nil {
+		fmt.Printf("❌ Failed to mark answer %s notified: %v\n", answerID, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		fmt.Printf("❌ Failed to mark answer %s notified: %v\n", answerID, err)
+	}
+}
+
+// RetryQuestionAnsweredNotifications pushes again the approved answers whose
+// notification has not gone out, for up to questionNotifyRetryWindow
+func (ns *NotificationScheduler) RetryQuestionAnsweredNotifications() error {
+	rows, err := database.Database.Query(`
+		SELECT a.id::text
+		FROM product_answers a
+		JOIN product_questions q ON q.id = a.question_id
+		WHERE a.status = 'approved' AND a.notified_at IS NULL
+		  AND a.user_id IS DISTINCT FROM q.user_id
+		  AND COALESCE(a.moderated_at, a.created_at) > $1
+		ORDER BY COALESCE(a.moderated_at, a.created_at)
+		LIMIT 500`, time.Now().Add(-questionNotifyRetryWindow))
+	if err != nil {
+		return fmt.Errorf("failed to fetch unsent question answered notifications: %w", err)
+	}
+	var answerIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read unsent question answered notification: %w", err)
+		}
+		answerIDs = append(answerIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch unsent question answered notifications: %w", err)
+	}
+
+	for _, id := range answerIDs {
+		NotifyQuestionAnswered(id)
+	}
+	return nil
+}
+
+// questionDeepLink builds the in-app link to a question on its item's page
+func questionDeepLink(itemType, itemID, questionID string) string {
+	switch itemType {
+	case "product":
+		return fmt.Sprintf("%sproduct/%s?question=%s", appDeepLinkScheme, itemID, questionID)
+	case "melhaf_collection":
+		return fmt.Sprintf("%smelhaf/collections/%s?question=%s", appDeepLinkScheme, itemID, questionID)
+	default:
+		return fmt.Sprintf("%smaison-adrar/perfumes/%s?question=%s", appDeepLinkScheme, itemID, questionID)
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fmbq-server/config"
+	"fmbq-server/database"
+)
+
+const (
+	// viewSessionWindow is how long repeat views of a product by the same visitor
+	// count as one
+	viewSessionWindow = 30 * time.Minute
+	// viewBatchSize is how many buffered views trigger a flush before the next tick
+	viewBatchSize = 500
+	// viewBufferMax bounds the views kept in memory while the database is unreachable
+	viewBufferMax = 50000
+	// viewRetentionBatch is how many raw views are anonymized or deleted per statement
+	viewRetentionBatch = 5000
+)
+
+// botUserAgents are user agent fragments of crawlers, monitors and scripts, in
+// lower case
+var botUserAgents = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "headless", "phantomjs",
+	"lighthouse", "pingdom", "uptime", "curl/", "wget", "python-requests", "python-urllib",
+	"go-http-client", "apache-httpclient", "scrapy", "postmanruntime",
+}
+
+// ProductViewEvent is a product view waiting to be written
+type ProductViewEvent struct {
+	ProductID          string    `json:"product_id"`
+	UserID             *string   `json:"user_id"`
+	AnonymousSessionID *string   `json:"anonymous_session_id"`
+	ViewedAt           time.Time `json:"viewed_at"`
+	IPAddress          string    `json:"ip_address"`
+	UserAgent          string    `json:"user_agent"`
+}
+
+// visitor identifies who viewed, for deduplication
+func (v ProductViewEvent) visitor() string {
+	if v.UserID != nil {
+		return "user:" + *v.UserID
+	}
+	if v.AnonymousSessionID != nil {
+		return "session:" + *v.AnonymousSessionID
+	}
+	return ""
+}
+
+var viewBuffer = struct {
+	sync.Mutex
+	pending []ProductViewEvent
+	seen    map[string]time.Time // visitor and product -> last recorded view
+	// counts since the last maintenance run, for the log
+	recorded, duplicates, bots int
+}{seen: map[string]time.Time{}}
+
+// IsBotUserAgent tells whether a user agent belongs to a crawler, monitor or script
+func IsBotUserAgent(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, marker := range botUserAgents {
+		if strings.Contains(ua, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnonymousViewSession returns the session of a guest: the one the client sends, or
+// else one derived from its IP address and user agent
+func AnonymousViewSession(clientSession, ipAddress, userAgent string) string {
+	if s := strings.TrimSpace(clientSession); s != "" {
+		if len(s) > 255 {
+			s = s[:255]
+		}
+		return s
+	}
+	sum := sha256.Sum256([]byte(ipAddress + "|" + userAgent))
+	return "anon-" + hex.EncodeToString(sum[:16])
+}
+
+// RecordProductView buffers a view for the next flush. Bots and repeat views
+// within viewSessionWindow are dropped; the reason is returned when it was.
+func RecordProductView(v ProductViewEvent) (bool, string) {
+	if IsBotUserAgent(v.UserAgent) {
+		viewBuffer.Lock()
+		viewBuffer.bots++
+		viewBuffer.Unlock()
+		return false, "bot"
+	}
+
+	key := v.visitor() + "|" + v.ProductID
+	viewBuffer.Lock()
+	if last, ok := viewBuffer.seen[key]; ok && v.ViewedAt.Sub(last) < viewSessionWindow {
+		viewBuffer.duplicates++
+		viewBuffer.Unlock()
+		return false, "duplicate"
+	}
+	viewBuffer.seen[key] = v.ViewedAt
+	viewBuffer.pending = append(viewBuffer.pending, v)
+	viewBuffer.recorded++
+	full := len(viewBuffer.pending) >= viewBatchSize
+	viewBuffer.Unlock()
+
+	if full {
+		go func() {
+			if err := FlushProductViews(); err != nil {
+				log.Printf("⚠️ Error flushing product views: %v", err)
+			}
+		}()
+	}
+	return true, ""
+}
+
+// FlushProductViews writes the buffered views in one statement, skipping unknown
+// products and keeping views of since deleted users without the user. On failure the views are put back for the next flush, up to
+// viewBufferMax. Views still buffered when the server stops are lost.
+func FlushProductViews() error {
+	viewBuffer.Lock()
+	batch := viewBuffer.pending
+	viewBuffer.pending = nil
+	// Forget views older than the session window
+	now := time.Now()
+	for key, last := range viewBuffer.seen {
+		if now.Sub(last) >= viewSessionWindow {
+			delete(viewBuffer.seen, key)
+		}
+	}
+	viewBuffer.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(batch)
+	if err == nil {
+		_, err = database.Database.Exec(`
+			INSERT INTO product_views (product_id, user_id, anonymous_session_id, view_timestamp, ip_address, user_agent)
+			SELECT v.product_id, u.id, v.anonymous_session_id, v.viewed_at,
+			       NULLIF(v.ip_address, '')::inet, NULLIF(v.user_agent, '')
+			FROM json_to_recordset($1::json) AS v(product_id uuid, user_id uuid, anonymous_session_id text,
+			                                      viewed_at timestamptz, ip_address text, user_agent text)
+			JOIN product_models pm ON pm.id = v.product_id
+			LEFT JOIN users u ON u.id = v.user_id
+			ON CONFLICT DO NOTHING`, string(payload))
+	}
+	if err != nil {
+		viewBuffer.Lock()
+		if len(viewBuffer.pending)+len(batch) <= viewBufferMax {
+			viewBuffer.pending = append(batch, viewBuffer.pending...)
+		} else {
+			log.Printf("⚠️ Dropped %d product views: buffer full", len(batch))
+		}
+		viewBuffer.Unlock()
+		return fmt.Errorf("failed to write %d product views: %w", len(batch), err)
+	}
+	return nil
+}
+
+// ProductViewJob maintains product view data: daily rollups and raw view retention
+type ProductViewJob struct{}
+
+// NewProductViewJob creates a product view job
+func NewProductViewJob() *ProductViewJob {
+	return &ProductViewJob{}
+}
+
+// Run rolls up the days with new views and applies the retention policy
+func (pj *ProductViewJob) Run() error {
+	viewBuffer.Lock()
+	recorded, duplicates, bots := viewBuffer.recorded, viewBuffer.duplicates, viewBuffer.bots
+	viewBuffer.recorded, viewBuffer.duplicates, viewBuffer.bots = 0, 0, 0
+	viewBuffer.Unlock()
+	if recorded+duplicates+bots > 0 {
+		log.Printf("👁️ Product views: %d recorded, %d repeat views and %d bot views dropped", recorded, duplicates, bots)
+	}
+
+	if err := pj.rollUp(); err != nil {
+		return err
+	}
+	return pj.applyRetention()
+}
+
+// rollUp recounts product_view_daily for every day that got views written since
+// the previous run, including days a late flush wrote into
+func (pj *ProductViewJob) rollUp() error {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start view rollup: %w", err)
+	}
+	defer tx.Rollback()
+
+	var since time.Time
+	err = tx.QueryRow(`SELECT processed_until FROM recommendation_watermarks WHERE signal = 'view_rollup' FOR UPDATE`).Scan(&since)
+	if err == sql.ErrNoRows {
+		// First run: roll up the whole history
+		since = time.Unix(0, 0)
+	} else if err != nil {
+		return fmt.Errorf("failed to read view rollup watermark: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		WITH days AS (
+			SELECT DISTINCT view_timestamp::date AS day FROM product_views WHERE created_at >= $1
+		)
+		INSERT INTO product_view_daily (product_id, day, views, visitors, updated_at)
+		SELECT product_id, view_timestamp::date, COUNT(*),
+		       COUNT(DISTINCT COALESCE(user_id::text, anonymous_session_id, id::text)), now()
+		FROM product_views
+		WHERE view_timestamp >= (SELECT MIN(day) FROM days)
+		  AND view_timestamp::date IN (SELECT day FROM days)
+		GROUP BY product_id, view_timestamp::date
+		ON CONFLICT (product_id, day) DO UPDATE
+		SET views = EXCLUDED.views, visitors = EXCLUDED.visitors, updated_at = now()
+		WHERE product_view_daily.views <> EXCLUDED.views OR product_view_daily.visitors <> EXCLUDED.visitors`, since)
+	if err != nil {
+		return fmt.Errorf("failed to roll up product views: %w", err)
+	}
+	// Stay behind so views still being written are picked up by the next run
+	if _, err := tx.Exec(`
+		INSERT INTO recommendation_watermarks (signal, processed_until) VALUES ('view_rollup', $1)
+		ON CONFLICT (signal) DO UPDATE SET processed_until = EXCLUDED.processed_until`, time.Now().Add(-recommendationLag)); err != nil {
+		return fmt.Errorf("failed to move view rollup watermark: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit view rollup: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("👁️ Updated %d daily product view rollups", n)
+	}
+	return nil
+}
+
+// applyRetention strips the IP address, user agent and session of raw views older
+// than VIEW_ANONYMIZE_DAYS and deletes those older than VIEW_RETENTION_DAYS, in
+// batches so inserts are not held up
+func (pj *ProductViewJob) applyRetention() error {
+	anonymizeDays := retentionSetting(config.AppConfig.ViewAnonymizeDays, 30)
+	retentionDays := retentionSetting(config.AppConfig.ViewRetentionDays, 365)
+
+	for _, step := range []struct {
+		name  string
+		query string
+		days  int
+	}{
+		{"anonymize", `
+			UPDATE product_views SET ip_address = NULL, user_agent = NULL, anonymous_session_id = NULL
+			WHERE id IN (
+				SELECT id FROM product_views
+				WHERE view_timestamp < now() - make_interval(days => $1)
+				  AND (ip_address IS NOT NULL OR user_agent IS NOT NULL OR anonymous_session_id IS NOT NULL)
+				LIMIT $2
+			)`, anonymizeDays},
+		{"delete", `
+			DELETE FROM product_views
+			WHERE id IN (
+				SELECT id FROM product_views
+				WHERE view_timestamp < now() - make_interval(days => $1)
+				LIMIT $2
+			)`, retentionDays},
+	} {
+		total := int64(0)
+		for {
+			res, err := database.Database.Exec(step.query, step.days, viewRetentionBatch)
+			if err != nil {
+				return fmt.Errorf("failed to %s old product views: %w", step.name, err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+			if n < viewRetentionBatch {
+				break
+			}
+		}
+		if total > 0 {
+			log.Printf("👁️ Applied %s retention to %d product views", step.name, total)
+		}
+	}
+	return nil
+}
+
+// retentionSetting reads a number of days from the configuration
+func retentionSetting(value string, fallback int) int {
+	if days, err := strconv.Atoi(value); err == nil && days > 0 {
+		return days
+	}
+	return fallback
+}
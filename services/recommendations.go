@@ -0,0 +1,267 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"fmbq-server/database"
+)
+
+const (
+	// recommendationLag keeps the job behind the clock so orders and views still
+	// being written are picked up by the next run rather than skipped
+	recommendationLag = 5 * time.Minute
+	// recommendationSessionGap is how close two views by the same visitor must be to
+	// count as one browsing session
+	recommendationSessionGap = "1 hour"
+	// recommendationsPerProduct is how many related products are kept per kind
+	recommendationsPerProduct = 30
+	// categoryRefreshAge is how long category fallbacks are kept before being rebuilt
+	categoryRefreshAge = "1 day"
+)
+
+// recommendationSignals are the co-occurrence signals and what they feed
+var recommendationSignals = []struct {
+	Signal   string // product_co_occurrences.signal
+	Kind     string // product_recommendations.kind
+	Source   string // product_recommendations.source
+	MinCount int    // co-occurrences below this are treated as noise
+}{
+	{"purchase", "bought_together", "co_purchase", 1},
+	{"view", "viewed_together", "co_view", 2},
+}
+
+// purchaseCoOccurrenceSQL counts the product models bought together in the web and
+// POS orders placed in ($1, $2], bundles included; cancelled and refunded orders
+// are left out
+const purchaseCoOccurrenceSQL = `
+	WITH new_orders AS (
+		SELECT id FROM orders
+		WHERE created_at > $1 AND created_at <= $2 AND status NOT IN ('cancelled', 'refunded')
+	),
+	bought AS (
+		SELECT oi.order_id, COALESCE(oi.product_id, s.product_model_id) AS product_id
+		FROM order_items oi
+		JOIN new_orders o ON o.id = oi.order_id
+		LEFT JOIN skus s ON oi.item_type = 'sku' AND s.id = oi.item_id
+		UNION
+		SELECT oi.order_id, s.product_model_id
+		FROM order_items oi
+		JOIN new_orders o ON o.id = oi.order_id
+		JOIN order_item_components oic ON oic.order_item_id = oi.id AND oic.item_type = 'sku'
+		JOIN skus s ON s.id = oic.item_id
+	)
+	SELECT a.product_id, b.product_id, COUNT(*)
+	FROM bought a
+	JOIN bought b ON b.order_id = a.order_id
+	WHERE a.product_id IS NOT NULL AND b.product_id IS NOT NULL
+	GROUP BY a.product_id, b.product_id`
+
+// viewCoOccurrenceSQL counts the product models viewed in the same session as the
+// views written in ($1, $2], read by created_at as buffered views can be written
+// late. Each new view pairs with the visitor's views within the session gap either
+// side that were written before it, so a pair is counted once, by the run that
+// sees the later-written view; a visitor counts once per pair and per product in a
+// run.
+const viewCoOccurrenceSQL = `
+	WITH new_views AS (
+		SELECT id, product_id, user_id, anonymous_session_id, view_timestamp, created_at,
+		       COALESCE(user_id::text, anonymous_session_id) AS visitor
+		FROM product_views
+		WHERE created_at > $1 AND created_at <= $2
+		  AND (user_id IS NOT NULL OR anonymous_session_id IS NOT NULL)
+	),
+	earlier AS (
+		SELECT nv.visitor, nv.product_id, pv.product_id AS related_id
+		FROM new_views nv
+		JOIN product_views pv ON pv.user_id = nv.user_id
+		WHERE (pv.created_at, pv.view_timestamp, pv.id) < (nv.created_at, nv.view_timestamp, nv.id)
+		  AND pv.view_timestamp BETWEEN nv.view_timestamp - $3::interval AND nv.view_timestamp + $3::interval
+		UNION
+		SELECT nv.visitor, nv.product_id, pv.product_id
+		FROM new_views nv
+		JOIN product_views pv ON pv.anonymous_session_id = nv.anonymous_session_id
+		WHERE nv.user_id IS NULL AND pv.user_id IS NULL
+		  AND (pv.created_at, pv.view_timestamp, pv.id) < (nv.created_at, nv.view_timestamp, nv.id)
+		  AND pv.view_timestamp BETWEEN nv.view_timestamp - $3::interval AND nv.view_timestamp + $3::interval
+	),
+	pairs AS (
+		SELECT DISTINCT visitor, product_id, related_id FROM earlier WHERE related_id <> product_id
+		UNION
+		SELECT DISTINCT visitor, related_id, product_id FROM earlier WHERE related_id <> product_id
+		UNION
+		SELECT DISTINCT visitor, product_id, product_id FROM new_views
+	)
+	SELECT product_id, related_id, COUNT(*)
+	FROM pairs
+	GROUP BY product_id, related_id`
+
+// RecommendationJob turns orders and product views into item-to-item
+// recommendations. Each run reads only what arrived since the previous one and
+// rescores the products it touched.
+type RecommendationJob struct{}
+
+// NewRecommendationJob creates a recommendation job
+func NewRecommendationJob() *RecommendationJob {
+	return &RecommendationJob{}
+}
+
+// Refresh folds new orders and views into the co-occurrence counts, rescores the
+// products involved and gives products without history category-based picks.
+func (rj *RecommendationJob) Refresh() error {
+	until := time.Now().Add(-recommendationLag)
+	for _, s := range recommendationSignals {
+		var query string
+		var args []interface{}
+		switch s.Signal {
+		case "purchase":
+			query = purchaseCoOccurrenceSQL
+		case "view":
+			query = viewCoOccurrenceSQL
+			args = []interface{}{recommendationSessionGap}
+		}
+		touched, err := rj.refreshSignal(s.Signal, s.Kind, s.Source, s.MinCount, query, until, args...)
+		if err != nil {
+			return err
+		}
+		if touched > 0 {
+			log.Printf("🧲 Rescored %s recommendations of %d products", s.Kind, touched)
+		}
+		if err := rj.fillFromCategories(s.Kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshSignal adds the co-occurrences since the signal's watermark and rescores
+// the products they touched, in one transaction with the watermark move
+func (rj *RecommendationJob) refreshSignal(signal, kind, source string, minCount int, coOccurrenceSQL string, until time.Time, extra ...interface{}) (int, error) {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start %s recommendation refresh: %w", signal, err)
+	}
+	defer tx.Rollback()
+
+	var since time.Time
+	err = tx.QueryRow(`SELECT processed_until FROM recommendation_watermarks WHERE signal = $1 FOR UPDATE`, signal).Scan(&since)
+	if err == sql.ErrNoRows {
+		// First run: read the whole history
+		since = time.Unix(0, 0)
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to read %s watermark: %w", signal, err)
+	}
+	if !until.After(since) {
+		return 0, nil
+	}
+
+	args := append([]interface{}{since, until}, extra...)
+	args = append(args, signal)
+	if _, err := tx.Exec(`
+		INSERT INTO product_co_occurrences (product_id, related_id, signal, count, updated_at)
+		SELECT pairs.product_id, pairs.related_id, $`+strconv.Itoa(len(args))+`, pairs.count, now()
+		FROM (`+coOccurrenceSQL+`) pairs(product_id, related_id, count)
+		ON CONFLICT (product_id, signal, related_id) DO UPDATE
+		SET count = product_co_occurrences.count + EXCLUDED.count, updated_at = now()`, args...); err != nil {
+		return 0, fmt.Errorf("failed to count %s co-occurrences: %w", signal, err)
+	}
+
+	// Products whose counts moved in this transaction (now() is its start time).
+	// Scores are cosine similarities: co-occurrences over the geometric mean of
+	// each product's own count.
+	res, err := tx.Exec(`
+		CREATE TEMP TABLE touched_products ON COMMIT DROP AS
+		SELECT DISTINCT product_id FROM product_co_occurrences WHERE signal = $1 AND updated_at = now()`, signal)
+	if err != nil {
+		return 0, fmt.Errorf("failed to collect %s rescoring: %w", signal, err)
+	}
+	touched, _ := res.RowsAffected()
+	if _, err := tx.Exec(`
+		DELETE FROM product_recommendations r USING touched_products t
+		WHERE r.product_id = t.product_id AND r.kind = $1`, kind); err != nil {
+		return 0, fmt.Errorf("failed to clear %s recommendations: %w", kind, err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO product_recommendations (product_id, kind, related_id, score, co_count, source, rank, updated_at)
+		SELECT product_id, $2, related_id, score, count, $3, rank, now()
+		FROM (
+			SELECT co.product_id, co.related_id, co.count,
+			       ROUND(co.count / sqrt(self.count::numeric * other.count), 6) AS score,
+			       ROW_NUMBER() OVER (PARTITION BY co.product_id
+			                          ORDER BY co.count / sqrt(self.count::numeric * other.count) DESC, co.count DESC) AS rank
+			FROM product_co_occurrences co
+			JOIN touched_products t ON t.product_id = co.product_id
+			JOIN product_co_occurrences self
+			  ON self.product_id = co.product_id AND self.related_id = co.product_id AND self.signal = co.signal
+			JOIN product_co_occurrences other
+			  ON other.product_id = co.related_id AND other.related_id = co.related_id AND other.signal = co.signal
+			WHERE co.signal = $1 AND co.related_id <> co.product_id AND co.count >= $4
+		) scored
+		WHERE rank <= $5`, signal, kind, source, minCount, recommendationsPerProduct); err != nil {
+		return 0, fmt.Errorf("failed to score %s recommendations: %w", kind, err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO recommendation_watermarks (signal, processed_until) VALUES ($1, $2)
+		ON CONFLICT (signal) DO UPDATE SET processed_until = EXCLUDED.processed_until`, signal, until); err != nil {
+		return 0, fmt.Errorf("failed to move %s watermark: %w", signal, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit %s recommendation refresh: %w", signal, err)
+	}
+	return int(touched), nil
+}
+
+// fillFromCategories gives published products without recommendations of a kind
+// the products sharing their categories: same subcategory first, then same top
+// category, with a bonus for the same brand. These picks make way for real ones as
+// soon as the product has history, and are rebuilt daily.
+func (rj *RecommendationJob) fillFromCategories(kind string) error {
+	tx, err := database.Database.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start %s category fallback: %w", kind, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		DELETE FROM product_recommendations
+		WHERE kind = $1 AND source = 'category' AND updated_at < now() - $2::interval`, kind, categoryRefreshAge); err != nil {
+		return fmt.Errorf("failed to expire %s category fallback: %w", kind, err)
+	}
+	if _, err := tx.Exec(`
+		WITH cold AS (
+			SELECT pm.id, pm.brand_id FROM product_models pm
+			WHERE pm.is_active = true
+			  AND NOT EXISTS (SELECT 1 FROM product_recommendations r WHERE r.product_id = pm.id AND r.kind = $1)
+		),
+		cats AS (
+			SELECT pmc.product_model_id, c.id, COALESCE(c.parent_id, c.id) AS level1_id
+			FROM product_model_categories pmc
+			JOIN categories c ON c.id = pmc.category_id
+		),
+		scored AS (
+			SELECT cold.id AS product_id, pm.id AS related_id,
+			       MAX(CASE WHEN a.id = b.id THEN 1.0 ELSE 0.5 END)
+			         + CASE WHEN pm.brand_id = cold.brand_id THEN 0.25 ELSE 0 END AS score,
+			       pm.created_at
+			FROM cold
+			JOIN cats a ON a.product_model_id = cold.id
+			JOIN cats b ON b.level1_id = a.level1_id AND b.product_model_id <> cold.id
+			JOIN product_models pm ON pm.id = b.product_model_id AND pm.is_active = true
+			GROUP BY cold.id, cold.brand_id, pm.id, pm.brand_id, pm.created_at
+		)
+		INSERT INTO product_recommendations (product_id, kind, related_id, score, co_count, source, rank, updated_at)
+		SELECT product_id, $1, related_id, score, 0, 'category', rank, now()
+		FROM (
+			SELECT product_id, related_id, score,
+			       ROW_NUMBER() OVER (PARTITION BY product_id ORDER BY score DESC, created_at DESC) AS rank
+			FROM scored
+		) ranked
+		WHERE rank <= $2`, kind, recommendationsPerProduct); err != nil {
+		return fmt.Errorf("failed to fill %s category fallback: %w", kind, err)
+	}
+	return tx.Commit()
+}
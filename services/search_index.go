@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"fmbq-server/database"
+)
+
+// searchPopularityWindow is how far back views and sales count towards popularity
+const searchPopularityWindow = "30 days"
+
+// SearchIndexer keeps the search index in shape. Documents themselves follow the
+// catalog through database triggers; popularity is recomputed periodically.
+type SearchIndexer struct{}
+
+// NewSearchIndexer creates a search indexer
+func NewSearchIndexer() *SearchIndexer {
+	return &SearchIndexer{}
+}
+
+// RefreshPopularity scores every document from recent activity: one point per
+// product view and five per unit sold, web and POS, bundle components included,
+// cancelled and refunded orders left out. The score is stored as ln(1 + points) so
+// a best seller lifts relevance without drowning it.
+func (si *SearchIndexer) RefreshPopularity() error {
+	res, err := database.Database.Exec(`
+		WITH recent_sales AS (
+			SELECT oi.item_type, oi.item_id, COALESCE(oi.product_id, s.product_model_id) AS product_id, oi.quantity
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			LEFT JOIN skus s ON oi.item_type = 'sku' AND s.id = oi.item_id
+			WHERE o.created_at > now() - $1::interval AND o.status NOT IN ('cancelled', 'refunded')
+			UNION ALL
+			SELECT oic.item_type, oic.item_id, s.product_model_id, oic.quantity
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			JOIN order_item_components oic ON oic.order_item_id = oi.id
+			LEFT JOIN skus s ON oic.item_type = 'sku' AND s.id = oic.item_id
+			WHERE o.created_at > now() - $1::interval AND o.status NOT IN ('cancelled', 'refunded')
+		),
+		signals AS (
+			SELECT 'product' AS doc_type, product_id AS doc_id, SUM(views)::numeric AS points
+			FROM product_view_daily WHERE day > (now() - $1::interval)::date
+			GROUP BY product_id
+			UNION ALL
+			SELECT 'product', product_id, 5 * SUM(quantity)
+			FROM recent_sales WHERE product_id IS NOT NULL
+			GROUP BY product_id
+			UNION ALL
+			SELECT 'melhaf_color', item_id, 5 * SUM(quantity)
+			FROM recent_sales WHERE item_type = 'melhaf_color'
+			GROUP BY item_id
+			UNION ALL
+			SELECT 'melhaf_collection', mc.collection_id, 5 * SUM(rs.quantity)
+			FROM recent_sales rs JOIN melhaf_colors mc ON mc.id = rs.item_id
+			WHERE rs.item_type = 'melhaf_color'
+			GROUP BY mc.collection_id
+			UNION ALL
+			SELECT 'perfume', pc.perfume_id, 5 * SUM(rs.quantity)
+			FROM recent_sales rs JOIN maison_adrar_perfume_colors pc ON pc.id = rs.item_id
+			WHERE rs.item_type = 'perfume_variant'
+			GROUP BY pc.perfume_id
+		),
+		scores AS (
+			SELECT sd.doc_type, sd.doc_id, ROUND(ln(1 + COALESCE(SUM(s.points), 0)), 4) AS popularity
+			FROM search_documents sd
+			LEFT JOIN signals s ON s.doc_type = sd.doc_type AND s.doc_id = sd.doc_id
+			GROUP BY sd.doc_type, sd.doc_id
+		)
+		UPDATE search_documents sd SET popularity = sc.popularity
+		FROM scores sc
+		WHERE sc.doc_type = sd.doc_type AND sc.doc_id = sd.doc_id
+		  AND sd.popularity IS DISTINCT FROM sc.popularity`, searchPopularityWindow)
+	if err != nil {
+		return fmt.Errorf("failed to refresh search popularity: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("🔎 Updated search popularity of %d documents", n)
+	}
+	return nil
+}
+
+// Reindex rebuilds every search document from the catalog, for when the indexing
+// rules change; day-to-day edits are indexed by the triggers.
+func (si *SearchIndexer) Reindex() (int, error) {
+	total := 0
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM (SELECT refresh_search_document('product', id) FROM product_models) r`,
+		`SELECT COUNT(*) FROM (SELECT refresh_search_document('melhaf_collection', id) FROM melhaf_collections) r`,
+		`SELECT COUNT(*) FROM (SELECT refresh_search_document('melhaf_color', id) FROM melhaf_colors) r`,
+		`SELECT COUNT(*) FROM (SELECT refresh_search_document('perfume', id) FROM maison_adrar_perfumes) r`,
+	} {
+		var n int
+		if err := database.Database.QueryRow(q).Scan(&n); err != nil {
+			return total, fmt.Errorf("failed to reindex search documents: %w", err)
+		}
+		total += n
+	}
+	return total, nil
+}
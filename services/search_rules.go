@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fmbq-server/database"
+)
+
+// searchRulesMaxAge bounds how long another instance's rule edits take to apply
+const searchRulesMaxAge = time.Minute
+
+// SearchTermKey is the form search rule terms are matched on: normalized words
+// separated by single spaces
+func SearchTermKey(s string) string {
+	return strings.Join(searchTokens(NormalizeSearchText(s)), " ")
+}
+
+// SearchRedirect is where a redirect rule sends a query
+type SearchRedirect struct {
+	RuleID     string `json:"rule_id"`
+	TargetType string `json:"target_type"` // category, brand, product, melhaf_collection, perfume, url
+	Target     string `json:"target"`
+}
+
+// SearchRewrite is a query after the search rules
+type SearchRewrite struct {
+	Query     string          // text to search
+	Rewritten bool            // synonyms changed the text
+	Redirect  *SearchRedirect // the query should land on this instead of results
+}
+
+type searchSynonym struct {
+	tokens      []string
+	replacement string
+}
+
+type searchRuleSet struct {
+	mu        sync.Mutex
+	synonyms  []searchSynonym // longest term first
+	redirects map[string]*SearchRedirect
+	loadedAt  time.Time
+}
+
+var searchRules = &searchRuleSet{}
+
+// InvalidateSearchRules makes the next search reload the rules, after an edit
+func InvalidateSearchRules() {
+	searchRules.mu.Lock()
+	searchRules.loadedAt = time.Time{}
+	searchRules.mu.Unlock()
+}
+
+func (rs *searchRuleSet) current() ([]searchSynonym, map[string]*SearchRedirect) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if time.Since(rs.loadedAt) < searchRulesMaxAge {
+		return rs.synonyms, rs.redirects
+	}
+	synonyms, redirects, err := loadSearchRules()
+	if err != nil {
+		// Keep searching with the rules we have; retry on the next search
+		log.Printf("⚠️ %v", err)
+		return rs.synonyms, rs.redirects
+	}
+	rs.synonyms, rs.redirects, rs.loadedAt = synonyms, redirects, time.Now()
+	return rs.synonyms, rs.redirects
+}
+
+func loadSearchRules() ([]searchSynonym, map[string]*SearchRedirect, error) {
+	rows, err := database.Database.Query(`
+		SELECT id::text, kind, normalized_term, COALESCE(replacement, ''), COALESCE(target_type, ''), COALESCE(target, '')
+		FROM search_rules
+		WHERE is_active = true`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load search rules: %w", err)
+	}
+	defer rows.Close()
+
+	var synonyms []searchSynonym
+	redirects := map[string]*SearchRedirect{}
+	for rows.Next() {
+		var id, kind, term, replacement, targetType, target string
+		if err := rows.Scan(&id, &kind, &term, &replacement, &targetType, &target); err != nil {
+			continue
+		}
+		switch kind {
+		case "synonym":
+			if tokens := strings.Fields(term); len(tokens) > 0 && replacement != "" {
+				synonyms = append(synonyms, searchSynonym{tokens: tokens, replacement: replacement})
+			}
+		case "redirect":
+			redirects[term] = &SearchRedirect{RuleID: id, TargetType: targetType, Target: target}
+		}
+	}
+	sort.SliceStable(synonyms, func(a, b int) bool { return len(synonyms[a].tokens) > len(synonyms[b].tokens) })
+	return synonyms, redirects, rows.Err()
+}
+
+// RewriteSearch applies the search rules to a query. A redirect applies when the
+// whole query, before or after synonyms, is its term. Synonyms replace their term
+// wherever its words appear in a row, longest terms first; a query they changed is
+// searched as normalized words.
+func RewriteSearch(query string) SearchRewrite {
+	rewrite := SearchRewrite{Query: query}
+	tokens := searchTokens(NormalizeSearchText(query))
+	if len(tokens) == 0 {
+		return rewrite
+	}
+	synonyms, redirects := searchRules.current()
+	if r, ok := redirects[strings.Join(tokens, " ")]; ok {
+		rewrite.Redirect = r
+		return rewrite
+	}
+
+	var out []string
+	for i := 0; i < len(tokens); {
+		matched := false
+		for _, s := range synonyms {
+			if i+len(s.tokens) <= len(tokens) && equalTokens(tokens[i:i+len(s.tokens)], s.tokens) {
+				out = append(out, s.replacement)
+				i += len(s.tokens)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, tokens[i])
+			i++
+		}
+	}
+	if !equalTokens(out, tokens) {
+		rewrite.Query = strings.Join(out, " ")
+		rewrite.Rewritten = true
+		if r, ok := redirects[SearchTermKey(rewrite.Query)]; ok {
+			rewrite.Redirect = r
+		}
+	}
+	return rewrite
+}
+
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
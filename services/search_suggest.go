@@ -0,0 +1,298 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"fmbq-server/database"
+)
+
+const (
+	// suggestMaxPrefix is the longest prefix indexed; longer words are matched on it
+	suggestMaxPrefix = 20
+	// suggestPostingLimit is how many entries, best first, a prefix keeps
+	suggestPostingLimit = 100
+	// suggestMaxAge forces a rebuild so popular queries stay current
+	suggestMaxAge = time.Hour
+)
+
+// searchFoldFrom and searchFoldTo mirror the search_normalize SQL function: French
+// accents and Arabic letter variants fold to their base letter
+const (
+	searchFoldFrom = "àâäáãåçéèêëíìîïñóòôöõúùûüýÿأإآٱىةؤئ"
+	searchFoldTo   = "aaaaaaceeeeiiiinooooouuuuyyاااايهوي"
+)
+
+var searchFoldRunes = func() map[rune]rune {
+	from, to := []rune(searchFoldFrom), []rune(searchFoldTo)
+	m := make(map[rune]rune, len(from))
+	for i, r := range from {
+		m[r] = to[i]
+	}
+	return m
+}()
+
+// NormalizeSearchText folds text the way the search index does: lower case, no
+// French accents, one form of alef/ya/ta marbuta and no Arabic diacritics or tatweel
+func NormalizeSearchText(s string) string {
+	s = strings.ReplaceAll(strings.ToLower(s), "œ", "oe")
+	return strings.Map(func(r rune) rune {
+		if (r >= 0x064B && r <= 0x0652) || r == 0x0670 || r == 0x0640 {
+			return -1
+		}
+		if f, ok := searchFoldRunes[r]; ok {
+			return f
+		}
+		return r
+	}, s)
+}
+
+// searchTokens splits normalized text into words, in any script
+func searchTokens(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Suggestion is one autocomplete entry
+type Suggestion struct {
+	Type  string  `json:"type"` // query, product, brand, category, melhaf_collection, perfume
+	ID    string  `json:"id,omitempty"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+type suggestEntry struct {
+	Suggestion
+	normalized string
+	tokens     []string
+}
+
+// suggestIndex maps every word prefix of the catalog's names to the entries that
+// contain it, so a keystroke is answered without touching the database
+type suggestIndex struct {
+	mu          sync.RWMutex
+	entries     []suggestEntry
+	prefixes    map[string][]int
+	fingerprint string
+	builtAt     time.Time
+}
+
+var suggestions = &suggestIndex{}
+
+// suggestFingerprintSQL changes whenever something the index is built from changes;
+// catalog edits reach search_documents through its triggers
+const suggestFingerprintSQL = `
+	SELECT concat_ws('|',
+		(SELECT COUNT(*) || ':' || COALESCE(MAX(updated_at)::text, '') FROM search_documents),
+		(SELECT COUNT(*) || ':' || COALESCE(MAX(updated_at)::text, '') FROM categories),
+		(SELECT md5(COALESCE(string_agg(id::text || name, ',' ORDER BY id), '')) FROM brands))`
+
+// RefreshSuggestIndex rebuilds the autocomplete index when the catalog changed
+// since the last build, or when the last build is older than suggestMaxAge
+func RefreshSuggestIndex() error {
+	var fingerprint string
+	if err := database.Database.QueryRow(suggestFingerprintSQL).Scan(&fingerprint); err != nil {
+		return fmt.Errorf("failed to check suggestion index: %w", err)
+	}
+	suggestions.mu.RLock()
+	fresh := fingerprint == suggestions.fingerprint && time.Since(suggestions.builtAt) < suggestMaxAge
+	suggestions.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	entries, err := loadSuggestEntries()
+	if err != nil {
+		return err
+	}
+	prefixes := map[string][]int{}
+	for i, e := range entries {
+		seen := map[string]bool{}
+		for _, token := range e.tokens {
+			runes := []rune(token)
+			for n := 1; n <= len(runes) && n <= suggestMaxPrefix; n++ {
+				prefix := string(runes[:n])
+				if !seen[prefix] {
+					seen[prefix] = true
+					prefixes[prefix] = append(prefixes[prefix], i)
+				}
+			}
+		}
+	}
+	for prefix, ids := range prefixes {
+		sort.SliceStable(ids, func(a, b int) bool { return entries[ids[a]].Score > entries[ids[b]].Score })
+		if len(ids) > suggestPostingLimit {
+			prefixes[prefix] = ids[:suggestPostingLimit]
+		}
+	}
+
+	suggestions.mu.Lock()
+	suggestions.entries = entries
+	suggestions.prefixes = prefixes
+	suggestions.fingerprint = fingerprint
+	suggestions.builtAt = time.Now()
+	suggestions.mu.Unlock()
+	log.Printf("🔤 Suggestion index built: %d entries, %d prefixes", len(entries), len(prefixes))
+	return nil
+}
+
+func loadSuggestEntries() ([]suggestEntry, error) {
+	var entries []suggestEntry
+	names := map[string]bool{}
+	add := func(kind, id, text string, score float64) {
+		normalized := NormalizeSearchText(strings.TrimSpace(text))
+		tokens := searchTokens(normalized)
+		if len(tokens) == 0 {
+			return
+		}
+		entries = append(entries, suggestEntry{
+			Suggestion: Suggestion{Type: kind, ID: id, Text: strings.TrimSpace(text), Score: math.Round(score*1000) / 1000},
+			normalized: strings.Join(tokens, " "),
+			tokens:     tokens,
+		})
+		names[strings.Join(tokens, " ")] = true
+	}
+
+	sources := []struct {
+		kind  string
+		query string
+		score func(n float64) float64
+	}{
+		{"product", `
+			SELECT sd.doc_id::text, sd.title, sd.popularity FROM search_documents sd
+			WHERE sd.doc_type = 'product' AND sd.is_active = true`,
+			func(n float64) float64 { return 1 + n }},
+		{"melhaf_collection", `
+			SELECT sd.doc_id::text, sd.title, sd.popularity FROM search_documents sd
+			WHERE sd.doc_type = 'melhaf_collection' AND sd.is_active = true`,
+			func(n float64) float64 { return 1.5 + n }},
+		{"perfume", `
+			SELECT p.id::text, names.label, COALESCE(sd.popularity, 0)
+			FROM maison_adrar_perfumes p
+			CROSS JOIN LATERAL (VALUES (p.name), (p.name_ar)) names(label)
+			LEFT JOIN search_documents sd ON sd.doc_type = 'perfume' AND sd.doc_id = p.id
+			WHERE p.is_active = true AND COALESCE(names.label, '') <> ''`,
+			func(n float64) float64 { return 1.5 + n }},
+		{"brand", `
+			SELECT b.id::text, b.name, COUNT(pm.id)
+			FROM brands b
+			JOIN product_models pm ON pm.brand_id = b.id AND pm.is_active = true
+			GROUP BY b.id, b.name`,
+			func(n float64) float64 { return 2 + math.Log1p(n) }},
+		{"category", `
+			SELECT c.id::text, c.name, COUNT(DISTINCT pm.id)
+			FROM categories c
+			JOIN product_model_categories pmc ON pmc.category_id = c.id
+			JOIN product_models pm ON pm.id = pmc.product_model_id AND pm.is_active = true
+			WHERE c.is_active = true
+			GROUP BY c.id, c.name`,
+			func(n float64) float64 { return 2 + math.Log1p(n) }},
+	}
+	for _, src := range sources {
+		rows, err := database.Database.Query(src.query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s suggestions: %w", src.kind, err)
+		}
+		for rows.Next() {
+			var id, text string
+			var n float64
+			if err := rows.Scan(&id, &text, &n); err == nil {
+				add(src.kind, id, text, src.score(n))
+			}
+		}
+		rows.Close()
+	}
+
+	// Popular queries that found something, unless they just repeat a catalog name
+	rows, err := database.Database.Query(`
+		SELECT (array_agg(query ORDER BY created_at DESC))[1], COUNT(*)
+		FROM search_queries
+		WHERE created_at > now() - interval '30 days' AND result_count > 0 AND normalized_query <> ''
+		  AND source <> 'product_code'
+		GROUP BY normalized_query
+		HAVING COUNT(*) >= 2
+		ORDER BY COUNT(*) DESC
+		LIMIT 500`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load popular queries: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var text string
+		var n float64
+		if err := rows.Scan(&text, &n); err != nil {
+			continue
+		}
+		if names[strings.Join(searchTokens(NormalizeSearchText(text)), " ")] {
+			continue
+		}
+		add("query", "", text, 1+math.Log1p(n))
+	}
+	return entries, nil
+}
+
+// Suggest returns up to limit suggestions for what has been typed so far: every
+// typed word must start one of the suggestion's words, in any order. Entries that
+// start with the whole input rank first.
+func Suggest(input string, limit int) []Suggestion {
+	tokens := searchTokens(NormalizeSearchText(input))
+	if len(tokens) == 0 {
+		return []Suggestion{}
+	}
+	normalized := strings.Join(tokens, " ")
+	last := []rune(tokens[len(tokens)-1])
+	if len(last) > suggestMaxPrefix {
+		last = last[:suggestMaxPrefix]
+	}
+
+	suggestions.mu.RLock()
+	defer suggestions.mu.RUnlock()
+
+	results := []Suggestion{}
+	seen := map[string]bool{}
+	for _, i := range suggestions.prefixes[string(last)] {
+		e := suggestions.entries[i]
+		if !tokensMatch(e.tokens, tokens) {
+			continue
+		}
+		key := e.Type + ":" + e.normalized
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s := e.Suggestion
+		if strings.HasPrefix(e.normalized, normalized) {
+			s.Score *= 2
+		}
+		results = append(results, s)
+	}
+	sort.SliceStable(results, func(a, b int) bool { return results[a].Score > results[b].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// tokensMatch tells whether every typed word starts one of the entry's words
+func tokensMatch(entryTokens, typed []string) bool {
+	for _, t := range typed {
+		found := false
+		for _, e := range entryTokens {
+			if strings.HasPrefix(e, t) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
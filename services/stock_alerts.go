@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"fmbq-server/config"
+	"fmbq-server/database"
+	"github.com/google/uuid"
+)
+
+// stockDigestHour is the local hour after which the daily stock digest goes out
+const stockDigestHour = 8
+
+// StaffContact is a staff member subscribed to stock alerts
+type StaffContact struct {
+	UserID    uuid.UUID
+	FullName  string
+	Email     string
+	Phone     string
+	PushToken string
+}
+
+// AlertChannel delivers a staff alert through one medium (push, email, SMS...).
+// New channels are plugged in with StockAlertService.RegisterChannel.
+type AlertChannel interface {
+	Name() string
+	Send(to StaffContact, subject, body string, data map[string]interface{}) error
+}
+
+// StockAlertItem is an unresolved stock alert with a readable item label
+type StockAlertItem struct {
+	ID           uuid.UUID  `json:"id"`
+	ItemType     string     `json:"item_type"`
+	ItemID       uuid.UUID  `json:"item_id"`
+	Label        string     `json:"label"`
+	Code         string     `json:"code"`
+	AlertType    string     `json:"alert_type"`
+	Available    int        `json:"available"`
+	ReorderPoint int        `json:"reorder_point"`
+	Status       string     `json:"status"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// StockAlertService detects stock level breaches and notifies subscribed staff
+type StockAlertService struct {
+	channels map[string]AlertChannel
+}
+
+// NewStockAlertService creates a stock alert service with push enabled and email/SMS
+// enabled when configured
+func NewStockAlertService() *StockAlertService {
+	s := &StockAlertService{channels: map[string]AlertChannel{}}
+	s.RegisterChannel(&pushAlertChannel{notificationService: NewNotificationService()})
+	if cfg := config.AppConfig; cfg != nil {
+		if cfg.SMTPHost != "" && cfg.AlertEmailFrom != "" {
+			s.RegisterChannel(&EmailAlertChannel{
+				Host:     cfg.SMTPHost,
+				Port:     cfg.SMTPPort,
+				Username: cfg.SMTPUsername,
+				Password: cfg.SMTPPassword,
+				From:     cfg.AlertEmailFrom,
+			})
+		}
+		if cfg.SMSGatewayURL != "" {
+			s.RegisterChannel(&SMSAlertChannel{GatewayURL: cfg.SMSGatewayURL, Token: cfg.SMSGatewayToken})
+		}
+	}
+	return s
+}
+
+// RegisterChannel adds or replaces a delivery channel. Subscriptions refer to
+// channels by name ("push", "email", "sms").
+func (s *StockAlertService) RegisterChannel(ch AlertChannel) {
+	s.channels[ch.Name()] = ch
+}
+
+// stockLevelsSQL lists the current level and reorder point of every stocked item
+const stockLevelsSQL = `
+	SELECT 'sku' AS item_type, sku_id AS item_id, available, reorder_point FROM inventory
+	UNION ALL
+	SELECT 'melhaf_color', color_id, available, reorder_point FROM melhaf_inventory
+	UNION ALL
+	SELECT 'perfume_variant', id, COALESCE(stock, 0), reorder_point FROM maison_adrar_perfume_colors WHERE is_active = TRUE`
+
+// CheckStockLevels raises, escalates and resolves alerts from current stock levels.
+// An item has at most one unresolved alert, so repeated runs never duplicate alerts.
+func (s *StockAlertService) CheckStockLevels() error {
+	// Raise new alerts and escalate low_stock to out_of_stock. Escalation re-opens an
+	// acknowledged alert so staff hear about it again.
+	_, err := database.Database.Exec(`
+		WITH levels AS (` + stockLevelsSQL + `)
+		INSERT INTO stock_alerts (id, item_type, item_id, alert_type, available, reorder_point, status, notify_pending, created_at, updated_at)
+		SELECT gen_random_uuid(), item_type, item_id,
+		       CASE WHEN available <= 0 THEN 'out_of_stock' ELSE 'low_stock' END,
+		       available, reorder_point, 'open', TRUE, now(), now()
+		FROM levels
+		WHERE available <= 0 OR available <= reorder_point
+		ON CONFLICT (item_type, item_id) WHERE status <> 'resolved' DO UPDATE SET
+			available = EXCLUDED.available,
+			reorder_point = EXCLUDED.reorder_point,
+			alert_type = EXCLUDED.alert_type,
+			status = CASE WHEN stock_alerts.alert_type <> EXCLUDED.alert_type AND EXCLUDED.alert_type = 'out_of_stock'
+			              THEN 'open' ELSE stock_alerts.status END,
+			notify_pending = stock_alerts.notify_pending
+			              OR (stock_alerts.alert_type <> EXCLUDED.alert_type AND EXCLUDED.alert_type = 'out_of_stock'),
+			updated_at = now()`)
+	if err != nil {
+		return fmt.Errorf("failed to raise stock alerts: %w", err)
+	}
+
+	// Resolve alerts whose item recovered (or no longer exists)
+	res, err := database.Database.Exec(`
+		WITH levels AS (` + stockLevelsSQL + `)
+		UPDATE stock_alerts a
+		SET status = 'resolved', resolved_at = now(), notify_pending = FALSE, updated_at = now()
+		WHERE a.status <> 'resolved'
+		AND NOT EXISTS (
+			SELECT 1 FROM levels l
+			WHERE l.item_type = a.item_type AND l.item_id = a.item_id
+			AND (l.available <= 0 OR l.available <= l.reorder_point)
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to resolve stock alerts: %w", err)
+	}
+	if resolved, _ := res.RowsAffected(); resolved > 0 {
+		fmt.Printf("✅ Resolved %d stock alerts\n", resolved)
+	}
+
+	return s.sendPendingAlerts()
+}
+
+// sendPendingAlerts notifies instant subscribers about open alerts that are not snoozed
+func (s *StockAlertService) sendPendingAlerts() error {
+	alerts, err := s.listAlerts(`a.status = 'open' AND a.notify_pending = TRUE
+		AND (a.snoozed_until IS NULL OR a.snoozed_until <= now())`)
+	if err != nil {
+		return err
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	subscribers, err := s.loadSubscribers("instant = TRUE")
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range alerts {
+		title := "Low stock ⚠️"
+		if alert.AlertType == "out_of_stock" {
+			title = "Out of stock ❌"
+		}
+		body := fmt.Sprintf("%s: %d left (reorder point %d)", alert.Label, alert.Available, alert.ReorderPoint)
+		data := map[string]interface{}{
+			"type":       "stock_alert",
+			"alert_id":   alert.ID.String(),
+			"alert_type": alert.AlertType,
+			"item_type":  alert.ItemType,
+			"item_id":    alert.ItemID.String(),
+			"timestamp":  time.Now().Unix(),
+		}
+
+		for _, sub := range subscribers {
+			if sub.outOfStockOnly && alert.AlertType != "out_of_stock" {
+				continue
+			}
+			s.deliver(sub, title, body, data)
+		}
+
+		if _, err := database.Database.Exec(`UPDATE stock_alerts SET notify_pending = FALSE, notified_at = now(), updated_at = now() WHERE id = $1`, alert.ID); err != nil {
+			fmt.Printf("⚠️ Failed to mark stock alert %s as notified: %v\n", alert.ID, err)
+		}
+	}
+	fmt.Printf("🔔 Sent %d stock alerts to %d subscribers\n", len(alerts), len(subscribers))
+	return nil
+}
+
+// SendDueDigests sends the daily digest to subscribers who have not had today's yet
+func (s *StockAlertService) SendDueDigests() error {
+	now := time.Now()
+	if now.Hour() < stockDigestHour {
+		return nil
+	}
+	digestStart := time.Date(now.Year(), now.Month(), now.Day(), stockDigestHour, 0, 0, 0, now.Location())
+
+	subscribers, err := s.loadSubscribers("daily_digest = TRUE AND (last_digest_at IS NULL OR last_digest_at < $1)", digestStart)
+	if err != nil {
+		return err
+	}
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	alerts, err := s.UnresolvedAlerts()
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subscribers {
+		subject, body := BuildStockDigest(alerts, sub.outOfStockOnly)
+		if subject != "" {
+			s.deliver(sub, subject, body, map[string]interface{}{"type": "stock_digest", "timestamp": now.Unix()})
+		}
+		if _, err := database.Database.Exec(`UPDATE stock_alert_subscriptions SET last_digest_at = now(), updated_at = now() WHERE user_id = $1`, sub.contact.UserID); err != nil {
+			fmt.Printf("⚠️ Failed to record stock digest for %s: %v\n", sub.contact.UserID, err)
+		}
+	}
+	return nil
+}
+
+// UnresolvedAlerts returns every open or acknowledged alert, out-of-stock first
+func (s *StockAlertService) UnresolvedAlerts() ([]StockAlertItem, error) {
+	return s.listAlerts(`a.status <> 'resolved'`)
+}
+
+// BuildStockDigest summarises unresolved alerts. It returns an empty subject when
+// there is nothing to report.
+func BuildStockDigest(alerts []StockAlertItem, outOfStockOnly bool) (string, string) {
+	var out, low []StockAlertItem
+	for _, a := range alerts {
+		if a.AlertType == "out_of_stock" {
+			out = append(out, a)
+		} else if !outOfStockOnly {
+			low = append(low, a)
+		}
+	}
+	if len(out) == 0 && len(low) == 0 {
+		return "", ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d out of stock, %d low stock\n", len(out), len(low))
+	for _, section := range []struct {
+		name  string
+		items []StockAlertItem
+	}{{"Out of stock", out}, {"Low stock", low}} {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n", section.name)
+		for i, a := range section.items {
+			if i == 20 {
+				fmt.Fprintf(&b, "…and %d more\n", len(section.items)-20)
+				break
+			}
+			fmt.Fprintf(&b, "- %s (%d left, reorder point %d)\n", a.Label, a.Available, a.ReorderPoint)
+		}
+	}
+	return "Daily stock digest 📦", b.String()
+}
+
+// listAlerts loads alerts matching where, labelled with the product/Melhaf/perfume name
+func (s *StockAlertService) listAlerts(where string) ([]StockAlertItem, error) {
+	rows, err := database.Database.Query(`
+		SELECT a.id, a.item_type, a.item_id, a.alert_type, a.available, a.reorder_point, a.status, a.snoozed_until, a.created_at,
+		       CASE a.item_type
+		           WHEN 'sku' THEN COALESCE(pm.title, '') || COALESCE(' ' || pc.color_name, '') || COALESCE(' ' || sk.size, '')
+		           WHEN 'melhaf_color' THEN 'Melhaf ' || COALESCE(mcol.name || ' - ', '') || COALESCE(mc.name, '')
+		           ELSE 'Maison Adrar ' || COALESCE(p.name || ' - ', '') || COALESCE(pvc.name, '')
+		       END,
+		       COALESCE(sk.sku_code, mc.ean, '')
+		FROM stock_alerts a
+		LEFT JOIN skus sk ON a.item_type = 'sku' AND sk.id = a.item_id
+		LEFT JOIN product_models pm ON pm.id = sk.product_model_id
+		LEFT JOIN product_colors pc ON pc.id = sk.product_color_id
+		LEFT JOIN melhaf_colors mc ON a.item_type = 'melhaf_color' AND mc.id = a.item_id
+		LEFT JOIN melhaf_collections mcol ON mcol.id = mc.collection_id
+		LEFT JOIN maison_adrar_perfume_colors pvc ON a.item_type = 'perfume_variant' AND pvc.id = a.item_id
+		LEFT JOIN maison_adrar_perfumes p ON p.id = pvc.perfume_id
+		WHERE ` + where + `
+		ORDER BY a.alert_type DESC, a.available ASC, a.created_at ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stock alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []StockAlertItem
+	for rows.Next() {
+		var a StockAlertItem
+		if err := rows.Scan(&a.ID, &a.ItemType, &a.ItemID, &a.AlertType, &a.Available, &a.ReorderPoint, &a.Status,
+			&a.SnoozedUntil, &a.CreatedAt, &a.Label, &a.Code); err != nil {
+			continue
+		}
+		a.Label = strings.TrimSpace(a.Label)
+		alerts = append(alerts, a)
+	}
+	return alerts, nil
+}
+
+type stockAlertSubscriber struct {
+	contact        StaffContact
+	channels       []string
+	outOfStockOnly bool
+}
+
+// loadSubscribers loads active admin/employee subscribers matching where
+func (s *StockAlertService) loadSubscribers(where string, args ...interface{}) ([]stockAlertSubscriber, error) {
+	rows, err := database.Database.Query(`
+		SELECT u.id, COALESCE(u.full_name, ''), COALESCE(u.email, ''), COALESCE(u.phone, ''), COALESCE(u.push_token, ''),
+		       sub.push, sub.email, sub.sms, sub.out_of_stock_only
+		FROM stock_alert_subscriptions sub
+		JOIN users u ON u.id = sub.user_id
+		WHERE u.is_active = TRUE AND u.role IN ('admin', 'employee') AND `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stock alert subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []stockAlertSubscriber
+	for rows.Next() {
+		var sub stockAlertSubscriber
+		var push, email, sms bool
+		if err := rows.Scan(&sub.contact.UserID, &sub.contact.FullName, &sub.contact.Email, &sub.contact.Phone, &sub.contact.PushToken,
+			&push, &email, &sms, &sub.outOfStockOnly); err != nil {
+			continue
+		}
+		if push {
+			sub.channels = append(sub.channels, "push")
+		}
+		if email {
+			sub.channels = append(sub.channels, "email")
+		}
+		if sms {
+			sub.channels = append(sub.channels, "sms")
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+// deliver sends through every channel the subscriber opted into; failures are logged
+func (s *StockAlertService) deliver(sub stockAlertSubscriber, subject, body string, data map[string]interface{}) {
+	for _, name := range sub.channels {
+		ch, ok := s.channels[name]
+		if !ok {
+			continue
+		}
+		if err := ch.Send(sub.contact, subject, body, data); err != nil {
+			fmt.Printf("⚠️ Stock alert via %s to %s failed: %v\n", name, sub.contact.UserID, err)
+		}
+	}
+}
+
+// pushAlertChannel sends alerts as Expo push notifications
+type pushAlertChannel struct {
+	notificationService *NotificationService
+}
+
+func (p *pushAlertChannel) Name() string { return "push" }
+
+func (p *pushAlertChannel) Send(to StaffContact, subject, body string, data map[string]interface{}) error {
+	if to.PushToken == "" {
+		return nil
+	}
+	return p.notificationService.SendPushNotification(to.PushToken, subject, body, data)
+}
+
+// EmailAlertChannel sends alerts as plain-text email over SMTP
+type EmailAlertChannel struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (e *EmailAlertChannel) Name() string { return "email" }
+
+func (e *EmailAlertChannel) Send(to StaffContact, subject, body string, data map[string]interface{}) error {
+	if to.Email == "" {
+		return nil
+	}
+	msg := "From: " + e.From + "\r\n" +
+		"To: " + to.Email + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n\r\n" +
+		body + "\r\n"
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, e.Host)
+	}
+	return smtp.SendMail(e.Host+":"+e.Port, auth, e.From, []string{to.Email}, []byte(msg))
+}
+
+// SMSAlertChannel posts alerts to an HTTP SMS gateway as {"to": ..., "message": ...}
+type SMSAlertChannel struct {
+	GatewayURL string
+	Token      string
+}
+
+func (s *SMSAlertChannel) Name() string { return "sms" }
+
+func (s *SMSAlertChannel) Send(to StaffContact, subject, body string, data map[string]interface{}) error {
+	if to.Phone == "" {
+		return nil
+	}
+	payload, err := json.Marshal(map[string]string{"to": to.Phone, "message": subject + "\n" + body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", s.GatewayURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS gateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
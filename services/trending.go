@@ -0,0 +1,144 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"fmbq-server/database"
+)
+
+const (
+	// trendingHalfLife is how fast activity fades from the trending score
+	trendingHalfLife = 72 * time.Hour
+	// trendingVelocityHalfLife is how fast it fades from the velocity
+	trendingVelocityHalfLife = 6 * time.Hour
+	// TrendingVelocityScale puts velocity on the score's scale: under a steady flow of
+	// activity, velocity times TrendingVelocityScale equals the score
+	TrendingVelocityScale = float64(trendingHalfLife / trendingVelocityHalfLife)
+	// trendingBackfill is how much history the first run reads
+	trendingBackfill = 14 * 24 * time.Hour
+	// trendingMinScore drops products whose activity has faded away
+	trendingMinScore = 0.01
+)
+
+// trendingWeights weighs each kind of activity in the trending score
+var trendingWeights = struct {
+	View, Cart, Wishlist, Purchase float64
+}{View: 1, Cart: 3, Wishlist: 3, Purchase: 8}
+
+// trendingEventsSQL lists the weighted activity recorded in ($1, $2]: views, cart
+// adds, wishlist adds and purchases (web and POS, bundles included; cancelled and
+// refunded orders left out). A product counts once per order. Views are read by
+// when they were written, as buffered views can be written late.
+const trendingEventsSQL = `
+	SELECT product_id, $3::numeric AS weight, view_timestamp AS at
+	FROM product_views
+	WHERE created_at > $1 AND created_at <= $2
+	UNION ALL
+	SELECT s.product_model_id, $4::numeric, ci.added_at
+	FROM cart_items ci
+	JOIN skus s ON s.id = ci.sku_id
+	WHERE ci.added_at > $1 AND ci.added_at <= $2
+	UNION ALL
+	SELECT product_id, $5::numeric, created_at
+	FROM wishlist_items
+	WHERE created_at > $1 AND created_at <= $2
+	UNION ALL
+	SELECT bought.product_id, $6::numeric, bought.created_at
+	FROM (
+		SELECT o.id, COALESCE(oi.product_id, s.product_model_id) AS product_id, o.created_at
+		FROM orders o
+		JOIN order_items oi ON oi.order_id = o.id
+		LEFT JOIN skus s ON oi.item_type = 'sku' AND s.id = oi.item_id
+		WHERE o.created_at > $1 AND o.created_at <= $2 AND o.status NOT IN ('cancelled', 'refunded')
+		UNION
+		SELECT o.id, s.product_model_id, o.created_at
+		FROM orders o
+		JOIN order_items oi ON oi.order_id = o.id
+		JOIN order_item_components oic ON oic.order_item_id = oi.id AND oic.item_type = 'sku'
+		JOIN skus s ON s.id = oic.item_id
+		WHERE o.created_at > $1 AND o.created_at <= $2 AND o.status NOT IN ('cancelled', 'refunded')
+	) bought`
+
+// TrendingJob keeps product_trending_scores current. Each run decays every score
+// to the run's time and adds only the activity since the previous run.
+type TrendingJob struct{}
+
+// NewTrendingJob creates a trending job
+func NewTrendingJob() *TrendingJob {
+	return &TrendingJob{}
+}
+
+// Refresh folds the activity since the last run into the trending scores, in one
+// transaction with the watermark move
+func (tj *TrendingJob) Refresh() error {
+	until := time.Now().Add(-recommendationLag)
+
+	tx, err := database.Database.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start trending refresh: %w", err)
+	}
+	defer tx.Rollback()
+
+	var since time.Time
+	err = tx.QueryRow(`SELECT processed_until FROM recommendation_watermarks WHERE signal = 'trending' FOR UPDATE`).Scan(&since)
+	if err == sql.ErrNoRows {
+		// First run: older activity would have faded anyway
+		since = until.Add(-trendingBackfill)
+	} else if err != nil {
+		return fmt.Errorf("failed to read trending watermark: %w", err)
+	}
+	if !until.After(since) {
+		return nil
+	}
+
+	halfLife, velocityHalfLife := trendingHalfLife.Seconds(), trendingVelocityHalfLife.Seconds()
+	if _, err := tx.Exec(`
+		UPDATE product_trending_scores
+		SET score = score * power(0.5, EXTRACT(EPOCH FROM ($1::timestamptz - scored_until)) / $2),
+		    velocity = velocity * power(0.5, EXTRACT(EPOCH FROM ($1::timestamptz - scored_until)) / $3),
+		    scored_until = $1
+		WHERE scored_until < $1`, until, halfLife, velocityHalfLife); err != nil {
+		return fmt.Errorf("failed to decay trending scores: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO product_trending_scores (product_id, score, velocity, last_event_at, scored_until)
+		SELECT events.product_id,
+		       SUM(events.weight * power(0.5, EXTRACT(EPOCH FROM ($2::timestamptz - events.at)) / $7)),
+		       SUM(events.weight * power(0.5, EXTRACT(EPOCH FROM ($2::timestamptz - events.at)) / $8)),
+		       MAX(events.at), $2
+		FROM (`+trendingEventsSQL+`) events
+		JOIN product_models pm ON pm.id = events.product_id
+		GROUP BY events.product_id
+		ON CONFLICT (product_id) DO UPDATE
+		SET score = product_trending_scores.score + EXCLUDED.score,
+		    velocity = product_trending_scores.velocity + EXCLUDED.velocity,
+		    last_event_at = GREATEST(product_trending_scores.last_event_at, EXCLUDED.last_event_at),
+		    scored_until = EXCLUDED.scored_until`,
+		since, until, trendingWeights.View, trendingWeights.Cart, trendingWeights.Wishlist, trendingWeights.Purchase,
+		halfLife, velocityHalfLife)
+	if err != nil {
+		return fmt.Errorf("failed to add trending activity: %w", err)
+	}
+	active, _ := res.RowsAffected()
+
+	if _, err := tx.Exec(`
+		DELETE FROM product_trending_scores WHERE score < $1 AND velocity < $1`, trendingMinScore); err != nil {
+		return fmt.Errorf("failed to prune trending scores: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO recommendation_watermarks (signal, processed_until) VALUES ('trending', $1)
+		ON CONFLICT (signal) DO UPDATE SET processed_until = EXCLUDED.processed_until`, until); err != nil {
+		return fmt.Errorf("failed to move trending watermark: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit trending refresh: %w", err)
+	}
+	if active > 0 {
+		log.Printf("📈 Added new activity to the trending scores of %d products", active)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// Barcode is an encoded linear barcode: one entry per module, true for a dark bar
+type Barcode struct {
+	Symbology string
+	Text      string
+	Modules   []bool
+	// QuietZone is the number of blank modules required on each side when rendering
+	QuietZone int
+}
+
+// BarRun is a contiguous dark bar, in modules from the left edge of the symbol
+type BarRun struct {
+	Start int
+	Width int
+}
+
+// Runs collapses the module pattern into dark bars, which is what every renderer draws
+func (b *Barcode) Runs() []BarRun {
+	var runs []BarRun
+	for i := 0; i < len(b.Modules); i++ {
+		if !b.Modules[i] {
+			continue
+		}
+		start := i
+		for i < len(b.Modules) && b.Modules[i] {
+			i++
+		}
+		runs = append(runs, BarRun{Start: start, Width: i - start})
+	}
+	return runs
+}
+
+// TotalModules is the symbol width including both quiet zones
+func (b *Barcode) TotalModules() int {
+	return len(b.Modules) + 2*b.QuietZone
+}
+
+var (
+	eanLeftOdd = [10]string{
+		"0001101", "0011001", "0010011", "0111101", "0100011",
+		"0110001", "0101111", "0111011", "0110111", "0001011",
+	}
+	// eanParity gives the L/G pattern of the left half, selected by the first digit
+	eanParity = [10]string{
+		"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+		"LGGLLG", "LGGGLG", "LGGGGL", "LGLGLG", "LGLGGL",
+	}
+)
+
+// EncodeEAN13 encodes a 13-digit EAN (or a UPC-A, which is widened to EAN-13).
+// A 12-digit body without check digit is not accepted: codes must already be valid.
+func EncodeEAN13(code string) (*Barcode, error) {
+	normalized, err := NormalizeBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+	if len(normalized) != 13 {
+		return nil, fmt.Errorf("EAN-13 rendering needs a 13-digit code, got %s", normalized)
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("101")
+	parity := eanParity[normalized[0]-'0']
+	for i := 1; i <= 6; i++ {
+		l := eanLeftOdd[normalized[i]-'0']
+		if parity[i-1] == 'G' {
+			// G codes are the right-hand codes read backwards
+			pattern.WriteString(reverseString(complementPattern(l)))
+		} else {
+			pattern.WriteString(l)
+		}
+	}
+	pattern.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		pattern.WriteString(complementPattern(eanLeftOdd[normalized[i]-'0']))
+	}
+	pattern.WriteString("101")
+
+	return &Barcode{Symbology: "EAN-13", Text: normalized, Modules: patternModules(pattern.String()), QuietZone: 11}, nil
+}
+
+// code128Widths holds the bar/space widths of Code 128 symbols 0-106 (106 is the stop)
+var code128Widths = [107]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// EncodeCode128 encodes printable ASCII text as Code 128. All-digit text of even
+// length uses code set C (two digits per symbol) for a narrower label; everything
+// else uses code set B.
+func EncodeCode128(text string) (*Barcode, error) {
+	if text == "" {
+		return nil, fmt.Errorf("Code 128 text must not be empty")
+	}
+	for _, r := range text {
+		if r < 32 || r > 126 {
+			return nil, fmt.Errorf("Code 128 supports printable ASCII only, got %q", r)
+		}
+	}
+
+	var symbols []int
+	if len(text) >= 4 && len(text)%2 == 0 && isDigits(text) {
+		symbols = append(symbols, code128StartC)
+		for i := 0; i < len(text); i += 2 {
+			symbols = append(symbols, int(text[i]-'0')*10+int(text[i+1]-'0'))
+		}
+	} else {
+		symbols = append(symbols, code128StartB)
+		for i := 0; i < len(text); i++ {
+			symbols = append(symbols, int(text[i])-32)
+		}
+	}
+
+	checksum := symbols[0]
+	for i := 1; i < len(symbols); i++ {
+		checksum += i * symbols[i]
+	}
+	symbols = append(symbols, checksum%103, code128Stop)
+
+	var modules []bool
+	for _, s := range symbols {
+		dark := true
+		for _, w := range code128Widths[s] {
+			for n := 0; n < int(w-'0'); n++ {
+				modules = append(modules, dark)
+			}
+			dark = !dark
+		}
+	}
+	return &Barcode{Symbology: "Code128", Text: text, Modules: modules, QuietZone: 10}, nil
+}
+
+// PNG renders the barcode as a black-on-white PNG, moduleWidth pixels per module
+// and height pixels tall, quiet zones included. No human-readable text is drawn.
+func (b *Barcode) PNG(moduleWidth, height int) ([]byte, error) {
+	if moduleWidth < 1 || height < 1 {
+		return nil, fmt.Errorf("module width and height must be positive")
+	}
+	width := b.TotalModules() * moduleWidth
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for _, run := range b.Runs() {
+		x0 := (b.QuietZone + run.Start) * moduleWidth
+		x1 := x0 + run.Width*moduleWidth
+		for y := 0; y < height; y++ {
+			for x := x0; x < x1; x++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the barcode as an SVG document with the code printed underneath
+func (b *Barcode) SVG(moduleWidth, height int) string {
+	if moduleWidth < 1 {
+		moduleWidth = 1
+	}
+	textSize := moduleWidth * 10
+	width := b.TotalModules() * moduleWidth
+	total := height + textSize + moduleWidth*2
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, total, width, total)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/>`, width, total)
+	for _, run := range b.Runs() {
+		fmt.Fprintf(&svg, `<rect x="%d" y="0" width="%d" height="%d" fill="#000"/>`,
+			(b.QuietZone+run.Start)*moduleWidth, run.Width*moduleWidth, height)
+	}
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle">%s</text>`,
+		width/2, height+textSize, textSize, escapeXML(b.Text))
+	svg.WriteString(`</svg>`)
+	return svg.String()
+}
+
+func patternModules(pattern string) []bool {
+	modules := make([]bool, len(pattern))
+	for i := range pattern {
+		modules[i] = pattern[i] == '1'
+	}
+	return modules
+}
+
+func complementPattern(p string) string {
+	out := []byte(p)
+	for i := range out {
+		if out[i] == '0' {
+			out[i] = '1'
+		} else {
+			out[i] = '0'
+		}
+	}
+	return string(out)
+}
+
+func reverseString(s string) string {
+	out := []byte(s)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ColorFamily is a broad color used for filtering ("red" covers Bordeaux and Rouge).
+// Refs are the reference colors a hex value is compared against.
+type ColorFamily struct {
+	Slug   string   `json:"slug"`
+	Name   string   `json:"name"`
+	NameFR string   `json:"name_fr"`
+	NameAR string   `json:"name_ar"`
+	Hex    string   `json:"hex"`
+	Refs   []string `json:"-"`
+	words  []string
+}
+
+var colorFamilies = []ColorFamily{
+	{Slug: "black", Name: "Black", NameFR: "Noir", NameAR: "أسود", Hex: "#000000",
+		Refs:  []string{"#000000", "#1C1C1C", "#2B2B2B"},
+		words: []string{"noir", "black", "أسود", "اسود", "ebene"}},
+	{Slug: "white", Name: "White", NameFR: "Blanc", NameAR: "أبيض", Hex: "#FFFFFF",
+		Refs:  []string{"#FFFFFF", "#FFFFF0", "#F8F8F8"},
+		words: []string{"blanc", "blanche", "white", "ivoire", "ivory", "أبيض", "ابيض"}},
+	{Slug: "grey", Name: "Grey", NameFR: "Gris", NameAR: "رمادي", Hex: "#808080",
+		Refs:  []string{"#808080", "#A9A9A9", "#C0C0C0", "#5A5A5A", "#36454F"},
+		words: []string{"gris", "grise", "grey", "gray", "argent", "argente", "silver", "anthracite", "رمادي", "فضي"}},
+	{Slug: "beige", Name: "Beige", NameFR: "Beige", NameAR: "بيج", Hex: "#F5F5DC",
+		Refs:  []string{"#F5F5DC", "#D2B48C", "#E8D8C0", "#C8AD7F", "#F3E5AB", "#FFFDD0"},
+		words: []string{"beige", "creme", "cream", "ecru", "sable", "sand", "nude", "taupe", "camel", "بيج"}},
+	{Slug: "brown", Name: "Brown", NameFR: "Marron", NameAR: "بني", Hex: "#8B4513",
+		Refs:  []string{"#8B4513", "#5C4033", "#A0522D", "#6F4E37"},
+		words: []string{"marron", "brun", "brown", "chocolat", "chocolate", "cafe", "coffee", "cognac", "caramel", "بني"}},
+	{Slug: "red", Name: "Red", NameFR: "Rouge", NameAR: "أحمر", Hex: "#FF0000",
+		Refs:  []string{"#FF0000", "#B22222", "#800020", "#8B0000", "#DC143C"},
+		words: []string{"rouge", "red", "bordeaux", "burgundy", "grenat", "carmin", "cerise", "cherry", "أحمر", "احمر"}},
+	{Slug: "pink", Name: "Pink", NameFR: "Rose", NameAR: "وردي", Hex: "#FFC0CB",
+		Refs:  []string{"#FFC0CB", "#FF69B4", "#E75480", "#FF00FF", "#F4C2C2"},
+		words: []string{"rose", "pink", "fuchsia", "framboise", "magenta", "poudre", "وردي", "زهري"}},
+	{Slug: "orange", Name: "Orange", NameFR: "Orange", NameAR: "برتقالي", Hex: "#FFA500",
+		Refs:  []string{"#FFA500", "#FF7F50", "#FF8C00", "#E2725B"},
+		words: []string{"orange", "corail", "coral", "abricot", "apricot", "terracotta", "برتقالي"}},
+	{Slug: "yellow", Name: "Yellow", NameFR: "Jaune", NameAR: "أصفر", Hex: "#FFFF00",
+		Refs:  []string{"#FFFF00", "#FFD700", "#FFDB58", "#FFFACD"},
+		words: []string{"jaune", "yellow", "moutarde", "mustard", "citron", "lemon", "أصفر", "اصفر"}},
+	{Slug: "green", Name: "Green", NameFR: "Vert", NameAR: "أخضر", Hex: "#008000",
+		Refs:  []string{"#008000", "#556B2F", "#98FB98", "#006400", "#808000", "#50C878", "#008080"},
+		words: []string{"vert", "verte", "green", "olive", "kaki", "khaki", "menthe", "mint", "emeraude", "emerald", "أخضر", "اخضر"}},
+	{Slug: "blue", Name: "Blue", NameFR: "Bleu", NameAR: "أزرق", Hex: "#0000FF",
+		Refs:  []string{"#0000FF", "#000080", "#87CEEB", "#4169E1", "#40E0D0", "#1560BD", "#191970"},
+		words: []string{"bleu", "bleue", "blue", "marine", "navy", "turquoise", "ciel", "sky", "indigo", "denim", "azur", "أزرق", "ازرق", "كحلي"}},
+	{Slug: "purple", Name: "Purple", NameFR: "Violet", NameAR: "بنفسجي", Hex: "#800080",
+		Refs:  []string{"#800080", "#E6E6FA", "#8E4585", "#9370DB", "#4B0082"},
+		words: []string{"violet", "violette", "purple", "mauve", "lilas", "lilac", "lavande", "lavender", "prune", "plum", "aubergine", "بنفسجي"}},
+	{Slug: "gold", Name: "Gold", NameFR: "Doré", NameAR: "ذهبي", Hex: "#D4AF37",
+		Refs:  []string{"#D4AF37", "#CFB53B", "#B8860B"},
+		words: []string{"or", "dore", "doree", "gold", "golden", "ذهبي"}},
+	{Slug: "multicolor", Name: "Multicolor", NameFR: "Multicolore", NameAR: "متعدد الألوان", Hex: "",
+		words: []string{"multicolore", "multicolor", "multi", "imprime", "print", "motif", "motifs", "متعدد"}},
+}
+
+var colorFamilyWords = map[string]string{}
+
+func init() {
+	for _, f := range colorFamilies {
+		for _, w := range f.words {
+			colorFamilyWords[w] = f.Slug
+		}
+	}
+}
+
+// ColorFamilies lists the color family taxonomy in display order
+func ColorFamilies() []ColorFamily {
+	return colorFamilies
+}
+
+// IsColorFamily reports whether slug names a family of the taxonomy
+func IsColorFamily(slug string) bool {
+	for _, f := range colorFamilies {
+		if f.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseHexColor reads "#RRGGBB", "RRGGBB" or "#RGB"
+func ParseHexColor(s string) (r, g, b uint8, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), true
+}
+
+// FormatHexColor formats a color as "#RRGGBB"
+func FormatHexColor(r, g, b uint8) string {
+	return fmt.Sprintf("#%02X%02X%02X", r, g, b)
+}
+
+// NearestColorFamily returns the family whose reference color is closest to hex
+// (CIE76 distance in Lab space) and that distance. ok is false for invalid hex values.
+func NearestColorFamily(hex string) (slug string, distance float64, ok bool) {
+	r, g, b, ok := ParseHexColor(hex)
+	if !ok {
+		return "", 0, false
+	}
+	lab := rgbToLab(r, g, b)
+	distance = math.MaxFloat64
+	for _, f := range colorFamilies {
+		for _, ref := range f.Refs {
+			rr, rg, rb, _ := ParseHexColor(ref)
+			if d := labDistance(lab, rgbToLab(rr, rg, rb)); d < distance {
+				slug, distance = f.Slug, d
+			}
+		}
+	}
+	return slug, distance, true
+}
+
+// ColorFamilyFromName finds a family from words in a color name ("Bleu marine",
+// "Rouge Bordeaux", "أحمر"). It returns "" when no word is known.
+func ColorFamilyFromName(name string) string {
+	folded := strings.NewReplacer("é", "e", "è", "e", "ê", "e", "ë", "e", "à", "a", "â", "a", "î", "i", "ô", "o", "û", "u", "ç", "c").
+		Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(folded, func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, w := range words {
+		if slug, ok := colorFamilyWords[w]; ok {
+			return slug
+		}
+	}
+	return ""
+}
+
+type labColor struct{ l, a, b float64 }
+
+func rgbToLab(r, g, b uint8) labColor {
+	lin := func(c uint8) float64 {
+		v := float64(c) / 255
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	rl, gl, bl := lin(r), lin(g), lin(b)
+	x := (rl*0.4124 + gl*0.3576 + bl*0.1805) / 0.95047
+	y := rl*0.2126 + gl*0.7152 + bl*0.0722
+	z := (rl*0.0193 + gl*0.1192 + bl*0.9505) / 1.08883
+	f := func(t float64) float64 {
+		if t > 0.008856 {
+			return math.Cbrt(t)
+		}
+		return 7.787*t + 16.0/116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return labColor{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
+
+func labDistance(p, q labColor) float64 {
+	return math.Sqrt((p.l-q.l)*(p.l-q.l) + (p.a-q.a)*(p.a-q.a) + (p.b-q.b)*(p.b-q.b))
+}
+
+// DominantColor returns the most common color of a product photo as "#RRGGBB".
+// Transparent pixels and the studio background (taken from the image corners when
+// they agree, plain white otherwise) are ignored, then the remaining pixels are
+// grouped into coarse buckets and the largest bucket is averaged.
+func DominantColor(img image.Image) (string, bool) {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return "", false
+	}
+	step := bounds.Dx() / 120
+	if s := bounds.Dy() / 120; s > step {
+		step = s
+	}
+	if step < 1 {
+		step = 1
+	}
+
+	rgba := func(x, y int) (uint8, uint8, uint8, uint8) {
+		r, g, b, a := img.At(x, y).RGBA()
+		return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)
+	}
+
+	// The background is the corner color when all four corners roughly agree
+	bg := labColor{l: 100}
+	corners := [][2]int{{bounds.Min.X, bounds.Min.Y}, {bounds.Max.X - 1, bounds.Min.Y}, {bounds.Min.X, bounds.Max.Y - 1}, {bounds.Max.X - 1, bounds.Max.Y - 1}}
+	var cornerLabs []labColor
+	for _, pt := range corners {
+		r, g, b, _ := rgba(pt[0], pt[1])
+		cornerLabs = append(cornerLabs, rgbToLab(r, g, b))
+	}
+	agree := true
+	for _, c := range cornerLabs[1:] {
+		if labDistance(c, cornerLabs[0]) > 8 {
+			agree = false
+		}
+	}
+	if agree {
+		bg = cornerLabs[0]
+	}
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := map[int]*bucket{}
+	collect := func(skipBackground bool) {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+			for x := bounds.Min.X; x < bounds.Max.X; x += step {
+				r, g, b, a := rgba(x, y)
+				if a < 128 {
+					continue
+				}
+				if skipBackground && labDistance(rgbToLab(r, g, b), bg) < 10 {
+					continue
+				}
+				key := int(r>>4)<<8 | int(g>>4)<<4 | int(b>>4)
+				bk := buckets[key]
+				if bk == nil {
+					bk = &bucket{}
+					buckets[key] = bk
+				}
+				bk.count++
+				bk.r += int(r)
+				bk.g += int(g)
+				bk.b += int(b)
+			}
+		}
+	}
+	collect(true)
+	if len(buckets) == 0 {
+		// The product is the same color as the background (e.g. white on white)
+		collect(false)
+	}
+
+	var best *bucket
+	bestKey := 0
+	for key, bk := range buckets {
+		if best == nil || bk.count > best.count || (bk.count == best.count && key < bestKey) {
+			best, bestKey = bk, key
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return FormatHexColor(uint8(best.r/best.count), uint8(best.g/best.count), uint8(best.b/best.count)), true
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GTINCheckDigit computes the GS1 mod-10 check digit for a GTIN body (the code
+// without its last digit). Weights alternate 3,1 starting from the rightmost digit,
+// so the same function serves EAN-8, UPC-A and EAN-13.
+func GTINCheckDigit(body string) (int, error) {
+	sum := 0
+	for i := 0; i < len(body); i++ {
+		ch := body[len(body)-1-i]
+		if ch < '0' || ch > '9' {
+			return 0, fmt.Errorf("barcode contains a non-digit character %q", ch)
+		}
+		d := int(ch - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10, nil
+}
+
+// IsValidGTIN reports whether code is an 8, 12 or 13 digit GTIN with a correct check digit
+func IsValidGTIN(code string) bool {
+	if len(code) != 8 && len(code) != 12 && len(code) != 13 {
+		return false
+	}
+	check, err := GTINCheckDigit(code[:len(code)-1])
+	return err == nil && int(code[len(code)-1]-'0') == check
+}
+
+// NormalizeBarcode validates a scanned or typed EAN-8, UPC-A or EAN-13 and returns
+// its canonical form: spaces and dashes are stripped and UPC-A is widened to EAN-13
+// with a leading zero. EAN-8 codes are kept as 8 digits.
+func NormalizeBarcode(input string) (string, error) {
+	code := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(input))
+
+	switch len(code) {
+	case 8, 13:
+	case 12:
+		code = "0" + code
+	default:
+		return "", fmt.Errorf("barcode must have 8 (EAN-8), 12 (UPC-A) or 13 (EAN-13) digits, got %d", len(code))
+	}
+
+	check, err := GTINCheckDigit(code[:len(code)-1])
+	if err != nil {
+		return "", err
+	}
+	if int(code[len(code)-1]-'0') != check {
+		return "", fmt.Errorf("invalid check digit for %s (expected %d)", code, check)
+	}
+	return code, nil
+}
+
+// BuildEAN13 assembles an EAN-13 from a numeric prefix and an item reference,
+// zero-padding the reference to fill 12 digits and appending the check digit
+func BuildEAN13(prefix string, reference int64) (string, error) {
+	refLen := 12 - len(prefix)
+	if refLen <= 0 {
+		return "", fmt.Errorf("prefix %q leaves no room for an item reference", prefix)
+	}
+	body := fmt.Sprintf("%s%0*d", prefix, refLen, reference)
+	if len(body) != 12 {
+		return "", fmt.Errorf("item reference %d does not fit in %d digits", reference, refLen)
+	}
+	check, err := GTINCheckDigit(body)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%d", body, check), nil
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// PointsPerMM converts millimetres to PDF points
+const PointsPerMM = 72.0 / 25.4
+
+// PDFDocument is a minimal PDF writer for printable sheets: filled rectangles and
+// single-line text in the standard Helvetica fonts. Coordinates are in points with
+// the origin at the top-left of the page.
+type PDFDocument struct {
+	pages []*pdfPage
+}
+
+type pdfPage struct {
+	width, height float64
+	content       bytes.Buffer
+}
+
+// NewPDFDocument creates an empty document
+func NewPDFDocument() *PDFDocument {
+	return &PDFDocument{}
+}
+
+// AddPage starts a new page of the given size; later drawing goes to it
+func (d *PDFDocument) AddPage(width, height float64) {
+	d.pages = append(d.pages, &pdfPage{width: width, height: height})
+}
+
+func (d *PDFDocument) current() *pdfPage {
+	if len(d.pages) == 0 {
+		d.AddPage(595.28, 841.89) // A4
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Rect draws a black filled rectangle whose top-left corner is at (x, y)
+func (d *PDFDocument) Rect(x, y, w, h float64) {
+	p := d.current()
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f re f\n", x, p.height-y-h, w, h)
+}
+
+// Text draws a line of text with its baseline at y. Only Latin-1 characters can be
+// shown with the built-in fonts; anything else is printed as '?'.
+func (d *PDFDocument) Text(x, y, size float64, bold bool, text string) {
+	p := d.current()
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, p.height-y, pdfString(text))
+}
+
+// TextWidth estimates the width of text in points. Digits are exact for Helvetica;
+// other characters use an average width, which is enough for centring and truncation.
+func TextWidth(text string, size float64) float64 {
+	width := 0.0
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			width += 0.556
+		case r == ' ' || r == '.' || r == ',':
+			width += 0.278
+		case r >= 'A' && r <= 'Z':
+			width += 0.667
+		default:
+			width += 0.52
+		}
+	}
+	return width * size
+}
+
+// FitText shortens text with an ellipsis until it fits maxWidth
+func FitText(text string, size, maxWidth float64) string {
+	if TextWidth(text, size) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && TextWidth(string(runes)+"...", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// Bytes serialises the document
+func (d *PDFDocument) Bytes() []byte {
+	d.current()
+
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	// Objects 1-4: catalog, page tree, fonts; each page then takes two objects
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+i*2)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			p.width, p.height, 6+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// pdfString encodes text as a PDF literal string body in Latin-1
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
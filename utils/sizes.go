@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Size scales a SKU size can belong to
+const (
+	SizeScaleLetter  = "letter"
+	SizeScaleEU      = "eu"
+	SizeScaleShoe    = "shoe"
+	SizeScaleKids    = "kids"
+	SizeScaleNumeric = "numeric"
+	SizeScaleOneSize = "one_size"
+	SizeScaleOther   = "other"
+)
+
+// CanonicalSize is a size label reduced to a code that compares equal across brands.
+// Rank orders sizes (letter sizes first, then EU clothing, shoes, kids ages...) and
+// Group is the letter size a size corresponds to, so "38" and "M" can be matched.
+type CanonicalSize struct {
+	Code  string `json:"code"`
+	Scale string `json:"scale"`
+	Rank  int    `json:"rank"`
+	Group string `json:"group,omitempty"`
+}
+
+// SizeScale describes a scale and its sizes in canonical order
+type SizeScale struct {
+	Scale string   `json:"scale"`
+	Sizes []string `json:"sizes"`
+}
+
+var letterSizes = []struct {
+	code    string
+	aliases []string
+}{
+	{"XXS", []string{"xxs", "2xs", "xxsmall", "extraextrasmall"}},
+	{"XS", []string{"xs", "xsmall", "extrasmall", "trespetit"}},
+	{"S", []string{"s", "small", "petit"}},
+	{"M", []string{"m", "medium", "med", "moyen"}},
+	{"L", []string{"l", "large", "grand"}},
+	{"XL", []string{"xl", "1xl", "xlarge", "extralarge", "tresgrand"}},
+	{"XXL", []string{"xxl", "2xl", "xxlarge"}},
+	{"3XL", []string{"3xl", "xxxl"}},
+	{"4XL", []string{"4xl", "xxxxl"}},
+	{"5XL", []string{"5xl", "xxxxxl"}},
+}
+
+// euLetterGroups maps EU/FR clothing sizes to the letter size they usually match
+var euLetterGroups = map[int]string{
+	32: "XXS", 34: "XS", 36: "S", 38: "M", 40: "L", 42: "XL", 44: "XXL", 46: "3XL", 48: "4XL", 50: "5XL",
+}
+
+var oneSizeAliases = map[string]bool{
+	"onesize": true, "os": true, "tu": true, "tailleunique": true, "unique": true, "freesize": true, "free": true, "standard": true,
+}
+
+var (
+	sizeLetterIndex = map[string]int{}
+
+	sizePrefixRe  = regexp.MustCompile(`^(taille|size|sz|t\.)\s*`)
+	sizeKidsRe    = regexp.MustCompile(`^(\d{1,2})(?:\s*(?:-|/|à|a)\s*(\d{1,2}))?\s*(m|mo|mois|months?|y|yrs?|years?|ans?|a)$`)
+	sizeNumericRe = regexp.MustCompile(`^(?:(eu|fr|it|uk|us)\s*)?(\d{1,2})(\.5|,5| 1/2|½)?\s*(eu|fr|it|uk|us)?$`)
+)
+
+func init() {
+	for i, s := range letterSizes {
+		for _, alias := range s.aliases {
+			sizeLetterIndex[alias] = i
+		}
+	}
+}
+
+// compactSize lowercases a label and drops spaces, dashes, dots and accents used
+// inconsistently in size labels ("X-Large", "Très grand", "One size")
+func compactSize(s string) string {
+	s = strings.NewReplacer("é", "e", "è", "e", "ê", "e", " ", "", "-", "", ".", "", "_", "").Replace(s)
+	return s
+}
+
+// NormalizeSize maps a size label to its canonical size. scaleHint ("shoe" or "kids",
+// usually derived from the product's categories) settles bare numbers, which are
+// read as EU clothing sizes otherwise. Labels that match no scale keep their text.
+func NormalizeSize(raw, scaleHint string) CanonicalSize {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	s = strings.Join(strings.Fields(s), " ")
+	s = sizePrefixRe.ReplaceAllString(s, "")
+	if s == "" {
+		return CanonicalSize{Code: "", Scale: SizeScaleOther, Rank: 9000}
+	}
+
+	compact := compactSize(s)
+	if oneSizeAliases[compact] {
+		return CanonicalSize{Code: "ONESIZE", Scale: SizeScaleOneSize, Rank: 8000}
+	}
+	if i, ok := sizeLetterIndex[compact]; ok {
+		code := letterSizes[i].code
+		return CanonicalSize{Code: code, Scale: SizeScaleLetter, Rank: 100 + i*10, Group: code}
+	}
+
+	if m := sizeKidsRe.FindStringSubmatch(s); m != nil {
+		return kidsSize(m[1], m[2], strings.HasPrefix(m[3], "m"))
+	}
+
+	if m := sizeNumericRe.FindStringSubmatch(s); m != nil {
+		system := m[1]
+		if system == "" {
+			system = m[4]
+		}
+		n, _ := strconv.Atoi(m[2])
+		half := m[3] != ""
+
+		if scaleHint == SizeScaleKids && system == "" && !half && n >= 1 && n <= 16 {
+			return kidsSize(m[2], "", false)
+		}
+		if scaleHint == SizeScaleShoe || half || (scaleHint == "" && system == "" && n%2 == 1 && n >= 35 && n <= 47) {
+			eu := float64(n)
+			if half {
+				eu += 0.5
+			}
+			// Approximate adult conversions; brands that differ get a size alias
+			switch system {
+			case "uk":
+				eu += 33
+			case "us":
+				eu += 32
+			}
+			return CanonicalSize{
+				Code:  "SHOE" + strconv.FormatFloat(eu, 'f', -1, 64),
+				Scale: SizeScaleShoe,
+				Rank:  2000 + int(eu*10),
+			}
+		}
+
+		eu := n
+		switch system {
+		case "it":
+			eu = n - 4
+		case "uk":
+			eu = n + 28
+		case "us":
+			eu = n + 32
+		}
+		if eu >= 30 && eu <= 64 {
+			return CanonicalSize{
+				Code:  "EU" + strconv.Itoa(eu),
+				Scale: SizeScaleEU,
+				Rank:  1000 + eu,
+				Group: euLetterGroups[eu],
+			}
+		}
+		return CanonicalSize{Code: strconv.Itoa(n), Scale: SizeScaleNumeric, Rank: 6000 + n}
+	}
+
+	return CanonicalSize{Code: strings.ToUpper(strings.TrimSpace(raw)), Scale: SizeScaleOther, Rank: 9000}
+}
+
+// sizeCodeRe matches the canonical codes NormalizeSize produces for shoe, EU and
+// age sizes
+var sizeCodeRe = regexp.MustCompile(`^(?:SHOE(\d{2}(?:\.5)?)|EU(\d{2})|AGE(\d{1,2})(?:-(\d{1,2}))?([MY]))$`)
+
+// ParseSizeCode reads a canonical code (M, EU40, SHOE42.5, AGE4Y, ONESIZE...) back
+// into its canonical size, without any scale hint. It reports false for anything
+// that is not a canonical code.
+func ParseSizeCode(code string) (CanonicalSize, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "ONESIZE" {
+		return CanonicalSize{Code: code, Scale: SizeScaleOneSize, Rank: 8000}, true
+	}
+	for i, s := range letterSizes {
+		if s.code == code {
+			return CanonicalSize{Code: code, Scale: SizeScaleLetter, Rank: 100 + i*10, Group: code}, true
+		}
+	}
+	m := sizeCodeRe.FindStringSubmatch(code)
+	switch {
+	case m == nil:
+		return CanonicalSize{}, false
+	case m[1] != "":
+		eu, _ := strconv.ParseFloat(m[1], 64)
+		return CanonicalSize{Code: code, Scale: SizeScaleShoe, Rank: 2000 + int(eu*10)}, true
+	case m[2] != "":
+		eu, _ := strconv.Atoi(m[2])
+		if eu < 30 || eu > 64 {
+			return CanonicalSize{}, false
+		}
+		return CanonicalSize{Code: code, Scale: SizeScaleEU, Rank: 1000 + eu, Group: euLetterGroups[eu]}, true
+	default:
+		return kidsSize(m[3], m[4], m[5] == "M"), true
+	}
+}
+
+// kidsSize builds an age size such as AGE6M, AGE12-18M or AGE4Y. Months are kept
+// for babies; the rank is the lower bound in months so 18M sorts before 2Y.
+func kidsSize(from, to string, months bool) CanonicalSize {
+	lower, _ := strconv.Atoi(from)
+	unit := "Y"
+	lowerMonths := lower * 12
+	if months {
+		unit = "M"
+		lowerMonths = lower
+	}
+	code := "AGE" + from
+	rank := 3000 + lowerMonths*2
+	if to != "" {
+		code += "-" + to
+		rank++
+	}
+	return CanonicalSize{Code: code + unit, Scale: SizeScaleKids, Rank: rank}
+}
+
+// SizeFilterMatch returns the canonical codes and letter groups a size filter value
+// can mean, trying every scale: "38" matches EU38 and SHOE38, and through its
+// group every size equivalent to M.
+func SizeFilterMatch(raw string) (codes, groups []string) {
+	seenCode := map[string]bool{}
+	seenGroup := map[string]bool{}
+	for _, hint := range []string{"", SizeScaleShoe, SizeScaleKids} {
+		size := NormalizeSize(raw, hint)
+		if size.Code != "" && !seenCode[size.Code] {
+			seenCode[size.Code] = true
+			codes = append(codes, size.Code)
+		}
+		if size.Group != "" && !seenGroup[size.Group] {
+			seenGroup[size.Group] = true
+			groups = append(groups, size.Group)
+		}
+	}
+	return codes, groups
+}
+
+// SizeScales lists the built-in scales with their sizes in canonical order
+func SizeScales() []SizeScale {
+	letters := make([]string, len(letterSizes))
+	for i, s := range letterSizes {
+		letters[i] = s.code
+	}
+	var eu []string
+	for n := 32; n <= 60; n += 2 {
+		eu = append(eu, fmt.Sprintf("EU%d", n))
+	}
+	var shoes []string
+	for n := 16; n <= 48; n++ {
+		shoes = append(shoes, fmt.Sprintf("SHOE%d", n))
+	}
+	kids := []string{"AGE0-3M", "AGE3-6M", "AGE6-12M", "AGE12-18M", "AGE18-24M"}
+	for n := 2; n <= 16; n++ {
+		kids = append(kids, fmt.Sprintf("AGE%dY", n))
+	}
+	scales := []SizeScale{
+		{Scale: SizeScaleLetter, Sizes: letters},
+		{Scale: SizeScaleEU, Sizes: eu},
+		{Scale: SizeScaleShoe, Sizes: shoes},
+		{Scale: SizeScaleKids, Sizes: kids},
+		{Scale: SizeScaleOneSize, Sizes: []string{"ONESIZE"}},
+	}
+	return scales
+}
+
+// SortSizes orders size labels canonically, keeping labels of unknown scales in
+// alphabetical order at the end
+func SortSizes(sizes []string, scaleHint string) {
+	sort.SliceStable(sizes, func(i, j int) bool {
+		a, b := NormalizeSize(sizes[i], scaleHint), NormalizeSize(sizes[j], scaleHint)
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		return sizes[i] < sizes[j]
+	})
+}